@@ -97,7 +97,7 @@ WHERE id = ?
 	if user.Email != nil {
 		if res := db.Exec(`
 UPDATE newsletters
-SET verified = TRUE
+SET verified = TRUE, confirmed_at = IFNULL(confirmed_at, NOW())
 WHERE email = ?
 	`, user.Email); res.Error != nil {
 			return nil, "", fmt.Errorf("Unable to allow sending newsletters to user")
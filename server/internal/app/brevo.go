@@ -2,7 +2,11 @@ package app
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
+	"net/http"
+	"slices"
+	"strings"
 
 	lib "github.com/getbrevo/brevo-go/lib"
 	"github.com/gin-gonic/gin"
@@ -20,8 +24,12 @@ func BrevoInit() {
 	Brevo = &brevo{lib.NewAPIClient(cfg)}
 }
 
+// Existing contacts are updated, so that they are added to the newsletter list
 func (b *brevo) CreateContact(ctx context.Context, email string) error {
-	var params = lib.CreateContact{Email: email}
+	var params = lib.CreateContact{Email: email, UpdateEnabled: true}
+	if Config.BREVO_NEWSLETTER_LIST != 0 {
+		params.ListIds = []int64{Config.BREVO_NEWSLETTER_LIST}
+	}
 
 	obj, resp, err := b.client.ContactsApi.CreateContact(ctx, params)
 	if err != nil {
@@ -32,6 +40,23 @@ func (b *brevo) CreateContact(ctx context.Context, email string) error {
 	return nil
 }
 
+// Allows a blocked contact to receive marketing emails again and adds it back to the newsletter list,
+// only to be used after the contact gave explicit consent again
+func (b *brevo) ResubscribeContact(ctx context.Context, email string) error {
+	params := lib.UpdateContact{EmailBlacklisted: false}
+	if Config.BREVO_NEWSLETTER_LIST != 0 {
+		params.ListIds = []int64{Config.BREVO_NEWSLETTER_LIST}
+	}
+
+	resp, err := b.client.ContactsApi.UpdateContact(ctx, email, params)
+	if err != nil {
+		fmt.Println("Error in ContactsApi->UpdateContact", err.Error())
+		return err
+	}
+	fmt.Println("UpdateContact Response: ", resp)
+	return nil
+}
+
 func (b *brevo) ExistsContact(ctx context.Context, email string) error {
 	obj, resp, err := b.client.ContactsApi.GetContactStats(ctx, email, nil)
 	if err != nil {
@@ -42,6 +67,36 @@ func (b *brevo) ExistsContact(ctx context.Context, email string) error {
 	return nil
 }
 
+type BrevoContactStatus struct {
+	Exists bool
+	// Blocked from receiving marketing emails, or unsubscribed from the newsletter list
+	Unsubscribed bool
+	InList       bool
+}
+
+func (b *brevo) GetContactStatus(ctx context.Context, email string) (BrevoContactStatus, error) {
+	status := BrevoContactStatus{}
+	contact, resp, err := b.client.ContactsApi.GetContactInfo(ctx, email, nil)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return status, nil
+		}
+		return status, err
+	}
+
+	status.Exists = true
+	status.Unsubscribed = contact.EmailBlacklisted
+	if Config.BREVO_NEWSLETTER_LIST == 0 {
+		status.InList = true
+	} else {
+		status.InList = slices.Contains(contact.ListIds, Config.BREVO_NEWSLETTER_LIST)
+		if slices.Contains(contact.ListUnsubscribed, Config.BREVO_NEWSLETTER_LIST) {
+			status.Unsubscribed = true
+		}
+	}
+	return status, nil
+}
+
 func (b *brevo) DeleteContact(ctx context.Context, email string) error {
 	resp, err := b.client.ContactsApi.DeleteContact(ctx, email)
 	if err != nil {
@@ -52,26 +107,35 @@ func (b *brevo) DeleteContact(ctx context.Context, email string) error {
 	return nil
 }
 
-type webhookUnsubscribeResponse struct {
-	Event        string `json:"event" binding:"required,eq=unsubscribe"` // "unsubscribe"
-	Email        string `json:"email" binding:"email,required"`          //	recipient email
-	ID           uint   `json:"id"`                                      //	internal id of webhook
-	DateSent     string `json:"date_sent"`                               //	date the campaign was sent (year-month-day, hour:minute:second)
-	DateEvent    string `json:"date_event"`                              //	date the event occurred (year-month-day, hour:minute:second)
-	TsSent       int64  `json:"ts_sent"`                                 //	timestamp in seconds of when campaign was sent
-	TsEvent      int64  `json:"ts_event"`                                //	timestamp in seconds of when event occurred
-	CampId       uint   `json:"camp_id"`                                 //	internal id of campaign
-	CampaignName string `json:"campaign name"`                           //	string	internal name of campaign
-	ListId       []uint `json:"list_id"`                                 // array of integers	the internal list id's the recipient has been unsubscribed from
-	Ts           int64  `json:"ts"`                                      //	timestamp in seconds of when event occurred
-	Tag          string `json:"tag"`                                     // internal tag of campaign
+const (
+	BrevoWebhookEventHardBounce            = "hard_bounce"
+	BrevoWebhookEventSpam                  = "spam"
+	BrevoWebhookEventUnsubscribed          = "unsubscribed" // transactional email
+	BrevoWebhookEventUnsubscribeNewsletter = "unsubscribe"  // marketing campaign
+)
+
+// Shared by the transactional and the marketing webhooks, unused fields are left empty
+type BrevoWebhookEvent struct {
+	Event     string `json:"event" binding:"required"`
+	Email     string `json:"email" binding:"required,email"`
+	ID        uint   `json:"id"`         // internal id of webhook
+	MessageID string `json:"message-id"` // transactional email only
+	Reason    string `json:"reason"`     // bounce reason
+	DateEvent string `json:"date_event"` // date the event occurred (year-month-day, hour:minute:second)
+	TsEvent   int64  `json:"ts_event"`   // timestamp in seconds of when event occurred
+	CampId    uint   `json:"camp_id"`    // internal id of campaign
+	ListId    []uint `json:"list_id"`    // the internal list id's the recipient has been unsubscribed from
+	Tag       string `json:"tag"`        // internal tag of campaign or email
 }
 
-func (sib *brevo) WebhookUnsubscribed(c *gin.Context) (email string, err error) {
-	var body webhookUnsubscribeResponse
-	if err = c.BindJSON(&body); err != nil {
-		return "", err
+// The webhook is configured in Brevo with bearer authentication using the brevo_webhook secret
+func BrevoWebhookVerify(c *gin.Context) bool {
+	if Config.BREVO_WEBHOOK == "" {
+		return false
 	}
-
-	return body.Email, nil
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(Config.BREVO_WEBHOOK)) == 1
 }
@@ -39,12 +39,18 @@ var Config struct {
 	SMTP_SENDER             string `yaml:"smtp_sender" env:"SMTP_SENDER"`
 	SMTP_USER               string `yaml:"smtp_user" env:"SMTP_USER"`
 	SMTP_PASS               string `yaml:"smtp_pass" env:"SMTP_PASS"`
+	MAIL_TRANSPORT          string `yaml:"mail_transport" env:"MAIL_TRANSPORT"`
+	MAIL_FILE_DIR           string `yaml:"mail_file_dir" env:"MAIL_FILE_DIR"`
 	GOSCOPE2_USER           string `yaml:"goscope2_user" env:"GOSCOPE2_USER"`
 	GOSCOPE2_PASS           string `yaml:"goscope2_pass" env:"GOSCOPE2_PASS"`
 	SENDINBLUE_API_KEY      string `yaml:"sendinblue_api_key" env:"SENDINBLUE_API_KEY"`
+	BREVO_WEBHOOK           string `yaml:"brevo_webhook" env:"BREVO_WEBHOOK"`
+	BREVO_NEWSLETTER_LIST   int64  `yaml:"brevo_newsletter_list" env:"BREVO_NEWSLETTER_LIST"`
 	IMGBB_KEY               string `yaml:"imgbb_key" env:"IMGBB_KEY"`
 	ONESIGNAL_APP_ID        string `yaml:"onesignal_app_id" env:"ONESIGNAL_APP_ID"`
 	ONESIGNAL_REST_API_KEY  string `yaml:"onesignal_rest_api_key" env:"ONESIGNAL_REST_API_KEY"`
+	VAPID_PRIVATE_KEY       string `yaml:"vapid_private_key" env:"VAPID_PRIVATE_KEY"`
+	VAPID_SUBJECT           string `yaml:"vapid_subject" env:"VAPID_SUBJECT"`
 	APPSTORE_REVIEWER_EMAIL string `yaml:"appstore_reviewer_email" env:"APPSTORE_REVIEWER_EMAIL"`
 	IMAGES_DIR              string `yaml:"images_dir" env:"IMAGES_DIR"`
 }
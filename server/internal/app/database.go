@@ -56,6 +56,10 @@ func DatabaseAutoMigrate(db *gorm.DB) {
 	hadIsApprovedColumn := db.Migrator().HasColumn(&sharedtypes.UserChain{}, "is_approved")
 	hadEventPriceTypeColumn := db.Migrator().HasColumn(&models.Event{}, "price_type")
 	hadAllowMapColumn := db.Migrator().HasColumn(&models.Chain{}, "allow_map")
+	hadChainFacetsTable := db.Migrator().HasTable(&sharedtypes.ChainFacet{})
+	hadMailSensitiveColumn := db.Migrator().HasColumn(&models.Mail{}, "sensitive")
+	hadUserPausedFromColumn := db.Migrator().HasColumn(&models.User{}, "paused_from")
+	hadEventRsvpOccurrenceDateColumn := db.Migrator().HasColumn(&sharedtypes.EventRsvp{}, "occurrence_date")
 
 	// User Tokens
 	if db.Migrator().HasTable("user_tokens") {
@@ -121,20 +125,54 @@ func DatabaseAutoMigrate(db *gorm.DB) {
 	db.AutoMigrate(
 		&models.Chain{},
 		&models.Newsletter{},
+		&models.LocationAlert{},
+		&models.LocationAlertNotification{},
 		&models.User{},
 		&models.Event{},
+		&sharedtypes.EventOccurrence{},
+		&sharedtypes.EventRsvp{},
 		&sharedtypes.UserToken{},
+		&sharedtypes.UserCalendarToken{},
+		&sharedtypes.NotificationPreference{},
+		&sharedtypes.ChainAnnouncement{},
+		&sharedtypes.ChainRestructure{},
+		&sharedtypes.ChainInvite{},
+		&sharedtypes.ChainInviteUse{},
+		&sharedtypes.ChainFacet{},
 		&sharedtypes.UserChain{},
 		&models.UserOnesignal{},
+		&sharedtypes.UserWebPushSubscription{},
 		&models.Bag{},
 		&models.BulkyItem{},
 		&models.Payment{},
 		&models.Mail{},
+		&models.MailAttempt{},
+		&sharedtypes.ContactTicket{},
+		&sharedtypes.ContactTicketReply{},
 		&models.DeletedUser{},
 		&sharedtypes.ChatChannel{},
+		&sharedtypes.ChatChannelRead{},
 		&sharedtypes.ChatMessage{},
+		&sharedtypes.ChatMessageReport{},
+		&sharedtypes.ChatModerationAction{},
 	)
 
+	// Mail retries moved to the outbox
+	if db.Migrator().HasTable("mail_retries") {
+		slog.Info("Migration run: move pending mail retries to outbox")
+		err := db.Exec(`
+INSERT INTO mail_outbox (sender_name, sender_address, to_name, to_address, subject, body, err, status, attempts, max_retry_attempts, created_at, updated_at)
+SELECT sender_name, sender_address, to_name, to_address, subject, body, err, ?, next_retry_attempt, max_retry_attempts, created_at, NOW()
+FROM mail_retries
+WHERE next_retry_attempt > 0
+		`, models.MailStatusQueued).Error
+		if err == nil {
+			db.Exec(`DROP TABLE mail_retries`)
+		} else {
+			slog.Error("Unable to move mail retries to outbox", "err", err)
+		}
+	}
+
 	if !db.Migrator().HasConstraint("user_chains", "uci_user_id_chain_id") {
 		db.Exec(`
 ALTER TABLE user_chains
@@ -173,6 +211,36 @@ UPDATE user_chains SET is_approved = FALSE WHERE id IN (
 		slog.Info("Migration run: set new allow_map column to true")
 		db.Exec("UPDATE chains SET allow_map = 1")
 	}
+	if !hadChainFacetsTable {
+		slog.Info("Migration run: fill chain facets from sizes and genders")
+		if err := models.ChainFacetsSyncAll(db); err != nil {
+			slog.Error("Unable to fill chain facets", "err", err)
+		}
+	}
+
+	if !hadMailSensitiveColumn {
+		slog.Info("Migration run: remove login tokens from sent emails")
+		db.Exec(`
+UPDATE mail_outbox SET sensitive = TRUE, body = IF(status = ?, body, '')
+WHERE body LIKE '%/users/login/validate?apiKey=%' OR body LIKE '%<code>%'
+		`, models.MailStatusQueued)
+	}
+
+	if !hadUserPausedFromColumn {
+		slog.Info("Migration run: clear pauses that ended before holiday mode without emailing")
+		db.Exec(`UPDATE users SET paused_until = NULL WHERE paused_until <= NOW()`)
+		db.Exec(`UPDATE user_chains SET is_paused = FALSE, paused_until = NULL WHERE is_paused = TRUE AND paused_until <= NOW()`)
+	}
+
+	if !hadEventRsvpOccurrenceDateColumn {
+		slog.Info("Migration run: respond to single occurrences of events")
+		if db.Migrator().HasIndex(&sharedtypes.EventRsvp{}, "uci_event_id_user_id") {
+			db.Migrator().DropIndex(&sharedtypes.EventRsvp{}, "uci_event_id_user_id")
+		}
+		if err := models.EventRsvpMigrateOccurrenceDates(db); err != nil {
+			slog.Error("Unable to set occurrence dates of event responses", "err", err)
+		}
+	}
 
 	if db.Migrator().HasColumn(&models.User{}, "chat_user") {
 		db.Migrator().DropColumn(&models.User{}, "chat_user")
@@ -1,38 +1,63 @@
 package app
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"net/mail"
 	"os"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	uuid "github.com/satori/go.uuid"
 	"github.com/the-clothing-loop/website/server/internal/models"
 	"gorm.io/gorm"
-
-	gomail "github.com/wneessen/go-mail"
 )
 
-var smtpClient *gomail.Client
+var mailTransport MailTransport
+
+// Called when a mail has failed its last attempt
+var MailOnLastRetry func(db *gorm.DB, m *models.Mail)
+
+var mailOutboxWorkerRunning atomic.Bool
+var mailOutboxWake = make(chan struct{}, 1)
 
 func MailInit() {
-	var err error
-	smtpClient, err = gomail.NewClient(Config.SMTP_HOST, gomail.WithPort(Config.SMTP_PORT))
-	if err != nil {
-		panic(err)
+	transport := Config.MAIL_TRANSPORT
+	if transport == "" {
+		if Config.SENDINBLUE_API_KEY != "" {
+			transport = MailTransportEnumBrevo
+		} else {
+			transport = MailTransportEnumSmtp
+		}
 	}
 
-	if Config.SMTP_PASS == "" {
-		smtpClient.SetTLSPolicy(gomail.NoTLS)
-	} else {
-		smtpClient.SetSMTPAuth(gomail.SMTPAuthPlain)
-		smtpClient.SetUsername(Config.SMTP_USER)
-		smtpClient.SetPassword(Config.SMTP_PASS)
+	switch transport {
+	case MailTransportEnumBrevo:
+		mailTransport = &mailTransportBrevo{apiKey: Config.SENDINBLUE_API_KEY}
+	case MailTransportEnumFile:
+		dir := Config.MAIL_FILE_DIR
+		if dir == "" {
+			dir = "./mails"
+		}
+		mailTransport = &mailTransportFile{dir: dir}
+	case MailTransportEnumSmtp:
+		t, err := newMailTransportSmtp()
+		if err != nil {
+			panic(err)
+		}
+		mailTransport = t
+	default:
+		panic(fmt.Errorf("unknown mail transport: %s", transport))
 	}
 }
 
+// Replaces the transport, for example with a recorder in tests
+func MailSetTransport(t MailTransport) {
+	mailTransport = t
+}
+
 func MailCreate() *models.Mail {
 	m := &models.Mail{
 		SenderName:    "The Clothing Loop",
@@ -41,92 +66,137 @@ func MailCreate() *models.Mail {
 	return m
 }
 
-func MailSend(db *gorm.DB, m *models.Mail) error {
-	// if Config.ENV == EnvEnumAcceptance && strings.HasSuffix(m.ToAddress, "@example.com") {
-	// 	return nil
-	// }
-
-	var err error
-	if Config.SENDINBLUE_API_KEY != "" {
-		err = mailSendByBrevoApi(m)
-	} else {
-		err = mailSendBySmtp(m)
+// A unique Message-ID header value on the domain of the sender address
+func MailNewMessageID() string {
+	domain := "clothingloop.org"
+	if _, d, ok := strings.Cut(Config.SMTP_SENDER, "@"); ok && d != "" {
+		domain = d
 	}
-	if err != nil {
-		slog.Error("Unable to send email", "err", err)
+	return fmt.Sprintf("<%s@%s>", uuid.NewV4().String(), domain)
+}
+
+// Headers of the mail besides the addresses and subject, the unsubscribe headers
+// let email clients offer one-click unsubscribe as described in rfc8058
+func mailHeaders(m *models.Mail) map[string]string {
+	headers := mailThreadHeaders(m)
+	if m.UnsubscribeURL != "" {
+		headers["List-Unsubscribe"] = "<" + m.UnsubscribeURL + ">"
+		headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+	}
+	return headers
+}
 
-		// Ensure that the email is not sent infinitely by the retry mechanism
-		if m.NextRetryAttempt == 0 && m.MaxRetryAttempts > models.MAIL_RETRY_NEVER {
-			m.NextRetryAttempt = 1
-			m.AddToQueue(db)
-			fmt.Printf("Adding email to resend queue: %s to: %s", m.Subject, m.ToAddress)
+// Stores the mail in the outbox, it is sent by the outbox worker.
+// Without a running worker the mail is sent right away.
+// Mails to addresses marked undeliverable are dropped without an error.
+func MailSend(db *gorm.DB, m *models.Mail) error {
+	if !m.AllowUndeliverable {
+		isUndeliverable, err := models.UserIsEmailUndeliverable(db, m.ToAddress)
+		if err != nil {
+			slog.Error("Unable to check if email is undeliverable", "err", err)
+		} else if isUndeliverable {
+			slog.Info("Skipped email to undeliverable address", "subject", m.Subject)
+			return nil
 		}
+	}
+
+	err := m.Enqueue(db)
+	if err != nil {
+		slog.Error("Unable to add email to outbox", "err", err)
 		return err
 	}
 
-	return nil
+	return mailOutboxDispatch(db, m)
 }
 
-func MailpitRemoveAllEmails() {
-	url := fmt.Sprintf("http://%s:8025/api/v1/messages", Config.SMTP_HOST)
-	req, _ := http.NewRequest(http.MethodDelete, url, nil)
-	_, err := http.DefaultClient.Do(req)
+// Queues a failed or bounced mail again, ignoring its previous retry schedule
+func MailResend(db *gorm.DB, id uint) error {
+	err := models.MailResend(db, id)
 	if err != nil {
-		slog.Error("Unable to remove emails mailpit", "err", err)
-		os.Exit(1)
-		return
+		return err
 	}
-}
-
-func mailSendByBrevoApi(m *models.Mail) error {
-	postBody, _ := json.Marshal(map[string]any{
-		"sender": map[string]any{
-			"name":  m.SenderName,
-			"email": m.SenderAddress,
-		},
-		"to": []map[string]any{{
-			"name":  m.ToName,
-			"email": m.ToAddress,
-		}},
-		"subject":     m.Subject,
-		"htmlContent": m.Body,
-	})
-	responseBody := bytes.NewBuffer(postBody)
-	req, err := http.NewRequest(http.MethodPost, "https://api.brevo.com/v3/smtp/email", responseBody)
+	m, err := models.MailGet(db, id)
 	if err != nil {
 		return err
 	}
 
-	req.Header = http.Header{
-		"accept":       {"application/json"},
-		"api-key":      {Config.SENDINBLUE_API_KEY},
-		"content-type": {"application/json"},
+	return mailOutboxDispatch(db, m)
+}
+
+func mailOutboxDispatch(db *gorm.DB, m *models.Mail) error {
+	if mailOutboxWorkerRunning.Load() {
+		select {
+		case mailOutboxWake <- struct{}{}:
+		default:
+		}
+		return nil
 	}
+	return MailOutboxSend(db, m)
+}
 
-	_, err = http.DefaultClient.Do(req)
+// Sends a mail from the outbox and records the attempt
+func MailOutboxSend(db *gorm.DB, m *models.Mail) error {
+	messageID, err := mailTransport.Send(m)
 	if err != nil {
+		slog.Error("Unable to send email", "err", err, "id", m.ID)
+		errr := m.MarkFailed(db, mailTransport.Name(), err)
+		if errors.Is(errr, models.ErrMailLastRetry) {
+			if MailOnLastRetry != nil && m.ToAddress != Config.SMTP_SENDER {
+				MailOnLastRetry(db, m)
+			}
+		} else if errr != nil {
+			slog.Error("Unable to record failed email attempt", "err", errr, "id", m.ID)
+		}
 		return err
 	}
 
+	err = m.MarkSent(db, mailTransport.Name(), messageID)
+	if err != nil {
+		slog.Error("Unable to record sent email", "err", err, "id", m.ID)
+	}
 	return nil
 }
 
-func mailSendBySmtp(m *models.Mail) error {
-	gm := gomail.NewMsg()
-
-	from := mail.Address{
-		Name:    m.SenderName,
-		Address: m.SenderAddress,
-	}
-	to := mail.Address{
-		Name:    m.ToName,
-		Address: m.ToAddress,
+func MailOutboxProcess(db *gorm.DB) {
+	for {
+		mails, err := models.MailClaimDue(db, 50)
+		if err != nil {
+			slog.Error("Unable to claim emails from outbox", "err", err)
+			return
+		}
+		if len(mails) == 0 {
+			return
+		}
+		for _, m := range mails {
+			MailOutboxSend(db, m)
+		}
 	}
+}
 
-	gm.From(from.String())
-	gm.AddTo(to.String())
-	gm.Subject(m.Subject)
-	gm.SetBodyString(gomail.TypeTextHTML, m.Body)
+// Sends queued mails when woken up by MailSend and checks for due retries every minute.
+// Mails left in the outbox by a previous process are sent on start.
+func MailOutboxWorkerRun(db *gorm.DB) {
+	mailOutboxWorkerRunning.Store(true)
+	defer mailOutboxWorkerRunning.Store(false)
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		MailOutboxProcess(db)
+		select {
+		case <-mailOutboxWake:
+		case <-ticker.C:
+		}
+	}
+}
 
-	return smtpClient.DialAndSend(gm)
+func MailpitRemoveAllEmails() {
+	url := fmt.Sprintf("http://%s:8025/api/v1/messages", Config.SMTP_HOST)
+	req, _ := http.NewRequest(http.MethodDelete, url, nil)
+	_, err := http.DefaultClient.Do(req)
+	if err != nil {
+		slog.Error("Unable to remove emails mailpit", "err", err)
+		os.Exit(1)
+		return
+	}
 }
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/the-clothing-loop/website/server/internal/models"
+	gomail "github.com/wneessen/go-mail"
+)
+
+const (
+	MailTransportEnumSmtp  = "smtp"
+	MailTransportEnumBrevo = "brevo"
+	MailTransportEnumFile  = "file"
+)
+
+type MailTransport interface {
+	Name() string
+	// Returns the message id given by the provider, if any
+	Send(m *models.Mail) (string, error)
+}
+
+type mailTransportSmtp struct {
+	client *gomail.Client
+}
+
+func newMailTransportSmtp() (*mailTransportSmtp, error) {
+	client, err := gomail.NewClient(Config.SMTP_HOST, gomail.WithPort(Config.SMTP_PORT))
+	if err != nil {
+		return nil, err
+	}
+
+	if Config.SMTP_PASS == "" {
+		client.SetTLSPolicy(gomail.NoTLS)
+	} else {
+		client.SetSMTPAuth(gomail.SMTPAuthPlain)
+		client.SetUsername(Config.SMTP_USER)
+		client.SetPassword(Config.SMTP_PASS)
+	}
+	return &mailTransportSmtp{client}, nil
+}
+
+func (t *mailTransportSmtp) Name() string { return MailTransportEnumSmtp }
+
+func (t *mailTransportSmtp) Send(m *models.Mail) (string, error) {
+	gm := gomail.NewMsg()
+
+	from := mail.Address{
+		Name:    m.SenderName,
+		Address: m.SenderAddress,
+	}
+	to := mail.Address{
+		Name:    m.ToName,
+		Address: m.ToAddress,
+	}
+
+	gm.From(from.String())
+	gm.AddTo(to.String())
+	gm.Subject(m.Subject)
+	gm.SetBodyString(gomail.TypeTextHTML, m.Body)
+	for k, v := range mailHeaders(m) {
+		gm.SetGenHeaderPreformatted(gomail.Header(k), v)
+	}
+
+	return "", t.client.DialAndSend(gm)
+}
+
+type mailTransportBrevo struct {
+	apiKey string
+}
+
+func (t *mailTransportBrevo) Name() string { return MailTransportEnumBrevo }
+
+func (t *mailTransportBrevo) Send(m *models.Mail) (string, error) {
+	body := map[string]any{
+		"sender": map[string]any{
+			"name":  m.SenderName,
+			"email": m.SenderAddress,
+		},
+		"to": []map[string]any{{
+			"name":  m.ToName,
+			"email": m.ToAddress,
+		}},
+		"subject":     m.Subject,
+		"htmlContent": m.Body,
+	}
+	if headers := mailHeaders(m); len(headers) > 0 {
+		body["headers"] = headers
+	}
+	postBody, _ := json.Marshal(body)
+	req, err := http.NewRequest(http.MethodPost, "https://api.brevo.com/v3/smtp/email", bytes.NewBuffer(postBody))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header = http.Header{
+		"accept":       {"application/json"},
+		"api-key":      {t.apiKey},
+		"content-type": {"application/json"},
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	resBody, _ := io.ReadAll(res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", fmt.Errorf("Brevo responded with status %d: %s", res.StatusCode, string(resBody))
+	}
+
+	var resJSON struct {
+		MessageID string `json:"messageId"`
+	}
+	json.Unmarshal(resBody, &resJSON)
+	return resJSON.MessageID, nil
+}
+
+// Writes each mail as an .eml file, useful when no mail server is available
+type mailTransportFile struct {
+	dir string
+}
+
+func (t *mailTransportFile) Name() string { return MailTransportEnumFile }
+
+func (t *mailTransportFile) Send(m *models.Mail) (string, error) {
+	err := os.MkdirAll(t.dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	from := mail.Address{Name: m.SenderName, Address: m.SenderAddress}
+	to := mail.Address{Name: m.ToName, Address: m.ToAddress}
+	headers := ""
+	mailHeaders := mailHeaders(m)
+	for _, k := range []string{"Message-ID", "In-Reply-To", "References", "List-Unsubscribe", "List-Unsubscribe-Post"} {
+		if v, ok := mailHeaders[k]; ok {
+			headers += fmt.Sprintf("%s: %s\r\n", k, v)
+		}
+	}
+	content := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n%sContent-Type: text/html; charset=UTF-8\r\n\r\n%s",
+		from.String(), to.String(), mime.QEncoding.Encode("utf-8", m.Subject), time.Now().Format(time.RFC1123Z), headers, m.Body)
+
+	name := fmt.Sprintf("%d-%d.eml", time.Now().UnixMilli(), m.ID)
+	return name, os.WriteFile(filepath.Join(t.dir, name), []byte(content), 0644)
+}
+
+func mailThreadHeaders(m *models.Mail) map[string]string {
+	headers := map[string]string{}
+	if m.MessageID.Valid {
+		headers["Message-ID"] = m.MessageID.String
+	}
+	if m.InReplyTo.Valid {
+		headers["In-Reply-To"] = m.InReplyTo.String
+	}
+	if m.References.Valid {
+		headers["References"] = m.References.String
+	}
+	return headers
+}
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/models"
+)
+
+func TestMailTransportFileUnsubscribeHeaders(t *testing.T) {
+	transport := &mailTransportFile{dir: t.TempDir()}
+
+	m := &models.Mail{
+		SenderAddress:  "hello@example.com",
+		ToAddress:      "member@example.com",
+		Subject:        "Poke",
+		Body:           "<p>Hi</p>",
+		UnsubscribeURL: "https://api.example.com/v2/notification/unsubscribe?e=abc&s=def&t=poke",
+	}
+	name, err := transport.Send(m)
+	AssertNotErrorNow(t, err)
+	content, err := os.ReadFile(filepath.Join(transport.dir, name))
+	AssertNotErrorNow(t, err)
+	assert.Contains(t, string(content), "List-Unsubscribe: <https://api.example.com/v2/notification/unsubscribe?e=abc&s=def&t=poke>\r\n")
+	assert.Contains(t, string(content), "List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+
+	m.UnsubscribeURL = ""
+	name, err = transport.Send(m)
+	AssertNotErrorNow(t, err)
+	content, _ = os.ReadFile(filepath.Join(transport.dir, name))
+	assert.NotContains(t, string(content), "List-Unsubscribe")
+}
@@ -7,12 +7,14 @@ import (
 	"io"
 	"log/slog"
 	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/OneSignal/onesignal-go-api"
 	"github.com/cdfmlr/ellipsis"
 	"github.com/samber/lo"
 	uuid "github.com/satori/go.uuid"
+	"github.com/the-clothing-loop/website/server/internal/models"
 	"gorm.io/gorm"
 )
 
@@ -40,15 +42,13 @@ func (e OneSignalErrorResponse) GetInvalidExternalUserIds() []string {
 	return []string{}
 }
 
-var OneSignalClient *onesignal.APIClient
-
-func OneSignalInit() {
-	if Config.ONESIGNAL_REST_API_KEY == "" {
-		return
-	}
+type pushProviderOneSignal struct {
+	client *onesignal.APIClient
+}
 
+func newPushProviderOneSignal() *pushProviderOneSignal {
 	configuration := onesignal.NewConfiguration()
-	OneSignalClient = onesignal.NewAPIClient(configuration)
+	return &pushProviderOneSignal{onesignal.NewAPIClient(configuration)}
 }
 
 func oneSignalGetAuth() context.Context {
@@ -57,27 +57,75 @@ func oneSignalGetAuth() context.Context {
 
 const notificationUserLimit = 15
 
+func (p *pushProviderOneSignal) Name() string { return PushProviderEnumOneSignal }
+
 // Chunk notifications
-func OneSignalCreateNotification(db *gorm.DB, userUIDs []string, notificationTitle, notificationContent onesignal.StringMap) error {
-	if OneSignalClient == nil {
-		slog.Info("Send onesignal notification", "userids", userUIDs, "title", *notificationTitle.En)
-		return nil
-	}
-	lenUserUIDs := len(userUIDs)
-	if lenUserUIDs == 0 {
-		return fmt.Errorf("No users to send a notification to")
-	}
-	userUIDchunks := lo.Chunk(userUIDs, notificationUserLimit)
+func (p *pushProviderOneSignal) Send(db *gorm.DB, userUIDs []string, msg PushMessage) error {
+	title := oneSignalStringMap(msg.Lang, msg.Title, msg.TitleEn)
+	content := oneSignalStringMap(msg.Lang, msg.Body, msg.BodyEn)
 
-	for _, userUIDs := range userUIDchunks {
-		err := oneSignalCreateNotificationSend(userUIDs, notificationTitle, notificationContent)
+	for _, userUIDs := range lo.Chunk(userUIDs, notificationUserLimit) {
+		invalidUserUIDs, err := p.send(userUIDs, title, content)
+		if len(invalidUserUIDs) > 0 {
+			err := models.UserOnesignalDeleteByUserUIDs(db, invalidUserUIDs)
+			if err != nil {
+				slog.Error("Unable to remove invalid onesignal connections", "err", err)
+			}
+		}
 		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
-func oneSignalCreateNotificationSend(userUIDs []string, notificationTitle, notificationContent onesignal.StringMap) error {
+
+// The OneSignal language of every app locale, Norwegian is shown as Bokmål and Chinese as simplified Chinese
+var oneSignalLangs = map[string]func(m *onesignal.StringMap, v string){
+	"ar": (*onesignal.StringMap).SetAr,
+	"ca": (*onesignal.StringMap).SetCa,
+	"da": (*onesignal.StringMap).SetDa,
+	"de": (*onesignal.StringMap).SetDe,
+	"en": (*onesignal.StringMap).SetEn,
+	"es": (*onesignal.StringMap).SetEs,
+	"fr": (*onesignal.StringMap).SetFr,
+	"he": (*onesignal.StringMap).SetHe,
+	"it": (*onesignal.StringMap).SetIt,
+	"ja": (*onesignal.StringMap).SetJa,
+	"ko": (*onesignal.StringMap).SetKo,
+	"nl": (*onesignal.StringMap).SetNl,
+	"no": (*onesignal.StringMap).SetNb,
+	"pl": (*onesignal.StringMap).SetPl,
+	"pt": (*onesignal.StringMap).SetPt,
+	"sv": (*onesignal.StringMap).SetSv,
+	"tr": (*onesignal.StringMap).SetTr,
+	"zh": (*onesignal.StringMap).SetZhHans,
+}
+
+// Every app locale that notifications are translated in
+var NotificationLangs = func() []string {
+	langs := lo.Keys(oneSignalLangs)
+	slices.Sort(langs)
+	return langs
+}()
+
+// OneSignal shows the text in the language of the device and requires English for all others
+func oneSignalStringMap(lang, text, textEn string) onesignal.StringMap {
+	m := onesignal.StringMap{}
+	if text == "" {
+		return m
+	}
+	if textEn == "" {
+		textEn = text
+	}
+	m.SetEn(textEn)
+	if set, ok := oneSignalLangs[lang]; ok && lang != "en" {
+		set(&m, text)
+	}
+	return m
+}
+
+// Returns the external user ids that OneSignal no longer has a subscription for
+func (p *pushProviderOneSignal) send(userUIDs []string, notificationTitle, notificationContent onesignal.StringMap) ([]string, error) {
 	notification := onesignal.NewNotification(Config.ONESIGNAL_APP_ID)
 	notification.SetId(uuid.NewV4().String())
 	notification.SetIncludeExternalUserIds(userUIDs)
@@ -91,24 +139,28 @@ func oneSignalCreateNotificationSend(userUIDs []string, notificationTitle, notif
 	})
 
 	auth := oneSignalGetAuth()
-	_, resp, err := OneSignalClient.DefaultApi.CreateNotification(auth).Notification(*notification).Execute()
+	res, resp, err := p.client.DefaultApi.CreateNotification(auth).Notification(*notification).Execute()
 	if err != nil {
+		if resp == nil {
+			return nil, err
+		}
 		defer resp.Body.Close()
-		body, err := io.ReadAll(resp.Body)
-		fmt.Printf("response error: %s\n", string(body))
-		slog.Error("Unable to send notification", "err", err)
-		return err
+		body, _ := io.ReadAll(resp.Body)
+		errRes := OneSignalErrorResponse{}
+		json.Unmarshal(body, &errRes)
+		return errRes.GetInvalidExternalUserIds(), fmt.Errorf("OneSignal responded with status %d: %s", resp.StatusCode, string(body))
 	}
-	return nil
+
+	if res.Errors != nil && res.Errors.InvalidIdentifierError != nil {
+		return res.Errors.InvalidIdentifierError.InvalidExternalUserIds, nil
+	}
+	return nil, nil
 }
 
-func OneSignalEllipsisContent(content string) onesignal.StringMap {
+func OneSignalEllipsis(content string) string {
 	if content == "" {
-		return onesignal.StringMap{}
+		return ""
 	}
 
-	content = ellipsis.Ending(content, 15)
-	return onesignal.StringMap{
-		En: onesignal.PtrString(content),
-	}
+	return ellipsis.Ending(content, 15)
 }
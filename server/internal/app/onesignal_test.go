@@ -1,10 +1,12 @@
 package app
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"testing"
 
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -33,3 +35,33 @@ func TestOneSignalCreateNotificationCheckByteSize(t *testing.T) {
 	str := strings.Join(arr, ",")
 	assert.Greater(t, len([]byte(str)), 1800)
 }
+
+func TestOneSignalStringMap(t *testing.T) {
+	m := oneSignalStringMap("nl", "Tas te lang", "Bag held too long")
+	assert.Equal(t, "Bag held too long", m.GetEn())
+	assert.Equal(t, "Tas te lang", m.GetNl())
+	assert.False(t, m.HasDe())
+
+	m = oneSignalStringMap("en", "Bag held too long", "")
+	assert.Equal(t, "Bag held too long", m.GetEn())
+	assert.False(t, m.HasNl())
+
+	m = oneSignalStringMap("he", "", "")
+	assert.False(t, m.HasEn())
+	assert.False(t, m.HasHe())
+}
+
+func TestOneSignalStringMapAllLangs(t *testing.T) {
+	for _, lng := range NotificationLangs {
+		if lng == "en" {
+			continue
+		}
+		m := oneSignalStringMap(lng, "Translated", "English")
+		b, err := json.Marshal(m)
+		assert.NoError(t, err)
+		entries := map[string]string{}
+		json.Unmarshal(b, &entries)
+		assert.Len(t, entries, 2, lng)
+		assert.Contains(t, lo.Values(entries), "Translated", lng)
+	}
+}
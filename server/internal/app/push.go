@@ -0,0 +1,123 @@
+package app
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PushProviderEnumOneSignal = "onesignal"
+	PushProviderEnumWebPush   = "webpush"
+	PushProviderEnumRecorder  = "recorder"
+)
+
+type PushMessage struct {
+	// The key of the notification, see views.NotificationEnum...
+	Notification string `json:"notification"`
+	Title        string `json:"title"`
+	Body         string `json:"body,omitempty"`
+	// Language of the title and body, with the English text for providers that need a fallback
+	Lang    string `json:"-"`
+	TitleEn string `json:"-"`
+	BodyEn  string `json:"-"`
+}
+
+type PushProvider interface {
+	Name() string
+	Send(db *gorm.DB, userUIDs []string, msg PushMessage) error
+}
+
+var pushProviders = []PushProvider{}
+
+// Every configured provider receives each notification,
+// without any providers notifications are only logged
+func PushInit() {
+	pushProviders = []PushProvider{}
+	if Config.ONESIGNAL_APP_ID != "" && Config.ONESIGNAL_REST_API_KEY != "" {
+		pushProviders = append(pushProviders, newPushProviderOneSignal())
+	}
+	if Config.VAPID_PRIVATE_KEY != "" {
+		p, err := newPushProviderWebPush(Config.VAPID_PRIVATE_KEY, Config.VAPID_SUBJECT)
+		if err != nil {
+			panic(err)
+		}
+		pushProviders = append(pushProviders, p)
+	}
+}
+
+// Replaces the providers, for example with a recorder in tests
+func PushSetProviders(providers ...PushProvider) {
+	pushProviders = providers
+}
+
+func PushSend(db *gorm.DB, userUIDs []string, msg PushMessage) error {
+	if len(userUIDs) == 0 {
+		return nil
+	}
+	if len(pushProviders) == 0 {
+		slog.Info("Send push notification", "userids", userUIDs, "title", msg.Title)
+		return nil
+	}
+
+	errs := []error{}
+	for _, p := range pushProviders {
+		err := p.Send(db, userUIDs, msg)
+		if err != nil {
+			slog.Error("Unable to send push notification", "err", err, "provider", p.Name())
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type PushRecord struct {
+	UserUIDs []string
+	Message  PushMessage
+}
+
+// Keeps every notification in memory so tests can assert who received what
+type PushRecorder struct {
+	mu      sync.Mutex
+	records []PushRecord
+}
+
+func (r *PushRecorder) Name() string { return PushProviderEnumRecorder }
+
+func (r *PushRecorder) Send(db *gorm.DB, userUIDs []string, msg PushMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, PushRecord{
+		UserUIDs: slices.Clone(userUIDs),
+		Message:  msg,
+	})
+	return nil
+}
+
+func (r *PushRecorder) Records() []PushRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.records)
+}
+
+// Returns the notifications sent to this user in the order they were sent
+func (r *PushRecorder) FindByUserUID(userUID string) []PushMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := []PushMessage{}
+	for _, record := range r.records {
+		if slices.Contains(record.UserUIDs, userUID) {
+			list = append(list, record.Message)
+		}
+	}
+	return list
+}
+
+func (r *PushRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = nil
+}
@@ -12,12 +12,16 @@ import (
 
 var Faker = faker.New()
 
+// Receives every push notification sent during tests
+var PushTestRecorder = &PushRecorder{}
+
 func RunTestMain(m *testing.M, dbP **gorm.DB, configPath string) {
 	// setup
 	ConfigTestInit(configPath)
 	MailpitRemoveAllEmails()
 	*dbP = DatabaseInit()
 	MailInit()
+	PushSetProviders(PushTestRecorder)
 
 	code := m.Run()
 	os.Exit(code)
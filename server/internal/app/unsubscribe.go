@@ -0,0 +1,48 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+)
+
+// Signs the unsubscribe link of an email, so that it works without logging in
+func UnsubscribeSignature(email, notificationType string) string {
+	mac := hmac.New(sha256.New, []byte(Config.JWT_SECRET))
+	mac.Write([]byte("unsubscribe:" + email + ":" + notificationType))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func UnsubscribeVerify(email, notificationType, signature string) bool {
+	expected := UnsubscribeSignature(email, notificationType)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// Used instead of a notification type to sign newsletter and location alert unsubscribe links
+const (
+	UnsubscribeTypeNewsletter    = "newsletter"
+	UnsubscribeTypeLocationAlert = "location_alert"
+)
+
+func UnsubscribeURL(email, notificationType string) string {
+	q := url.Values{}
+	q.Set("e", base64.RawURLEncoding.EncodeToString([]byte(email)))
+	q.Set("t", notificationType)
+	q.Set("s", UnsubscribeSignature(email, notificationType))
+	return Config.SITE_BASE_URL_API + "/v2/notification/unsubscribe?" + q.Encode()
+}
+
+func NewsletterUnsubscribeURL(email string) string {
+	q := url.Values{}
+	q.Set("e", base64.RawURLEncoding.EncodeToString([]byte(email)))
+	q.Set("s", UnsubscribeSignature(email, UnsubscribeTypeNewsletter))
+	return Config.SITE_BASE_URL_API + "/v2/contact/newsletter/unsubscribe?" + q.Encode()
+}
+
+func LocationAlertUnsubscribeURL(email string) string {
+	q := url.Values{}
+	q.Set("e", base64.RawURLEncoding.EncodeToString([]byte(email)))
+	q.Set("s", UnsubscribeSignature(email, UnsubscribeTypeLocationAlert))
+	return Config.SITE_BASE_URL_API + "/v2/location-alert/unsubscribe?" + q.Encode()
+}
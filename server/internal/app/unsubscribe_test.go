@@ -0,0 +1,43 @@
+package app_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+)
+
+func TestUnsubscribeVerify(t *testing.T) {
+	app.Config.JWT_SECRET = "secret"
+	email := "member@example.com"
+
+	signature := app.UnsubscribeSignature(email, "poke")
+	assert.True(t, app.UnsubscribeVerify(email, "poke", signature))
+	assert.False(t, app.UnsubscribeVerify(email, "host_updates", signature))
+	assert.False(t, app.UnsubscribeVerify("other@example.com", "poke", signature))
+	assert.False(t, app.UnsubscribeVerify(email, "poke", ""))
+}
+
+func TestUnsubscribeURL(t *testing.T) {
+	app.Config.JWT_SECRET = "secret"
+	app.Config.SITE_BASE_URL_API = "https://api.example.com"
+
+	u, err := url.Parse(app.UnsubscribeURL("member+loop@example.com", "poke"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(u.String(), "https://api.example.com/v2/notification/unsubscribe?"))
+	assert.Equal(t, "poke", u.Query().Get("t"))
+	assert.Equal(t, app.UnsubscribeSignature("member+loop@example.com", "poke"), u.Query().Get("s"))
+}
+
+func TestNewsletterUnsubscribeURL(t *testing.T) {
+	app.Config.JWT_SECRET = "secret"
+	app.Config.SITE_BASE_URL_API = "https://api.example.com"
+
+	u, err := url.Parse(app.NewsletterUnsubscribeURL("member@example.com"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(u.String(), "https://api.example.com/v2/contact/newsletter/unsubscribe?"))
+	assert.True(t, app.UnsubscribeVerify("member@example.com", app.UnsubscribeTypeNewsletter, u.Query().Get("s")))
+	assert.False(t, app.UnsubscribeVerify("member@example.com", "poke", u.Query().Get("s")))
+}
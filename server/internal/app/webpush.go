@@ -0,0 +1,306 @@
+package app
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// Largest payload size every push service must accept, minus the encryption overhead
+const webPushMaxPayload = 4096 - 16 - 4 - 1 - 65 - 16 - 1
+
+var ErrWebPushEndpointInvalid = errors.New("Endpoint is not a supported push service")
+
+// Hosts of the push services of the browsers, a leading dot also allows every subdomain.
+// Endpoints are given by the client, so only these are ever requested by the server.
+var webPushAllowedHosts = []string{
+	"fcm.googleapis.com",                // Chrome, Edge and other Chromium browsers
+	"updates.push.services.mozilla.com", // Firefox
+	"web.push.apple.com",                // Safari
+	".notify.windows.com",               // Windows Push Notification Services
+}
+
+// Checks that the endpoint is an https url of a known push service
+func WebPushValidateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ErrWebPushEndpointInvalid
+	}
+	if u.Scheme != "https" || u.User != nil || (u.Port() != "" && u.Port() != "443") {
+		return ErrWebPushEndpointInvalid
+	}
+	host := strings.ToLower(u.Hostname())
+	if net.ParseIP(host) != nil {
+		return ErrWebPushEndpointInvalid
+	}
+	for _, allowed := range webPushAllowedHosts {
+		if host == allowed || (strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed)) {
+			return nil
+		}
+	}
+	return ErrWebPushEndpointInvalid
+}
+
+// Refuses redirects and connections to addresses that are not public,
+// in case the dns of an allowed host points somewhere internal
+func newWebPushClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			ip = ip.Unmap()
+			if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() {
+				return fmt.Errorf("push service resolved to a non public address: %s", ip)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+type pushProviderWebPush struct {
+	privateKey *ecdsa.PrivateKey
+	// Uncompressed P-256 point, base64 url encoded
+	publicKey string
+	subject   string
+	client    *http.Client
+}
+
+// The private key is the base64 url encoded 32 byte P-256 scalar, as generated by most web push libraries
+func newPushProviderWebPush(privateKey, subject string) (*pushProviderWebPush, error) {
+	d, err := base64.RawURLEncoding.DecodeString(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vapid private key: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vapid private key: %w", err)
+	}
+	pub := key.PublicKey().Bytes()
+
+	return &pushProviderWebPush{
+		privateKey: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(pub[1:33]),
+				Y:     new(big.Int).SetBytes(pub[33:]),
+			},
+			D: new(big.Int).SetBytes(d),
+		},
+		publicKey: base64.RawURLEncoding.EncodeToString(pub),
+		subject:   subject,
+		client:    newWebPushClient(),
+	}, nil
+}
+
+// Used by browsers to subscribe, empty if web push is not configured
+func WebPushPublicKey() string {
+	for _, p := range pushProviders {
+		if wp, ok := p.(*pushProviderWebPush); ok {
+			return wp.publicKey
+		}
+	}
+	return ""
+}
+
+func (p *pushProviderWebPush) Name() string { return PushProviderEnumWebPush }
+
+func (p *pushProviderWebPush) Send(db *gorm.DB, userUIDs []string, msg PushMessage) error {
+	subscriptions, err := models.UserWebPushSubscriptionGetAllByUserUIDs(db, userUIDs)
+	if err != nil {
+		return err
+	}
+	payload, _ := json.Marshal(msg)
+	if len(payload) > webPushMaxPayload {
+		return fmt.Errorf("web push payload is too large: %d bytes", len(payload))
+	}
+
+	var lastErr error
+	for _, s := range subscriptions {
+		var gone bool
+		if err = WebPushValidateEndpoint(s.Endpoint); err != nil {
+			// stored before endpoints were validated
+			gone = true
+		} else {
+			gone, err = p.send(s.Endpoint, s.P256dh, s.Auth, payload)
+		}
+		if gone {
+			// the browser unsubscribed or the subscription expired
+			err := models.UserWebPushSubscriptionDelete(db, s.Endpoint)
+			if err != nil {
+				slog.Error("Unable to remove expired web push subscription", "err", err)
+			}
+			continue
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// Returns true if the push service no longer knows the subscription
+func (p *pushProviderWebPush) send(endpoint, p256dh, auth string, payload []byte) (bool, error) {
+	body, err := webPushEncrypt(p256dh, auth, payload)
+	if err != nil {
+		return false, err
+	}
+	authorization, err := p.vapidAuthorization(endpoint)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header = http.Header{
+		"Authorization":    {authorization},
+		"Content-Encoding": {"aes128gcm"},
+		"Content-Type":     {"application/octet-stream"},
+		"TTL":              {"86400"},
+		"Urgency":          {"normal"},
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone {
+		return true, nil
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		resBody, _ := io.ReadAll(res.Body)
+		return false, fmt.Errorf("Push service responded with status %d: %s", res.StatusCode, string(resBody))
+	}
+	return false, nil
+}
+
+// https://datatracker.ietf.org/doc/html/rfc8292
+func (p *pushProviderWebPush) vapidAuthorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": fmt.Sprintf("%s://%s", u.Scheme, u.Host),
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": p.subject,
+	})
+	signed, err := token.SignedString(p.privateKey)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("vapid t=%s, k=%s", signed, p.publicKey), nil
+}
+
+// Encrypts the payload for a single record using aes128gcm
+//
+// https://datatracker.ietf.org/doc/html/rfc8291
+func webPushEncrypt(p256dh, auth string, payload []byte) ([]byte, error) {
+	uaPublicBytes, err := base64.RawURLEncoding.DecodeString(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription key: %w", err)
+	}
+	authSecret, err := base64.RawURLEncoding.DecodeString(auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription auth: %w", err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription key: %w", err)
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublicBytes := asPrivate.PublicKey().Bytes()
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublicBytes...)
+	keyInfo = append(keyInfo, asPublicBytes...)
+	ikm, err := hkdf.Key(sha256.New, sharedSecret, authSecret, string(keyInfo), 32)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// 0x02 marks the last and only record
+	plaintext := append(bytes.Clone(payload), 0x02)
+
+	header := make([]byte, 0, 16+4+1+len(asPublicBytes))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, 4096)
+	header = append(header, byte(len(asPublicBytes)))
+	header = append(header, asPublicBytes...)
+
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
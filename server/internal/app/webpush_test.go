@@ -0,0 +1,174 @@
+package app
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// Decrypts like a browser would, following rfc8291
+func webPushTestDecrypt(t *testing.T, uaPrivate *ecdh.PrivateKey, authSecret, body []byte) []byte {
+	salt := body[:16]
+	rs := binary.BigEndian.Uint32(body[16:20])
+	idLen := int(body[20])
+	asPublicBytes := body[21 : 21+idLen]
+	ciphertext := body[21+idLen:]
+	assert.Equal(t, uint32(4096), rs)
+
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	AssertNotErrorNow(t, err)
+	sharedSecret, err := uaPrivate.ECDH(asPublic)
+	AssertNotErrorNow(t, err)
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPrivate.PublicKey().Bytes()...)
+	keyInfo = append(keyInfo, asPublicBytes...)
+	ikm, _ := hkdf.Key(sha256.New, sharedSecret, authSecret, string(keyInfo), 32)
+	cek, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	AssertNotErrorNow(t, err)
+
+	assert.Equal(t, byte(0x02), plaintext[len(plaintext)-1], "last record delimiter")
+	return plaintext[:len(plaintext)-1]
+}
+
+func TestWebPushEncrypt(t *testing.T) {
+	uaPrivate, _ := ecdh.P256().GenerateKey(rand.Reader)
+	authSecret := make([]byte, 16)
+	rand.Read(authSecret)
+
+	payload := []byte(`{"notification":"bag_too_old","title":"Bag","body":"12"}`)
+	body, err := webPushEncrypt(
+		base64.RawURLEncoding.EncodeToString(uaPrivate.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(authSecret),
+		payload,
+	)
+	AssertNotErrorNow(t, err)
+
+	assert.Equal(t, payload, webPushTestDecrypt(t, uaPrivate, authSecret, body))
+}
+
+func TestWebPushVapidAuthorization(t *testing.T) {
+	key, _ := ecdh.P256().GenerateKey(rand.Reader)
+	p, err := newPushProviderWebPush(base64.RawURLEncoding.EncodeToString(key.Bytes()), "mailto:dev@example.com")
+	AssertNotErrorNow(t, err)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()), p.publicKey)
+
+	authorization, err := p.vapidAuthorization("https://push.example.com/send/abc")
+	AssertNotErrorNow(t, err)
+
+	tokenStr, publicKey, ok := strings.Cut(strings.TrimPrefix(authorization, "vapid t="), ", k=")
+	assert.True(t, ok)
+	assert.Equal(t, p.publicKey, publicKey)
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (any, error) {
+		return &p.privateKey.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"}))
+	AssertNotErrorNow(t, err)
+	assert.Equal(t, "https://push.example.com", claims["aud"])
+	assert.Equal(t, "mailto:dev@example.com", claims["sub"])
+}
+
+func TestWebPushSendGone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "aes128gcm", r.Header.Get("Content-Encoding"))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "vapid t="))
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer server.Close()
+
+	key, _ := ecdh.P256().GenerateKey(rand.Reader)
+	p, _ := newPushProviderWebPush(base64.RawURLEncoding.EncodeToString(key.Bytes()), "mailto:dev@example.com")
+	// the push client refuses to connect to loopback addresses
+	p.client = server.Client()
+
+	uaPrivate, _ := ecdh.P256().GenerateKey(rand.Reader)
+	gone, err := p.send(server.URL,
+		base64.RawURLEncoding.EncodeToString(uaPrivate.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(make([]byte, 16)),
+		[]byte(`{}`))
+	assert.NoError(t, err)
+	assert.True(t, gone)
+}
+
+func TestWebPushValidateEndpoint(t *testing.T) {
+	valid := []string{
+		"https://fcm.googleapis.com/fcm/send/abc:def",
+		"https://updates.push.services.mozilla.com/wpush/v2/abc",
+		"https://web.push.apple.com/QGm4",
+		"https://wns2-par02p.notify.windows.com/w/?token=abc",
+		"https://FCM.googleapis.com:443/fcm/send/abc",
+	}
+	for _, endpoint := range valid {
+		assert.NoError(t, WebPushValidateEndpoint(endpoint), endpoint)
+	}
+
+	invalid := []string{
+		"http://fcm.googleapis.com/fcm/send/abc",
+		"https://fcm.googleapis.com:8443/fcm/send/abc",
+		"https://user@fcm.googleapis.com/fcm/send/abc",
+		"https://fcm.googleapis.com.example.com/abc",
+		"https://evilnotify.windows.com/abc",
+		"https://notify.windows.com.internal/abc",
+		"https://127.0.0.1/abc",
+		"https://[::1]/abc",
+		"https://169.254.169.254/latest/meta-data",
+		"https://localhost/abc",
+		"not a url",
+	}
+	for _, endpoint := range invalid {
+		assert.ErrorIs(t, WebPushValidateEndpoint(endpoint), ErrWebPushEndpointInvalid, endpoint)
+	}
+}
+
+func TestWebPushClientRefusesInternalAddresses(t *testing.T) {
+	redirected := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	_, err := newWebPushClient().Get(server.URL)
+	assert.ErrorContains(t, err, "non public address")
+
+	client := newWebPushClient()
+	client.Transport = server.Client().Transport
+	res, err := client.Get(server.URL)
+	AssertNotErrorNow(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+	assert.False(t, redirected, "redirects must not be followed")
+}
+
+func TestPushRecorder(t *testing.T) {
+	r := &PushRecorder{}
+	r.Send(nil, []string{"a", "b"}, PushMessage{Notification: "chat_message", Title: "1"})
+	r.Send(nil, []string{"b"}, PushMessage{Notification: "bag_too_old", Title: "2"})
+
+	assert.Len(t, r.Records(), 2)
+	assert.Equal(t, []PushMessage{{Notification: "chat_message", Title: "1"}}, r.FindByUserUID("a"))
+	assert.Len(t, r.FindByUserUID("b"), 2)
+
+	r.Reset()
+	assert.Empty(t, r.Records())
+}
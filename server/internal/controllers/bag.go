@@ -14,8 +14,11 @@ import (
 	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/app/auth"
 	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
 	"github.com/the-clothing-loop/website/server/internal/views"
+	"github.com/the-clothing-loop/website/server/pkg/cursor"
 	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
 )
 
 func BagGetAll(c *gin.Context) {
@@ -23,6 +26,7 @@ func BagGetAll(c *gin.Context) {
 	var query struct {
 		UserUID  string `form:"user_uid" binding:"required,uuid"`
 		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+		sharedtypes.PaginationQuery
 	}
 	if err := c.ShouldBindQuery(&query); err != nil {
 		c.String(http.StatusBadRequest, err.Error())
@@ -34,6 +38,26 @@ func BagGetAll(c *gin.Context) {
 		return
 	}
 
+	isPaginated := paginationIsSet(query.PaginationQuery)
+	ok, cur := paginationDecodeCursor(c, query.PaginationQuery)
+	if !ok {
+		return
+	}
+	limit := paginationLimit(query.PaginationQuery)
+	args := []any{chain.ID}
+	sqlPagination := ""
+	if cur != nil {
+		sqlPagination += `AND bags.id > ?
+`
+		args = append(args, cur.ID)
+	}
+	sqlPagination += `ORDER BY bags.id ASC`
+	if isPaginated {
+		sqlPagination += `
+LIMIT ?`
+		args = append(args, limit+1)
+	}
+
 	bags := []models.Bag{}
 	err := db.Raw(fmt.Sprintf(`
 SELECT
@@ -52,13 +76,19 @@ WHERE user_chain_id IN (
 	SELECT uc2.id FROM user_chains AS uc2
 	WHERE uc2.chain_id = ?
 )
-ORDER BY bags.id ASC
-	`, "`", "`", "`", "`"), chain.ID).Scan(&bags).Error
+%s
+	`, "`", "`", "`", "`", sqlPagination), args...).Scan(&bags).Error
 	if err != nil {
 		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to find bags")
 		return
 	}
 
+	if isPaginated {
+		c.JSON(http.StatusOK, paginationTrim(bags, limit, func(b models.Bag) cursor.Cursor {
+			return cursor.Cursor{ID: b.ID}
+		}))
+		return
+	}
 	c.JSON(http.StatusOK, bags)
 }
 
@@ -151,9 +181,9 @@ LIMIT 1
 	}
 
 	if body.UserUID != body.HolderUID {
-		err := app.OneSignalCreateNotification(db, []string{body.HolderUID},
-			*views.Notifications[views.NotificationEnumTitleBagAssignedYou],
-			app.OneSignalEllipsisContent(bag.Number))
+		err := services.NotifyPush(db, sharedtypes.NotificationTypeBagAssigned, []string{body.HolderUID},
+			views.NotificationEnumBagAssignedYou,
+			app.OneSignalEllipsis(bag.Number))
 		if err != nil {
 			slog.Error("Notification creation failed", "err", err)
 		}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+// Receives hard bounces, spam complaints and unsubscribes from Brevo.
+// Unknown events are acknowledged so that Brevo does not retry them.
+func BrevoWebhook(c *gin.Context) {
+	db := getDB(c)
+
+	if !app.BrevoWebhookVerify(c) {
+		c.String(http.StatusUnauthorized, "Invalid webhook secret")
+		return
+	}
+
+	var body app.BrevoWebhookEvent
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reason := ""
+	switch body.Event {
+	case app.BrevoWebhookEventHardBounce:
+		reason = sharedtypes.EmailUndeliverableReasonBounce
+	case app.BrevoWebhookEventSpam:
+		reason = sharedtypes.EmailUndeliverableReasonComplaint
+	case app.BrevoWebhookEventUnsubscribed:
+		// Brevo blocks all further transactional emails to this address
+		reason = sharedtypes.EmailUndeliverableReasonUnsubscribed
+	case app.BrevoWebhookEventUnsubscribeNewsletter:
+	default:
+		return
+	}
+
+	err := models.NewsletterDeleteByEmail(db, body.Email)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to remove email from newsletter")
+		return
+	}
+	if reason == "" {
+		return
+	}
+
+	_, err = models.UserSetEmailUndeliverable(db, body.Email, reason)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to mark email as undeliverable")
+		return
+	}
+
+	if body.Event == app.BrevoWebhookEventHardBounce && body.MessageID != "" {
+		found, err := models.MailMarkBounced(db, body.MessageID, body.Reason)
+		if err != nil {
+			slog.Error("Unable to mark email as bounced", "err", err)
+		} else if !found {
+			slog.Warn("Bounced email not found in outbox", "messageID", body.MessageID)
+		}
+	}
+}
@@ -9,8 +9,11 @@ import (
 	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/app/auth"
 	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
 	"github.com/the-clothing-loop/website/server/internal/views"
+	"github.com/the-clothing-loop/website/server/pkg/cursor"
 	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
 )
 
 func BulkyGetAll(c *gin.Context) {
@@ -18,6 +21,7 @@ func BulkyGetAll(c *gin.Context) {
 	var query struct {
 		UserUID  string `form:"user_uid" binding:"required,uuid"`
 		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+		sharedtypes.PaginationQuery
 	}
 	if err := c.ShouldBindQuery(&query); err != nil {
 		c.String(http.StatusBadRequest, err.Error())
@@ -29,6 +33,26 @@ func BulkyGetAll(c *gin.Context) {
 		return
 	}
 
+	isPaginated := paginationIsSet(query.PaginationQuery)
+	ok, cur := paginationDecodeCursor(c, query.PaginationQuery)
+	if !ok {
+		return
+	}
+	limit := paginationLimit(query.PaginationQuery)
+	args := []any{chain.ID}
+	sqlPagination := ""
+	if cur != nil {
+		sqlPagination += `AND bulky_items.id > ?
+`
+		args = append(args, cur.ID)
+	}
+	sqlPagination += `ORDER BY bulky_items.id ASC`
+	if isPaginated {
+		sqlPagination += `
+LIMIT ?`
+		args = append(args, limit+1)
+	}
+
 	bulkyItems := []models.BulkyItem{}
 	err := db.Raw(`
 	SELECT 
@@ -48,12 +72,18 @@ WHERE user_chain_id IN (
 	SELECT uc2.id FROM user_chains AS uc2
 	WHERE uc2.chain_id = ?
 )
-	`, chain.ID).Scan(&bulkyItems).Error
+`+sqlPagination, args...).Scan(&bulkyItems).Error
 	if err != nil {
 		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to find bulky items")
 		return
 	}
 
+	if isPaginated {
+		c.JSON(http.StatusOK, paginationTrim(bulkyItems, limit, func(b models.BulkyItem) cursor.Cursor {
+			return cursor.Cursor{ID: b.ID}
+		}))
+		return
+	}
 	c.JSON(http.StatusOK, bulkyItems)
 }
 
@@ -88,9 +118,9 @@ func BulkyPut(c *gin.Context) {
 			WHERE c.uid = ? AND u.uid != ? AND uc.is_approved = TRUE`, body.ChainUID, body.UserUID).Scan(&userUIDs)
 
 		if len(userUIDs) > 0 {
-			err := app.OneSignalCreateNotification(db, userUIDs,
-				*views.Notifications[views.NotificationEnumTitleNewBulkyCreated],
-				app.OneSignalEllipsisContent(lo.FromPtr(body.Title)))
+			err := services.NotifyPush(db, sharedtypes.NotificationTypeBulkyItem, userUIDs,
+				views.NotificationEnumNewBulkyCreated,
+				app.OneSignalEllipsis(lo.FromPtr(body.Title)))
 			if err != nil {
 				slog.Error(err.Error())
 			}
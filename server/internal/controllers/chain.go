@@ -13,6 +13,7 @@ import (
 	"github.com/the-clothing-loop/website/server/internal/models"
 	"github.com/the-clothing-loop/website/server/internal/services"
 	"github.com/the-clothing-loop/website/server/internal/views"
+	"github.com/the-clothing-loop/website/server/pkg/cursor"
 	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
 	"github.com/the-clothing-loop/website/server/pkg/tsp"
 	"github.com/the-clothing-loop/website/server/sharedtypes"
@@ -82,13 +83,25 @@ func ChainCreate(c *gin.Context) {
 		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to create chain")
 		return
 	}
+	if err := models.ChainFacetsSync(db, chain.ID, chain.Sizes, chain.Genders); err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to create chain")
+		return
+	}
 
 	if err := user.AcceptLegal(db); err != nil {
 		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to set toh to true, during chain creation")
 		return
 	}
 
-	c.Status(200)
+	interestedNearby, err := services.LocationAlertNotify(db, &chain)
+	if err != nil {
+		slog.Error("Unable to send location alerts", "chainID", chain.ID, "err", err)
+	}
+
+	c.JSON(http.StatusOK, sharedtypes.ChainCreateResponse{
+		ChainUID:         chain.UID,
+		InterestedNearby: interestedNearby,
+	})
 }
 
 func ChainGet(c *gin.Context) {
@@ -188,11 +201,18 @@ func ChainGetAll(c *gin.Context) {
 		FilterGenders   []string `form:"filter_genders"`
 		FilterPublished bool     `form:"filter_out_unpublished"`
 		AddTotals       bool     `form:"add_totals"`
+		sharedtypes.PaginationQuery
 	}
 	if err := c.ShouldBindQuery(&query); err != nil && err != io.EOF {
 		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
+	isPaginated := paginationIsSet(query.PaginationQuery)
+	ok, cur := paginationDecodeCursor(c, query.PaginationQuery)
+	if !ok {
+		return
+	}
+	limit := paginationLimit(query.PaginationQuery)
 
 	if ok := models.ValidateAllSizeEnum(query.FilterSizes); !ok {
 		c.String(http.StatusBadRequest, models.ErrSizeInvalid.Error())
@@ -247,8 +267,20 @@ func ChainGetAll(c *gin.Context) {
 	if query.FilterPublished {
 		whereOrSql = append(whereOrSql, "chains.published = TRUE")
 	}
+	whereAndSql := []string{}
 	if len(whereOrSql) > 0 {
-		sql = fmt.Sprintf("%s WHERE %s", sql, strings.Join(whereOrSql, " OR "))
+		whereAndSql = append(whereAndSql, fmt.Sprintf("( %s )", strings.Join(whereOrSql, " OR ")))
+	}
+	if cur != nil {
+		whereAndSql = append(whereAndSql, "chains.id > ?")
+		args = append(args, cur.ID)
+	}
+	if len(whereAndSql) > 0 {
+		sql = fmt.Sprintf("%s WHERE %s", sql, strings.Join(whereAndSql, " AND "))
+	}
+	if isPaginated {
+		sql = fmt.Sprintf("%s ORDER BY chains.id ASC LIMIT ?", sql)
+		args = append(args, limit+1)
 	}
 	if err := db.Raw(sql, args...).Scan(&chains).Error; err != nil {
 		slog.Warn("Chain not found", "err", err)
@@ -256,6 +288,12 @@ func ChainGetAll(c *gin.Context) {
 		return
 	}
 
+	if isPaginated {
+		c.JSON(200, paginationTrim(chains, limit, func(chain sharedtypes.ChainResponse) cursor.Cursor {
+			return cursor.Cursor{ID: chain.ID}
+		}))
+		return
+	}
 	c.JSON(200, chains)
 }
 
@@ -324,6 +362,8 @@ func ChainUpdate(c *gin.Context) {
 		return
 	}
 
+	wasOpen := chain.Published && chain.OpenToNewMembers
+
 	valuesToUpdate := map[string]any{}
 	if body.Name != nil {
 		valuesToUpdate["name"] = *(body.Name)
@@ -393,6 +433,33 @@ func ChainUpdate(c *gin.Context) {
 		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to update loop values")
 		return
 	}
+
+	if body.Sizes != nil || body.Genders != nil {
+		sizes, genders := chain.Sizes, chain.Genders
+		if body.Sizes != nil {
+			sizes = *(body.Sizes)
+		}
+		if body.Genders != nil {
+			genders = *(body.Genders)
+		}
+		err = models.ChainFacetsSync(db, chain.ID, sizes, genders)
+		if err != nil {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to update loop values")
+			return
+		}
+	}
+
+	// a loop that opens up again is announced to the location alerts nearby
+	if !wasOpen && (body.Published != nil || body.OpenToNewMembers != nil) {
+		updated := &models.Chain{}
+		err = db.First(updated, chain.ID).Error
+		if err == nil {
+			_, err = services.LocationAlertNotify(db, updated)
+		}
+		if err != nil {
+			slog.Error("Unable to send location alerts", "chainID", chain.ID, "err", err)
+		}
+	}
 }
 
 func ChainDelete(c *gin.Context) {
@@ -684,3 +751,27 @@ func ChainChangeUserWarden(c *gin.Context) {
 		return
 	}
 }
+
+// Lists current and upcoming holidays of the loop members for the hosts
+func ChainGetAbsences(c *gin.Context) {
+	db := getDB(c)
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	absences, err := models.UserChainGetAbsences(db, chain.ID)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to find absences")
+		return
+	}
+	c.JSON(http.StatusOK, absences)
+}
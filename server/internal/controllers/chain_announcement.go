@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	uuid "github.com/satori/go.uuid"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+// Sends an email and push notification to all approved members of the loop,
+// or only to those matching the size or warden filter
+func ChainAnnounce(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.ChainAnnounceRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if ok := models.ValidateAllSizeEnum(body.FilterSizes); !ok {
+		c.String(http.StatusBadRequest, models.ErrSizeInvalid.Error())
+		return
+	}
+
+	ok, authUser, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	members, err := models.ChainAnnouncementGetMembers(db, chain.ID, authUser.ID, body.WardensOnly)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to find the members of this Loop")
+		return
+	}
+	if len(body.FilterSizes) > 0 {
+		members = lo.Filter(members, func(m models.ChainAnnouncementMember, _ int) bool {
+			return lo.Some(m.Sizes, body.FilterSizes)
+		})
+	}
+	if len(members) == 0 {
+		c.String(http.StatusBadRequest, "No members match this announcement")
+		return
+	}
+
+	res := sharedtypes.ChainAnnounceResponse{
+		Undeliverable: []sharedtypes.ChainAnnounceRecipient{},
+		Unsubscribed:  []sharedtypes.ChainAnnounceRecipient{},
+	}
+	deliverable := []models.ChainAnnouncementMember{}
+	for _, m := range members {
+		if m.Email == nil || m.IsUndelivered {
+			res.Undeliverable = append(res.Undeliverable, sharedtypes.ChainAnnounceRecipient{UserUID: m.UserUID, Name: m.Name})
+			continue
+		}
+		deliverable = append(deliverable, m)
+	}
+	subscribedUIDs, err := models.NotificationPreferenceFilterUserUIDs(db, sharedtypes.NotificationTypeAnnouncement, sharedtypes.NotificationChannelEmail,
+		lo.Map(deliverable, func(m models.ChainAnnouncementMember, _ int) string { return m.UserUID }))
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to check notification preferences")
+		return
+	}
+	recipients := []models.ChainAnnouncementMember{}
+	for _, m := range deliverable {
+		if !slices.Contains(subscribedUIDs, m.UserUID) {
+			res.Unsubscribed = append(res.Unsubscribed, sharedtypes.ChainAnnounceRecipient{UserUID: m.UserUID, Name: m.Name})
+			continue
+		}
+		recipients = append(recipients, m)
+	}
+
+	announcement := sharedtypes.ChainAnnouncement{
+		UID:            uuid.NewV4().String(),
+		ChainID:        chain.ID,
+		ChainUID:       chain.UID,
+		AuthorUserID:   authUser.ID,
+		AuthorUserUID:  authUser.UID,
+		AuthorName:     authUser.Name,
+		Subject:        body.Subject,
+		Body:           body.Body,
+		FilterSizes:    lo.Ternary(body.FilterSizes == nil, []string{}, body.FilterSizes),
+		WardensOnly:    body.WardensOnly,
+		RecipientCount: len(members),
+		EmailedCount:   len(recipients),
+	}
+	err = models.ChainAnnouncementCreate(db, &announcement)
+	if errors.Is(err, models.ErrChainAnnouncementLimit) {
+		c.String(http.StatusTooManyRequests, err.Error())
+		return
+	}
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to save announcement")
+		return
+	}
+
+	for _, m := range recipients {
+		err := views.EmailAnnouncement(db, m.I18n, m.Name, *m.Email, authUser.Name, chain.Name, body.Subject, body.Body)
+		if err != nil {
+			slog.Error("Unable to send announcement email", "err", err, "user", m.UserUID)
+		}
+	}
+	services.NotifyPush(db, sharedtypes.NotificationTypeAnnouncement,
+		lo.Map(members, func(m models.ChainAnnouncementMember, _ int) string { return m.UserUID }),
+		views.NotificationEnumAnnouncement, app.OneSignalEllipsis(body.Subject))
+
+	res.Announcement = announcement
+	c.JSON(http.StatusOK, res)
+}
+
+// History of announcements sent to the loop, newest first
+func ChainAnnouncementGetAll(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	list, err := models.ChainAnnouncementGetAll(db, chain.ID)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to find announcements")
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
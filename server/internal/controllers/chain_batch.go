@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/pkg/tsp"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+// Runs one action for many members of a loop at once, every user gets their own result.
+// Database changes are applied in a single transaction, emails are sent after it is committed.
+func ChainBatchUsers(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.ChainBatchUsersRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, authUser, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	var toChainID uint
+	if body.Action == sharedtypes.ChainBatchUsersActionMove {
+		if body.ToChainUID == chain.UID {
+			c.String(http.StatusBadRequest, "Destination loop must be a different loop")
+			return
+		}
+		if !authUser.IsRootAdmin {
+			_, isChainAdmin := authUser.IsPartOfChain(body.ToChainUID)
+			if !isChainAdmin {
+				c.String(http.StatusUnauthorized, "you must be a host of both loops")
+				return
+			}
+		}
+		var found bool
+		var err error
+		toChainID, found, err = models.ChainCheckIfExist(db, body.ToChainUID, false)
+		if err != nil || !found {
+			c.String(http.StatusBadRequest, "Destination loop does not exist")
+			return
+		}
+	}
+	// finished authentication
+
+	userUIDs := lo.Uniq(body.UserUIDs)
+	members, err := models.UserChainGetBatchMembers(db, chain.ID, userUIDs)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to find loop members")
+		return
+	}
+	membersByUID := lo.KeyBy(members, func(m models.UserChainBatchMember) string { return m.UserUID })
+
+	amountChainAdmins := 0
+	err = db.Raw(`SELECT COUNT(*) FROM user_chains WHERE chain_id = ? AND is_chain_admin = TRUE`, chain.ID).Scan(&amountChainAdmins).Error
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to count hosts of loop")
+		return
+	}
+
+	results := make([]sharedtypes.ChainBatchUsersResult, 0, len(userUIDs))
+	done := []models.UserChainBatchMember{}
+
+	// run in a queue with the ability to rollback on failure
+	tx := db.Begin()
+	for _, userUID := range userUIDs {
+		result := sharedtypes.ChainBatchUsersResult{UserUID: userUID}
+		member, ok := membersByUID[userUID]
+		if !ok {
+			result.Error = "User is not a member of this loop"
+			results = append(results, result)
+			continue
+		}
+
+		switch body.Action {
+		case sharedtypes.ChainBatchUsersActionApprove:
+			if member.IsApproved {
+				result.Error = "User is already approved"
+				break
+			}
+			err = tx.Exec(`UPDATE user_chains SET is_approved = TRUE, created_at = NOW() WHERE id = ?`, member.UserChainID).Error
+		case sharedtypes.ChainBatchUsersActionPromote:
+			if !member.IsApproved {
+				result.Error = "User must be approved before becoming a host"
+				break
+			}
+			if member.IsChainAdmin {
+				result.Error = "User is already a host"
+				break
+			}
+			err = tx.Exec(`UPDATE user_chains SET is_chain_admin = TRUE, is_chain_warden = FALSE WHERE id = ?`, member.UserChainID).Error
+		case sharedtypes.ChainBatchUsersActionRemove, sharedtypes.ChainBatchUsersActionMove:
+			if member.IsChainAdmin {
+				if amountChainAdmins <= 1 {
+					result.Error = "Unable to remove last host of loop"
+					break
+				}
+				amountChainAdmins--
+			}
+			if body.Action == sharedtypes.ChainBatchUsersActionRemove {
+				err = chainBatchRemoveUser(tx, chain.ID, member)
+			} else {
+				err = chainBatchMoveUser(tx, toChainID, member)
+			}
+		}
+		if err != nil {
+			tx.Rollback()
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, fmt.Sprintf("Unable to %s user %s", body.Action, userUID))
+			return
+		}
+
+		if result.Error == "" {
+			result.OK = true
+			done = append(done, member)
+		}
+		results = append(results, result)
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to apply changes to loop members")
+		return
+	}
+
+	switch body.Action {
+	case sharedtypes.ChainBatchUsersActionApprove:
+		if len(done) > 0 {
+			chain.ClearAllLastNotifiedIsUnapprovedAt(db)
+		}
+		for _, member := range done {
+			cities := retrieveChainUsersAsTspCities(db, chain.ID)
+			if cities != nil {
+				newRoute, _ := tsp.RunAddOptimalOrderNewCity(cities.ToTspCities(), member.UserUID)
+				chain.SetRouteOrderByUserUIDs(db, newRoute)
+			}
+
+			if member.Email.Valid {
+				views.EmailAnAdminApprovedYourJoinRequest(db, member.I18n, member.Name, member.Email.String, chain.Name)
+			}
+		}
+	case sharedtypes.ChainBatchUsersActionRemove:
+		if len(done) > 0 {
+			chain.ClearAllLastNotifiedIsUnapprovedAt(db)
+		}
+		// pending members are told their join request was denied, hosts only hear about participants
+		reason := body.Reason
+		if reason == "" {
+			reason = UnapprovedReasonOther
+		}
+		removed := []views.EmailParticipant{}
+		for _, member := range done {
+			if !member.Email.Valid {
+				continue
+			}
+			if !member.IsApproved {
+				views.EmailAnAdminDeniedYourJoinRequest(db, member.I18n, member.Name, member.Email.String, chain.Name, reason)
+				continue
+			}
+			removed = append(removed, views.EmailParticipant{Name: member.Name, Email: member.Email.String})
+		}
+		services.EmailLoopAdminsOnUsersLeft(db, removed, lo.FromPtr(authUser.Email), chain.ID)
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// Removes the user from the loop, passing on their bags, within the given transaction
+func chainBatchRemoveUser(tx *gorm.DB, chainID uint, member models.UserChainBatchMember) error {
+	err := (&models.User{ID: member.UserID}).DeleteOrPassOnUserChainBags(tx, chainID)
+	if err != nil {
+		return err
+	}
+	err = tx.Exec(`DELETE FROM bulky_items WHERE user_chain_id = ?`, member.UserChainID).Error
+	if err != nil {
+		return err
+	}
+	return tx.Exec(`DELETE FROM user_chains WHERE id = ?`, member.UserChainID).Error
+}
+
+// Moves the user to the destination loop the same way UserTransferChain does,
+// if they are already a member there their bags and bulky items follow them
+func chainBatchMoveUser(tx *gorm.DB, toChainID uint, member models.UserChainBatchMember) error {
+	toUserChainID := uint(0)
+	err := tx.Raw(`SELECT id FROM user_chains WHERE chain_id = ? AND user_id = ? LIMIT 1`, toChainID, member.UserID).Scan(&toUserChainID).Error
+	if err != nil {
+		return err
+	}
+	if toUserChainID == 0 {
+		return tx.Exec(`UPDATE user_chains SET chain_id = ?, route_order = 0 WHERE id = ?`, toChainID, member.UserChainID).Error
+	}
+
+	err = tx.Exec(`UPDATE bags SET user_chain_id = ? WHERE user_chain_id = ?`, toUserChainID, member.UserChainID).Error
+	if err != nil {
+		return err
+	}
+	err = tx.Exec(`UPDATE bulky_items SET user_chain_id = ? WHERE user_chain_id = ?`, toUserChainID, member.UserChainID).Error
+	if err != nil {
+		return err
+	}
+	return tx.Exec(`DELETE FROM user_chains WHERE id = ?`, member.UserChainID).Error
+}
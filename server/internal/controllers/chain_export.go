@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/pkg/pdf"
+	"github.com/the-clothing-loop/website/server/pkg/xlsx"
+)
+
+var chainExportContentTypes = map[string]string{
+	"csv":   "text/csv; charset=utf-8",
+	"xlsx":  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"vcard": "text/vcard; charset=utf-8",
+	"pdf":   "application/pdf",
+}
+
+var chainExportFileExtensions = map[string]string{
+	"csv":   "csv",
+	"xlsx":  "xlsx",
+	"vcard": "vcf",
+	"pdf":   "pdf",
+}
+
+// Exports the approved members of a loop in route order for hosts.
+// The spreadsheets and vCards are for the hosts themselves and contain everything a host can see,
+// the printable route sheet is handed out to members and only shows what is shared with the whole loop.
+func ChainExportUsers(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+		Format   string `form:"format" binding:"required,oneof=csv xlsx vcard pdf"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	members, err := models.UserChainGetExport(db, chain.ID)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve loop members")
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	switch query.Format {
+	case "csv":
+		// excel only detects utf-8 with a byte order mark
+		buf.WriteString("\xef\xbb\xbf")
+		err = csv.NewWriter(buf).WriteAll(chainExportRows(members))
+	case "xlsx":
+		err = xlsx.Write(buf, chain.Name, chainExportRows(members))
+	case "vcard":
+		chainExportVCards(buf, chain.Name, members)
+	case "pdf":
+		var privacy *models.UserPrivacy
+		privacy, err = models.UserPrivacyGet(db, chain, 0)
+		if err == nil {
+			_, err = chainExportRouteSheet(chain.Name, members, privacy).WriteTo(buf)
+		}
+	}
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to create export")
+		return
+	}
+
+	filename := fmt.Sprintf("%s.%s", chainExportFilename(chain.Name), chainExportFileExtensions[query.Format])
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, chainExportContentTypes[query.Format], buf.Bytes())
+}
+
+func chainExportRows(members []models.UserChainExportMember) [][]string {
+	rows := [][]string{{"route_order", "name", "email", "phone_number", "address", "sizes", "host", "paused", "bags", "note"}}
+	for i, m := range members {
+		row := []string{
+			strconv.Itoa(i + 1),
+			m.Name,
+			m.Email.String,
+			m.PhoneNumber,
+			m.Address,
+			strings.Join(m.Sizes, ","),
+			chainExportYesNo(m.IsChainAdmin),
+			chainExportYesNo(m.IsPaused),
+			m.Bags.String,
+			m.Note.String,
+		}
+		for j := range row {
+			row[j] = chainExportEscapeCell(row[j])
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Spreadsheet apps run cells starting with these characters as formulas, members choose their own
+// name, address and notes so those are prefixed with a quote to always show them as text
+func chainExportEscapeCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func chainExportYesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return ""
+}
+
+func chainExportVCards(buf *bytes.Buffer, chainName string, members []models.UserChainExportMember) {
+	line := func(property, value string) {
+		if value == "" {
+			return
+		}
+		fmt.Fprintf(buf, "%s:%s\r\n", property, value)
+	}
+	for i, m := range members {
+		buf.WriteString("BEGIN:VCARD\r\nVERSION:3.0\r\n")
+		line("FN", vcardEscape(m.Name))
+		line("N", ";"+vcardEscape(m.Name)+";;;")
+		line("EMAIL;TYPE=INTERNET", vcardEscape(m.Email.String))
+		line("TEL;TYPE=CELL", vcardEscape(m.PhoneNumber))
+		if m.Address != "" {
+			line("ADR;TYPE=HOME", ";;"+vcardEscape(m.Address)+";;;;")
+		}
+		line("CATEGORIES", vcardEscape(chainName))
+		line("NOTE", vcardEscape(fmt.Sprintf("%s #%d", chainName, i+1)))
+		buf.WriteString("END:VCARD\r\n")
+	}
+}
+
+func vcardEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// A4 route sheet with a row per member, continued on the next page when full
+func chainExportRouteSheet(chainName string, members []models.UserChainExportMember, privacy *models.UserPrivacy) *pdf.Document {
+	const (
+		marginX   = 40.0
+		marginY   = 50.0
+		rowHeight = 16.0
+		fontSize  = 9.0
+	)
+	columns := []struct {
+		title string
+		x     float64
+		width float64
+	}{
+		{"#", marginX, 25},
+		{"Name", marginX + 25, 135},
+		{"Address", marginX + 160, 180},
+		{"Phone", marginX + 340, 95},
+		{"Bags", marginX + 435, 80},
+	}
+
+	d := pdf.New()
+	y := 0.0
+	header := func() {
+		d.AddPage()
+		d.Text(marginX, marginY, 16, true, chainName)
+		d.Text(marginX, marginY+16, fontSize, false, "Route sheet, "+time.Now().Format(time.DateOnly))
+		y = marginY + 40
+		for _, col := range columns {
+			d.Text(col.x, y, fontSize, true, col.title)
+		}
+		d.Line(marginX, y+4, pdf.PageWidth-marginX, y+4)
+		y += rowHeight
+	}
+	header()
+
+	for i, m := range members {
+		if y > pdf.PageHeight-marginY {
+			header()
+		}
+		_, showPhone, showAddress := privacy.IsSharedWithLoop(m.UserID)
+		name := m.Name
+		if m.IsPaused {
+			name += " (paused)"
+		}
+		values := []string{strconv.Itoa(i + 1), name, "", "", m.Bags.String}
+		if showAddress {
+			values[2] = m.Address
+		}
+		if showPhone {
+			values[3] = m.PhoneNumber
+		}
+		for j, col := range columns {
+			d.Text(col.x, y, fontSize, false, pdf.Truncate(values[j], fontSize, col.width-5))
+		}
+		y += rowHeight
+	}
+
+	return d
+}
+
+// Keeps letters and digits of the loop name so it can be used as a filename
+func chainExportFilename(chainName string) string {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, chainName)
+	name = strings.Trim(name, "-")
+	if name == "" {
+		return "loop"
+	}
+	return name
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"gopkg.in/guregu/null.v3"
+)
+
+func TestChainExportRows(t *testing.T) {
+	rows := chainExportRows([]models.UserChainExportMember{
+		{Name: "Jane", Email: null.StringFrom("jane@example.com"), Address: "Dam 1", Sizes: []string{"1", "B"}, IsChainAdmin: true, Bags: null.StringFrom("3, 7")},
+		{Name: "John", IsPaused: true, Note: null.StringFrom("Ring twice")},
+		{Name: "=HYPERLINK(\"http://example.com\")", PhoneNumber: "+31612345678", Address: "@SUM(A1)", Note: null.StringFrom("-1\t")},
+	})
+
+	assert.Len(t, rows, 4)
+	assert.Equal(t, []string{"1", "Jane", "jane@example.com", "", "Dam 1", "1,B", "yes", "", "3, 7", ""}, rows[1])
+	assert.Equal(t, []string{"2", "John", "", "", "", "", "", "yes", "", "Ring twice"}, rows[2])
+	assert.Equal(t, []string{"3", "'=HYPERLINK(\"http://example.com\")", "", "'+31612345678", "'@SUM(A1)", "", "", "", "", "'-1\t"}, rows[3])
+}
+
+func TestChainExportVCards(t *testing.T) {
+	buf := &bytes.Buffer{}
+	chainExportVCards(buf, "Oost", []models.UserChainExportMember{
+		{Name: "Jane; Doe", Email: null.StringFrom("jane@example.com"), Address: "Dam 1, Amsterdam"},
+	})
+
+	assert.Equal(t, "BEGIN:VCARD\r\nVERSION:3.0\r\n"+
+		"FN:Jane\\; Doe\r\n"+
+		"N:;Jane\\; Doe;;;\r\n"+
+		"EMAIL;TYPE=INTERNET:jane@example.com\r\n"+
+		"ADR;TYPE=HOME:;;Dam 1\\, Amsterdam;;;;\r\n"+
+		"CATEGORIES:Oost\r\n"+
+		"NOTE:Oost #1\r\n"+
+		"END:VCARD\r\n", buf.String())
+}
+
+func TestChainExportFilename(t *testing.T) {
+	assert.Equal(t, "Amsterdam-Oost", chainExportFilename("Amsterdam Oost"))
+	assert.Equal(t, "loop", chainExportFilename("אמסטרדם"))
+}
@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	uuid "github.com/satori/go.uuid"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/pkg/xlsx"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+const (
+	chainImportMaxRows = 1000
+	// more than enough for the known headers and some unrelated columns
+	chainImportMaxColumns = 100
+	// days the invite of a row with an existing account stays valid
+	chainImportInviteDays = 14
+)
+
+// Spreadsheet header names mapped to the column they are imported into
+var chainImportHeaders = map[string]string{
+	"name":         "name",
+	"email":        "email",
+	"e-mail":       "email",
+	"phone":        "phone",
+	"phone number": "phone",
+	"phone_number": "phone",
+	"address":      "address",
+	"size":         "sizes",
+	"sizes":        "sizes",
+}
+
+// Adds members from a csv or xlsx file to the loop as approved participants in the order of the file.
+// New emails become unverified users, existing accounts are emailed a single use invite instead
+// so that they decide themselves whether to join.
+// Rows of existing accounts are reported as new, so that the file can not be used to find out who has an account.
+// A dry run only reports what would happen to each row.
+func ChainImportUsers(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.ChainImportUsersRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, authUser, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	records, err := chainImportReadFile(body.Format, body.File)
+	if err != nil {
+		c.String(http.StatusBadRequest, fmt.Sprintf("Unable to read file: %v", err))
+		return
+	}
+	rows, err := chainImportParseRows(records)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// match existing accounts
+	existingUserIDs := map[int]uint{}
+	for i, row := range rows {
+		if row.Status != sharedtypes.ChainImportUserStatusNew {
+			continue
+		}
+		userID, found, err := models.UserCheckEmail(db, row.Email)
+		if err != nil {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to check if email exists")
+			return
+		}
+		if found {
+			existingUserIDs[i] = userID
+		}
+	}
+	if len(existingUserIDs) > 0 {
+		memberIDs := []uint{}
+		err = db.Raw(`SELECT user_id FROM user_chains WHERE chain_id = ? AND user_id IN ?`, chain.ID, lo.Values(existingUserIDs)).Scan(&memberIDs).Error
+		if err != nil {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to find existing loop members")
+			return
+		}
+		for i, userID := range existingUserIDs {
+			if lo.Contains(memberIDs, userID) {
+				rows[i].Status = sharedtypes.ChainImportUserStatusMember
+				delete(existingUserIDs, i)
+			}
+		}
+	}
+
+	if body.DryRun {
+		c.JSON(http.StatusOK, sharedtypes.ChainImportUsersResponse{DryRun: true, Rows: rows})
+		return
+	}
+
+	routeOrder := 0
+	err = db.Raw(`SELECT COALESCE(MAX(route_order), 0) FROM user_chains WHERE chain_id = ?`, chain.ID).Scan(&routeOrder).Error
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to find the end of the route")
+		return
+	}
+
+	newUserIDs := map[int]uint{}
+	invites := map[int]*sharedtypes.ChainInvite{}
+	inviteExpiresAt := time.Now().Add(chainImportInviteDays * 24 * time.Hour)
+	// run in a queue with the ability to rollback on failure
+	tx := db.Begin()
+	for i := range rows {
+		row := &rows[i]
+		if row.Status != sharedtypes.ChainImportUserStatusNew {
+			continue
+		}
+		if _, ok := existingUserIDs[i]; ok {
+			invite := &sharedtypes.ChainInvite{
+				ChainID:         chain.ID,
+				CreatedByUserID: authUser.ID,
+				ExpiresAt:       &inviteExpiresAt,
+				MaxUses:         1,
+				AutoApprove:     true,
+			}
+			err = models.ChainInviteCreate(tx, invite)
+			if err != nil {
+				tx.Rollback()
+				ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, fmt.Sprintf("Unable to import row %d", row.Row))
+				return
+			}
+			invites[i] = invite
+			continue
+		}
+
+		user := &models.User{
+			UID:             uuid.NewV4().String(),
+			Email:           lo.ToPtr(row.Email),
+			IsEmailVerified: false,
+			Name:            row.Name,
+			PhoneNumber:     row.PhoneNumber,
+			Address:         row.Address,
+			Sizes:           row.Sizes,
+			I18n:            authUser.I18n,
+		}
+		err = tx.Create(user).Error
+		if err != nil {
+			tx.Rollback()
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, fmt.Sprintf("Unable to import row %d", row.Row))
+			return
+		}
+		newUserIDs[i] = user.ID
+
+		routeOrder++
+		err = tx.Create(&sharedtypes.UserChain{
+			UserID:     user.ID,
+			ChainID:    chain.ID,
+			IsApproved: true,
+			RouteOrder: routeOrder,
+		}).Error
+		if err != nil {
+			tx.Rollback()
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, fmt.Sprintf("Unable to add row %d to loop", row.Row))
+			return
+		}
+	}
+	err = tx.Commit().Error
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to import members")
+		return
+	}
+
+	// members are already imported, so a failing email is reported on its row and the others are still sent
+	for i, row := range rows {
+		var err error
+		if userID, ok := newUserIDs[i]; ok {
+			// new accounts verify their email with the first login
+			var token string
+			token, err = auth.OtpCreate(db, userID)
+			if err == nil {
+				err = views.EmailImportedIntoLoop(db, authUser.I18n, row.Name, row.Email, authUser.Name, chain.Name, chain.UID, token)
+			}
+		} else if invite, ok := invites[i]; ok {
+			// existing accounts are emailed in their own language and with the name of their account
+			var user *models.User
+			user, err = models.UserGetByEmail(db, row.Email)
+			if err == nil {
+				err = views.EmailInvitedToLoop(db, user.I18n, user.Name, row.Email, authUser.Name, chain.Name, chainInviteUrl(invite.Token), chainImportInviteDays)
+			}
+		}
+		if err != nil {
+			slog.Error("Unable to email imported member", "err", err, "chainID", chain.ID, "row", row.Row)
+			rows[i].Error = "Imported, but the email could not be sent"
+		}
+	}
+
+	c.JSON(http.StatusOK, sharedtypes.ChainImportUsersResponse{DryRun: false, Rows: rows})
+}
+
+func chainImportReadFile(format string, file []byte) ([][]string, error) {
+	if format == "xlsx" {
+		// the header row is not counted as a member
+		rows, err := xlsx.ReadLimit(file, chainImportMaxRows+1, chainImportMaxColumns)
+		if errors.Is(err, xlsx.ErrTooLarge) {
+			return nil, fmt.Errorf("File contains more than %d rows or %d columns", chainImportMaxRows, chainImportMaxColumns)
+		}
+		return rows, err
+	}
+
+	file = bytes.TrimPrefix(file, []byte("\xef\xbb\xbf"))
+	firstLine, _, _ := bytes.Cut(file, []byte("\n"))
+	r := csv.NewReader(bytes.NewReader(file))
+	// spreadsheet programs with a comma as decimal separator export with semicolons
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		r.Comma = ';'
+	}
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	return r.ReadAll()
+}
+
+// Validates each row of the file, the first row must contain the column names.
+// Rows are given the status new, or invalid and duplicate when they can not be imported.
+func chainImportParseRows(records [][]string) ([]sharedtypes.ChainImportUserRow, error) {
+	if len(records) == 0 {
+		return nil, errors.New("File is empty")
+	}
+	columns := map[string]int{}
+	for i, header := range records[0] {
+		if column, ok := chainImportHeaders[strings.ToLower(strings.TrimSpace(header))]; ok {
+			if _, exists := columns[column]; !exists {
+				columns[column] = i
+			}
+		}
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("File must contain a name column")
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, errors.New("File must contain an email column")
+	}
+	if len(records)-1 > chainImportMaxRows {
+		return nil, fmt.Errorf("File contains more than %d rows", chainImportMaxRows)
+	}
+
+	cell := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := []sharedtypes.ChainImportUserRow{}
+	emails := map[string]bool{}
+	for i, record := range records[1:] {
+		if lo.EveryBy(record, func(s string) bool { return strings.TrimSpace(s) == "" }) {
+			continue
+		}
+		row := sharedtypes.ChainImportUserRow{
+			Row:         i + 2,
+			Name:        cell(record, "name"),
+			Email:       cell(record, "email"),
+			PhoneNumber: cell(record, "phone"),
+			Address:     cell(record, "address"),
+			Sizes: lo.Uniq(strings.FieldsFunc(strings.ToUpper(cell(record, "sizes")), func(r rune) bool {
+				return r == ',' || r == ';' || r == ' ' || r == '/'
+			})),
+			Status: sharedtypes.ChainImportUserStatusNew,
+		}
+
+		if row.Name == "" {
+			row.Status = sharedtypes.ChainImportUserStatusInvalid
+			row.Error = "Name is required"
+		} else if err := validate.Var(row.Email, "required,email"); err != nil {
+			row.Status = sharedtypes.ChainImportUserStatusInvalid
+			row.Error = "Email is invalid"
+		} else if !models.ValidateAllSizeEnum(row.Sizes) {
+			row.Status = sharedtypes.ChainImportUserStatusInvalid
+			row.Error = models.ErrSizeInvalid.Error()
+		} else if emails[strings.ToLower(row.Email)] {
+			row.Status = sharedtypes.ChainImportUserStatusDuplicate
+			row.Error = "Email is listed more than once"
+		}
+		emails[strings.ToLower(row.Email)] = true
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestChainImportReadFileCsv(t *testing.T) {
+	records, err := chainImportReadFile("csv", []byte("\xef\xbb\xbfName;Email;Sizes\nJane;jane@example.com;\"1,2\"\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"Name", "Email", "Sizes"},
+		{"Jane", "jane@example.com", "1,2"},
+	}, records)
+
+	records, err = chainImportReadFile("csv", []byte("email,name\njane@example.com,Jane\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"jane@example.com", "Jane"}, records[1])
+}
+
+func TestChainImportParseRows(t *testing.T) {
+	t.Run("Required columns", func(t *testing.T) {
+		_, err := chainImportParseRows([][]string{{"Name", "Phone"}})
+		assert.Error(t, err)
+		_, err = chainImportParseRows([][]string{})
+		assert.Error(t, err)
+	})
+
+	rows, err := chainImportParseRows([][]string{
+		{" E-mail ", "Name", "Phone number", "Address", "Size"},
+		{"jane@example.com", "Jane", "0612345678", "Dam 1", "1, b"},
+		{},
+		{"not an email", "John", "", "", ""},
+		{"sam@example.com", "", "", "", ""},
+		{"JANE@example.com", "Jane again", "", "", ""},
+		{"kim@example.com", "Kim", "", "", "XXL"},
+		{"lou@example.com", "Lou"},
+	})
+	assert.NoError(t, err)
+	if !assert.Len(t, rows, 6) {
+		return
+	}
+
+	assert.Equal(t, sharedtypes.ChainImportUserRow{
+		Row:         2,
+		Name:        "Jane",
+		Email:       "jane@example.com",
+		PhoneNumber: "0612345678",
+		Address:     "Dam 1",
+		Sizes:       []string{"1", "B"},
+		Status:      sharedtypes.ChainImportUserStatusNew,
+	}, rows[0])
+	assert.Equal(t, 4, rows[1].Row, "empty rows are skipped but counted")
+	assert.Equal(t, sharedtypes.ChainImportUserStatusInvalid, rows[1].Status)
+	assert.Equal(t, sharedtypes.ChainImportUserStatusInvalid, rows[2].Status, "name is required")
+	assert.Equal(t, sharedtypes.ChainImportUserStatusDuplicate, rows[3].Status)
+	assert.Equal(t, sharedtypes.ChainImportUserStatusInvalid, rows[4].Status, "sizes must be valid")
+	assert.Equal(t, sharedtypes.ChainImportUserStatusNew, rows[5].Status, "missing trailing cells are empty")
+}
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/pkg/qrcode"
+	"github.com/the-clothing-loop/website/server/pkg/tsp"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func chainInviteUrl(token string) string {
+	return fmt.Sprintf("%s/loops/join/?invite=%s", app.Config.SITE_BASE_URL_FE, url.QueryEscape(token))
+}
+
+func ChainInviteCreate(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.ChainInviteCreateRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.ExpiresAt != nil && !body.ExpiresAt.After(time.Now()) {
+		c.String(http.StatusBadRequest, "Expiry date must be in the future")
+		return
+	}
+
+	ok, authUser, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	invite := &sharedtypes.ChainInvite{
+		ChainID:         chain.ID,
+		ChainUID:        chain.UID,
+		CreatedByUserID: authUser.ID,
+		ExpiresAt:       body.ExpiresAt,
+		MaxUses:         body.MaxUses,
+		AutoApprove:     body.AutoApprove,
+	}
+	if err := models.ChainInviteCreate(db, invite); err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to create invite")
+		return
+	}
+	invite.CreatedByUserUID = authUser.UID
+	invite.Url = chainInviteUrl(invite.Token)
+	invite.Uses = []sharedtypes.ChainInviteUse{}
+
+	c.JSON(http.StatusOK, invite)
+}
+
+func ChainInviteGetAll(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	invites, err := models.ChainInviteGetAllByChain(db, chain.ID)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve invites of loop")
+		return
+	}
+	for i := range invites {
+		invites[i].Url = chainInviteUrl(invites[i].Token)
+	}
+	c.JSON(http.StatusOK, invites)
+}
+
+// Revoked invites are kept so that hosts can still see who joined with them
+func ChainInviteRevoke(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+		Token    string `form:"token" binding:"required,max=64"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	err := models.ChainInviteRevoke(db, chain.ID, query.Token)
+	if err != nil {
+		if errors.Is(err, models.ErrChainInviteNotFound) {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to revoke invite")
+		return
+	}
+}
+
+// Renders the invite link as a QR code to print on posters
+func ChainInviteQRCode(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+		Token    string `form:"token" binding:"required,max=64"`
+		Scale    int    `form:"scale" binding:"omitempty,min=1,max=40"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if query.Scale == 0 {
+		query.Scale = 10
+	}
+
+	ok, _, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	invite, err := models.ChainInviteGetByToken(db, query.Token)
+	if err != nil || invite.ChainID != chain.ID {
+		c.String(http.StatusNotFound, models.ErrChainInviteNotFound.Error())
+		return
+	}
+
+	code, err := qrcode.Encode(chainInviteUrl(invite.Token))
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to create QR code")
+		return
+	}
+	buf := &bytes.Buffer{}
+	if err := code.PNG(buf, query.Scale); err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to create QR code")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s-invite.png"`, chainExportFilename(chain.Name)))
+	c.Data(http.StatusOK, "image/png", buf.Bytes())
+}
+
+// Joins the loop of the invite, members that are already part of the loop do not use up the invite
+func ChainInviteJoin(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.ChainInviteJoinRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, authUser, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	if !ok {
+		return
+	}
+	if err := authUser.AddUserChainsToObject(db); err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, models.ErrAddUserChainsToObject.Error())
+		return
+	}
+
+	invite, err := models.ChainInviteGetByToken(db, body.Token)
+	if err != nil {
+		if errors.Is(err, models.ErrChainInviteNotFound) {
+			c.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to find invite")
+		return
+	}
+	chain, err := models.ChainGetByUID(db, invite.ChainUID)
+	if err != nil {
+		c.String(http.StatusNotFound, models.ErrChainInviteNotFound.Error())
+		return
+	}
+
+	if isMember, isApproved := chainInviteMembership(authUser, chain.UID); isMember && (isApproved || !invite.AutoApprove) {
+		c.JSON(http.StatusOK, sharedtypes.ChainInviteJoinResponse{ChainUID: chain.UID, IsApproved: isApproved})
+		return
+	}
+	if err := models.ChainInviteValidate(invite); err != nil {
+		c.String(http.StatusGone, err.Error())
+		return
+	}
+
+	err = models.ChainInviteUse(db, invite, authUser.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrChainInviteUsedUp) {
+			c.String(http.StatusGone, err.Error())
+			return
+		}
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "User could not be added to chain")
+		return
+	}
+
+	if invite.AutoApprove {
+		cities := retrieveChainUsersAsTspCities(db, chain.ID)
+		newRoute, _ := tsp.RunAddOptimalOrderNewCity(cities.ToTspCities(), authUser.UID)
+		chain.SetRouteOrderByUserUIDs(db, newRoute)
+	}
+	err = services.EmailLoopAdminsOnUserJoin(db, authUser, chain.ID)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to send email to associated loop admins")
+		return
+	}
+	services.EmailYouSignedUpForLoop(db, authUser, chain.Name)
+
+	c.JSON(http.StatusOK, sharedtypes.ChainInviteJoinResponse{ChainUID: chain.UID, IsApproved: invite.AutoApprove})
+}
+
+func chainInviteMembership(user *models.User, chainUID string) (isMember, isApproved bool) {
+	for _, uc := range user.Chains {
+		if uc.ChainUID == chainUID {
+			return true, uc.IsApproved
+		}
+	}
+	return false, false
+}
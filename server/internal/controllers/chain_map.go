@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+const (
+	// clusters are roughly a quarter of a 256 pixel map tile wide
+	chainMapCellsPerTile = 4
+	// from this zoom level onwards every loop is shown on its own
+	chainMapMaxClusterZoom = 13
+)
+
+type chainMapPoint struct {
+	UID       string   `gorm:"uid"`
+	Name      string   `gorm:"name"`
+	Latitude  float64  `gorm:"latitude"`
+	Longitude float64  `gorm:"longitude"`
+	Radius    float32  `gorm:"radius"`
+	Genders   []string `gorm:"genders;serializer:json"`
+}
+
+// Returns the loops on the map inside the bounding box, grouped into clusters for the zoom level.
+//
+// All map points are cached for a few minutes, so clients only download what is visible
+// while the database is not queried for every pan and zoom.
+func ChainGetMap(c *gin.Context) {
+	db := getDB(c)
+
+	var query sharedtypes.ChainMapQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if *query.MaxLatitude < *query.MinLatitude {
+		c.String(http.StatusBadRequest, "Maximum latitude must be larger than minimum latitude")
+		return
+	}
+
+	points, err := app.CacheFindOrUpdate("chain_map", cache.DefaultExpiration, func() (*[]chainMapPoint, error) {
+		points := []chainMapPoint{}
+		err := db.Raw(`
+SELECT uid, name, latitude, longitude, radius, genders
+FROM chains
+WHERE published = TRUE AND allow_map = TRUE AND deleted_at IS NULL
+		`).Scan(&points).Error
+		if err != nil {
+			return nil, err
+		}
+		return &points, nil
+	})
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve loops for the map")
+		return
+	}
+
+	c.JSON(http.StatusOK, sharedtypes.ChainMapResponse{
+		Zoom:     query.Zoom,
+		Clusters: chainMapClusters(*points, query),
+	})
+}
+
+// Groups the points inside the bounding box by the grid cell they fall in,
+// the cells halve in size with every zoom level like map tiles do
+func chainMapClusters(points []chainMapPoint, query sharedtypes.ChainMapQuery) []sharedtypes.ChainMapCluster {
+	cellSize := 360 / (math.Exp2(float64(query.Zoom)) * chainMapCellsPerTile)
+
+	type cellKey struct{ x, y int }
+	cells := map[cellKey][]chainMapPoint{}
+	for i, p := range points {
+		if !chainMapIsInside(p, query) {
+			continue
+		}
+		key := cellKey{int(math.Floor(p.Longitude / cellSize)), int(math.Floor(p.Latitude / cellSize))}
+		if query.Zoom >= chainMapMaxClusterZoom {
+			// a unique key per loop
+			key = cellKey{i, math.MinInt}
+		}
+		cells[key] = append(cells[key], p)
+	}
+
+	clusters := make([]sharedtypes.ChainMapCluster, 0, len(cells))
+	for _, cell := range cells {
+		cluster := sharedtypes.ChainMapCluster{
+			Count:        len(cell),
+			MinLatitude:  cell[0].Latitude,
+			MaxLatitude:  cell[0].Latitude,
+			MinLongitude: cell[0].Longitude,
+			MaxLongitude: cell[0].Longitude,
+		}
+		for _, p := range cell {
+			cluster.Latitude += p.Latitude / float64(len(cell))
+			cluster.Longitude += p.Longitude / float64(len(cell))
+			cluster.MinLatitude = math.Min(cluster.MinLatitude, p.Latitude)
+			cluster.MaxLatitude = math.Max(cluster.MaxLatitude, p.Latitude)
+			cluster.MinLongitude = math.Min(cluster.MinLongitude, p.Longitude)
+			cluster.MaxLongitude = math.Max(cluster.MaxLongitude, p.Longitude)
+		}
+		if len(cell) == 1 {
+			cluster.ChainUID = cell[0].UID
+			cluster.Name = cell[0].Name
+			cluster.Radius = cell[0].Radius
+			cluster.Genders = cell[0].Genders
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	// the largest clusters first, the rest in a stable order
+	sort.Slice(clusters, func(i, j int) bool {
+		a, b := clusters[i], clusters[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Latitude != b.Latitude {
+			return a.Latitude < b.Latitude
+		}
+		return a.Longitude < b.Longitude
+	})
+	return clusters
+}
+
+func chainMapIsInside(p chainMapPoint, query sharedtypes.ChainMapQuery) bool {
+	if p.Latitude < *query.MinLatitude || p.Latitude > *query.MaxLatitude {
+		return false
+	}
+	if *query.MinLongitude > *query.MaxLongitude {
+		return p.Longitude >= *query.MinLongitude || p.Longitude <= *query.MaxLongitude
+	}
+	return p.Longitude >= *query.MinLongitude && p.Longitude <= *query.MaxLongitude
+}
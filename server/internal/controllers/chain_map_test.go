@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestChainMapClusters(t *testing.T) {
+	points := []chainMapPoint{
+		{UID: "amsterdam-1", Latitude: 52.37, Longitude: 4.89},
+		{UID: "amsterdam-2", Latitude: 52.36, Longitude: 4.90},
+		{UID: "utrecht", Latitude: 52.09, Longitude: 5.12, Name: "Utrecht", Radius: 3, Genders: []string{"1"}},
+		{UID: "auckland", Latitude: -36.85, Longitude: 174.76},
+		{UID: "fiji", Latitude: -17.71, Longitude: -178.06},
+	}
+	bbox := func(minLat, maxLat, minLong, maxLong float64, zoom int) sharedtypes.ChainMapQuery {
+		return sharedtypes.ChainMapQuery{
+			MinLatitude:  &minLat,
+			MaxLatitude:  &maxLat,
+			MinLongitude: &minLong,
+			MaxLongitude: &maxLong,
+			Zoom:         zoom,
+		}
+	}
+	counts := func(clusters []sharedtypes.ChainMapCluster) []int {
+		return lo.Map(clusters, func(c sharedtypes.ChainMapCluster, _ int) int { return c.Count })
+	}
+
+	t.Run("World", func(t *testing.T) {
+		clusters := chainMapClusters(points, bbox(-90, 90, -180, 180, 0))
+		assert.Equal(t, []int{3, 1, 1}, counts(clusters))
+		assert.Empty(t, clusters[0].ChainUID)
+		assert.Equal(t, 52.09, clusters[0].MinLatitude)
+		assert.Equal(t, 52.37, clusters[0].MaxLatitude)
+		assert.InDelta(t, (52.37+52.36+52.09)/3, clusters[0].Latitude, 0.0001)
+	})
+
+	t.Run("Zoomed in on the netherlands", func(t *testing.T) {
+		clusters := chainMapClusters(points, bbox(50, 54, 3, 8, 8))
+		assert.Equal(t, []int{2, 1}, counts(clusters))
+		assert.Equal(t, "utrecht", clusters[1].ChainUID)
+		assert.Equal(t, "Utrecht", clusters[1].Name)
+		assert.Equal(t, []string{"1"}, clusters[1].Genders)
+	})
+
+	t.Run("Every loop on its own", func(t *testing.T) {
+		clusters := chainMapClusters(points, bbox(52.3, 52.4, 4.8, 5, chainMapMaxClusterZoom))
+		assert.Equal(t, []int{1, 1}, counts(clusters))
+	})
+
+	t.Run("Across the antimeridian", func(t *testing.T) {
+		clusters := chainMapClusters(points, bbox(-50, 0, 170, -170, 3))
+		assert.ElementsMatch(t, []string{"auckland", "fiji"}, lo.Map(clusters, func(c sharedtypes.ChainMapCluster, _ int) string { return c.ChainUID }))
+	})
+}
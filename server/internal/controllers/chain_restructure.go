@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	uuid "github.com/satori/go.uuid"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+// Moves all members and content of one loop into another, the emptied loop is removed
+func ChainMerge(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.ChainMergeRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, authUser, fromChain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, body.FromChainUID)
+	if !ok {
+		return
+	}
+	if !authUser.IsRootAdmin {
+		_, isChainAdmin := authUser.IsPartOfChain(body.ToChainUID)
+		if !isChainAdmin {
+			c.String(http.StatusUnauthorized, "you must be a host of both loops")
+			return
+		}
+	}
+	toChain, err := models.ChainGetByUID(db, body.ToChainUID)
+	if err != nil {
+		c.String(http.StatusBadRequest, models.ErrChainNotFound.Error())
+		return
+	}
+	// finished authentication
+
+	users, err := fromChain.GetUserContactData(db)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to find loop members to notify")
+		return
+	}
+
+	restructure, err := models.ChainMerge(db, fromChain, toChain, authUser.ID)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to merge loops")
+		return
+	}
+
+	for _, user := range users {
+		if user.IsApproved && user.Email.Valid {
+			views.EmailLoopMoved(db, user.I18n, user.Name, user.Email.String, fromChain.Name, toChain.Name, true)
+		}
+	}
+
+	c.JSON(http.StatusOK, restructure)
+}
+
+// Moves a selection of members, with their bags and bulky items, into a new loop with its own hosts
+func ChainSplit(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.ChainSplitRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if !lo.Every(body.UserUIDs, body.HostUIDs) {
+		c.String(http.StatusBadRequest, "Hosts of the new loop must be part of the selected members")
+		return
+	}
+
+	ok, authUser, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	userUIDs := lo.Uniq(body.UserUIDs)
+	members, err := models.UserChainGetBatchMembers(db, chain.ID, userUIDs)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to find loop members")
+		return
+	}
+	if len(members) != len(userUIDs) {
+		c.String(http.StatusBadRequest, "All selected users must be members of this loop")
+		return
+	}
+	hostUserIDs := []uint{}
+	for _, m := range members {
+		if !lo.Contains(body.HostUIDs, m.UserUID) {
+			continue
+		}
+		if !m.IsApproved {
+			c.String(http.StatusBadRequest, "Hosts of the new loop must be approved members")
+			return
+		}
+		hostUserIDs = append(hostUserIDs, m.UserID)
+	}
+
+	newChain := &models.Chain{
+		UID:              uuid.NewV4().String(),
+		Name:             body.Name,
+		Description:      chain.Description,
+		Address:          chain.Address,
+		CountryCode:      chain.CountryCode,
+		Latitude:         chain.Latitude,
+		Longitude:        chain.Longitude,
+		Radius:           chain.Radius,
+		Published:        chain.Published,
+		OpenToNewMembers: chain.OpenToNewMembers,
+		AllowMap:         chain.AllowMap,
+		Sizes:            chain.Sizes,
+		Genders:          chain.Genders,
+		Theme:            chain.Theme,
+		RoutePrivacy:     chain.RoutePrivacy,
+	}
+	userIDs := lo.Map(members, func(m models.UserChainBatchMember, _ int) uint { return m.UserID })
+	_, err = models.ChainSplit(db, chain, newChain, userIDs, hostUserIDs, authUser.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrChainSplitNoHostsLeft) {
+			c.String(http.StatusConflict, err.Error())
+			return
+		}
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to split loop")
+		return
+	}
+
+	for _, m := range members {
+		if m.IsApproved && m.Email.Valid {
+			views.EmailLoopMoved(db, m.I18n, m.Name, m.Email.String, chain.Name, newChain.Name, false)
+		}
+	}
+
+	c.JSON(http.StatusOK, sharedtypes.ChainSplitResponse{ChainUID: newChain.UID})
+}
+
+func ChainRestructureGetAll(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	restructures, err := models.ChainRestructureGetAllByChain(db, chain.ID)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve merges and splits of loop")
+		return
+	}
+	c.JSON(http.StatusOK, restructures)
+}
@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/pkg/cursor"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+// words shorter than this are not indexed by the full-text index
+const chainSearchMinWordLength = 3
+
+// default stopwords of the InnoDB full-text index, requiring them would never match
+var chainSearchStopwords = map[string]bool{
+	"about": true, "an": true, "are": true, "as": true, "at": true, "be": true, "by": true, "com": true,
+	"de": true, "en": true, "for": true, "from": true, "how": true, "in": true, "is": true, "it": true,
+	"la": true, "of": true, "on": true, "or": true, "that": true, "the": true, "this": true, "to": true,
+	"was": true, "what": true, "when": true, "where": true, "who": true, "will": true, "with": true,
+	"und": true, "www": true,
+}
+
+const chainSearchMembersSql = `(
+	SELECT COUNT(uc.id) FROM user_chains AS uc
+	WHERE uc.chain_id = chains.id AND uc.is_approved = TRUE
+)`
+
+// Searches published loops by text, sizes, genders, location and number of members.
+//
+// Results are sorted by distance when a location is given, otherwise by relevance to the text or by name.
+func ChainSearch(c *gin.Context) {
+	db := getDB(c)
+
+	var query sharedtypes.ChainSearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if ok := models.ValidateAllSizeEnum(query.FilterSizes); !ok {
+		c.String(http.StatusBadRequest, models.ErrSizeInvalid.Error())
+		return
+	}
+	if ok := models.ValidateAllGenderEnum(query.FilterGenders); !ok {
+		c.String(http.StatusBadRequest, models.ErrGenderInvalid.Error())
+		return
+	}
+	if query.MaxMembers != 0 && query.MaxMembers < query.MinMembers {
+		c.String(http.StatusBadRequest, "Maximum members must be larger than minimum members")
+		return
+	}
+	if query.Radius != 0 && query.Latitude == nil {
+		c.String(http.StatusBadRequest, "Radius requires a location")
+		return
+	}
+	ok, cur := paginationDecodeCursor(c, query.PaginationQuery)
+	if !ok {
+		return
+	}
+	limit := paginationLimit(query.PaginationQuery)
+
+	whereSql, whereArgs := chainSearchWhere(query)
+
+	sortBy := "name"
+	selectSql := fmt.Sprintf("%s, %s AS total_members", models.ChainResponseSQLSelect, chainSearchMembersSql)
+	selectArgs := []any{}
+	distanceSql := sqlCalcDistance("chains.latitude", "chains.longitude", "?", "?")
+	relevanceSql := "MATCH(chains.name, chains.description) AGAINST (? IN BOOLEAN MODE)"
+	booleanQuery := chainSearchBooleanQuery(query.Q)
+	if query.Latitude != nil {
+		sortBy = "distance"
+		selectSql = fmt.Sprintf("%s, %s AS distance", selectSql, distanceSql)
+		selectArgs = append(selectArgs, *query.Latitude, *query.Longitude)
+	}
+	if booleanQuery != "" {
+		if sortBy == "name" {
+			sortBy = "relevance"
+		}
+		selectSql = fmt.Sprintf("%s, %s AS relevance", selectSql, relevanceSql)
+		selectArgs = append(selectArgs, booleanQuery)
+	}
+
+	cursorSql := ""
+	cursorArgs := []any{}
+	if cur != nil {
+		var value any = cur.Value
+		if sortBy != "name" {
+			v, err := strconv.ParseFloat(cur.Value, 64)
+			if err != nil {
+				c.String(http.StatusBadRequest, cursor.ErrInvalid.Error())
+				return
+			}
+			value = v
+		}
+		switch sortBy {
+		case "distance":
+			cursorSql = fmt.Sprintf(" AND (%s > ? OR (%s = ? AND chains.id > ?))", distanceSql, distanceSql)
+			cursorArgs = append(cursorArgs, *query.Latitude, *query.Longitude, value, *query.Latitude, *query.Longitude, value, cur.ID)
+		case "relevance":
+			cursorSql = fmt.Sprintf(" AND (%s < ? OR (%s = ? AND chains.id > ?))", relevanceSql, relevanceSql)
+			cursorArgs = append(cursorArgs, booleanQuery, value, booleanQuery, value, cur.ID)
+		default:
+			cursorSql = " AND (chains.name > ? OR (chains.name = ? AND chains.id > ?))"
+			cursorArgs = append(cursorArgs, value, value, cur.ID)
+		}
+	}
+	orderSql := map[string]string{
+		"distance":  "distance ASC, chains.id ASC",
+		"relevance": "relevance DESC, chains.id ASC",
+		"name":      "chains.name ASC, chains.id ASC",
+	}[sortBy]
+
+	items := []sharedtypes.ChainSearchResult{}
+	err := db.Raw(
+		fmt.Sprintf("%s FROM chains WHERE %s%s ORDER BY %s LIMIT ?", selectSql, whereSql, cursorSql, orderSql),
+		append(append(append(selectArgs, whereArgs...), cursorArgs...), limit+1)...,
+	).Scan(&items).Error
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to search loops")
+		return
+	}
+
+	res := sharedtypes.ChainSearchResponse{
+		Facets: sharedtypes.ChainSearchFacets{Sizes: map[string]int{}, Genders: map[string]int{}},
+	}
+	err = db.Raw(fmt.Sprintf(`SELECT COUNT(*) FROM chains WHERE %s`, whereSql), whereArgs...).Scan(&res.Total).Error
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to search loops")
+		return
+	}
+	facets := []struct {
+		Facet string `gorm:"facet"`
+		Value string `gorm:"value"`
+		Count int    `gorm:"count"`
+	}{}
+	err = db.Raw(fmt.Sprintf(`
+SELECT cf.facet, cf.value, COUNT(DISTINCT chains.id) AS count
+FROM chain_facets AS cf
+JOIN chains ON chains.id = cf.chain_id
+WHERE %s
+GROUP BY cf.facet, cf.value
+	`, whereSql), whereArgs...).Scan(&facets).Error
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to search loops")
+		return
+	}
+	for _, f := range facets {
+		switch f.Facet {
+		case sharedtypes.ChainFacetSize:
+			res.Facets.Sizes[f.Value] = f.Count
+		case sharedtypes.ChainFacetGender:
+			res.Facets.Genders[f.Value] = f.Count
+		}
+	}
+
+	page := paginationTrim(items, limit, func(item sharedtypes.ChainSearchResult) cursor.Cursor {
+		switch sortBy {
+		case "distance":
+			return cursor.Cursor{ID: item.ID, Value: strconv.FormatFloat(*item.Distance, 'g', -1, 64)}
+		case "relevance":
+			return cursor.Cursor{ID: item.ID, Value: strconv.FormatFloat(item.Relevance, 'g', -1, 64)}
+		default:
+			return cursor.Cursor{ID: item.ID, Value: item.Name}
+		}
+	})
+	res.Items = page.Items
+	res.NextCursor = page.NextCursor
+
+	c.JSON(http.StatusOK, res)
+}
+
+// Conditions shared by the results, the total and the facet counts
+func chainSearchWhere(query sharedtypes.ChainSearchQuery) (string, []any) {
+	whereSql := []string{"chains.published = TRUE", "chains.deleted_at IS NULL"}
+	args := []any{}
+
+	if booleanQuery := chainSearchBooleanQuery(query.Q); booleanQuery != "" {
+		whereSql = append(whereSql, "MATCH(chains.name, chains.description) AGAINST (? IN BOOLEAN MODE)")
+		args = append(args, booleanQuery)
+	} else if q := strings.TrimSpace(query.Q); q != "" {
+		// the text only has words that are not part of the full-text index
+		whereSql = append(whereSql, "chains.name LIKE ?")
+		args = append(args, "%"+chainSearchEscapeLike(q)+"%")
+	}
+
+	for _, facet := range []struct {
+		name   string
+		values []string
+	}{
+		{sharedtypes.ChainFacetSize, lo.Uniq(query.FilterSizes)},
+		{sharedtypes.ChainFacetGender, lo.Uniq(query.FilterGenders)},
+	} {
+		if len(facet.values) == 0 {
+			continue
+		}
+		// loops must have every selected value
+		whereSql = append(whereSql, `chains.id IN (
+	SELECT cf_filter.chain_id FROM chain_facets AS cf_filter
+	WHERE cf_filter.facet = ? AND cf_filter.value IN ?
+	GROUP BY cf_filter.chain_id
+	HAVING COUNT(DISTINCT cf_filter.value) = ?
+)`)
+		args = append(args, facet.name, facet.values, len(facet.values))
+	}
+
+	if query.Latitude != nil && query.Radius != 0 {
+		// the bounding box uses the location index before the exact distance is calculated,
+		// sqlCalcDistance uses degrees of equal length in both directions
+		degrees := query.Radius / 111.195
+		whereSql = append(whereSql,
+			"chains.latitude BETWEEN ? AND ?",
+			"chains.longitude BETWEEN ? AND ?",
+			fmt.Sprintf("%s <= ?", sqlCalcDistance("chains.latitude", "chains.longitude", "?", "?")),
+		)
+		args = append(args,
+			*query.Latitude-degrees, *query.Latitude+degrees,
+			*query.Longitude-degrees, *query.Longitude+degrees,
+			*query.Latitude, *query.Longitude, query.Radius,
+		)
+	}
+
+	if query.OpenToNewMembers {
+		whereSql = append(whereSql, "chains.open_to_new_members = TRUE")
+	}
+	if query.MinMembers > 0 {
+		whereSql = append(whereSql, chainSearchMembersSql+" >= ?")
+		args = append(args, query.MinMembers)
+	}
+	if query.MaxMembers > 0 {
+		whereSql = append(whereSql, chainSearchMembersSql+" <= ?")
+		args = append(args, query.MaxMembers)
+	}
+
+	return strings.Join(whereSql, " AND "), args
+}
+
+// Turns the search text into a boolean mode full-text query where every word is required
+// and may be the start of a longer word, words that are not indexed are left out
+func chainSearchBooleanQuery(q string) string {
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	terms := []string{}
+	for _, word := range lo.Uniq(words) {
+		if utf8.RuneCountInString(word) < chainSearchMinWordLength || chainSearchStopwords[word] {
+			continue
+		}
+		terms = append(terms, "+"+word+"*")
+	}
+	return strings.Join(terms, " ")
+}
+
+func chainSearchEscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestChainSearchBooleanQuery(t *testing.T) {
+	assert.Equal(t, "+amsterdam* +noord*", chainSearchBooleanQuery("Amsterdam-Noord"))
+	assert.Equal(t, "+kleding* +ruil*", chainSearchBooleanQuery(`"kleding" ruil* ruil +(the)`))
+	assert.Equal(t, "+zoë*", chainSearchBooleanQuery("Zoë's"))
+	assert.Equal(t, "", chainSearchBooleanQuery("a EU of"))
+	assert.Equal(t, "", chainSearchBooleanQuery(""))
+}
+
+func TestChainSearchWhere(t *testing.T) {
+	latitude, longitude := 52.37, 4.89
+	tests := []struct {
+		name     string
+		query    sharedtypes.ChainSearchQuery
+		contains []string
+	}{
+		{"Only published", sharedtypes.ChainSearchQuery{}, []string{"chains.published = TRUE"}},
+		{"Full-text", sharedtypes.ChainSearchQuery{Q: "Utrecht"}, []string{"MATCH(chains.name, chains.description)"}},
+		{"Short words", sharedtypes.ChainSearchQuery{Q: "EU"}, []string{"chains.name LIKE ?"}},
+		{"Facets", sharedtypes.ChainSearchQuery{FilterSizes: []string{"1", "2", "1"}, FilterGenders: []string{"2"}}, []string{"cf_filter.facet = ?"}},
+		{"Location", sharedtypes.ChainSearchQuery{Latitude: &latitude, Longitude: &longitude, Radius: 10}, []string{"chains.latitude BETWEEN ? AND ?", "ST_Distance"}},
+		{"Members", sharedtypes.ChainSearchQuery{OpenToNewMembers: true, MinMembers: 5, MaxMembers: 20}, []string{"open_to_new_members = TRUE", ">= ?", "<= ?"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sql, args := chainSearchWhere(test.query)
+			for _, s := range test.contains {
+				assert.Contains(t, sql, s)
+			}
+			assert.Equal(t, strings.Count(sql, "?"), len(args))
+		})
+	}
+
+	_, args := chainSearchWhere(sharedtypes.ChainSearchQuery{FilterSizes: []string{"1", "2", "1"}})
+	assert.Equal(t, []any{sharedtypes.ChainFacetSize, []string{"1", "2"}, 2}, args)
+}
@@ -4,16 +4,17 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/OneSignal/onesignal-go-api"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/samber/lo"
-	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/app/auth"
 	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
 	"github.com/the-clothing-loop/website/server/internal/views"
+	"github.com/the-clothing-loop/website/server/pkg/cursor"
 	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
 	"github.com/the-clothing-loop/website/server/sharedtypes"
 	"gorm.io/gorm"
@@ -161,6 +162,12 @@ func ChatChannelDelete(c *gin.Context) {
 			return err
 		}
 
+		err = tx.Exec("DELETE FROM chat_channel_reads WHERE chat_channel_id = ?", body.ChatChannelID).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
 		err = tx.Exec("DELETE FROM chat_channels WHERE id = ?", body.ChatChannelID).Error
 		if err != nil {
 			tx.Rollback()
@@ -183,31 +190,78 @@ func ChatChannelMessageList(c *gin.Context) {
 		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
-	ok, _, chain := auth.Authenticate(c, db, auth.AuthState2UserOfChain, body.ChainUID)
+	ok, authUser, chain := auth.Authenticate(c, db, auth.AuthState2UserOfChain, body.ChainUID)
 	if !ok {
 		return
 	}
 
-	chatChannelMessageList := []sharedtypes.ChatMessage{}
-	err := db.Debug().Raw(`
+	res := sharedtypes.ChatChannelMessageListResponse{}
+	var err error
+	isNewest := body.Page == 0
+	if paginationIsSet(body.PaginationQuery) {
+		ok, cur := paginationDecodeCursor(c, body.PaginationQuery)
+		if !ok {
+			return
+		}
+		isNewest = cur == nil
+		limit := paginationLimit(body.PaginationQuery)
+
+		sql := `
+SELECT msg.* FROM chat_messages msg
+JOIN chat_channels channel ON channel.id = msg.chat_channel_id AND channel.id = ? AND channel.chain_id = ?
+`
+		args := []any{body.ChatChannelID, chain.ID}
+		if cur != nil {
+			createdAt, err := strconv.ParseInt(cur.Value, 10, 64)
+			if err != nil {
+				c.String(http.StatusBadRequest, cursor.ErrInvalid.Error())
+				return
+			}
+			sql += `WHERE msg.created_at < ? OR (msg.created_at = ? AND msg.id < ?)
+`
+			args = append(args, createdAt, createdAt, cur.ID)
+		}
+		sql += `ORDER BY msg.created_at DESC, msg.id DESC
+LIMIT ?`
+		args = append(args, limit+1)
+
+		list := []sharedtypes.ChatMessage{}
+		err = db.Raw(sql, args...).Scan(&list).Error
+		page := paginationTrim(list, limit, func(m sharedtypes.ChatMessage) cursor.Cursor {
+			return cursor.Cursor{ID: m.ID, Value: strconv.FormatInt(m.CreatedAt, 10)}
+		})
+		res.Messages = page.Items
+		res.NextCursor = page.NextCursor
+	} else {
+		res.Messages = []sharedtypes.ChatMessage{}
+		err = db.Raw(`
 SELECT msg.* FROM chat_messages msg
 JOIN chat_channels channel ON channel.id = msg.chat_channel_id AND channel.id = ? AND channel.chain_id = ?
 WHERE msg.created_at < ?
 ORDER BY msg.created_at DESC
 LIMIT ?, 20 
-`, body.ChatChannelID, chain.ID, body.StartFrom, body.Page*20).Scan(&chatChannelMessageList).Error
+`, body.ChatChannelID, chain.ID, body.StartFrom, body.Page*20).Scan(&res.Messages).Error
+	}
 	if err != nil {
 		c.String(http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	for i, v := range chatChannelMessageList {
+	for i, v := range res.Messages {
 		if v.DeletedAt != nil {
-			chatChannelMessageList[i].Message = "__DELETED__"
+			res.Messages[i].Message = "__DELETED__"
 		}
 	}
 
-	c.JSON(http.StatusOK, sharedtypes.ChatChannelMessageListResponse{Messages: chatChannelMessageList})
+	// loading the newest messages counts as reading the room, for the unread count in the weekly digest
+	if isNewest && len(res.Messages) > 0 {
+		err = models.ChatChannelReadSet(db, authUser.ID, body.ChatChannelID, res.Messages[0].CreatedAt)
+		if err != nil {
+			slog.Error("Unable to mark chat messages as read", "err", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, res)
 }
 
 func ChatChannelMessagePinToggle(c *gin.Context) {
@@ -253,13 +307,23 @@ func ChatChannelMessageCreate(c *gin.Context) {
 		return
 	}
 
+	isMuted, err := models.UserChainIsChatMuted(db, authUser.ID, chain.ID)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to check chat permissions")
+		return
+	}
+	if isMuted {
+		c.String(http.StatusForbidden, models.ErrChatUserMuted.Error())
+		return
+	}
+
 	chatMessage := sharedtypes.ChatMessage{
 		Message:       body.Message,
 		SendByUID:     authUser.UID,
 		ChatChannelID: body.ChatChannelID,
 		CreatedAt:     time.Now().UnixMilli(),
 	}
-	err := db.Save(&chatMessage).Error
+	err = db.Save(&chatMessage).Error
 	if err != nil {
 		c.String(http.StatusInternalServerError, err.Error())
 		return
@@ -275,9 +339,7 @@ func ChatChannelMessageCreate(c *gin.Context) {
 		return uid != authUser.UID
 	})
 	notificationMessage := lo.Ellipsis(body.Message, 10)
-	err = app.OneSignalCreateNotification(db, userUIDs, *views.Notifications[views.NotificationEnumTitleChatMessage], onesignal.StringMap{
-		En: &notificationMessage,
-	})
+	err = services.NotifyPush(db, sharedtypes.NotificationTypeChatMessage, userUIDs, views.NotificationEnumChatMessage, notificationMessage)
 	if err != nil {
 		slog.Error("Unable to send notification", "err", err)
 	}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+const chatModerationDefaultMuteHours = 24
+
+func ChatChannelMessageReport(c *gin.Context) {
+	db := getDB(c)
+	var body sharedtypes.ChatMessageReportRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	ok, authUser, chain := auth.Authenticate(c, db, auth.AuthState2UserOfChain, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	ok = isChatPartOfChain(c, db, chain.ID, body.ChatChannelID)
+	if !ok {
+		return
+	}
+
+	message, err := models.ChatMessageGet(db, body.ChatMessageID, body.ChatChannelID)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to find chat message")
+		return
+	}
+	if message.ID == 0 {
+		c.String(http.StatusNotFound, "Chat message not found")
+		return
+	}
+	if message.SendByUID == authUser.UID {
+		c.String(http.StatusBadRequest, "You can not report your own message")
+		return
+	}
+
+	_, err = models.ChatMessageReportCreate(db, chain.ID, authUser.ID, message, body.Reason, body.Comment)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to report chat message")
+		return
+	}
+}
+
+// Without a chain_uid all reports of all loops are returned, this is only allowed for root admins
+func ChatModerationReportList(c *gin.Context) {
+	db := getDB(c)
+	var query sharedtypes.ChatModerationReportListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	minimumAuthState := lo.Ternary(query.ChainUID == "", auth.AuthState4RootUser, auth.AuthState3AdminChainUser)
+	ok, _, chain := auth.Authenticate(c, db, minimumAuthState, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	reports, err := models.ChatMessageReportGetAll(db, chain.ID, query.Status)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve chat reports")
+		return
+	}
+
+	c.JSON(http.StatusOK, sharedtypes.ChatModerationReportListResponse{Reports: reports})
+}
+
+// Without a chain_uid all actions of all loops are returned, this is only allowed for root admins
+func ChatModerationActionList(c *gin.Context) {
+	db := getDB(c)
+	var query sharedtypes.ChatModerationActionListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	minimumAuthState := lo.Ternary(query.ChainUID == "", auth.AuthState4RootUser, auth.AuthState3AdminChainUser)
+	ok, _, chain := auth.Authenticate(c, db, minimumAuthState, query.ChainUID)
+	if !ok {
+		return
+	}
+
+	actions, err := models.ChatModerationActionGetAll(db, chain.ID)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve chat moderation history")
+		return
+	}
+
+	c.JSON(http.StatusOK, sharedtypes.ChatModerationActionListResponse{Actions: actions})
+}
+
+func ChatModerationActionCreate(c *gin.Context) {
+	db := getDB(c)
+	var body sharedtypes.ChatModerationActionRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	ok, authUser, chain := auth.Authenticate(c, db, auth.AuthState3AdminChainUser, body.ChainUID)
+	if !ok {
+		return
+	}
+
+	var report *sharedtypes.ChatMessageReport
+	targetUID := body.UserUID
+	if body.ReportID != 0 {
+		var err error
+		report, err = models.ChatMessageReportGet(db, body.ReportID, chain.ID)
+		if err != nil {
+			c.String(http.StatusNotFound, "Report not found in this Loop")
+			return
+		}
+		targetUID = report.SendByUID
+	} else if body.Action == sharedtypes.ChatModerationActionDelete || body.Action == sharedtypes.ChatModerationActionDismiss {
+		c.String(http.StatusBadRequest, "A report is required to delete a message or dismiss a report")
+		return
+	}
+
+	target, err := models.UserGetByUID(db, targetUID, false)
+	if err == nil {
+		err = target.AddUserChainsToObject(db)
+	}
+	if err != nil {
+		c.String(http.StatusBadRequest, models.ErrUserNotFound.Error())
+		return
+	}
+	if isMember, _ := target.IsPartOfChain(chain.UID); !isMember {
+		c.String(http.StatusBadRequest, "User is not a member of this loop")
+		return
+	}
+	if target.ID == authUser.ID {
+		c.String(http.StatusBadRequest, "You can not moderate yourself")
+		return
+	}
+
+	action := &sharedtypes.ChatModerationAction{
+		ChainID:         chain.ID,
+		ModeratorUserID: authUser.ID,
+		TargetUserID:    target.ID,
+		Action:          body.Action,
+		Note:            body.Note,
+	}
+	if report != nil {
+		action.ChatMessageReportID = &report.ID
+		action.ChatMessageID = &report.ChatMessageID
+	}
+
+	reportStatus := sharedtypes.ChatReportStatusResolved
+	err = db.Transaction(func(tx *gorm.DB) error {
+		switch body.Action {
+		case sharedtypes.ChatModerationActionDelete:
+			err := tx.Exec(`UPDATE chat_messages SET deleted_at = NOW(), is_pinned = FALSE WHERE id = ? AND chat_channel_id = ?`, report.ChatMessageID, report.ChatChannelID).Error
+			if err != nil {
+				return err
+			}
+		case sharedtypes.ChatModerationActionMute:
+			hours := lo.Ternary(body.MuteHours > 0, body.MuteHours, chatModerationDefaultMuteHours)
+			action.MutedUntil = lo.ToPtr(time.Now().Add(time.Duration(hours) * time.Hour))
+			err := models.UserChainSetChatMutedUntil(tx, target.ID, chain.ID, action.MutedUntil)
+			if err != nil {
+				return err
+			}
+		case sharedtypes.ChatModerationActionUnmute:
+			err := models.UserChainSetChatMutedUntil(tx, target.ID, chain.ID, nil)
+			if err != nil {
+				return err
+			}
+		case sharedtypes.ChatModerationActionDismiss:
+			reportStatus = sharedtypes.ChatReportStatusDismissed
+		case sharedtypes.ChatModerationActionWarn:
+		default:
+			return errors.New("Unknown moderation action")
+		}
+
+		if report != nil {
+			err := models.ChatMessageReportSetStatus(tx, report.ID, reportStatus)
+			if err != nil {
+				return err
+			}
+		}
+
+		return models.ChatModerationActionCreate(tx, action)
+	})
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to apply moderation action")
+		return
+	}
+
+	if body.Action == sharedtypes.ChatModerationActionWarn {
+		services.NotifyPushRequired(db, []string{target.UID},
+			views.NotificationEnumChatWarning,
+			app.OneSignalEllipsis(body.Note))
+	}
+
+	c.JSON(http.StatusOK, action)
+}
@@ -1,10 +1,17 @@
 package controllers
 
 import (
+	"encoding/base64"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strings"
 
+	"github.com/gin-gonic/gin/binding"
+	"github.com/samber/lo"
 	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
 	"github.com/the-clothing-loop/website/server/internal/views"
 	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
 	"github.com/the-clothing-loop/website/server/sharedtypes"
@@ -12,21 +19,19 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// Starts the double opt-in, the subscription is only active once the link in the confirmation email is used
 func ContactNewsletter(c *gin.Context) {
 	db := getDB(c)
 
 	var body sharedtypes.ContactNewsletterRequest
-	if err := c.ShouldBindJSON(&body); err != nil {
+	if err := c.ShouldBindBodyWith(&body, binding.JSON); err != nil {
 		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
 
+	// unsubscribing is only possible with the signed link in the newsletter or from the account settings
 	if !body.Subscribe {
-		db.Raw("DELETE FROM newsletters WHERE email = ?", body.Email)
-		if app.Brevo != nil {
-			app.Brevo.DeleteContact(c.Request.Context(), body.Email)
-		}
-
+		c.String(http.StatusBadRequest, "Use the unsubscribe link in the newsletter to unsubscribe")
 		return
 	}
 
@@ -37,44 +42,141 @@ func ContactNewsletter(c *gin.Context) {
 		name = row.Name
 	}
 
-	n := &models.Newsletter{
-		Name:     name,
-		Email:    body.Email,
-		Verified: true,
+	n, err := models.NewsletterGetByEmail(db, body.Email)
+	if err == nil && n.Verified {
+		c.String(http.StatusAlreadyReported, "Already subscribed")
+		return
 	}
-	err := n.CreateOrUpdate(db)
+
+	token, err := models.NewsletterCreatePending(db, name, body.Email)
 	if err != nil {
 		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to add to newsletter database backup")
 		return
 	}
-	ctx := c.Request.Context()
-	if app.Brevo != nil {
-		if err = app.Brevo.ExistsContact(ctx, body.Email); err == nil {
-			c.String(http.StatusAlreadyReported, "Already subscribed")
-			return
+
+	err = views.EmailConfirmNewsletter(c, db, name, body.Email, token)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to send confirmation email")
+		return
+	}
+}
+
+// Opened from the link in the confirmation email
+func ContactNewsletterConfirm(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		Token string `form:"token" binding:"required,len=64"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	n, err := models.NewsletterConfirm(db, query.Token)
+	if err != nil {
+		if errors.Is(err, models.ErrNewsletterConfirmInvalid) {
+			c.String(http.StatusNotFound, err.Error())
+		} else {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to confirm subscription")
 		}
-		app.Brevo.CreateContact(ctx, body.Email)
+		return
+	}
+
+	// the reconciliation job retries if Brevo is unavailable
+	err = services.NewsletterSubscribeBrevo(c.Request.Context(), db, n)
+	if err != nil {
+		slog.Error("Unable to add newsletter subscription to Brevo", "err", err)
 	}
 
-	views.EmailSubscribeToNewsletter(c, db, name, body.Email)
+	views.EmailSubscribeToNewsletter(c, db, n.Name, n.Email)
+
+	c.String(http.StatusOK, "Your subscription to the newsletter is confirmed.")
 }
 
+// Opened from the signed link in a newsletter email,
+// GET shows a page to confirm and POST unsubscribes
+func ContactNewsletterUnsubscribe(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		Email     string `form:"e" binding:"required"`
+		Signature string `form:"s" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	emailB, err := base64.RawURLEncoding.DecodeString(query.Email)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Malformed url: email required")
+		return
+	}
+	email := string(emailB)
+	if !app.UnsubscribeVerify(email, app.UnsubscribeTypeNewsletter, query.Signature) {
+		c.String(http.StatusUnauthorized, "Invalid unsubscribe link")
+		return
+	}
+	if c.Request.Method == http.MethodGet {
+		unsubscribeConfirmPage(c, "Do you want to unsubscribe from the newsletter?")
+		return
+	}
+
+	err = models.NewsletterDeleteByEmail(db, email)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to unsubscribe")
+		return
+	}
+	if app.Brevo != nil {
+		app.Brevo.DeleteContact(c.Request.Context(), email)
+	}
+
+	c.String(http.StatusOK, "You are unsubscribed from the newsletter.")
+}
+
+// Identifies contact form submissions by the address of the sender, used to throttle spam
+func ContactMailIdentifyEmail(c *gin.Context) string {
+	var body struct {
+		Email string `json:"email"`
+	}
+	c.ShouldBindBodyWith(&body, binding.JSON)
+	return strings.ToLower(strings.TrimSpace(body.Email))
+}
+
+// Stores the message as a ticket for the root admins and confirms it to the sender
 func ContactMail(c *gin.Context) {
 	db := getDB(c)
 
 	var body sharedtypes.ContactMailRequest
-	if err := c.ShouldBindJSON(&body); err != nil {
+	if err := c.ShouldBindBodyWith(&body, binding.JSON); err != nil {
 		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
 
-	err := views.EmailContactReceived(db, body.Name, body.Email, body.Message)
+	i18n, _ := c.Cookie("i18next")
+	ticket := &sharedtypes.ContactTicket{
+		Name:            body.Name,
+		Email:           body.Email,
+		Message:         body.Message,
+		I18n:            lo.Substring(i18n, 0, 5),
+		Status:          sharedtypes.ContactTicketStatusOpen,
+		ThreadMessageID: app.MailNewMessageID(),
+		IPAddress:       c.ClientIP(),
+	}
+	err := db.Create(ticket).Error
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to save message")
+		return
+	}
+
+	err = views.EmailContactReceived(db, body.Name, body.Email, body.Message)
 	if err != nil {
 		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to send email")
 		return
 	}
 
-	err = views.EmailContactConfirmation(c, db, body.Name, body.Email, body.Message)
+	err = views.EmailContactConfirmation(db, ticket)
 	if err != nil {
 		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to send email")
 		return
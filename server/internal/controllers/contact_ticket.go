@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	"github.com/the-clothing-loop/website/server/pkg/cursor"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+// Lists contact form tickets newest first, only allowed for root admins
+func ContactTicketGetAll(c *gin.Context) {
+	db := getDB(c)
+	var query struct {
+		Status          string `form:"status" binding:"omitempty,oneof=open answered closed"`
+		AssigneeUserUID string `form:"assignee_user_uid" binding:"omitempty,uuid"`
+		sharedtypes.PaginationQuery
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, _ := auth.Authenticate(c, db, auth.AuthState4RootUser, "")
+	if !ok {
+		return
+	}
+
+	assigneeUserID := uint(0)
+	if query.AssigneeUserUID != "" {
+		assignee, err := models.UserGetByUID(db, query.AssigneeUserUID, false)
+		if err != nil {
+			c.String(http.StatusBadRequest, "Assignee not found")
+			return
+		}
+		assigneeUserID = assignee.ID
+	}
+
+	ok, cur := paginationDecodeCursor(c, query.PaginationQuery)
+	if !ok {
+		return
+	}
+	limit := paginationLimit(query.PaginationQuery)
+	beforeID := uint(0)
+	if cur != nil {
+		beforeID = cur.ID
+	}
+
+	tickets, err := models.ContactTicketGetAll(db, query.Status, assigneeUserID, beforeID, limit+1)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve tickets")
+		return
+	}
+
+	c.JSON(http.StatusOK, paginationTrim(tickets, limit, func(t sharedtypes.ContactTicket) cursor.Cursor {
+		return cursor.Cursor{ID: t.ID}
+	}))
+}
+
+// Returns a single ticket including every reply
+func ContactTicketGet(c *gin.Context) {
+	db := getDB(c)
+	var query struct {
+		ID uint `form:"id" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, _ := auth.Authenticate(c, db, auth.AuthState4RootUser, "")
+	if !ok {
+		return
+	}
+
+	ticket, ok := contactTicketGet(c, db, query.ID)
+	if !ok {
+		return
+	}
+	replies, err := models.ContactTicketReplyGetAll(db, ticket.ID)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve ticket replies")
+		return
+	}
+
+	c.JSON(http.StatusOK, sharedtypes.ContactTicketResponse{
+		Ticket:  *ticket,
+		Replies: replies,
+	})
+}
+
+// Changes the status, assignee or internal notes of a ticket
+func ContactTicketUpdate(c *gin.Context) {
+	db := getDB(c)
+	var body sharedtypes.ContactTicketUpdateRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, _ := auth.Authenticate(c, db, auth.AuthState4RootUser, "")
+	if !ok {
+		return
+	}
+
+	ticket, ok := contactTicketGet(c, db, body.ID)
+	if !ok {
+		return
+	}
+
+	columns := map[string]any{}
+	if body.Status != nil {
+		columns["status"] = *body.Status
+	}
+	if body.Notes != nil {
+		columns["notes"] = *body.Notes
+	}
+	if body.AssigneeUserUID != nil {
+		if *body.AssigneeUserUID == "" {
+			columns["assignee_user_id"] = nil
+		} else {
+			assignee, err := models.UserGetByUID(db, *body.AssigneeUserUID, false)
+			if err != nil || !assignee.IsRootAdmin {
+				c.String(http.StatusBadRequest, "Assignee must be a root admin")
+				return
+			}
+			columns["assignee_user_id"] = assignee.ID
+		}
+	}
+	if len(columns) == 0 {
+		return
+	}
+
+	err := models.ContactTicketUpdate(db, ticket.ID, columns)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to update ticket")
+		return
+	}
+}
+
+// Emails the reply to the sender in the same conversation as the confirmation email
+func ContactTicketReply(c *gin.Context) {
+	db := getDB(c)
+	var body sharedtypes.ContactTicketReplyRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, authUser, _ := auth.Authenticate(c, db, auth.AuthState4RootUser, "")
+	if !ok {
+		return
+	}
+
+	ticket, ok := contactTicketGet(c, db, body.ID)
+	if !ok {
+		return
+	}
+
+	m, err := views.EmailContactTicketReply(db, ticket, authUser.Name, body.Message)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to send reply")
+		return
+	}
+	if m == nil {
+		c.String(http.StatusConflict, "The email address of the sender is undeliverable")
+		return
+	}
+
+	reply := &sharedtypes.ContactTicketReply{
+		ContactTicketID: ticket.ID,
+		AuthorUserID:    authUser.ID,
+		Message:         body.Message,
+		MailID:          m.ID,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(reply).Error
+		if err != nil {
+			return err
+		}
+		columns := map[string]any{}
+		if ticket.Status == sharedtypes.ContactTicketStatusOpen {
+			columns["status"] = sharedtypes.ContactTicketStatusAnswered
+		}
+		if ticket.AssigneeUserID == nil {
+			columns["assignee_user_id"] = authUser.ID
+		}
+		if len(columns) == 0 {
+			return nil
+		}
+		return models.ContactTicketUpdate(tx, ticket.ID, columns)
+	})
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Reply is sent but could not be saved")
+		return
+	}
+
+	reply.AuthorUserUID = authUser.UID
+	reply.AuthorName = authUser.Name
+	c.JSON(http.StatusOK, reply)
+}
+
+func contactTicketGet(c *gin.Context, db *gorm.DB, id uint) (*sharedtypes.ContactTicket, bool) {
+	ticket, err := models.ContactTicketGet(db, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusNotFound, "Ticket not found")
+		} else {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve ticket")
+		}
+		return nil, false
+	}
+	return ticket, true
+}
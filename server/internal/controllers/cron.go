@@ -1,16 +1,19 @@
 package controllers
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"log/slog"
 	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/samber/lo"
 	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/app/auth"
 	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
 	"github.com/the-clothing-loop/website/server/internal/views"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
 	"gorm.io/gorm"
 )
 
@@ -23,13 +26,21 @@ func CronMonthly(db *gorm.DB) {
 }
 
 func CronDaily(db *gorm.DB) {
-	emailSendAgain(db)
 	emailAbandonedChainRecruitment(db)
+	newsletterReconcileBrevo(db)
+	removeExpiredLocationAlerts(db)
+	removeOldMails(db)
 	auth.OtpDeleteOld(db)
 }
 
+func CronWeekly(db *gorm.DB) {
+	emailWeeklyDigest(db)
+}
+
 func CronHourly(db *gorm.DB) {
 	notifyIfIsHoldingABagForTooLong(db)
+	notifyEventRsvpReminders(db)
+	emailPauseEnded(db)
 }
 
 // Email hosts about pending participants after 60 days.
@@ -134,7 +145,9 @@ ORDER BY u.email
 	for i := range emailValues {
 		email := emailValues[i]
 		slog.Info("Sending email approve reminder", "to", email.Email)
-		go views.EmailApproveReminder(db, email.I18n, email.Name, email.Email, email.Approvals)
+		go services.NotifyEmail(db, sharedtypes.NotificationTypeHostUpdates, email.Email, func() error {
+			return views.EmailApproveReminder(db, email.I18n, email.Name, email.Email, email.Approvals)
+		})
 	}
 }
 
@@ -221,6 +234,7 @@ func notifyIfIsHoldingABagForTooLong(db *gorm.DB) {
 		BagNumber string `gorm:"bag_number"`
 		BagID     uint   `gorm:"bag_id"`
 	}{}
+	// bags handed over within a week before the holder went on holiday wait until they are back
 	db.Raw(`
 SELECT b.number as bag_number, u.uid as user_uid, b.id as bag_id
 FROM bags as b
@@ -228,6 +242,13 @@ JOIN user_chains as uc ON b.user_chain_id = uc.id
 JOIN users as u ON uc.user_id = u.id
 WHERE b.updated_at < (NOW() - INTERVAL 7 DAY)
 AND b.last_notified_at IS NULL
+AND NOT (
+	` + models.UserChainIsPausedSql + `
+	AND (
+		COALESCE(u.paused_from, uc.paused_from) IS NULL
+		OR b.updated_at > COALESCE(u.paused_from, uc.paused_from) - INTERVAL 7 DAY
+	)
+)
 	`).Scan(res)
 
 	if len(*res) > 0 {
@@ -235,7 +256,7 @@ AND b.last_notified_at IS NULL
 		for i := range *res {
 			item := (*res)[i]
 			slog.Info("Create notification", "user", item.UserUID, "holding_bag", item.BagNumber)
-			app.OneSignalCreateNotification(db, []string{item.UserUID}, *views.Notifications[views.NotificationEnumTitleBagTooOld], app.OneSignalEllipsisContent(item.BagNumber))
+			services.NotifyPush(db, sharedtypes.NotificationTypeBagTooOld, []string{item.UserUID}, views.NotificationEnumBagTooOld, app.OneSignalEllipsis(item.BagNumber))
 
 			bagIDs = append(bagIDs, item.BagID)
 		}
@@ -244,29 +265,351 @@ AND b.last_notified_at IS NULL
 	}
 }
 
-func emailSendAgain(db *gorm.DB) {
-	slog.Info("Running emailSendAgain")
-	ms, err := models.MailGetDueForResend(db)
+// Runs hourly, pauses that ended longer ago than this are cleared without an email
+const pauseEndedEmailWindow = 2 * time.Hour
+
+// Welcome members back once their holiday ends and clear the expired pause.
+// Only pauses that ended since the last runs are emailed, older ones are cleared silently.
+func emailPauseEnded(db *gorm.DB) {
+	slog.Info("Running emailPauseEnded")
+	type pauseEnded struct {
+		ID        uint   `gorm:"id"`
+		Name      string `gorm:"name"`
+		Email     string `gorm:"email"`
+		I18n      string `gorm:"i18n"`
+		ChainName string `gorm:"chain_name"`
+	}
+	windowStart := time.Now().Add(-pauseEndedEmailWindow)
+
+	users := []pauseEnded{}
+	err := db.Raw(`
+SELECT u.id, u.name, u.email, u.i18n
+FROM users AS u
+WHERE u.paused_until <= NOW() AND u.paused_until > ?
+	AND u.is_email_verified = TRUE AND u.email IS NOT NULL
+	`, windowStart).Scan(&users).Error
+	if err != nil {
+		slog.Error("Unable to find users whose pause ended", "err", err)
+		return
+	}
+	userIDs := []uint{}
+	for _, user := range users {
+		err := views.EmailPauseEnded(db, user.I18n, user.Name, user.Email, "")
+		if err != nil {
+			slog.Error("Unable to send pause ended email", "err", err)
+			continue
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+	if len(userIDs) > 0 {
+		err = db.Exec(`UPDATE users SET paused_until = NULL, paused_from = NULL WHERE id IN ? AND paused_until <= NOW()`, userIDs).Error
+		if err != nil {
+			slog.Error("Unable to clear ended user pauses", "err", err)
+			return
+		}
+	}
+	err = db.Exec(`UPDATE users SET paused_until = NULL, paused_from = NULL WHERE paused_until <= ?`, windowStart).Error
+	if err != nil {
+		slog.Error("Unable to clear old user pauses", "err", err)
+		return
+	}
+
+	userChains := []pauseEnded{}
+	err = db.Raw(`
+SELECT uc.id, u.name, u.email, u.i18n, c.name AS chain_name
+FROM user_chains AS uc
+JOIN users AS u ON u.id = uc.user_id
+JOIN chains AS c ON c.id = uc.chain_id
+WHERE uc.is_paused = TRUE AND uc.paused_until <= NOW() AND uc.paused_until > ?
+	AND u.is_email_verified = TRUE AND u.email IS NOT NULL
+	`, windowStart).Scan(&userChains).Error
+	if err != nil {
+		slog.Error("Unable to find loop pauses that ended", "err", err)
+		return
+	}
+	userChainIDs := []uint{}
+	for _, uc := range userChains {
+		err := views.EmailPauseEnded(db, uc.I18n, uc.Name, uc.Email, uc.ChainName)
+		if err != nil {
+			slog.Error("Unable to send pause ended email", "err", err)
+			continue
+		}
+		userChainIDs = append(userChainIDs, uc.ID)
+	}
+	if len(userChainIDs) > 0 {
+		err = db.Exec(`
+UPDATE user_chains SET is_paused = FALSE, paused_until = NULL, paused_from = NULL
+WHERE id IN ? AND is_paused = TRUE AND paused_until <= NOW()
+		`, userChainIDs).Error
+		if err != nil {
+			slog.Error("Unable to clear ended loop pauses", "err", err)
+			return
+		}
+	}
+	err = db.Exec(`
+UPDATE user_chains SET is_paused = FALSE, paused_until = NULL, paused_from = NULL
+WHERE is_paused = TRUE AND paused_until <= ?
+	`, windowStart).Error
+	if err != nil {
+		slog.Error("Unable to clear old loop pauses", "err", err)
+	}
+}
+
+// Remind everyone that is going or might be going 24 hours before an event starts
+func notifyEventRsvpReminders(db *gorm.DB) {
+	slog.Info("Running notifyEventRsvpReminders")
+	events := []models.Event{}
+	err := db.Raw(models.EventGetSql + `WHERE events.id IN (
+	SELECT DISTINCT event_id FROM event_rsvps WHERE status IN ('going', 'maybe')
+) AND (
+	(events.rrule = '' AND events.date > NOW() AND events.date <= NOW() + INTERVAL 24 HOUR)
+	OR (events.rrule <> '' AND (events.rrule_until IS NULL OR events.rrule_until > NOW()))
+)`).Scan(&events).Error
+	if err != nil {
+		slog.Error("Unable to find events to remind attendees of", "err", err)
+		return
+	}
+
+	overrides, err := models.EventOccurrenceGetAllByEventIDs(db, lo.FilterMap(events, func(e models.Event, _ int) (uint, bool) {
+		return e.ID, e.RRule != ""
+	}))
+	if err != nil {
+		slog.Error("Unable to find event occurrences", "err", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range events {
+		occurrence := events[i].NextOccurrence(now, now.Add(24*time.Hour), overrides[events[i].ID])
+		if occurrence == nil {
+			continue
+		}
+		occurrenceDate := occurrence.Date
+		if occurrence.OccurrenceDate != nil {
+			occurrenceDate = *occurrence.OccurrenceDate
+		}
+		contacts, err := models.EventRsvpGetContacts(db, occurrence.ID, occurrenceDate)
+		if err != nil {
+			slog.Error("Unable to find event attendees", "err", err)
+			continue
+		}
+
+		rsvpIDs := []uint{}
+		userUIDs := []string{}
+		for _, contact := range contacts {
+			if contact.IsWaitlisted || (contact.RemindedForDate != nil && contact.RemindedForDate.Equal(occurrence.Date)) {
+				continue
+			}
+			rsvpIDs = append(rsvpIDs, contact.RsvpID)
+			userUIDs = append(userUIDs, contact.UserUID)
+			if contact.Email.Valid {
+				services.NotifyEmail(db, sharedtypes.NotificationTypeEventReminder, contact.Email.String, func() error {
+					return views.EmailEventReminder(db, contact.I18n, contact.Name, contact.Email.String, occurrence.Name, occurrence.UID, eventFormatDate(occurrence.Date, occurrence), occurrence.Address)
+				})
+			}
+		}
+		if len(userUIDs) == 0 {
+			continue
+		}
+		services.NotifyPush(db, sharedtypes.NotificationTypeEventReminder, userUIDs, views.NotificationEnumEventReminder, app.OneSignalEllipsis(occurrence.Name))
+
+		// prevent duplicate reminders
+		err = models.EventRsvpSetRemindedForDate(db, rsvpIDs, occurrence.Date)
+		if err != nil {
+			slog.Error("Unable to prevent duplicate event reminders", "err", err)
+		}
+	}
+}
+
+// Email everyone who opted in a summary of the last week of activity in their loops
+func emailWeeklyDigest(db *gorm.DB) {
+	slog.Info("Running emailWeeklyDigest")
+	users := []struct {
+		ID        uint    `gorm:"id"`
+		UID       string  `gorm:"uid"`
+		Name      string  `gorm:"name"`
+		Email     string  `gorm:"email"`
+		I18n      string  `gorm:"i18n"`
+		Latitude  float64 `gorm:"latitude"`
+		Longitude float64 `gorm:"longitude"`
+	}{}
+	err := db.Raw(`
+SELECT u.id, u.uid, u.name, u.email, u.i18n, u.latitude, u.longitude
+FROM users AS u
+JOIN notification_preferences AS np ON np.user_id = u.id
+WHERE np.type = ? AND np.channel = ? AND np.enabled = TRUE
+	AND u.is_email_verified = TRUE AND u.email IS NOT NULL
+	`, sharedtypes.NotificationTypeWeeklyDigest, sharedtypes.NotificationChannelEmail).Scan(&users).Error
 	if err != nil {
+		slog.Error("Unable to find weekly digest recipients", "err", err)
 		return
 	}
 
-	fmt.Printf("Error mail send: %++v", ms)
-	for _, m := range ms {
-		err := validate.Var(m.ToAddress, "email")
+	now := time.Now()
+	weekAgo := now.Add(-7 * 24 * time.Hour)
+	for _, user := range users {
+		digest := &views.EmailWeeklyDigestData{}
+
+		err = db.Raw(`
+SELECT c.name AS chain_name, COUNT(uc2.id) AS count
+FROM user_chains AS uc
+JOIN chains AS c ON c.id = uc.chain_id
+JOIN user_chains AS uc2 ON uc2.chain_id = uc.chain_id AND uc2.is_approved = FALSE
+JOIN users AS u2 ON u2.id = uc2.user_id AND u2.is_email_verified = TRUE
+WHERE uc.user_id = ? AND uc.is_chain_admin = TRUE
+GROUP BY c.id, c.name
+		`, user.ID).Scan(&digest.PendingApprovals).Error
+		if err != nil {
+			slog.Error("Unable to find pending approvals for weekly digest", "err", err)
+			continue
+		}
+
+		err = db.Raw(`
+SELECT b.number, c.name AS chain_name, DATEDIFF(NOW(), b.updated_at) AS days
+FROM bags AS b
+JOIN user_chains AS uc ON uc.id = b.user_chain_id
+JOIN chains AS c ON c.id = uc.chain_id
+WHERE uc.user_id = ?
+ORDER BY b.updated_at ASC
+		`, user.ID).Scan(&digest.Bags).Error
 		if err != nil {
-			m.Delete(db)
+			slog.Error("Unable to find bags for weekly digest", "err", err)
 			continue
 		}
-		err = app.MailSend(db, m)
-		// err = models.ErrMailLastRetry
-		errr := m.UpdateNextRetryAttempt(db, err)
 
-		if errr != nil {
-			if errors.Is(errr, models.ErrMailLastRetry) {
-				views.EmailRootAdminFailedLastRetry(db, m.ToAddress, m.Subject)
+		err = db.Raw(`
+SELECT bi.title, c.name AS chain_name
+FROM bulky_items AS bi
+JOIN user_chains AS buc ON buc.id = bi.user_chain_id
+JOIN chains AS c ON c.id = buc.chain_id
+WHERE bi.created_at > ? AND buc.user_id <> ? AND buc.chain_id IN (
+	SELECT chain_id FROM user_chains WHERE user_id = ? AND is_approved = TRUE
+)
+ORDER BY bi.created_at DESC
+LIMIT 10
+		`, weekAgo, user.ID, user.ID).Scan(&digest.BulkyItems).Error
+		if err != nil {
+			slog.Error("Unable to find bulky items for weekly digest", "err", err)
+			continue
+		}
+
+		// messages of the last week sent after the newest message the user has loaded in that room
+		err = db.Raw(`
+SELECT COUNT(cm.id)
+FROM chat_messages AS cm
+JOIN chat_channels AS cc ON cc.id = cm.chat_channel_id
+LEFT JOIN chat_channel_reads AS ccr ON ccr.chat_channel_id = cc.id AND ccr.user_id = ?
+WHERE cm.created_at > ? AND cm.created_at > COALESCE(ccr.last_read_at, 0)
+	AND cm.deleted_at IS NULL AND cm.send_by_uid <> ? AND cc.chain_id IN (
+	SELECT chain_id FROM user_chains WHERE user_id = ? AND is_approved = TRUE
+)
+		`, user.ID, weekAgo.UnixMilli(), user.UID, user.ID).Scan(&digest.UnreadChatMessageCount).Error
+		if err != nil {
+			slog.Error("Unable to count chat messages for weekly digest", "err", err)
+			continue
+		}
+
+		if user.Latitude != 0 && user.Longitude != 0 {
+			events, err := weeklyDigestEventsNearby(db, user.Latitude, user.Longitude, now)
+			if err != nil {
+				slog.Error("Unable to find events for weekly digest", "err", err)
+				continue
+			}
+			lng := views.NotificationLang(user.I18n)
+			for _, e := range events {
+				digest.Events = append(digest.Events, views.EmailWeeklyDigestEvent{
+					Name: e.Name,
+					Date: eventFormatDate(e.Date, &e),
+					URL:  fmt.Sprintf("%s/%s/events/%s", app.Config.SITE_BASE_URL_FE, lng, e.UID),
+				})
 			}
 		}
+
+		if digest.IsEmpty() {
+			continue
+		}
+		services.NotifyEmail(db, sharedtypes.NotificationTypeWeeklyDigest, user.Email, func() error {
+			return views.EmailWeeklyDigest(db, user.I18n, user.Name, user.Email, digest)
+		})
+	}
+}
+
+// Events within 25 km happening in the next two weeks
+func weeklyDigestEventsNearby(db *gorm.DB, latitude, longitude float64, now time.Time) ([]models.Event, error) {
+	before := now.Add(14 * 24 * time.Hour)
+	sql := fmt.Sprintf(`%sWHERE %s <= ? AND (
+	(events.rrule = '' AND events.date > ? AND events.date <= ?)
+	OR (events.rrule <> '' AND (events.rrule_until IS NULL OR events.rrule_until > ?))
+)`, models.EventGetSql, sqlCalcDistance("events.latitude", "events.longitude", "?", "?"))
+	events := []models.Event{}
+	err := db.Raw(sql, latitude, longitude, 25, now, before, now).Scan(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	events, err = models.EventExpandAll(db, events, now, before, false)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Slice(events, 0, 5), nil
+}
+
+// Removes unconfirmed newsletter subscriptions and compares active ones with the Brevo contacts,
+// a limited number of subscriptions is checked each day to stay within the Brevo rate limit
+func newsletterReconcileBrevo(db *gorm.DB) {
+	slog.Info("Running newsletterReconcileBrevo")
+
+	affected, err := models.NewsletterDeleteExpiredPending(db)
+	if err != nil {
+		slog.Error("Unable to remove expired newsletter subscriptions", "err", err)
+	} else if affected > 0 {
+		slog.Info("Expired newsletter subscriptions removed", "affected", affected)
+	}
+
+	if app.Brevo == nil {
+		return
+	}
+
+	list, err := models.NewsletterGetAllUnsynced(db, time.Now().Add(-30*24*time.Hour), 200)
+	if err != nil {
+		slog.Error("Unable to find newsletter subscriptions to reconcile", "err", err)
+		return
+	}
+	ctx := context.Background()
+	removed := 0
+	for i := range list {
+		isRemoved, err := services.NewsletterSyncBrevo(ctx, db, &list[i])
+		if err != nil {
+			slog.Error("Unable to reconcile newsletter subscription with Brevo", "err", err, "id", list[i].ID)
+			continue
+		}
+		if isRemoved {
+			removed++
+		}
+	}
+	slog.Info("Newsletter subscriptions reconciled", "checked", len(list), "removed", removed)
+}
+
+// Removes location alerts of which the confirmation link was never used
+func removeExpiredLocationAlerts(db *gorm.DB) {
+	slog.Info("Running removeExpiredLocationAlerts")
+
+	affected, err := models.LocationAlertDeleteExpiredPending(db)
+	if err != nil {
+		slog.Error("Unable to remove expired location alerts", "err", err)
+	} else if affected > 0 {
+		slog.Info("Expired location alerts removed", "affected", affected)
+	}
+}
+
+// Removes sent, failed and bounced emails from the outbox after the retention period
+func removeOldMails(db *gorm.DB) {
+	slog.Info("Running removeOldMails")
+
+	affected, err := models.MailDeleteBefore(db, time.Now().Add(-models.MailRetention))
+	if err != nil {
+		slog.Error("Unable to remove old emails", "err", err)
+	} else if affected > 0 {
+		slog.Info("Old emails removed", "affected", affected)
 	}
 }
 
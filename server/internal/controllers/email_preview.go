@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+// Without a template every template and the problems per language are listed,
+// with format=html the rendered email is returned to view in the browser
+func EmailPreviewGet(c *gin.Context) {
+	db := getDB(c)
+	var query struct {
+		Template string `form:"template"`
+		Lang     string `form:"lang"`
+		Format   string `form:"format" binding:"omitempty,oneof=json html"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, _ := auth.Authenticate(c, db, auth.AuthState4RootUser, "")
+	if !ok {
+		return
+	}
+
+	if query.Template == "" {
+		c.JSON(http.StatusOK, sharedtypes.EmailPreviewListResponse{
+			Templates: views.EmailPreviewTemplates(),
+			Languages: views.EmailPreviewLanguages(),
+			Problems:  views.EmailPreviewProblems(),
+		})
+		return
+	}
+
+	if query.Lang == "" {
+		query.Lang = "en"
+	}
+	m, err := views.EmailPreview(query.Lang, query.Template)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if query.Format == "html" {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(m.Body))
+		return
+	}
+	c.JSON(http.StatusOK, sharedtypes.EmailPreviewResponse{
+		Template: query.Template,
+		Lang:     query.Lang,
+		Subject:  m.Subject,
+		Body:     m.Body,
+	})
+}
+
+// Sends the preview to the email address of the root admin
+func EmailPreviewSend(c *gin.Context) {
+	db := getDB(c)
+	var body sharedtypes.EmailPreviewSendRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, authUser, _ := auth.Authenticate(c, db, auth.AuthState4RootUser, "")
+	if !ok {
+		return
+	}
+	if authUser.Email == nil {
+		c.String(http.StatusBadRequest, "Your account has no email address")
+		return
+	}
+
+	if _, err := views.EmailPreview(body.Lang, body.Template); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	err := views.EmailPreviewSend(db, body.Lang, body.Template, authUser.Name, *authUser.Email)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to send preview")
+		return
+	}
+}
@@ -9,13 +9,15 @@ import (
 
 	ics "github.com/arran4/golang-ical"
 	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
 	uuid "github.com/satori/go.uuid"
 	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/app/auth"
 	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/pkg/cursor"
 	"github.com/the-clothing-loop/website/server/pkg/imgbb"
 	"github.com/the-clothing-loop/website/server/sharedtypes"
-	"gopkg.in/guregu/null.v3/zero"
+	"gorm.io/gorm"
 )
 
 func EventCreate(c *gin.Context) {
@@ -57,8 +59,17 @@ func EventCreate(c *gin.Context) {
 		ImageUrl:       body.ImageUrl,
 		ImageDeleteUrl: body.ImageDeleteUrl,
 		PriceType:      &body.PriceType,
+		Capacity:       body.Capacity,
+		Timezone:       body.Timezone,
+	}
+	if event.Timezone == "" {
+		event.Timezone = "UTC"
 	}
 	event.ValidateDescription()
+	if err := event.SetRRule(body.RRule); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
 	if body.ChainUID != "" && chain != nil {
 		event.ChainID = &chain.ID
 	}
@@ -106,6 +117,7 @@ func EventGetAll(c *gin.Context) {
 		Latitude  float32 `form:"latitude" binding:"required,latitude"`
 		Longitude float32 `form:"longitude" binding:"required,longitude"`
 		Radius    float32 `form:"radius"`
+		sharedtypes.PaginationQuery
 	}
 	if err := c.ShouldBindQuery(&query); err != nil {
 		c.String(http.StatusBadRequest, err.Error())
@@ -114,14 +126,40 @@ func EventGetAll(c *gin.Context) {
 	if query.Radius == 5000 {
 		query.Radius = 0
 	}
+	isPaginated := paginationIsSet(query.PaginationQuery)
+	ok, cur := paginationDecodeCursor(c, query.PaginationQuery)
+	if !ok {
+		return
+	}
+	limit := paginationLimit(query.PaginationQuery)
 
-	sql := models.EventGetSql + `WHERE (date > NOW() OR (date_end IS NOT NULL AND date_end > NOW()))`
+	now := time.Now()
+	var curDate time.Time
+	if cur != nil {
+		var err error
+		curDate, err = time.Parse(time.RFC3339Nano, cur.Value)
+		if err != nil {
+			c.String(http.StatusBadRequest, cursor.ErrInvalid.Error())
+			return
+		}
+	}
+
+	// single events
+	sql := models.EventGetSql + `WHERE events.rrule = '' AND (date > NOW() OR (date_end IS NOT NULL AND date_end > NOW()))`
 	args := []any{}
 	if query.Latitude != 0 && query.Longitude != 0 && query.Radius != 0 {
 		sql = fmt.Sprintf("%s AND %s <= ? ", sql, sqlCalcDistance("events.latitude", "events.longitude", "?", "?"))
 		args = append(args, query.Latitude, query.Longitude, query.Radius)
 	}
-	sql = fmt.Sprintf("%s ORDER BY date ASC", sql)
+	if cur != nil {
+		sql = fmt.Sprintf("%s AND (date > ? OR (date = ? AND events.id > ?)) ", sql)
+		args = append(args, curDate, curDate, cur.ID)
+	}
+	sql = fmt.Sprintf("%s ORDER BY date ASC, events.id ASC", sql)
+	if isPaginated {
+		sql = fmt.Sprintf("%s LIMIT ?", sql)
+		args = append(args, limit+1)
+	}
 	events := []models.Event{}
 	err := db.Raw(sql, args...).Scan(&events).Error
 	if err != nil {
@@ -129,6 +167,44 @@ func EventGetAll(c *gin.Context) {
 		return
 	}
 
+	// recurring events
+	sql = models.EventGetSql + `WHERE events.rrule <> '' AND (events.rrule_until IS NULL OR events.rrule_until > NOW())`
+	args = []any{}
+	if query.Latitude != 0 && query.Longitude != 0 && query.Radius != 0 {
+		sql = fmt.Sprintf("%s AND %s <= ? ", sql, sqlCalcDistance("events.latitude", "events.longitude", "?", "?"))
+		args = append(args, query.Latitude, query.Longitude, query.Radius)
+	}
+	recurringEvents := []models.Event{}
+	err = db.Raw(sql, args...).Scan(&recurringEvents).Error
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	occurrences, err := models.EventExpandAll(db, recurringEvents, now.Add(-7*24*time.Hour), now.Add(models.EventRecurrenceHorizon), false)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	for _, e := range occurrences {
+		if !e.Date.After(now) && (e.DateEnd == nil || !e.DateEnd.After(now)) {
+			continue
+		}
+		if cur != nil && !(e.Date.After(curDate) || (e.Date.Equal(curDate) && e.ID > cur.ID)) {
+			continue
+		}
+		events = append(events, e)
+	}
+	models.EventSortByDate(events, false)
+
+	if isPaginated {
+		if len(events) > limit+1 {
+			events = events[:limit+1]
+		}
+		c.JSON(http.StatusOK, paginationTrim(events, limit, func(e models.Event) cursor.Cursor {
+			return cursor.Cursor{ID: e.ID, Value: e.Date.Format(time.RFC3339Nano)}
+		}))
+		return
+	}
 	c.JSON(http.StatusOK, events)
 }
 
@@ -149,13 +225,15 @@ func EventGetPrevious(c *gin.Context) {
 		query.Radius = 0
 	}
 
-	sql := models.EventGetSql + `WHERE date < NOW()`
+	now := time.Now()
+	distanceSql := ""
 	args := []any{}
 	if query.Latitude != 0 && query.Longitude != 0 && query.Radius != 0 {
-		sql = fmt.Sprintf("%s AND %s <= ? ", sql, sqlCalcDistance("events.latitude", "events.longitude", "?", "?"))
+		distanceSql = fmt.Sprintf(" AND %s <= ? ", sqlCalcDistance("events.latitude", "events.longitude", "?", "?"))
 		args = append(args, query.Latitude, query.Longitude, query.Radius)
 	}
-	sql = fmt.Sprintf("%s ORDER BY date DESC LIMIT 6", sql)
+
+	sql := models.EventGetSql + `WHERE events.rrule = '' AND date < NOW()` + distanceSql + ` ORDER BY date DESC LIMIT 6`
 	events := []models.Event{}
 	err := db.Raw(sql, args...).Scan(&events).Error
 	if err != nil {
@@ -163,17 +241,42 @@ func EventGetPrevious(c *gin.Context) {
 		return
 	}
 
+	sql = models.EventGetSql + `WHERE events.rrule <> '' AND date < NOW()` + distanceSql
+	recurringEvents := []models.Event{}
+	err = db.Raw(sql, args...).Scan(&recurringEvents).Error
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	occurrences, err := models.EventExpandAll(db, recurringEvents, now.Add(-models.EventRecurrenceHorizon), now, true)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	for _, e := range occurrences {
+		if e.Date.Before(now) {
+			events = append(events, e)
+		}
+	}
+	models.EventSortByDate(events, true)
+	if len(events) > 6 {
+		events = events[:6]
+	}
+
 	res := gin.H{
 		"previous_events": events,
 	}
 	if query.IncludeTotal {
-		total := 0
-		err = db.Raw(`SELECT COUNT(*) FROM events WHERE date < NOW()`).Scan(&total).Error
+		// the total is the same for everyone and only grows slowly, following every recurring event is too much work per request
+		total, err := app.CacheFindOrUpdate("events_previous_total", cache.DefaultExpiration, func() (*int, error) {
+			total, err := models.EventCountPrevious(db, now)
+			return &total, err
+		})
 		if err != nil {
 			c.AbortWithError(http.StatusInternalServerError, err)
 			return
 		}
-		res["previous_total"] = total
+		res["previous_total"] = *total
 	}
 	c.JSON(http.StatusOK, res)
 }
@@ -205,7 +308,17 @@ func EventDelete(c *gin.Context) {
 		imgbb.DeleteAll([]string{event.ImageDeleteUrl})
 	}
 
-	err := db.Exec(`DELETE FROM events WHERE id = ?`, event.ID).Error
+	err := models.EventOccurrenceDeleteAll(db, event.ID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	err = models.EventRsvpDeleteAll(db, event.ID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	err = db.Exec(`DELETE FROM events WHERE id = ?`, event.ID).Error
 	if err != nil {
 		c.AbortWithError(http.StatusInternalServerError, err)
 		return
@@ -275,6 +388,7 @@ func EventUpdate(c *gin.Context) {
 	if body.Longitude != nil {
 		event.Longitude = *(body.Longitude)
 	}
+	oldDate, oldRRule, oldAddress, oldCapacity, oldTimezone := event.Date, event.RRule, event.Address, event.Capacity, event.Timezone
 	if body.Date != nil {
 		event.Date = *(body.Date)
 		// Must set the start date to set the end date
@@ -312,12 +426,134 @@ func EventUpdate(c *gin.Context) {
 		}
 	}
 
+	if body.Timezone != nil && *body.Timezone != "" {
+		event.Timezone = *body.Timezone
+	}
+	rule := event.RRule
+	if body.RRule != nil {
+		rule = *body.RRule
+	}
+	if err := event.SetRRule(rule); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// overrides and responses point to occurrences that might no longer exist
+	if !event.Date.Equal(oldDate) || event.RRule != oldRRule || (event.RRule != "" && event.Timezone != oldTimezone) {
+		err := models.EventOccurrenceDeleteAll(db, event.ID)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+		err = models.EventRsvpMoveToOccurrences(db, event)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	if body.Capacity != nil {
+		event.Capacity = *body.Capacity
+	}
+
+	event.Sequence++
 	err := db.Save(event).Error
 	if err != nil {
 		slog.Error("Unable to update loop values", "err", err)
 		c.AbortWithError(http.StatusInternalServerError, errors.New("Unable to update loop values"))
 		return
 	}
+
+	if event.Capacity != oldCapacity {
+		var promotedUserIDs []uint
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			promotedUserIDs, err = models.EventRsvpPromoteWaitlistAll(tx, event)
+			return err
+		})
+		if err != nil {
+			slog.Error("Unable to promote users from the event waitlist", "err", err)
+		}
+		eventNotifyWaitlistPromoted(db, promotedUserIDs, event)
+	}
+	if !event.Date.Equal(oldDate) || event.RRule != oldRRule || event.Address != oldAddress || (event.RRule != "" && event.Timezone != oldTimezone) {
+		eventNotifyChanged(db, event)
+	}
+}
+
+func EventOccurrenceUpdate(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.EventOccurrenceUpdateRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.DateEnd != nil && body.DateEnd.Before(body.Date) {
+		c.String(http.StatusBadRequest, "End date must be after the start date")
+		return
+	}
+
+	ok, _, event := auth.AuthenticateEvent(c, db, body.EventUID)
+	if !ok {
+		return
+	}
+	if !event.IsOccurrence(body.OriginalDate) {
+		c.String(http.StatusNotFound, "Occurrence not found")
+		return
+	}
+
+	err := models.EventOccurrenceUpsert(db, &sharedtypes.EventOccurrence{
+		EventID:      event.ID,
+		OriginalDate: body.OriginalDate.UTC(),
+		Date:         &body.Date,
+		DateEnd:      body.DateEnd,
+	})
+	if err != nil {
+		slog.Error("Unable to move occurrence", "err", err)
+		c.AbortWithError(http.StatusInternalServerError, errors.New("Unable to move occurrence"))
+		return
+	}
+	err = models.EventIncrementSequence(db, event.ID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func EventOccurrenceCancel(c *gin.Context) {
+	db := getDB(c)
+
+	var query sharedtypes.EventOccurrenceCancelQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, event := auth.AuthenticateEvent(c, db, query.EventUID)
+	if !ok {
+		return
+	}
+	if !event.IsOccurrence(query.OriginalDate) {
+		c.String(http.StatusNotFound, "Occurrence not found")
+		return
+	}
+
+	err := models.EventOccurrenceUpsert(db, &sharedtypes.EventOccurrence{
+		EventID:      event.ID,
+		OriginalDate: query.OriginalDate.UTC(),
+		IsCancelled:  true,
+	})
+	if err != nil {
+		slog.Error("Unable to cancel occurrence", "err", err)
+		c.AbortWithError(http.StatusInternalServerError, errors.New("Unable to cancel occurrence"))
+		return
+	}
+	err = models.EventIncrementSequence(db, event.ID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
 }
 
 func EventICal(c *gin.Context) {
@@ -332,40 +568,18 @@ func EventICal(c *gin.Context) {
 	}
 
 	event := &models.Event{}
-	db.Raw("SELECT * FROM events WHERE uid = ? LIMIT 1", uri.UID).Scan(event)
+	db.Raw(models.EventGetSql+`WHERE events.uid = ? LIMIT 1`, uri.UID).Scan(event)
 	if event.ID == 0 {
 		c.AbortWithError(http.StatusNotFound, fmt.Errorf("Event not found"))
 		return
 	}
 
-	user := struct {
-		Name  string      `gorm:"name"`
-		Email zero.String `gorm:"email"`
-	}{}
-	db.Raw(`
-SELECT name, email
-FROM users
-WHERE id = ?
-LIMIT 1
-	`, event.UserID).Scan(&user)
-
 	cal := ics.NewCalendar()
 	cal.SetMethod(ics.MethodRequest)
-	icalE := cal.AddEvent(event.UID)
-	icalE.SetCreatedTime(event.CreatedAt)
-	icalE.SetModifiedAt(event.UpdatedAt)
-	icalE.SetStartAt(event.Date)
-	if event.DateEnd != nil {
-		icalE.SetEndAt(*event.DateEnd)
-	} else {
-		icalE.SetEndAt(event.Date.Add(time.Duration(2) * time.Hour))
-	}
-	icalE.SetSummary(event.Name)
-	icalE.SetLocation(fmt.Sprintf("https://www.google.com/maps/@%v,%v,17z", event.Latitude, event.Longitude))
-	icalE.SetDescription(event.Description)
-	icalE.SetURL(fmt.Sprintf("%s/events/%s", app.Config.SITE_BASE_URL_FE, uri.UID))
-	if user.Email.Valid {
-		icalE.SetOrganizer(user.Email.String, ics.WithCN(user.Name))
+	err := icalAddEvents(db, cal, []models.Event{*event})
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
 	}
 
 	c.Data(http.StatusOK, "text/calendar", []byte(cal.Serialize()))
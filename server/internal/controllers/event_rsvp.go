@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+// Formats the date in the timezone of the event, with the abbreviation of that timezone
+func eventFormatDate(t time.Time, event *models.Event) string {
+	return t.In(event.Location()).Format("2006-01-02 15:04 MST")
+}
+
+func eventGetByUID(c *gin.Context, db *gorm.DB, eventUID string) (*models.Event, bool) {
+	event := &models.Event{}
+	err := db.Raw(models.EventGetSql+`WHERE events.uid = ? LIMIT 1`, eventUID).Scan(event).Error
+	if err != nil || event.ID == 0 {
+		c.String(http.StatusNotFound, "Event not found")
+		return nil, false
+	}
+	return event, true
+}
+
+// Finds the occurrence a response is for, single events only have their start date.
+// Recurring events require the original start of one of their occurrences.
+// Responses can only change before the occurrence starts.
+func eventRsvpOccurrenceDate(c *gin.Context, db *gorm.DB, event *models.Event, date *time.Time, mustBeUpcoming bool) (time.Time, bool) {
+	if event.RRule == "" {
+		if mustBeUpcoming && !event.Date.After(time.Now()) {
+			c.String(http.StatusConflict, "Event has already started")
+			return time.Time{}, false
+		}
+		return event.Date, true
+	}
+
+	if date == nil {
+		c.String(http.StatusBadRequest, "Occurrence date is required for recurring events")
+		return time.Time{}, false
+	}
+	if !event.IsOccurrence(*date) {
+		c.String(http.StatusNotFound, "Occurrence not found")
+		return time.Time{}, false
+	}
+	if !mustBeUpcoming {
+		return *date, true
+	}
+
+	overrides, err := models.EventOccurrenceGetAllByEventIDs(db, []uint{event.ID})
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return time.Time{}, false
+	}
+	start := *date
+	for _, o := range overrides[event.ID] {
+		if !o.OriginalDate.Equal(*date) {
+			continue
+		}
+		if o.IsCancelled {
+			c.String(http.StatusConflict, "Occurrence has been cancelled")
+			return time.Time{}, false
+		}
+		if o.Date != nil {
+			start = *o.Date
+		}
+	}
+	if !start.After(time.Now()) {
+		c.String(http.StatusConflict, "Event has already started")
+		return time.Time{}, false
+	}
+	return *date, true
+}
+
+func eventRsvpRespond(c *gin.Context, db *gorm.DB, event *models.Event, occurrenceDate time.Time, userID uint) {
+	rsvp, err := models.EventRsvpGet(db, event.ID, occurrenceDate, userID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	going, maybe, waitlist, err := models.EventRsvpGetCounts(db, event.ID, occurrenceDate)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sharedtypes.EventRsvpResponse{
+		Rsvp:          rsvp,
+		GoingCount:    going,
+		MaybeCount:    maybe,
+		WaitlistCount: waitlist,
+		Capacity:      event.Capacity,
+	})
+}
+
+func EventRsvpGet(c *gin.Context) {
+	db := getDB(c)
+
+	var query sharedtypes.EventRsvpQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	if !ok {
+		return
+	}
+	event, ok := eventGetByUID(c, db, query.EventUID)
+	if !ok {
+		return
+	}
+	occurrenceDate, ok := eventRsvpOccurrenceDate(c, db, event, query.OccurrenceDate, false)
+	if !ok {
+		return
+	}
+
+	eventRsvpRespond(c, db, event, occurrenceDate, user.ID)
+}
+
+func EventRsvpPut(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.EventRsvpPutRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	if !ok {
+		return
+	}
+	event, ok := eventGetByUID(c, db, body.EventUID)
+	if !ok {
+		return
+	}
+	occurrenceDate, ok := eventRsvpOccurrenceDate(c, db, event, body.OccurrenceDate, true)
+	if !ok {
+		return
+	}
+
+	var promotedUserIDs []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		_, promotedUserIDs, err = models.EventRsvpSet(tx, event, occurrenceDate, user.ID, body.Status)
+		return err
+	})
+	if err != nil {
+		slog.Error("Unable to set event rsvp", "err", err)
+		c.AbortWithError(http.StatusInternalServerError, errors.New("Unable to set event response"))
+		return
+	}
+	eventNotifyWaitlistPromoted(db, promotedUserIDs, event)
+
+	eventRsvpRespond(c, db, event, occurrenceDate, user.ID)
+}
+
+func EventRsvpDelete(c *gin.Context) {
+	db := getDB(c)
+
+	var query sharedtypes.EventRsvpQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	if !ok {
+		return
+	}
+	event, ok := eventGetByUID(c, db, query.EventUID)
+	if !ok {
+		return
+	}
+	occurrenceDate, ok := eventRsvpOccurrenceDate(c, db, event, query.OccurrenceDate, false)
+	if !ok {
+		return
+	}
+
+	var promotedUserIDs []uint
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		promotedUserIDs, err = models.EventRsvpDelete(tx, event, occurrenceDate, user.ID)
+		return err
+	})
+	if err != nil {
+		slog.Error("Unable to cancel event rsvp", "err", err)
+		c.AbortWithError(http.StatusInternalServerError, errors.New("Unable to cancel event response"))
+		return
+	}
+	eventNotifyWaitlistPromoted(db, promotedUserIDs, event)
+}
+
+// Only visible to the organiser, hosts of the loop the event belongs to and root admins.
+// Organisers that are not a host only see the contact details that attendees share with them.
+func EventAttendeesGet(c *gin.Context) {
+	db := getDB(c)
+
+	var query sharedtypes.EventRsvpQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, authUser, event := auth.AuthenticateEvent(c, db, query.EventUID)
+	if !ok {
+		return
+	}
+	occurrenceDate, ok := eventRsvpOccurrenceDate(c, db, event, query.OccurrenceDate, false)
+	if !ok {
+		return
+	}
+
+	attendees, err := models.EventRsvpGetAttendees(db, event.ID, occurrenceDate)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	isChainAdmin := false
+	if event.ChainUID != nil && !authUser.IsRootAdmin {
+		if err := authUser.AddUserChainsToObject(db); err != nil {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, models.ErrAddUserChainsToObject.Error())
+			return
+		}
+		_, isChainAdmin = authUser.IsPartOfChain(*event.ChainUID)
+	}
+	if !authUser.IsRootAdmin && !isChainAdmin {
+		err = models.UserOmitEventAttendeeData(db, event, attendees, authUser.ID)
+		if err != nil {
+			c.AbortWithError(http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, attendees)
+}
+
+func eventNotifyWaitlistPromoted(db *gorm.DB, userIDs []uint, event *models.Event) {
+	if len(userIDs) == 0 {
+		return
+	}
+
+	userUIDs := []string{}
+	err := db.Raw(`SELECT uid FROM users WHERE id IN ?`, userIDs).Scan(&userUIDs).Error
+	if err != nil {
+		slog.Error("Unable to find users promoted from the waitlist", "err", err)
+		return
+	}
+	go services.NotifyPush(db, sharedtypes.NotificationTypeEventWaitlist, userUIDs,
+		views.NotificationEnumEventWaitlist,
+		app.OneSignalEllipsis(event.Name))
+}
+
+// Informs everyone that is going or might be going to an upcoming occurrence about a new date or address
+func eventNotifyChanged(db *gorm.DB, event *models.Event) {
+	contacts, err := models.EventRsvpGetContactsUpcoming(db, event.ID)
+	if err != nil {
+		slog.Error("Unable to find event attendees", "err", err)
+		return
+	}
+	if len(contacts) == 0 {
+		return
+	}
+
+	date := event.Date
+	if event.RRule != "" {
+		overrides, err := models.EventOccurrenceGetAllByEventIDs(db, []uint{event.ID})
+		if err != nil {
+			slog.Error("Unable to find event occurrences", "err", err)
+			return
+		}
+		next := event.NextOccurrence(time.Now(), time.Now().Add(models.EventRecurrenceHorizon), overrides[event.ID])
+		if next == nil {
+			return
+		}
+		date = next.Date
+	}
+
+	userUIDs := []string{}
+	for _, contact := range contacts {
+		userUIDs = append(userUIDs, contact.UserUID)
+		if contact.Email.Valid {
+			go services.NotifyEmail(db, sharedtypes.NotificationTypeEventChanged, contact.Email.String, func() error {
+				return views.EmailEventChanged(db, contact.I18n, contact.Name, contact.Email.String, event.Name, event.UID, eventFormatDate(date, event), event.Address)
+			})
+		}
+	}
+	go services.NotifyPush(db, sharedtypes.NotificationTypeEventChanged, userUIDs,
+		views.NotificationEnumEventChanged,
+		app.OneSignalEllipsis(event.Name))
+}
@@ -0,0 +1,290 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	ics "github.com/arran4/golang-ical"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+const (
+	icalTimestampFormatUtc   = "20060102T150405Z"
+	icalTimestampFormatLocal = "20060102T150405"
+)
+
+// Events that ended longer ago are left out of feeds
+const icalFeedSqlWhere = `(
+	(events.rrule = '' AND COALESCE(events.date_end, events.date) > NOW() - INTERVAL 30 DAY)
+	OR (events.rrule <> '' AND (events.rrule_until IS NULL OR events.rrule_until > NOW() - INTERVAL 30 DAY))
+)`
+
+// Adds events including the cancelled and moved occurrences of recurring events
+func icalAddEvents(db *gorm.DB, cal *ics.Calendar, events []models.Event) error {
+	overrides, err := models.EventOccurrenceGetAllByEventIDs(db, lo.FilterMap(events, func(e models.Event, _ int) (uint, bool) {
+		return e.ID, e.RRule != ""
+	}))
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		icalE := cal.AddEvent(event.UID)
+		icalSetEventProperties(icalE, &event, event.Date, event.DateEnd)
+		icalE.SetCreatedTime(event.CreatedAt)
+		icalE.SetModifiedAt(event.UpdatedAt)
+		icalE.SetDtStampTime(event.UpdatedAt)
+		if event.RRule == "" {
+			continue
+		}
+
+		icalE.AddRrule(event.RRule)
+		for _, o := range overrides[event.ID] {
+			if o.IsCancelled {
+				value, params := icalRecurrenceDate(&event, o.OriginalDate)
+				icalE.AddExdate(value, params...)
+				continue
+			}
+			if o.Date == nil {
+				continue
+			}
+
+			// a moved occurrence is its own VEVENT with the same UID
+			icalO := cal.AddEvent(event.UID)
+			value, params := icalRecurrenceDate(&event, o.OriginalDate)
+			icalO.SetProperty(ics.ComponentPropertyRecurrenceId, value, params...)
+			icalSetEventProperties(icalO, &event, *o.Date, o.DateEnd)
+			icalO.SetCreatedTime(o.CreatedAt)
+			icalO.SetModifiedAt(o.UpdatedAt)
+			icalO.SetDtStampTime(o.UpdatedAt)
+		}
+	}
+	return nil
+}
+
+// Recurring events outside of UTC use local times with a TZID
+func icalIsLocal(event *models.Event) bool {
+	return event.RRule != "" && event.Location() != time.UTC
+}
+
+// Formats the original date of an occurrence the same way as the start date of the event
+func icalRecurrenceDate(event *models.Event, t time.Time) (string, []ics.PropertyParameter) {
+	if icalIsLocal(event) {
+		return t.In(event.Location()).Format(icalTimestampFormatLocal), []ics.PropertyParameter{ics.WithTZID(event.Timezone)}
+	}
+	return t.UTC().Format(icalTimestampFormatUtc), nil
+}
+
+func icalSetEventProperties(icalE *ics.VEvent, event *models.Event, date time.Time, dateEnd *time.Time) {
+	icalE.SetSequence(event.Sequence)
+	end := date.Add(time.Duration(2) * time.Hour)
+	if dateEnd != nil {
+		end = *dateEnd
+	}
+	if icalIsLocal(event) {
+		// calendar apps expand the rule in the timezone of the start date, like the server does
+		loc := event.Location()
+		icalE.SetProperty(ics.ComponentPropertyDtStart, date.In(loc).Format(icalTimestampFormatLocal), ics.WithTZID(event.Timezone))
+		icalE.SetProperty(ics.ComponentPropertyDtEnd, end.In(loc).Format(icalTimestampFormatLocal), ics.WithTZID(event.Timezone))
+	} else {
+		icalE.SetStartAt(date)
+		icalE.SetEndAt(end)
+	}
+	icalE.SetSummary(event.Name)
+	icalE.SetLocation(fmt.Sprintf("https://www.google.com/maps/@%v,%v,17z", event.Latitude, event.Longitude))
+	icalE.SetDescription(event.Description)
+	icalE.SetURL(fmt.Sprintf("%s/events/%s", app.Config.SITE_BASE_URL_FE, event.UID))
+	if event.UserEmail != nil && *event.UserEmail != "" {
+		icalE.SetOrganizer(*event.UserEmail, ics.WithCN(lo.FromPtr(event.UserName)))
+	}
+}
+
+func icalFeedRespond(c *gin.Context, db *gorm.DB, name string, events []models.Event) {
+	cal := ics.NewCalendar()
+	cal.SetMethod(ics.MethodPublish)
+	cal.SetName(name)
+	cal.SetXWRCalName(name)
+	cal.SetRefreshInterval("PT6H")
+	cal.SetXPublishedTTL("PT6H")
+	err := icalAddEvents(db, cal, events)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(cal.Serialize()))
+}
+
+func ICalFeedChain(c *gin.Context) {
+	db := getDB(c)
+
+	var uri struct {
+		UID string `uri:"uid" binding:"required,uuid"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chain := models.Chain{}
+	err := db.Raw(`SELECT * FROM chains WHERE uid = ? AND deleted_at IS NULL LIMIT 1`, uri.UID).Scan(&chain).Error
+	if err != nil || chain.ID == 0 || !icalChainIsVisible(c, db, &chain) {
+		c.String(http.StatusNotFound, "Loop not found")
+		return
+	}
+
+	events := []models.Event{}
+	err = db.Raw(models.EventGetSql+`WHERE events.chain_id = ? AND `+icalFeedSqlWhere+` ORDER BY events.date ASC`, chain.ID).Scan(&events).Error
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	icalFeedRespond(c, db, chain.Name, events)
+}
+
+// Unpublished loops only share their events with members,
+// the web app sends the login token while calendar apps can only subscribe to published loops
+func icalChainIsVisible(c *gin.Context, db *gorm.DB, chain *models.Chain) bool {
+	if chain.Published {
+		return true
+	}
+	token, ok := auth.TokenReadFromRequest(c)
+	if !ok {
+		return false
+	}
+	user, err := auth.AuthenticateToken(db, token)
+	if err != nil {
+		return false
+	}
+	if user.IsRootAdmin {
+		return true
+	}
+	_, isMember, err := models.UserChainCheckIfRelationExist(db, chain.ID, user.ID, true)
+	return err == nil && isMember
+}
+
+func ICalFeedArea(c *gin.Context) {
+	db := getDB(c)
+
+	var query sharedtypes.ICalFeedAreaQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events := []models.Event{}
+	sql := fmt.Sprintf("%sWHERE %s <= ? AND %s ORDER BY events.date ASC", models.EventGetSql, sqlCalcDistance("events.latitude", "events.longitude", "?", "?"), icalFeedSqlWhere)
+	err := db.Raw(sql, query.Latitude, query.Longitude, query.Radius).Scan(&events).Error
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	icalFeedRespond(c, db, "Clothing Loop events", events)
+}
+
+// The token in the url is the only authentication, calendar apps can not send headers
+func ICalFeedUser(c *gin.Context) {
+	db := getDB(c)
+
+	var uri struct {
+		Token string `uri:"token" binding:"required"`
+	}
+	if err := c.ShouldBindUri(&uri); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID, err := models.UserCalendarTokenFindUserID(db, uri.Token)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+	if userID == 0 {
+		c.String(http.StatusNotFound, "Calendar not found")
+		return
+	}
+
+	events := []models.Event{}
+	err = db.Raw(models.EventGetSql+`WHERE (
+	events.user_id = ?
+	OR events.chain_id IN (
+		SELECT chain_id FROM user_chains WHERE user_id = ? AND is_approved = TRUE
+	)
+	OR events.id IN (
+		SELECT event_id FROM event_rsvps WHERE user_id = ? AND status IN ('going', 'maybe')
+	)
+) AND `+icalFeedSqlWhere+` ORDER BY events.date ASC`, userID, userID, userID).Scan(&events).Error
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	icalFeedRespond(c, db, "Clothing Loop", events)
+}
+
+func userCalendarTokenResponse(t *sharedtypes.UserCalendarToken) sharedtypes.UserCalendarTokenResponse {
+	return sharedtypes.UserCalendarTokenResponse{
+		Token:     t.Token,
+		Url:       fmt.Sprintf("%s/v2/ical/user/%s", app.Config.SITE_BASE_URL_API, t.Token),
+		CreatedAt: t.CreatedAt,
+	}
+}
+
+func UserCalendarTokenGet(c *gin.Context) {
+	db := getDB(c)
+
+	ok, user, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	if !ok {
+		return
+	}
+
+	t, err := models.UserCalendarTokenFindOrCreate(db, user.ID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, userCalendarTokenResponse(t))
+}
+
+// Revokes the previous feed url
+func UserCalendarTokenReset(c *gin.Context) {
+	db := getDB(c)
+
+	ok, user, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	if !ok {
+		return
+	}
+
+	t, err := models.UserCalendarTokenReset(db, user.ID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, userCalendarTokenResponse(t))
+}
+
+func UserCalendarTokenDelete(c *gin.Context) {
+	db := getDB(c)
+
+	ok, user, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	if !ok {
+		return
+	}
+
+	err := models.UserCalendarTokenDelete(db, user.ID)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+// Starts the double opt-in, the alert is only active once the link in the confirmation email is used.
+// Subscribing again with the same email address replaces the previous alert once confirmed.
+func LocationAlertSubscribe(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.LocationAlertSubscribeRequest
+	if err := c.ShouldBindBodyWith(&body, binding.JSON); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if ok := models.ValidateAllSizeEnum(body.Sizes); !ok {
+		c.String(http.StatusBadRequest, models.ErrSizeInvalid.Error())
+		return
+	}
+	if ok := models.ValidateAllGenderEnum(body.Genders); !ok {
+		c.String(http.StatusBadRequest, models.ErrGenderInvalid.Error())
+		return
+	}
+
+	i18n, _ := c.Cookie("i18next")
+	alert := &models.LocationAlert{
+		Email:     body.Email,
+		Name:      body.Name,
+		I18n:      lo.Substring(i18n, 0, 5),
+		Latitude:  body.Latitude,
+		Longitude: body.Longitude,
+		Radius:    body.Radius,
+		Sizes:     lo.Uniq(body.Sizes),
+		Genders:   lo.Uniq(body.Genders),
+	}
+	token, err := models.LocationAlertCreatePending(db, alert)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to save location alert")
+		return
+	}
+
+	err = views.EmailConfirmLocationAlert(db, lo.Substring(i18n, 0, 5), body.Name, body.Email, body.Radius, token)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to send confirmation email")
+		return
+	}
+}
+
+// Opened from the link in the confirmation email
+func LocationAlertConfirm(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		Token string `form:"token" binding:"required,len=64"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, err := models.LocationAlertConfirm(db, query.Token)
+	if err != nil {
+		if errors.Is(err, models.ErrLocationAlertConfirmInvalid) {
+			c.String(http.StatusNotFound, err.Error())
+		} else {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to confirm location alert")
+		}
+		return
+	}
+
+	c.String(http.StatusOK, "Your alert is confirmed, we will email you when a Loop opens near you.")
+}
+
+// Opened from the signed link in a location alert email,
+// GET shows a page to confirm and POST unsubscribes
+func LocationAlertUnsubscribe(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		Email     string `form:"e" binding:"required"`
+		Signature string `form:"s" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	emailB, err := base64.RawURLEncoding.DecodeString(query.Email)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Malformed url: email required")
+		return
+	}
+	email := string(emailB)
+	if !app.UnsubscribeVerify(email, app.UnsubscribeTypeLocationAlert, query.Signature) {
+		c.String(http.StatusUnauthorized, "Invalid unsubscribe link")
+		return
+	}
+	if c.Request.Method == http.MethodGet {
+		unsubscribeConfirmPage(c, "Do you want to stop receiving alerts when a Loop opens near you?")
+		return
+	}
+
+	err = models.LocationAlertDeleteByEmail(db, email)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to unsubscribe")
+		return
+	}
+
+	c.String(http.StatusOK, "You will no longer receive alerts when a Loop opens near you.")
+}
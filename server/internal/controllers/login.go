@@ -137,6 +137,16 @@ func LoginValidate(c *gin.Context) {
 		}
 	}
 
+	// The login email arrived, so the address can receive mail again
+	if user.EmailUndeliverableAt != nil {
+		err = models.UserClearEmailUndeliverable(db, user.ID)
+		if err != nil {
+			slog.Error("Unable to clear undeliverable email", "err", err)
+		}
+		user.EmailUndeliverableAt = nil
+		user.EmailUndeliverableReason = ""
+	}
+
 	// re-add IsEmailVerified, see TokenVerify
 	user.IsEmailVerified = true
 
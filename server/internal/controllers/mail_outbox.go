@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/pkg/cursor"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+// Lists the mail outbox newest first, only allowed for root admins
+func MailOutboxGetAll(c *gin.Context) {
+	db := getDB(c)
+	var query struct {
+		Status    string `form:"status" binding:"omitempty,oneof=queued sent failed bounced"`
+		ToAddress string `form:"to_address" binding:"omitempty,email"`
+		sharedtypes.PaginationQuery
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, _ := auth.Authenticate(c, db, auth.AuthState4RootUser, "")
+	if !ok {
+		return
+	}
+
+	ok, cur := paginationDecodeCursor(c, query.PaginationQuery)
+	if !ok {
+		return
+	}
+	limit := paginationLimit(query.PaginationQuery)
+	beforeID := uint(0)
+	if cur != nil {
+		beforeID = cur.ID
+	}
+
+	mails, err := models.MailGetAll(db, query.Status, query.ToAddress, beforeID, limit+1)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve emails")
+		return
+	}
+
+	c.JSON(http.StatusOK, paginationTrim(mails, limit, func(m models.Mail) cursor.Cursor {
+		return cursor.Cursor{ID: m.ID}
+	}))
+}
+
+// Returns a single mail including its body and every delivery attempt,
+// the body of a mail with a login token is left out
+func MailOutboxGet(c *gin.Context) {
+	db := getDB(c)
+	var query struct {
+		ID uint `form:"id" binding:"required"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, _ := auth.Authenticate(c, db, auth.AuthState4RootUser, "")
+	if !ok {
+		return
+	}
+
+	m, err := models.MailGet(db, query.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusNotFound, "Email not found")
+		} else {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve email")
+		}
+		return
+	}
+	if m.Sensitive {
+		m.Body = ""
+	}
+	attempts, err := models.MailGetAttempts(db, m.ID)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve email attempts")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mail":     m,
+		"attempts": attempts,
+	})
+}
+
+func MailOutboxResend(c *gin.Context) {
+	db := getDB(c)
+	var body struct {
+		ID uint `json:"id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, _ := auth.Authenticate(c, db, auth.AuthState4RootUser, "")
+	if !ok {
+		return
+	}
+
+	err := app.MailResend(db, body.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusNotFound, "Email not found")
+		} else if errors.Is(err, models.ErrMailRedacted) {
+			c.String(http.StatusConflict, err.Error())
+		} else {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to resend email")
+		}
+		return
+	}
+}
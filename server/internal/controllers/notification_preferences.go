@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+func NotificationPreferencesGet(c *gin.Context) {
+	db := getDB(c)
+
+	ok, user, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	if !ok {
+		return
+	}
+
+	preferences, err := models.NotificationPreferenceGetAll(db, user.ID)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve notification preferences")
+		return
+	}
+
+	c.JSON(http.StatusOK, sharedtypes.NotificationPreferencesResponse{Preferences: preferences})
+}
+
+func NotificationPreferencesUpdate(c *gin.Context) {
+	db := getDB(c)
+	var body sharedtypes.NotificationPreferencesUpdateRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	if !ok {
+		return
+	}
+
+	for _, p := range body.Preferences {
+		if !models.NotificationTypeHasChannel(p.Type, p.Channel) {
+			c.String(http.StatusBadRequest, fmt.Sprintf("Notification type %s is not sent by %s", p.Type, p.Channel))
+			return
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, p := range body.Preferences {
+			err := models.NotificationPreferenceSet(tx, user.ID, p.Type, p.Channel, p.Enabled)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to update notification preferences")
+		return
+	}
+
+	preferences, err := models.NotificationPreferenceGetAll(db, user.ID)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve notification preferences")
+		return
+	}
+
+	c.JSON(http.StatusOK, sharedtypes.NotificationPreferencesResponse{Preferences: preferences})
+}
+
+var unsubscribeConfirmTemplate = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Unsubscribe - The Clothing Loop</title>
+</head>
+<body style="font-family: sans-serif; max-width: 480px; margin: 40px auto; padding: 0 16px">
+<form method="post" action="{{ .Action }}">
+<p>{{ .Question }}</p>
+<button type="submit">Unsubscribe</button>
+</form>
+</body>
+</html>`))
+
+// Link scanners and mail prefetchers open links in emails, so the link only shows a page
+// to confirm with a POST. Email clients with one-click unsubscribe POST to the link directly.
+func unsubscribeConfirmPage(c *gin.Context, question string) {
+	buf := &bytes.Buffer{}
+	err := unsubscribeConfirmTemplate.Execute(buf, gin.H{
+		"Action":   "?" + c.Request.URL.RawQuery,
+		"Question": question,
+	})
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to show unsubscribe page")
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// The link in the footer of optional emails, turns off that email without logging in.
+// GET shows a page to confirm, POST unsubscribes and is used by email clients for one-click unsubscribe.
+func NotificationUnsubscribe(c *gin.Context) {
+	db := getDB(c)
+	var query sharedtypes.NotificationUnsubscribeQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	emailB, err := base64.RawURLEncoding.DecodeString(query.Email)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Malformed url: email required")
+		return
+	}
+	email := string(emailB)
+	if !app.UnsubscribeVerify(email, query.Type, query.Signature) {
+		c.String(http.StatusUnauthorized, "Invalid unsubscribe link")
+		return
+	}
+	if !models.NotificationTypeHasChannel(query.Type, sharedtypes.NotificationChannelEmail) {
+		c.String(http.StatusBadRequest, "Unknown notification type")
+		return
+	}
+	if c.Request.Method == http.MethodGet {
+		unsubscribeConfirmPage(c, "Do you want to unsubscribe from these emails?")
+		return
+	}
+
+	user, err := models.UserGetByEmail(db, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusNotFound, "User not found")
+		} else {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to find user")
+		}
+		return
+	}
+
+	err = models.NotificationPreferenceSet(db, user.ID, query.Type, sharedtypes.NotificationChannelEmail, false)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to unsubscribe")
+		return
+	}
+
+	c.String(http.StatusOK, "You are unsubscribed from these emails, this can be changed in your notification settings.")
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/pkg/cursor"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+const paginationDefaultLimit = 50
+
+// Lists are only paginated if limit or cursor is set,
+// otherwise the full list is returned to keep older clients working.
+func paginationIsSet(p sharedtypes.PaginationQuery) bool {
+	return p.Limit != 0 || p.Cursor != ""
+}
+
+func paginationLimit(p sharedtypes.PaginationQuery) int {
+	if p.Limit == 0 {
+		return paginationDefaultLimit
+	}
+	return p.Limit
+}
+
+func paginationDecodeCursor(c *gin.Context, p sharedtypes.PaginationQuery) (ok bool, cur *cursor.Cursor) {
+	cur, err := cursor.Decode(p.Cursor)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return false, nil
+	}
+	return true, cur
+}
+
+// Expects items to be queried with a limit of one more than the page size,
+// the extra item only indicates that a next page exists.
+func paginationTrim[T any](items []T, limit int, cursorOf func(T) cursor.Cursor) sharedtypes.PaginatedResponse[T] {
+	res := sharedtypes.PaginatedResponse[T]{Items: items}
+	if len(items) > limit {
+		res.Items = items[:limit]
+		res.NextCursor = cursor.Encode(cursorOf(res.Items[limit-1]))
+	}
+	return res
+}
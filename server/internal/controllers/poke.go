@@ -5,8 +5,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/services"
 	"github.com/the-clothing-loop/website/server/internal/views"
 	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
 )
 
 func Poke(c *gin.Context) {
@@ -55,12 +57,14 @@ WHERE uc.is_chain_admin = TRUE AND u.email IS NOT NULL AND uc.chain_id IN (
 	}
 
 	for _, v := range userAdmins {
-		go views.EmailPoke(db, v.I18n,
-			v.Name,
-			v.Email,
-			user.Name,
-			v.ChainName,
-		)
+		go services.NotifyEmail(db, sharedtypes.NotificationTypePoke, v.Email, func() error {
+			return views.EmailPoke(db, v.I18n,
+				v.Name,
+				v.Email,
+				user.Name,
+				v.ChainName,
+			)
+		})
 	}
 
 	if err := user.SetLastPokeToNow(db); err != nil {
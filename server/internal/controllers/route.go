@@ -1,17 +1,17 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
 	"math/rand/v2"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/samber/lo"
 	"github.com/shopspring/decimal"
 	"github.com/the-clothing-loop/website/server/internal/app/auth"
 	"github.com/the-clothing-loop/website/server/internal/models"
-	"github.com/the-clothing-loop/website/server/pkg/ring_ext"
+	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
 	"github.com/the-clothing-loop/website/server/pkg/tsp"
 	"github.com/the-clothing-loop/website/server/sharedtypes"
 	"gorm.io/gorm"
@@ -42,6 +42,42 @@ func RouteOrderGet(c *gin.Context) {
 	c.JSON(200, routeOrder)
 }
 
+// The member that should receive a bag from the given user, members on holiday are skipped
+func RouteNextHolderGet(c *gin.Context) {
+	db := getDB(c)
+
+	var query struct {
+		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+		UserUID  string `form:"user_uid" binding:"required,uuid"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, _, chain := auth.Authenticate(c, db, auth.AuthState2UserOfChain, query.ChainUID)
+	if !ok {
+		return
+	}
+	user, err := models.UserGetByUID(db, query.UserUID, false)
+	if err != nil {
+		c.String(http.StatusBadRequest, "User not found")
+		return
+	}
+
+	nextUserUID, err := models.UserChainGetNextHolder(db, chain.ID, user.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.String(http.StatusNotFound, "Every other member is on holiday")
+		} else {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to find the next member")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user_uid": nextUserUID})
+}
+
 func RouteOrderSet(c *gin.Context) {
 	db := getDB(c)
 
@@ -123,11 +159,14 @@ func GetRouteCoordinates(c *gin.Context) {
 
 	cities := retrieveChainUsersAsTspCities(db, chain.ID)
 
+	isAllowedAll := isChainAdmin || authUser.IsRootAdmin
+	privacy, err := models.UserPrivacyGet(db, chain, authUser.ID)
+	if err != nil {
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Internal error hiding user information")
+		return
+	}
+
 	response := []sharedtypes.RouteCoordinatesGetResponseItem{}
-	r := ring_ext.NewWithValues(cities.FilterOutIsPausedToKeys(authUser.UID))
-	slog.Debug("Chain route privacy", "chain uid", chain.UID, "route privacy", chain.RoutePrivacy)
-	closeBy := ring_ext.GetSurroundingValues(r, authUser.UID, chain.RoutePrivacy)
-	slog.Debug("chain surrounding", "closeBy", closeBy)
 	for _, city := range cities.Arr {
 		item := sharedtypes.RouteCoordinatesGetResponseItem{
 			UserUID:    city.Key,
@@ -135,10 +174,8 @@ func GetRouteCoordinates(c *gin.Context) {
 			Longitude:  city.Longitude,
 			RouteOrder: city.RouteOrder,
 		}
-		isCloseBy := lo.Contains(closeBy, item.UserUID)
-		isMe := authUser.UID == item.UserUID
-		if !(isMe || isChainAdmin || authUser.IsRootAdmin || isCloseBy) {
-			slog.Debug("Participant censorship", "uid", item.UserUID, "isChainAdmin", isChainAdmin, "isRootAdmin", authUser.IsRootAdmin, "isCloseBy", isCloseBy)
+		if !(isAllowedAll || privacy.CanSeeLocationByUID(item.UserUID)) {
+			slog.Debug("Participant censorship", "uid", item.UserUID)
 			item.UserUID = ""
 
 			// Randomize the 5 & 6th decimal places
@@ -167,33 +204,21 @@ func (a *ArrTspCityWithIsPaused) ToTspCities() []tsp.City[string] {
 	return result
 }
 
-// removes all cities except me and where is_paused is false
-func (a *ArrTspCityWithIsPaused) FilterOutIsPausedToKeys(me string) []string {
-	result := []string{}
-	for _, v := range a.Arr {
-		if v.IsPaused && v.Key != me {
-			continue
-		}
-		result = append(result, v.Key)
-	}
-	return result
-}
-
 func retrieveChainUsersAsTspCities(db *gorm.DB, chainID uint) *ArrTspCityWithIsPaused {
 	allUserChains := ArrTspCityWithIsPaused{}
 
 	err := db.Raw(fmt.Sprintf(`
 	SELECT
-		users.uid AS %skey%s,
-		users.latitude AS latitude,
-		users.longitude AS longitude,
-		COALESCE(users.paused_until IS NOT NULL, user_chains.is_paused) AS is_paused
-	FROM user_chains
-	LEFT JOIN users ON user_chains.user_id = users.id
-	WHERE user_chains.chain_id = ? 
-	AND users.is_email_verified = TRUE 
-	AND user_chains.is_approved = TRUE
-	ORDER BY user_chains.route_order ASC`, "`", "`"), chainID).Scan(&allUserChains.Arr).Error
+		u.uid AS %skey%s,
+		u.latitude AS latitude,
+		u.longitude AS longitude,
+		%s AS is_paused
+	FROM user_chains AS uc
+	LEFT JOIN users AS u ON uc.user_id = u.id
+	WHERE uc.chain_id = ? 
+	AND u.is_email_verified = TRUE 
+	AND uc.is_approved = TRUE
+	ORDER BY uc.route_order ASC`, "`", "`", models.UserChainIsPausedSql), chainID).Scan(&allUserChains.Arr).Error
 	if err != nil {
 		slog.Error("Unable to retrieve associations between a loop and its users", "err", err)
 		return nil
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/the-clothing-loop/website/server/internal/models"
 	"github.com/the-clothing-loop/website/server/internal/services"
 	"github.com/the-clothing-loop/website/server/internal/views"
+	"github.com/the-clothing-loop/website/server/pkg/cursor"
 	ginext "github.com/the-clothing-loop/website/server/pkg/gin_ext"
 	"github.com/the-clothing-loop/website/server/sharedtypes"
 	"gopkg.in/guregu/null.v3"
@@ -45,6 +47,7 @@ func UserGet(c *gin.Context) {
 
 	ok := false
 	var authUser *models.User
+	var chain *models.Chain
 	if query.ChainUID == "" {
 		ok, authUser, _ = auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
 
@@ -53,12 +56,14 @@ func UserGet(c *gin.Context) {
 			return
 		}
 	} else {
-		ok, authUser, _ = auth.Authenticate(c, db, auth.AuthState3AdminChainUser, query.ChainUID)
+		ok, authUser, chain = auth.Authenticate(c, db, auth.AuthState2UserOfChain, query.ChainUID)
 	}
 	if !ok {
 		return
 	}
 	isMe := authUser.UID == query.UserUID
+	_, isAuthUserChainAdmin := authUser.IsPartOfChain(query.ChainUID)
+	isParticipant := !isMe && !isAuthUserChainAdmin && !authUser.IsRootAdmin
 	if !isMe && query.AddApprovedTOH && !authUser.IsRootAdmin {
 		c.String(http.StatusUnauthorized, "User details requested are not authorized")
 		return
@@ -76,6 +81,24 @@ func UserGet(c *gin.Context) {
 		return
 	}
 
+	// participants only see fellow members of the loop, with the contact fields the member shares with them
+	if isParticipant {
+		uc, isMember := lo.Find(user.Chains, func(uc sharedtypes.UserChain) bool { return uc.ChainUID == chain.UID })
+		if !isMember {
+			c.String(http.StatusUnauthorized, "User details requested are not authorized")
+			return
+		}
+		user.Chains = []sharedtypes.UserChain{uc}
+		user.EmailUndeliverableAt = nil
+		user.EmailUndeliverableReason = ""
+		users, err := models.UserOmitData(db, chain, []models.User{*user}, authUser.ID)
+		if err != nil {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Internal error hiding user information")
+			return
+		}
+		user = &users[0]
+	}
+
 	if query.AddNotification && (isMe || authUser.IsRootAdmin) {
 		err := user.AddNotificationChainUIDs(db)
 		if err != nil {
@@ -96,6 +119,7 @@ func UserGetAllOfChain(c *gin.Context) {
 
 	var query struct {
 		ChainUID string `form:"chain_uid" binding:"required,uuid"`
+		sharedtypes.PaginationQuery
 	}
 	if err := c.ShouldBindQuery(&query); err != nil {
 		c.String(http.StatusBadRequest, err.Error())
@@ -106,23 +130,55 @@ func UserGetAllOfChain(c *gin.Context) {
 	if !ok {
 		return
 	}
+	ok, cur := paginationDecodeCursor(c, query.PaginationQuery)
+	if !ok {
+		return
+	}
 
 	_, isAuthUserChainAdmin := authUser.IsPartOfChain(chain.UID)
 	isAuthState3AdminChainUser := isAuthUserChainAdmin || authUser.IsRootAdmin
 
 	// retrieve user from query
-	tx := db.Begin()
-	allUserChains, err := models.UserChainGetIndirectByChain(tx, chain.ID)
-	if err != nil {
-		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve associations between a loop and its users")
-		return
-	}
-	users, errUsersByChain := models.UserGetAllUsersByChain(tx, chain.ID)
-	if errUsersByChain != nil {
-		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve associated users of a loop")
-		return
+	isPaginated := paginationIsSet(query.PaginationQuery)
+	limit := paginationLimit(query.PaginationQuery)
+	var users []models.User
+	var allUserChains []sharedtypes.UserChain
+	var err error
+	if isPaginated {
+		// pages follow the route, so the cursor holds the route order of the last member
+		afterRouteOrder, afterUserID := 0, uint(0)
+		if cur != nil {
+			afterRouteOrder, err = strconv.Atoi(cur.Value)
+			if err != nil {
+				c.String(http.StatusBadRequest, cursor.ErrInvalid.Error())
+				return
+			}
+			afterUserID = cur.ID
+		}
+		users, err = models.UserGetPageByChain(db, chain.ID, afterRouteOrder, afterUserID, limit+1)
+		if err != nil {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve associated users of a loop")
+			return
+		}
+		allUserChains, err = models.UserChainGetIndirectByUserIDs(db, lo.Map(users, func(u models.User, _ int) uint { return u.ID }))
+		if err != nil {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve associations between a loop and its users")
+			return
+		}
+	} else {
+		tx := db.Begin()
+		allUserChains, err = models.UserChainGetIndirectByChain(tx, chain.ID)
+		if err != nil {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve associations between a loop and its users")
+			return
+		}
+		users, err = models.UserGetAllUsersByChain(tx, chain.ID)
+		if err != nil {
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to retrieve associated users of a loop")
+			return
+		}
+		tx.Commit()
 	}
-	tx.Commit()
 
 	for i, user := range users {
 		thisUserChains := []sharedtypes.UserChain{}
@@ -138,6 +194,14 @@ func UserGetAllOfChain(c *gin.Context) {
 
 	// omit user data from participants
 	if !isAuthState3AdminChainUser {
+		// only hosts need to know which members can not be reached
+		for i := range users {
+			if users[i].ID != authUser.ID {
+				users[i].EmailUndeliverableAt = nil
+				users[i].EmailUndeliverableReason = ""
+			}
+		}
+		// the privacy of each member depends on the whole route, which is read separately from the page
 		users, err = models.UserOmitData(db, chain, users, authUser.ID)
 		if err != nil {
 			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Internal error hiding user information")
@@ -145,6 +209,18 @@ func UserGetAllOfChain(c *gin.Context) {
 		}
 	}
 
+	if isPaginated {
+		c.JSON(200, paginationTrim(users, limit, func(u models.User) cursor.Cursor {
+			routeOrder := 0
+			for _, uc := range u.Chains {
+				if uc.ChainID == chain.ID {
+					routeOrder = uc.RouteOrder
+				}
+			}
+			return cursor.Cursor{ID: u.ID, Value: strconv.Itoa(routeOrder)}
+		}))
+		return
+	}
 	c.JSON(200, users)
 }
 
@@ -192,9 +268,32 @@ func UserUpdate(c *gin.Context) {
 			return
 		}
 	}
+	privacyChanges := map[string]*string{
+		"privacy_email":   body.PrivacyEmail,
+		"privacy_phone":   body.PrivacyPhone,
+		"privacy_address": body.PrivacyAddress,
+	}
+	for _, privacy := range privacyChanges {
+		if privacy == nil {
+			continue
+		}
+		if authUser.ID != user.ID {
+			c.String(http.StatusUnauthorized, "Only the member can change who sees their contact details")
+			return
+		}
+		if !lo.Contains(sharedtypes.UserFieldPrivacies, *privacy) {
+			c.String(http.StatusBadRequest, "Invalid privacy")
+			return
+		}
+	}
 
 	userChanges := map[string]interface{}{}
 	{
+		for column, privacy := range privacyChanges {
+			if privacy != nil {
+				userChanges[column] = *privacy
+			}
+		}
 		if body.Name != nil {
 			userChanges["name"] = *body.Name
 		}
@@ -211,15 +310,41 @@ func UserUpdate(c *gin.Context) {
 		if body.PausedUntil != nil {
 			if body.PausedUntil.After(time.Now()) {
 				userChanges["paused_until"] = body.PausedUntil
+				isOngoing := user.PausedUntil != nil && user.PausedUntil.After(time.Now()) &&
+					(user.PausedFrom == nil || !user.PausedFrom.After(time.Now()))
+				if body.PausedFrom != nil && body.PausedFrom.After(time.Now()) {
+					userChanges["paused_from"] = body.PausedFrom
+				} else if !isOngoing {
+					// an ongoing pause keeps its start date
+					userChanges["paused_from"] = time.Now()
+				}
 			} else {
 				userChanges["paused_until"] = null.Time{}
+				userChanges["paused_from"] = null.Time{}
 				if authUser.ID == user.ID {
-					db.Exec(`UPDATE user_chains SET is_paused = FALSE WHERE user_id = ?`, user.ID)
+					db.Exec(`UPDATE user_chains SET is_paused = FALSE, paused_from = NULL, paused_until = NULL WHERE user_id = ?`, user.ID)
 				}
 			}
 		}
 		if body.ChainPaused != nil && chain != nil {
-			db.Exec(`UPDATE user_chains SET is_paused = ? WHERE user_id = ? AND chain_id = ?`, *body.ChainPaused, user.ID, chain.ID)
+			if *body.ChainPaused {
+				pausedFrom := time.Now()
+				isScheduled := body.ChainPausedFrom != nil && body.ChainPausedFrom.After(pausedFrom)
+				if isScheduled {
+					pausedFrom = *body.ChainPausedFrom
+				}
+				var pausedUntil *time.Time
+				if body.ChainPausedUntil != nil && body.ChainPausedUntil.After(pausedFrom) {
+					pausedUntil = body.ChainPausedUntil
+				}
+				// paused_from is set first so that it still sees the previous is_paused, an ongoing pause keeps its start date
+				db.Exec(`
+UPDATE user_chains SET paused_from = IF(? OR NOT is_paused OR paused_from IS NULL, ?, paused_from), is_paused = TRUE, paused_until = ?
+WHERE user_id = ? AND chain_id = ?
+				`, isScheduled, pausedFrom, pausedUntil, user.ID, chain.ID)
+			} else {
+				db.Exec(`UPDATE user_chains SET is_paused = FALSE, paused_from = NULL, paused_until = NULL WHERE user_id = ? AND chain_id = ?`, user.ID, chain.ID)
+			}
 		}
 		if body.Sizes != nil {
 			j, _ := json.Marshal(body.Sizes)
@@ -426,6 +551,48 @@ UPDATE events SET user_id = (
 		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to remove onesignal connections")
 		return
 	}
+	err = tx.Exec(`DELETE FROM user_web_push_subscriptions WHERE user_id = ?`, user.ID).Error
+	if err != nil {
+		tx.Rollback()
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to remove web push subscriptions")
+		return
+	}
+	err = models.UserCalendarTokenDelete(tx, user.ID)
+	if err != nil {
+		tx.Rollback()
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to remove calendar token")
+		return
+	}
+	err = models.NotificationPreferenceDeleteAll(tx, user.ID)
+	if err != nil {
+		tx.Rollback()
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to remove notification preferences")
+		return
+	}
+	err = tx.Exec(`DELETE FROM event_rsvps WHERE user_id = ?`, user.ID).Error
+	if err != nil {
+		tx.Rollback()
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to remove event responses")
+		return
+	}
+	err = tx.Exec(`DELETE FROM chain_invite_uses WHERE user_id = ?`, user.ID).Error
+	if err != nil {
+		tx.Rollback()
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to remove used invites")
+		return
+	}
+	err = tx.Exec(`DELETE FROM chat_channel_reads WHERE user_id = ?`, user.ID).Error
+	if err != nil {
+		tx.Rollback()
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to remove chat read markers")
+		return
+	}
+	err = tx.Exec(`UPDATE contact_tickets SET assignee_user_id = NULL WHERE assignee_user_id = ?`, user.ID).Error
+	if err != nil {
+		tx.Rollback()
+		ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to unassign contact tickets")
+		return
+	}
 	err = tx.Exec(`DELETE FROM users WHERE id = ?`, user.ID).Error
 	if err != nil {
 		tx.Rollback()
@@ -443,6 +610,24 @@ UPDATE events SET user_id = (
 			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to disconnect all loop bag connections")
 			return
 		}
+		err = tx.Exec(`DELETE FROM chain_announcements WHERE chain_id IN ?`, chainIDsToDelete).Error
+		if err != nil {
+			tx.Rollback()
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to remove hosted loop announcements")
+			return
+		}
+		err = models.ChainInviteDeleteAllByChainIDs(tx, chainIDsToDelete...)
+		if err != nil {
+			tx.Rollback()
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to remove hosted loop invites")
+			return
+		}
+		err = models.ChainFacetsDeleteAll(tx, chainIDsToDelete...)
+		if err != nil {
+			tx.Rollback()
+			ginext.AbortWithErrorInBody(c, http.StatusInternalServerError, err, "Unable to remove hosted loop search data")
+			return
+		}
 		err = tx.Exec(`DELETE FROM user_chains WHERE chain_id IN ?`, chainIDsToDelete).Error
 		if err != nil {
 			tx.Rollback()
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/app/auth"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+// The applicationServerKey to pass to PushManager.subscribe()
+func WebPushPublicKeyGet(c *gin.Context) {
+	publicKey := app.WebPushPublicKey()
+	if publicKey == "" {
+		c.String(http.StatusNotFound, "Web push is not configured")
+		return
+	}
+
+	c.JSON(http.StatusOK, sharedtypes.WebPushPublicKeyResponse{PublicKey: publicKey})
+}
+
+func UserWebPushSubscriptionPut(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.UserWebPushSubscriptionRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := app.WebPushValidateEndpoint(body.Endpoint); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	if !ok {
+		return
+	}
+
+	err := models.UserWebPushSubscriptionPut(db, user.ID, body.Endpoint, body.Keys.P256dh, body.Keys.Auth)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+}
+
+func UserWebPushSubscriptionDelete(c *gin.Context) {
+	db := getDB(c)
+
+	var body sharedtypes.UserWebPushSubscriptionDeleteRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ok, user, _ := auth.Authenticate(c, db, auth.AuthState1AnyUser, "")
+	if !ok {
+		return
+	}
+
+	err := models.UserWebPushSubscriptionDeleteByUser(db, user.ID, body.Endpoint)
+	if err != nil {
+		c.AbortWithError(http.StatusInternalServerError, err)
+		return
+	}
+}
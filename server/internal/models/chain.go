@@ -20,13 +20,13 @@ type Chain struct {
 	ID                            uint
 	UID                           string      `gorm:"uniqueIndex"`
 	FID                           zero.String `gorm:"column:fid"`
-	Name                          string
-	Description                   string
+	Name                          string      `gorm:"index:idx_chains_search,class:FULLTEXT"`
+	Description                   string      `gorm:"index:idx_chains_search,class:FULLTEXT"`
 	Address                       string
 	CountryCode                   string
 	Image                         *string
-	Latitude                      float64
-	Longitude                     float64
+	Latitude                      float64 `gorm:"index:idx_chains_location,priority:1"`
+	Longitude                     float64 `gorm:"index:idx_chains_location,priority:2"`
 	Radius                        float32
 	Published                     bool
 	OpenToNewMembers              bool
@@ -174,6 +174,21 @@ func (c *Chain) Delete(db *gorm.DB) error {
 		return err
 	}
 
+	err = tx.Exec(`DELETE FROM chain_announcements WHERE chain_id = ?`, c.ID).Error
+	if err != nil {
+		return err
+	}
+
+	err = ChainInviteDeleteAllByChainIDs(tx, c.ID)
+	if err != nil {
+		return err
+	}
+
+	err = ChainFacetsDeleteAll(tx, c.ID)
+	if err != nil {
+		return err
+	}
+
 	err = tx.Exec(`DELETE FROM user_chains WHERE chain_id = ?`, c.ID).Error
 	if err != nil {
 		return err
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+// Maximum announcements a loop can send within ChainAnnouncementLimitWithin
+const ChainAnnouncementLimit = 3
+const ChainAnnouncementLimitWithin = 7 * 24 * time.Hour
+
+const chainAnnouncementGetSql = `SELECT
+	ca.id,
+	ca.uid,
+	ca.chain_id,
+	c.uid AS chain_uid,
+	ca.author_user_id,
+	u.uid AS author_user_uid,
+	u.name AS author_name,
+	ca.subject,
+	ca.body,
+	ca.filter_sizes,
+	ca.wardens_only,
+	ca.recipient_count,
+	ca.emailed_count,
+	ca.created_at
+FROM chain_announcements AS ca
+LEFT JOIN chains AS c ON c.id = ca.chain_id
+LEFT JOIN users AS u ON u.id = ca.author_user_id
+`
+
+type ChainAnnouncementMember struct {
+	UserID        uint     `gorm:"user_id"`
+	UserUID       string   `gorm:"user_uid"`
+	Name          string   `gorm:"name"`
+	Email         *string  `gorm:"email"`
+	I18n          string   `gorm:"i18n"`
+	Sizes         []string `gorm:"serializer:json"`
+	IsUndelivered bool     `gorm:"is_undelivered"`
+}
+
+// Approved members of the loop, excluding the sender
+func ChainAnnouncementGetMembers(db *gorm.DB, chainID, senderUserID uint, wardensOnly bool) ([]ChainAnnouncementMember, error) {
+	sql := `
+SELECT
+	u.id AS user_id,
+	u.uid AS user_uid,
+	u.name,
+	IF(u.is_email_verified, u.email, NULL) AS email,
+	u.i18n,
+	u.sizes,
+	(u.email_undeliverable_at IS NOT NULL) AS is_undelivered
+FROM user_chains AS uc
+JOIN users AS u ON u.id = uc.user_id
+WHERE uc.chain_id = ? AND uc.is_approved = TRUE AND uc.user_id <> ?`
+	if wardensOnly {
+		sql += ` AND uc.is_chain_warden = TRUE`
+	}
+	members := []ChainAnnouncementMember{}
+	err := db.Raw(sql, chainID, senderUserID).Scan(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+var ErrChainAnnouncementLimit = errors.New("Too many announcements have been sent this week, please try again later")
+
+func ChainAnnouncementCountRecent(db *gorm.DB, chainID uint) (int, error) {
+	count := 0
+	err := db.Raw(`SELECT COUNT(*) FROM chain_announcements WHERE chain_id = ? AND created_at > ?`,
+		chainID, time.Now().Add(-ChainAnnouncementLimitWithin)).Scan(&count).Error
+	return count, err
+}
+
+// Saves the announcement unless the loop has reached ChainAnnouncementLimit,
+// the loop row is locked so that simultaneous announcements can not both pass the limit
+func ChainAnnouncementCreate(db *gorm.DB, announcement *sharedtypes.ChainAnnouncement) error {
+	tx := db.Begin()
+
+	var id uint
+	err := tx.Raw(`SELECT id FROM chains WHERE id = ? FOR UPDATE`, announcement.ChainID).Scan(&id).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	count, err := ChainAnnouncementCountRecent(tx, announcement.ChainID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if count >= ChainAnnouncementLimit {
+		tx.Rollback()
+		return ErrChainAnnouncementLimit
+	}
+
+	err = tx.Create(announcement).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// Newest first
+func ChainAnnouncementGetAll(db *gorm.DB, chainID uint) ([]sharedtypes.ChainAnnouncement, error) {
+	list := []sharedtypes.ChainAnnouncement{}
+	err := db.Raw(chainAnnouncementGetSql+`WHERE ca.chain_id = ? ORDER BY ca.created_at DESC, ca.id DESC`, chainID).Scan(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+// Replaces the facets of a loop with its current sizes and genders
+func ChainFacetsSync(db *gorm.DB, chainID uint, sizes, genders []string) error {
+	err := db.Exec(`DELETE FROM chain_facets WHERE chain_id = ?`, chainID).Error
+	if err != nil {
+		return err
+	}
+
+	facets := []sharedtypes.ChainFacet{}
+	for _, size := range sizes {
+		facets = append(facets, sharedtypes.ChainFacet{ChainID: chainID, Facet: sharedtypes.ChainFacetSize, Value: size})
+	}
+	for _, gender := range genders {
+		facets = append(facets, sharedtypes.ChainFacet{ChainID: chainID, Facet: sharedtypes.ChainFacetGender, Value: gender})
+	}
+	if len(facets) == 0 {
+		return nil
+	}
+	return db.Create(&facets).Error
+}
+
+// Fills the facets of all loops, used when the facets table is first created
+func ChainFacetsSyncAll(db *gorm.DB) error {
+	chains := []Chain{}
+	err := db.Raw(`SELECT id, sizes, genders FROM chains`).Scan(&chains).Error
+	if err != nil {
+		return err
+	}
+	for _, chain := range chains {
+		if err := ChainFacetsSync(db, chain.ID, chain.Sizes, chain.Genders); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ChainFacetsDeleteAll(db *gorm.DB, chainIDs ...uint) error {
+	return db.Exec(`DELETE FROM chain_facets WHERE chain_id IN ?`, chainIDs).Error
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/GGP1/atoll"
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrChainInviteNotFound = errors.New("Invite not found")
+	ErrChainInviteRevoked  = errors.New("Invite has been revoked")
+	ErrChainInviteExpired  = errors.New("Invite has expired")
+	ErrChainInviteUsedUp   = errors.New("Invite has reached its maximum number of uses")
+)
+
+func ChainInviteCreate(db *gorm.DB, invite *sharedtypes.ChainInvite) error {
+	token, err := atoll.NewPassword(32, []atoll.Level{atoll.Lower, atoll.Digit})
+	if err != nil {
+		return err
+	}
+	invite.Token = string(token)
+	return db.Create(invite).Error
+}
+
+// All invites of a loop including revoked and expired ones, newest first, with the members that used them
+func ChainInviteGetAllByChain(db *gorm.DB, chainID uint) ([]sharedtypes.ChainInvite, error) {
+	invites := []sharedtypes.ChainInvite{}
+	err := db.Raw(`
+SELECT ci.*, c.uid AS chain_uid, u.uid AS created_by_user_uid
+FROM chain_invites AS ci
+JOIN chains AS c ON c.id = ci.chain_id
+LEFT JOIN users AS u ON u.id = ci.created_by_user_id
+WHERE ci.chain_id = ?
+ORDER BY ci.created_at DESC, ci.id DESC
+	`, chainID).Scan(&invites).Error
+	if err != nil || len(invites) == 0 {
+		return invites, err
+	}
+
+	uses := []sharedtypes.ChainInviteUse{}
+	err = db.Raw(`
+SELECT ciu.*, u.uid AS user_uid, u.name AS user_name
+FROM chain_invite_uses AS ciu
+JOIN users AS u ON u.id = ciu.user_id
+WHERE ciu.chain_invite_id IN ?
+ORDER BY ciu.created_at ASC, ciu.id ASC
+	`, lo.Map(invites, func(invite sharedtypes.ChainInvite, _ int) uint { return invite.ID })).Scan(&uses).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range invites {
+		invites[i].Uses = lo.Filter(uses, func(use sharedtypes.ChainInviteUse, _ int) bool {
+			return use.ChainInviteID == invites[i].ID
+		})
+	}
+	return invites, nil
+}
+
+// Finds an invite of a loop that has not been deleted, revoked or expired invites are returned as well
+func ChainInviteGetByToken(db *gorm.DB, token string) (*sharedtypes.ChainInvite, error) {
+	invite := &sharedtypes.ChainInvite{}
+	err := db.Raw(`
+SELECT ci.*, c.uid AS chain_uid
+FROM chain_invites AS ci
+JOIN chains AS c ON c.id = ci.chain_id
+WHERE ci.token = ? AND c.deleted_at IS NULL
+LIMIT 1
+	`, token).Scan(invite).Error
+	if err != nil {
+		return nil, err
+	}
+	if invite.ID == 0 {
+		return nil, ErrChainInviteNotFound
+	}
+	return invite, nil
+}
+
+// Returns the reason the invite can no longer be used, or nil if it can
+func ChainInviteValidate(invite *sharedtypes.ChainInvite) error {
+	if invite.RevokedAt != nil {
+		return ErrChainInviteRevoked
+	}
+	if invite.ExpiresAt != nil && !invite.ExpiresAt.After(time.Now()) {
+		return ErrChainInviteExpired
+	}
+	if invite.MaxUses > 0 && invite.UseCount >= invite.MaxUses {
+		return ErrChainInviteUsedUp
+	}
+	return nil
+}
+
+func ChainInviteRevoke(db *gorm.DB, chainID uint, token string) error {
+	res := db.Exec(`
+UPDATE chain_invites SET revoked_at = NOW()
+WHERE chain_id = ? AND token = ? AND revoked_at IS NULL
+	`, chainID, token)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrChainInviteNotFound
+	}
+	return nil
+}
+
+// Counts a use of the invite and adds the user to its loop at the end of the route.
+// An existing membership that is still waiting for approval is approved when the invite auto approves.
+func ChainInviteUse(db *gorm.DB, invite *sharedtypes.ChainInvite, userID uint) (err error) {
+	if err = ChainInviteValidate(invite); err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// the conditions are repeated so that concurrent joins can not exceed the maximum number of uses
+	res := tx.Exec(`
+UPDATE chain_invites SET use_count = use_count + 1
+WHERE id = ? AND revoked_at IS NULL
+	AND (expires_at IS NULL OR expires_at > NOW())
+	AND (max_uses = 0 OR use_count < max_uses)
+	`, invite.ID)
+	if err = res.Error; err != nil {
+		return err
+	}
+	if res.RowsAffected == 0 {
+		err = ErrChainInviteUsedUp
+		return err
+	}
+
+	userChain := &sharedtypes.UserChain{}
+	err = tx.Raw(`SELECT * FROM user_chains WHERE user_id = ? AND chain_id = ? LIMIT 1`, userID, invite.ChainID).Scan(userChain).Error
+	if err != nil {
+		return err
+	}
+	if userChain.ID == 0 {
+		routeOrder := 0
+		err = tx.Raw(`SELECT COALESCE(MAX(route_order), 0) FROM user_chains WHERE chain_id = ?`, invite.ChainID).Scan(&routeOrder).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Create(&sharedtypes.UserChain{
+			UserID:     userID,
+			ChainID:    invite.ChainID,
+			IsApproved: invite.AutoApprove,
+			RouteOrder: routeOrder + 1,
+		}).Error
+	} else if invite.AutoApprove && !userChain.IsApproved {
+		err = tx.Exec(`UPDATE user_chains SET is_approved = TRUE, created_at = NOW() WHERE id = ?`, userChain.ID).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	err = tx.Create(&sharedtypes.ChainInviteUse{
+		ChainInviteID: invite.ID,
+		UserID:        userID,
+		IsApproved:    invite.AutoApprove || userChain.IsApproved,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit().Error
+	return err
+}
+
+// Removes the invites of the loops together with who used them
+func ChainInviteDeleteAllByChainIDs(db *gorm.DB, chainIDs ...uint) error {
+	err := db.Exec(`
+DELETE FROM chain_invite_uses WHERE chain_invite_id IN (
+	SELECT id FROM chain_invites WHERE chain_id IN ?
+)`, chainIDs).Error
+	if err != nil {
+		return err
+	}
+	return db.Exec(`DELETE FROM chain_invites WHERE chain_id IN ?`, chainIDs).Error
+}
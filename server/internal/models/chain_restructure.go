@@ -0,0 +1,229 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+var ErrChainSplitNoHostsLeft = errors.New("The loop must keep at least one host")
+
+func ChainGetByUID(db *gorm.DB, chainUID string) (*Chain, error) {
+	chain := &Chain{}
+	err := db.Raw(`SELECT * FROM chains WHERE uid = ? AND deleted_at IS NULL LIMIT 1`, chainUID).Scan(chain).Error
+	if err != nil {
+		return nil, err
+	}
+	if chain.ID == 0 {
+		return nil, ErrChainNotFound
+	}
+	return chain, nil
+}
+
+// Moves everything of the from loop into the to loop and removes the from loop afterwards.
+//
+// Members of both loops keep their place in the to loop, their bags and bulky items follow them.
+// The route of the from loop is appended to the end of the route of the to loop.
+func ChainMerge(db *gorm.DB, from, to *Chain, byUserID uint) (restructure *sharedtypes.ChainRestructure, err error) {
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	duplicates := []struct {
+		FromUserChainID uint `gorm:"from_user_chain_id"`
+		ToUserChainID   uint `gorm:"to_user_chain_id"`
+		IsChainAdmin    bool `gorm:"is_chain_admin"`
+		IsApproved      bool `gorm:"is_approved"`
+	}{}
+	err = tx.Raw(`
+SELECT uc_from.id AS from_user_chain_id, uc_to.id AS to_user_chain_id, uc_from.is_chain_admin, uc_from.is_approved
+FROM user_chains AS uc_from
+JOIN user_chains AS uc_to ON uc_to.user_id = uc_from.user_id AND uc_to.chain_id = ?
+WHERE uc_from.chain_id = ?
+	`, to.ID, from.ID).Scan(&duplicates).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range duplicates {
+		err = tx.Exec(`UPDATE bags SET user_chain_id = ? WHERE user_chain_id = ?`, d.ToUserChainID, d.FromUserChainID).Error
+		if err != nil {
+			return nil, err
+		}
+		err = tx.Exec(`UPDATE bulky_items SET user_chain_id = ? WHERE user_chain_id = ?`, d.ToUserChainID, d.FromUserChainID).Error
+		if err != nil {
+			return nil, err
+		}
+		// the highest role of both loops is kept
+		err = tx.Exec(`
+UPDATE user_chains SET
+	is_chain_admin = is_chain_admin OR ?,
+	is_chain_warden = is_chain_warden AND NOT (is_chain_admin OR ?),
+	is_approved = is_approved OR ?
+WHERE id = ?
+		`, d.IsChainAdmin, d.IsChainAdmin, d.IsApproved, d.ToUserChainID).Error
+		if err != nil {
+			return nil, err
+		}
+		err = tx.Exec(`DELETE FROM user_chains WHERE id = ?`, d.FromUserChainID).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	movedCount := 0
+	err = tx.Raw(`SELECT COUNT(*) FROM user_chains WHERE chain_id = ?`, from.ID).Scan(&movedCount).Error
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Exec(`
+UPDATE user_chains SET route_order = route_order + (
+	SELECT max_route_order FROM (
+		SELECT COALESCE(MAX(route_order), 0) AS max_route_order FROM user_chains WHERE chain_id = ?
+	) AS t
+), chain_id = ?
+WHERE chain_id = ?
+	`, to.ID, to.ID, from.ID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// channel names stay recognisable when both loops use the same name
+	err = tx.Exec(`
+UPDATE chat_channels SET name = CONCAT(name, ' (', ?, ')')
+WHERE chain_id = ? AND name IN (
+	SELECT name FROM (SELECT name FROM chat_channels WHERE chain_id = ?) AS t
+)
+	`, from.Name, from.ID, to.ID).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range []string{"events", "chat_channels", "chat_message_reports", "chat_moderation_actions", "chain_announcements"} {
+		err = tx.Exec(`UPDATE `+table+` SET chain_id = ? WHERE chain_id = ?`, to.ID, from.ID).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Exec(`
+UPDATE chains SET deleted_at = NOW(), published = FALSE, open_to_new_members = FALSE
+WHERE id = ?
+	`, from.ID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	restructure = &sharedtypes.ChainRestructure{
+		Action:      sharedtypes.ChainRestructureActionMerge,
+		FromChainID: from.ID,
+		FromName:    from.Name,
+		ToChainID:   to.ID,
+		ToName:      to.Name,
+		ByUserID:    byUserID,
+		MovedCount:  movedCount,
+		MergedCount: len(duplicates),
+	}
+	err = tx.Create(restructure).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit().Error
+	return restructure, err
+}
+
+// Creates newChain and moves the given members of the from loop into it, keeping their order in the route.
+// Only hostUserIDs become hosts of the new loop, the from loop must keep at least one host.
+func ChainSplit(db *gorm.DB, from, newChain *Chain, userIDs, hostUserIDs []uint, byUserID uint) (restructure *sharedtypes.ChainRestructure, err error) {
+	tx := db.Begin()
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	hostsLeft := 0
+	err = tx.Raw(`
+SELECT COUNT(*) FROM user_chains
+WHERE chain_id = ? AND is_chain_admin = TRUE AND user_id NOT IN ?
+	`, from.ID, userIDs).Scan(&hostsLeft).Error
+	if err != nil {
+		return nil, err
+	}
+	if hostsLeft == 0 {
+		err = ErrChainSplitNoHostsLeft
+		return nil, err
+	}
+
+	err = tx.Create(newChain).Error
+	if err != nil {
+		return nil, err
+	}
+	err = ChainFacetsSync(tx, newChain.ID, newChain.Sizes, newChain.Genders)
+	if err != nil {
+		return nil, err
+	}
+
+	userChains := []struct {
+		ID     uint `gorm:"id"`
+		UserID uint `gorm:"user_id"`
+	}{}
+	err = tx.Raw(`
+SELECT id, user_id FROM user_chains
+WHERE chain_id = ? AND user_id IN ?
+ORDER BY route_order ASC, id ASC
+	`, from.ID, userIDs).Scan(&userChains).Error
+	if err != nil {
+		return nil, err
+	}
+	for i, uc := range userChains {
+		isHost := lo.Contains(hostUserIDs, uc.UserID)
+		err = tx.Exec(`
+UPDATE user_chains SET
+	chain_id = ?,
+	route_order = ?,
+	is_chain_admin = ?,
+	is_chain_warden = is_chain_warden AND NOT ?
+WHERE id = ?
+		`, newChain.ID, i+1, isHost, isHost, uc.ID).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	restructure = &sharedtypes.ChainRestructure{
+		Action:      sharedtypes.ChainRestructureActionSplit,
+		FromChainID: from.ID,
+		FromName:    from.Name,
+		ToChainID:   newChain.ID,
+		ToName:      newChain.Name,
+		ByUserID:    byUserID,
+		MovedCount:  len(userChains),
+	}
+	err = tx.Create(restructure).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit().Error
+	return restructure, err
+}
+
+// Merges and splits the loop was part of, newest first
+func ChainRestructureGetAllByChain(db *gorm.DB, chainID uint) ([]sharedtypes.ChainRestructure, error) {
+	restructures := []sharedtypes.ChainRestructure{}
+	err := db.Raw(`
+SELECT cr.*, c_from.uid AS from_chain_uid, c_to.uid AS to_chain_uid, u.uid AS by_user_uid
+FROM chain_restructures AS cr
+LEFT JOIN chains AS c_from ON c_from.id = cr.from_chain_id
+LEFT JOIN chains AS c_to ON c_to.id = cr.to_chain_id
+LEFT JOIN users AS u ON u.id = cr.by_user_id
+WHERE cr.from_chain_id = ? OR cr.to_chain_id = ?
+ORDER BY cr.created_at DESC, cr.id DESC
+	`, chainID, chainID).Scan(&restructures).Error
+	return restructures, err
+}
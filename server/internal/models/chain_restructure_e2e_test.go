@@ -0,0 +1,95 @@
+//go:build !ci
+
+package models_test
+
+import (
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+)
+
+func TestChainMerge(t *testing.T) {
+	fromChain, fromHost, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{RouteOrderIndex: 1, IsChainAdmin: true})
+	fromMember, _ := mocks.MockUser(t, db, fromChain.ID, mocks.MockChainAndUserOptions{RouteOrderIndex: 2})
+	toChain, toHost, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{RouteOrderIndex: 1, IsChainAdmin: true})
+	// member of both loops
+	mocks.MockAddUserToChain(t, db, toChain.ID, fromMember, mocks.MockChainAndUserOptions{RouteOrderIndex: 2})
+	bag := mocks.MockBag(t, db, fromChain.ID, fromMember.ID, mocks.MockBagOptions{})
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM chain_restructures WHERE from_chain_id = ?`, fromChain.ID)
+	})
+
+	restructure, err := models.ChainMerge(db, fromChain, toChain, toHost.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, restructure.MovedCount)
+	assert.Equal(t, 1, restructure.MergedCount)
+
+	routeOrders := []struct {
+		UserID     uint
+		RouteOrder int
+	}{}
+	db.Raw(`SELECT user_id, route_order FROM user_chains WHERE chain_id = ? ORDER BY route_order`, toChain.ID).Scan(&routeOrders)
+	if assert.Len(t, routeOrders, 3) {
+		assert.Equal(t, toHost.ID, routeOrders[0].UserID)
+		assert.Equal(t, fromMember.ID, routeOrders[1].UserID)
+		assert.Equal(t, fromHost.ID, routeOrders[2].UserID, "route of the merged loop is appended")
+	}
+
+	bagChainID := uint(0)
+	db.Raw(`SELECT uc.chain_id FROM bags AS b JOIN user_chains AS uc ON uc.id = b.user_chain_id WHERE b.id = ?`, bag.ID).Scan(&bagChainID)
+	assert.Equal(t, toChain.ID, bagChainID, "bag follows the member")
+
+	_, err = models.ChainGetByUID(db, fromChain.UID)
+	assert.ErrorIs(t, err, models.ErrChainNotFound)
+
+	restructures, err := models.ChainRestructureGetAllByChain(db, toChain.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, restructures, 1) {
+		assert.Equal(t, fromChain.UID, restructures[0].FromChainUID)
+		assert.Equal(t, toHost.UID, restructures[0].ByUserUID)
+	}
+}
+
+func TestChainSplit(t *testing.T) {
+	chain, host, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{RouteOrderIndex: 1, IsChainAdmin: true})
+	member1, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{RouteOrderIndex: 3})
+	member2, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{RouteOrderIndex: 2})
+
+	newChain := func() *models.Chain {
+		c := &models.Chain{UID: uuid.NewV4().String(), Name: "Split " + chain.Name}
+		t.Cleanup(func() {
+			db.Exec(`DELETE FROM chain_restructures WHERE from_chain_id = ?`, chain.ID)
+			db.Exec(`DELETE FROM user_chains WHERE chain_id = ?`, c.ID)
+			db.Exec(`DELETE FROM chains WHERE id = ?`, c.ID)
+		})
+		return c
+	}
+
+	t.Run("Keep a host", func(t *testing.T) {
+		_, err := models.ChainSplit(db, chain, newChain(), []uint{host.ID, member1.ID}, []uint{member1.ID}, host.ID)
+		assert.ErrorIs(t, err, models.ErrChainSplitNoHostsLeft)
+	})
+
+	t.Run("Move members", func(t *testing.T) {
+		c := newChain()
+		restructure, err := models.ChainSplit(db, chain, c, []uint{member1.ID, member2.ID}, []uint{member1.ID}, host.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, restructure.MovedCount)
+
+		userChains := []struct {
+			UserID       uint
+			RouteOrder   int
+			IsChainAdmin bool
+		}{}
+		db.Raw(`SELECT user_id, route_order, is_chain_admin FROM user_chains WHERE chain_id = ? ORDER BY route_order`, c.ID).Scan(&userChains)
+		if assert.Len(t, userChains, 2) {
+			assert.Equal(t, member2.ID, userChains[0].UserID, "route order is kept")
+			assert.False(t, userChains[0].IsChainAdmin)
+			assert.Equal(t, member1.ID, userChains[1].UserID)
+			assert.True(t, userChains[1].IsChainAdmin)
+		}
+	})
+}
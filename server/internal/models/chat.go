@@ -1,6 +1,9 @@
 package models
 
 import (
+	"errors"
+	"time"
+
 	"github.com/the-clothing-loop/website/server/sharedtypes"
 	"gorm.io/gorm"
 )
@@ -13,3 +16,129 @@ func ChatMessageGet(db *gorm.DB, id, channelID uint) (message *sharedtypes.ChatM
 	}
 	return message, nil
 }
+
+// Marks the messages of a chat room up to lastReadAt as read, an older marker never replaces a newer one
+func ChatChannelReadSet(db *gorm.DB, userID, channelID uint, lastReadAt int64) error {
+	return db.Exec(`
+INSERT INTO chat_channel_reads (user_id, chat_channel_id, last_read_at)
+VALUES (?, ?, ?)
+ON DUPLICATE KEY UPDATE last_read_at = GREATEST(last_read_at, VALUES(last_read_at))
+	`, userID, channelID, lastReadAt).Error
+}
+
+var ErrChatUserMuted = errors.New("You are temporarily muted in this loop's chat")
+
+const chatMessageReportSelectSql = `SELECT
+r.*,
+c.uid AS chain_uid,
+u.uid AS reported_by_uid
+FROM chat_message_reports AS r
+LEFT JOIN chains AS c ON c.id = r.chain_id
+LEFT JOIN users AS u ON u.id = r.reported_by_user_id
+`
+
+const chatModerationActionSelectSql = `SELECT
+a.*,
+c.uid AS chain_uid,
+mu.uid AS moderator_uid,
+tu.uid AS target_uid
+FROM chat_moderation_actions AS a
+LEFT JOIN chains AS c ON c.id = a.chain_id
+LEFT JOIN users AS mu ON mu.id = a.moderator_user_id
+LEFT JOIN users AS tu ON tu.id = a.target_user_id
+`
+
+func ChatMessageReportCreate(db *gorm.DB, chainID, reportedByUserID uint, message *sharedtypes.ChatMessage, reason, comment string) (*sharedtypes.ChatMessageReport, error) {
+	report := &sharedtypes.ChatMessageReport{
+		ChainID:          chainID,
+		ChatChannelID:    message.ChatChannelID,
+		ChatMessageID:    message.ID,
+		Message:          message.Message,
+		SendByUID:        message.SendByUID,
+		ReportedByUserID: reportedByUserID,
+		Reason:           reason,
+		Comment:          comment,
+		Status:           sharedtypes.ChatReportStatusOpen,
+	}
+	err := db.Create(report).Error
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func ChatMessageReportGet(db *gorm.DB, id, chainID uint) (*sharedtypes.ChatMessageReport, error) {
+	report := &sharedtypes.ChatMessageReport{}
+	err := db.Raw(chatMessageReportSelectSql+`WHERE r.id = ? AND r.chain_id = ? LIMIT 1`, id, chainID).Scan(report).Error
+	if err != nil {
+		return nil, err
+	}
+	if report.ID == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return report, nil
+}
+
+// chainID 0 returns the reports of all loops
+func ChatMessageReportGetAll(db *gorm.DB, chainID uint, status string) ([]sharedtypes.ChatMessageReport, error) {
+	query := chatMessageReportSelectSql + `WHERE TRUE`
+	args := []any{}
+	if chainID != 0 {
+		query += ` AND r.chain_id = ?`
+		args = append(args, chainID)
+	}
+	if status != "" {
+		query += ` AND r.status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY r.created_at DESC`
+
+	reports := []sharedtypes.ChatMessageReport{}
+	err := db.Raw(query, args...).Scan(&reports).Error
+	if err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+func ChatMessageReportSetStatus(db *gorm.DB, id uint, status string) error {
+	return db.Exec(`UPDATE chat_message_reports SET status = ?, resolved_at = NOW() WHERE id = ?`, status, id).Error
+}
+
+func ChatModerationActionCreate(db *gorm.DB, action *sharedtypes.ChatModerationAction) error {
+	return db.Create(action).Error
+}
+
+// chainID 0 returns the actions of all loops
+func ChatModerationActionGetAll(db *gorm.DB, chainID uint) ([]sharedtypes.ChatModerationAction, error) {
+	query := chatModerationActionSelectSql
+	args := []any{}
+	if chainID != 0 {
+		query += `WHERE a.chain_id = ?`
+		args = append(args, chainID)
+	}
+	query += ` ORDER BY a.created_at DESC`
+
+	actions := []sharedtypes.ChatModerationAction{}
+	err := db.Raw(query, args...).Scan(&actions).Error
+	if err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+func UserChainSetChatMutedUntil(db *gorm.DB, userID, chainID uint, mutedUntil *time.Time) error {
+	return db.Exec(`UPDATE user_chains SET chat_muted_until = ? WHERE user_id = ? AND chain_id = ?`, mutedUntil, userID, chainID).Error
+}
+
+func UserChainIsChatMuted(db *gorm.DB, userID, chainID uint) (bool, error) {
+	count := 0
+	err := db.Raw(`
+SELECT COUNT(*) FROM user_chains
+WHERE user_id = ? AND chain_id = ? AND chat_muted_until > NOW()
+	`, userID, chainID).Scan(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+const contactTicketGetSql = `SELECT
+	ct.id,
+	ct.name,
+	ct.email,
+	ct.message,
+	ct.i18n,
+	ct.status,
+	ct.assignee_user_id,
+	u.uid AS assignee_user_uid,
+	u.name AS assignee_name,
+	ct.notes,
+	ct.thread_message_id,
+	ct.ip_address,
+	ct.created_at,
+	ct.updated_at
+FROM contact_tickets AS ct
+LEFT JOIN users AS u ON u.id = ct.assignee_user_id
+`
+
+func ContactTicketGet(db *gorm.DB, id uint) (*sharedtypes.ContactTicket, error) {
+	ticket := &sharedtypes.ContactTicket{}
+	err := db.Raw(contactTicketGetSql+`WHERE ct.id = ? LIMIT 1`, id).Scan(ticket).Error
+	if err != nil {
+		return nil, err
+	}
+	if ticket.ID == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return ticket, nil
+}
+
+// Lists tickets newest first, status and assigneeUserID are optional filters.
+// Set beforeID to 0 to start at the newest ticket.
+func ContactTicketGetAll(db *gorm.DB, status string, assigneeUserID, beforeID uint, limit int) ([]sharedtypes.ContactTicket, error) {
+	sql := contactTicketGetSql + `WHERE TRUE`
+	args := []any{}
+	if status != "" {
+		sql += ` AND ct.status = ?`
+		args = append(args, status)
+	}
+	if assigneeUserID != 0 {
+		sql += ` AND ct.assignee_user_id = ?`
+		args = append(args, assigneeUserID)
+	}
+	if beforeID != 0 {
+		sql += ` AND ct.id < ?`
+		args = append(args, beforeID)
+	}
+	sql += ` ORDER BY ct.id DESC LIMIT ?`
+	args = append(args, limit)
+
+	tickets := []sharedtypes.ContactTicket{}
+	err := db.Raw(sql, args...).Scan(&tickets).Error
+	return tickets, err
+}
+
+// Only the given columns are changed, see ContactTicketUpdateRequest
+func ContactTicketUpdate(db *gorm.DB, id uint, columns map[string]any) error {
+	return db.Model(&sharedtypes.ContactTicket{}).Where("id = ?", id).Updates(columns).Error
+}
+
+// Oldest first
+func ContactTicketReplyGetAll(db *gorm.DB, ticketID uint) ([]sharedtypes.ContactTicketReply, error) {
+	replies := []sharedtypes.ContactTicketReply{}
+	err := db.Raw(`
+SELECT
+	ctr.id,
+	ctr.contact_ticket_id,
+	ctr.author_user_id,
+	u.uid AS author_user_uid,
+	u.name AS author_name,
+	ctr.message,
+	ctr.mail_id,
+	ctr.created_at
+FROM contact_ticket_replies AS ctr
+LEFT JOIN users AS u ON u.id = ctr.author_user_id
+WHERE ctr.contact_ticket_id = ?
+ORDER BY ctr.id ASC
+	`, ticketID).Scan(&replies).Error
+	return replies, err
+}
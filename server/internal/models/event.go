@@ -1,12 +1,26 @@
 package models
 
 import (
+	"log/slog"
+	"sort"
+	"time"
+	// events can be in any timezone, also on servers without a timezone database
+	_ "time/tzdata"
+
 	"github.com/microcosm-cc/bluemonday"
+	"github.com/the-clothing-loop/website/server/pkg/rrule"
 	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
 )
 
 type Event sharedtypes.Event
 
+// How far ahead recurring events are expanded
+const EventRecurrenceHorizon = 365 * 24 * time.Hour
+
+// Maximum number of occurrences returned per recurring event
+const EventRecurrenceMaxOccurrences = 60
+
 const EventGetSql = `SELECT
 events.id                    AS id,
 events.uid                   AS uid,
@@ -31,6 +45,16 @@ users.uid                    AS user_uid,
 users.name                   AS user_name,
 users.email                  AS user_email,
 events.image_url             AS image_url,
+events.rrule                 AS rrule,
+events.rrule_until           AS rrule_until,
+events.sequence              AS sequence,
+events.capacity              AS capacity,
+events.timezone              AS timezone,
+(
+	SELECT COUNT(*) FROM event_rsvps
+	WHERE event_rsvps.event_id = events.id AND event_rsvps.occurrence_date = events.date
+		AND event_rsvps.status = 'going' AND event_rsvps.is_waitlisted = FALSE
+)                            AS rsvp_going_count,
 chains.name                  AS chain_name
 FROM events
 LEFT JOIN chains ON chains.id = chain_id
@@ -41,3 +65,270 @@ func (e *Event) ValidateDescription() {
 	p := bluemonday.UGCPolicy()
 	e.Description = p.Sanitize(e.Description)
 }
+
+// Validates and sets the recurrence rule, an empty string removes it.
+// Must be called again after the start date or timezone has changed.
+func (e *Event) SetRRule(s string) error {
+	if s == "" {
+		e.RRule = ""
+		e.RRuleUntil = nil
+		return nil
+	}
+	r, err := rrule.Parse(s)
+	if err != nil {
+		return err
+	}
+	dtstart := e.Date.In(e.Location())
+	// rules that never occur or take too long to expand are refused upfront
+	last, ok, err := r.Last(dtstart)
+	if err != nil {
+		return err
+	}
+	if _, err := r.Between(dtstart, dtstart, dtstart.AddDate(1000, 0, 0), 1); err != nil {
+		return err
+	}
+	e.RRule = r.String()
+	e.RRuleUntil = nil
+	if ok {
+		until := last.Add(e.duration()).UTC()
+		e.RRuleUntil = &until
+	}
+	return nil
+}
+
+// The timezone the event takes place in, recurrences are expanded in this timezone
+// so that they keep the same local time across daylight saving time changes
+func (e *Event) Location() *time.Location {
+	if e.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(e.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func (e *Event) duration() time.Duration {
+	if e.DateEnd == nil {
+		return 0
+	}
+	return e.DateEnd.Sub(e.Date)
+}
+
+// Checks if the date is an occurrence of the recurrence rule
+func (e *Event) IsOccurrence(date time.Time) bool {
+	r, err := rrule.Parse(e.RRule)
+	if err != nil {
+		return false
+	}
+	list, _ := r.Between(e.Date.In(e.Location()), date, date.Add(time.Second), 1)
+	return len(list) == 1 && list[0].Equal(date)
+}
+
+// Expands a recurring event into the occurrences that start in [after, before),
+// cancelled occurrences are left out and moved occurrences use their new dates.
+func (e *Event) Occurrences(after, before time.Time, max int, overrides []sharedtypes.EventOccurrence) []Event {
+	r, err := rrule.Parse(e.RRule)
+	if err != nil {
+		return []Event{}
+	}
+
+	duration := e.duration()
+	// long windows of frequent rules can reach the period limit, the occurrences found so far are still used
+	dates, err := r.Between(e.Date.In(e.Location()), after, before, max)
+	if err != nil {
+		slog.Warn("Recurring event is only partially expanded", "eventID", e.ID, "err", err)
+	}
+	list := []Event{}
+	for _, t := range dates {
+		t = t.UTC()
+		occurrence := *e
+		occurrence.OccurrenceDate = &t
+		occurrence.Date = t
+		if e.DateEnd != nil {
+			dateEnd := t.Add(duration)
+			occurrence.DateEnd = &dateEnd
+		}
+
+		isCancelled := false
+		for _, o := range overrides {
+			if !o.OriginalDate.Equal(t) {
+				continue
+			}
+			if o.IsCancelled {
+				isCancelled = true
+			} else if o.Date != nil {
+				occurrence.Date = *o.Date
+				occurrence.DateEnd = o.DateEnd
+			}
+		}
+		if !isCancelled {
+			list = append(list, occurrence)
+		}
+	}
+	return list
+}
+
+// Counts the occurrences of a recurring event that start before the given time without expanding them,
+// cancelled and moved occurrences are taken into account.
+// Returns rrule.ErrTooManyPeriods with the amount counted so far when the rule can not be followed that far.
+func (e *Event) CountOccurrencesBefore(before time.Time, overrides []sharedtypes.EventOccurrence) (int, error) {
+	r, err := rrule.Parse(e.RRule)
+	if err != nil {
+		return 0, nil
+	}
+
+	n, err := r.CountBetween(e.Date.In(e.Location()), e.Date, before)
+	for _, o := range overrides {
+		wasBefore := o.OriginalDate.Before(before)
+		isBefore := wasBefore
+		if o.IsCancelled {
+			isBefore = false
+		} else if o.Date != nil {
+			isBefore = o.Date.Before(before)
+		}
+		switch {
+		case wasBefore && !isBefore:
+			n--
+		case !wasBefore && isBefore:
+			n++
+		}
+	}
+	return n, err
+}
+
+// Returns the first occurrence that starts after the given time and before or at the limit,
+// for single events the event itself is returned if it is within range.
+func (e *Event) NextOccurrence(after, limit time.Time, overrides []sharedtypes.EventOccurrence) *Event {
+	if e.RRule == "" {
+		if e.Date.After(after) && !e.Date.After(limit) {
+			return e
+		}
+		return nil
+	}
+
+	// moved occurrences can start up to a week away from their original date
+	margin := 7 * 24 * time.Hour
+	list := e.Occurrences(after.Add(-margin), limit.Add(margin), EventRecurrenceMaxOccurrences, overrides)
+	EventSortByDate(list, false)
+	for i := range list {
+		if list[i].Date.After(after) && !list[i].Date.After(limit) {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
+// Returns the overrides of each event by event id
+func EventOccurrenceGetAllByEventIDs(db *gorm.DB, eventIDs []uint) (map[uint][]sharedtypes.EventOccurrence, error) {
+	result := map[uint][]sharedtypes.EventOccurrence{}
+	if len(eventIDs) == 0 {
+		return result, nil
+	}
+
+	list := []sharedtypes.EventOccurrence{}
+	err := db.Raw(`SELECT * FROM event_occurrences WHERE event_id IN ?`, eventIDs).Scan(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range list {
+		result[o.EventID] = append(result[o.EventID], o)
+	}
+	return result, nil
+}
+
+// Expands recurring events and merges them with single events, sorted by date
+func EventExpandAll(db *gorm.DB, events []Event, after, before time.Time, isDesc bool) ([]Event, error) {
+	recurringIDs := []uint{}
+	for _, e := range events {
+		if e.RRule != "" {
+			recurringIDs = append(recurringIDs, e.ID)
+		}
+	}
+	overrides, err := EventOccurrenceGetAllByEventIDs(db, recurringIDs)
+	if err != nil {
+		return nil, err
+	}
+	goingCounts, err := EventRsvpGetGoingCounts(db, recurringIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []Event{}
+	for i := range events {
+		if events[i].RRule == "" {
+			list = append(list, events[i])
+			continue
+		}
+		for _, o := range events[i].Occurrences(after, before, EventRecurrenceMaxOccurrences, overrides[events[i].ID]) {
+			going := goingCounts[o.ID][o.OccurrenceDate.Unix()]
+			o.RsvpGoingCount = &going
+			list = append(list, o)
+		}
+	}
+	EventSortByDate(list, isDesc)
+	return list, nil
+}
+
+// Counts all events and occurrences of recurring events that started before the given time
+func EventCountPrevious(db *gorm.DB, before time.Time) (int, error) {
+	total := 0
+	err := db.Raw(`SELECT COUNT(*) FROM events WHERE rrule = '' AND date < ?`, before).Scan(&total).Error
+	if err != nil {
+		return 0, err
+	}
+
+	recurringEvents := []Event{}
+	err = db.Raw(EventGetSql+`WHERE events.rrule <> '' AND date < ?`, before).Scan(&recurringEvents).Error
+	if err != nil {
+		return 0, err
+	}
+	recurringIDs := make([]uint, 0, len(recurringEvents))
+	for _, e := range recurringEvents {
+		recurringIDs = append(recurringIDs, e.ID)
+	}
+	overrides, err := EventOccurrenceGetAllByEventIDs(db, recurringIDs)
+	if err != nil {
+		return 0, err
+	}
+	for i := range recurringEvents {
+		e := &recurringEvents[i]
+		n, err := e.CountOccurrencesBefore(before, overrides[e.ID])
+		if err != nil {
+			slog.Warn("Previous events total is missing occurrences of a recurring event", "eventID", e.ID, "err", err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// Sorts by date, events on the same date are sorted by id
+func EventSortByDate(list []Event, isDesc bool) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Date.Equal(list[j].Date) {
+			return list[i].ID < list[j].ID
+		}
+		if isDesc {
+			return list[i].Date.After(list[j].Date)
+		}
+		return list[i].Date.Before(list[j].Date)
+	})
+}
+
+// Marks the event as changed for calendar apps
+func EventIncrementSequence(db *gorm.DB, eventID uint) error {
+	return db.Exec(`UPDATE events SET sequence = sequence + 1, updated_at = NOW() WHERE id = ?`, eventID).Error
+}
+
+func EventOccurrenceUpsert(db *gorm.DB, o *sharedtypes.EventOccurrence) error {
+	return db.Exec(`
+INSERT INTO event_occurrences (event_id, original_date, is_cancelled, date, date_end, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, NOW(), NOW())
+ON DUPLICATE KEY UPDATE is_cancelled = VALUES(is_cancelled), date = VALUES(date), date_end = VALUES(date_end), updated_at = NOW()
+	`, o.EventID, o.OriginalDate, o.IsCancelled, o.Date, o.DateEnd).Error
+}
+
+func EventOccurrenceDeleteAll(db *gorm.DB, eventID uint) error {
+	return db.Exec(`DELETE FROM event_occurrences WHERE event_id = ?`, eventID).Error
+}
@@ -0,0 +1,365 @@
+package models
+
+import (
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gopkg.in/guregu/null.v3/zero"
+	"gorm.io/gorm"
+)
+
+type EventRsvpContact struct {
+	RsvpID          uint        `gorm:"rsvp_id"`
+	UserID          uint        `gorm:"user_id"`
+	UserUID         string      `gorm:"user_uid"`
+	Name            string      `gorm:"name"`
+	Email           zero.String `gorm:"email"`
+	I18n            string      `gorm:"i18n"`
+	IsWaitlisted    bool        `gorm:"is_waitlisted"`
+	RemindedForDate *time.Time  `gorm:"reminded_for_date"`
+}
+
+// Returns nil if the user has not responded to the occurrence
+func EventRsvpGet(db *gorm.DB, eventID uint, occurrenceDate time.Time, userID uint) (*sharedtypes.EventRsvp, error) {
+	rsvp := &sharedtypes.EventRsvp{}
+	err := db.Raw(`SELECT * FROM event_rsvps WHERE event_id = ? AND occurrence_date = ? AND user_id = ? LIMIT 1`, eventID, occurrenceDate, userID).Scan(rsvp).Error
+	if err != nil {
+		return nil, err
+	}
+	if rsvp.ID == 0 {
+		return nil, nil
+	}
+	return rsvp, nil
+}
+
+func EventRsvpGetCounts(db *gorm.DB, eventID uint, occurrenceDate time.Time) (going, maybe, waitlist int, err error) {
+	var row struct {
+		Going    int `gorm:"going"`
+		Maybe    int `gorm:"maybe"`
+		Waitlist int `gorm:"waitlist"`
+	}
+	err = db.Raw(`
+SELECT
+	COALESCE(SUM(status = 'going' AND is_waitlisted = FALSE), 0) AS going,
+	COALESCE(SUM(status = 'maybe'), 0) AS maybe,
+	COALESCE(SUM(is_waitlisted = TRUE), 0) AS waitlist
+FROM event_rsvps
+WHERE event_id = ? AND occurrence_date = ?
+	`, eventID, occurrenceDate).Scan(&row).Error
+	return row.Going, row.Maybe, row.Waitlist, err
+}
+
+// Locks the event row to count attendees consistently
+func eventLockForUpdate(tx *gorm.DB, eventID uint) error {
+	var id uint
+	return tx.Raw(`SELECT id FROM events WHERE id = ? FOR UPDATE`, eventID).Scan(&id).Error
+}
+
+// Creates or updates the response of a user to an occurrence,
+// when the occurrence is full going users are put on the waitlist.
+// Returns the users that were moved from the waitlist.
+//
+// Expects to run inside a transaction
+func EventRsvpSet(tx *gorm.DB, event *Event, occurrenceDate time.Time, userID uint, status string) (*sharedtypes.EventRsvp, []uint, error) {
+	err := eventLockForUpdate(tx, event.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rsvp, err := EventRsvpGet(tx, event.ID, occurrenceDate, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	wasAttending := rsvp != nil && rsvp.Status == sharedtypes.EventRsvpStatusGoing && !rsvp.IsWaitlisted
+	if rsvp == nil {
+		rsvp = &sharedtypes.EventRsvp{EventID: event.ID, OccurrenceDate: occurrenceDate, UserID: userID}
+	} else if rsvp.Status == status {
+		return rsvp, []uint{}, nil
+	}
+
+	rsvp.Status = status
+	rsvp.IsWaitlisted = false
+	if status == sharedtypes.EventRsvpStatusGoing && event.Capacity > 0 {
+		going, _, _, err := EventRsvpGetCounts(tx, event.ID, occurrenceDate)
+		if err != nil {
+			return nil, nil, err
+		}
+		rsvp.IsWaitlisted = going >= event.Capacity
+	}
+
+	if rsvp.ID == 0 {
+		err = tx.Create(rsvp).Error
+	} else {
+		err = tx.Save(rsvp).Error
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	promotedUserIDs := []uint{}
+	if wasAttending && status != sharedtypes.EventRsvpStatusGoing {
+		promotedUserIDs, err = EventRsvpPromoteWaitlist(tx, event, occurrenceDate)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return rsvp, promotedUserIDs, nil
+}
+
+// Removes the response of a user to an occurrence.
+// Returns the users that were moved from the waitlist.
+//
+// Expects to run inside a transaction
+func EventRsvpDelete(tx *gorm.DB, event *Event, occurrenceDate time.Time, userID uint) ([]uint, error) {
+	err := eventLockForUpdate(tx, event.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	rsvp, err := EventRsvpGet(tx, event.ID, occurrenceDate, userID)
+	if err != nil || rsvp == nil {
+		return []uint{}, err
+	}
+	err = tx.Exec(`DELETE FROM event_rsvps WHERE id = ?`, rsvp.ID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if rsvp.Status == sharedtypes.EventRsvpStatusGoing && !rsvp.IsWaitlisted {
+		return EventRsvpPromoteWaitlist(tx, event, occurrenceDate)
+	}
+	return []uint{}, nil
+}
+
+// Fills open places of an occurrence with users from the waitlist, first come first served.
+// Returns the ids of the promoted users.
+func EventRsvpPromoteWaitlist(tx *gorm.DB, event *Event, occurrenceDate time.Time) ([]uint, error) {
+	going, _, waitlist, err := EventRsvpGetCounts(tx, event.ID, occurrenceDate)
+	if err != nil || waitlist == 0 {
+		return []uint{}, err
+	}
+
+	free := waitlist
+	if event.Capacity > 0 {
+		free = min(event.Capacity-going, waitlist)
+	}
+	if free <= 0 {
+		return []uint{}, nil
+	}
+
+	rows := []struct {
+		ID     uint `gorm:"id"`
+		UserID uint `gorm:"user_id"`
+	}{}
+	err = tx.Raw(`
+SELECT id, user_id FROM event_rsvps
+WHERE event_id = ? AND occurrence_date = ? AND is_waitlisted = TRUE
+ORDER BY updated_at ASC, id ASC
+LIMIT ?
+	`, event.ID, occurrenceDate, free).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []uint{}
+	userIDs := []uint{}
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+		userIDs = append(userIDs, row.UserID)
+	}
+	err = tx.Exec(`UPDATE event_rsvps SET is_waitlisted = FALSE WHERE id IN ?`, ids).Error
+	if err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
+
+// Fills open places of every occurrence that has a waitlist, used after the capacity has changed.
+// Returns the ids of the promoted users.
+//
+// Expects to run inside a transaction
+func EventRsvpPromoteWaitlistAll(tx *gorm.DB, event *Event) ([]uint, error) {
+	err := eventLockForUpdate(tx, event.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	dates := []time.Time{}
+	err = tx.Raw(`SELECT DISTINCT occurrence_date FROM event_rsvps WHERE event_id = ? AND is_waitlisted = TRUE`, event.ID).Scan(&dates).Error
+	if err != nil {
+		return nil, err
+	}
+	userIDs := []uint{}
+	for _, date := range dates {
+		promoted, err := EventRsvpPromoteWaitlist(tx, event, date)
+		if err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, promoted...)
+	}
+	return userIDs, nil
+}
+
+func EventRsvpGetAttendees(db *gorm.DB, eventID uint, occurrenceDate time.Time) ([]sharedtypes.EventAttendee, error) {
+	attendees := []sharedtypes.EventAttendee{}
+	err := db.Raw(`
+SELECT
+	users.id AS user_id,
+	users.uid AS user_uid,
+	users.name AS name,
+	COALESCE(users.email, '') AS email,
+	users.phone_number AS phone_number,
+	er.status AS status,
+	er.is_waitlisted AS is_waitlisted,
+	er.created_at AS created_at
+FROM event_rsvps AS er
+JOIN users ON users.id = er.user_id
+WHERE er.event_id = ? AND er.occurrence_date = ?
+ORDER BY er.status ASC, er.is_waitlisted ASC, er.updated_at ASC
+	`, eventID, occurrenceDate).Scan(&attendees).Error
+	return attendees, err
+}
+
+const eventRsvpContactSql = `
+SELECT
+	er.id AS rsvp_id,
+	users.id AS user_id,
+	users.uid AS user_uid,
+	users.name AS name,
+	users.email AS email,
+	users.i18n AS i18n,
+	er.is_waitlisted AS is_waitlisted,
+	er.reminded_for_date AS reminded_for_date
+FROM event_rsvps AS er
+JOIN users ON users.id = er.user_id
+`
+
+// Returns users that are going or might be going to the occurrence
+func EventRsvpGetContacts(db *gorm.DB, eventID uint, occurrenceDate time.Time) ([]EventRsvpContact, error) {
+	contacts := []EventRsvpContact{}
+	err := db.Raw(eventRsvpContactSql+`WHERE er.event_id = ? AND er.occurrence_date = ? AND er.status IN ('going', 'maybe')`,
+		eventID, occurrenceDate).Scan(&contacts).Error
+	return contacts, err
+}
+
+// Returns users that are going or might be going to any occurrence that has not started yet, each user once
+func EventRsvpGetContactsUpcoming(db *gorm.DB, eventID uint) ([]EventRsvpContact, error) {
+	contacts := []EventRsvpContact{}
+	err := db.Raw(eventRsvpContactSql+`WHERE er.event_id = ? AND er.occurrence_date > NOW() AND er.status IN ('going', 'maybe')
+ORDER BY er.occurrence_date ASC`, eventID).Scan(&contacts).Error
+	if err != nil {
+		return nil, err
+	}
+	return lo.UniqBy(contacts, func(c EventRsvpContact) uint { return c.UserID }), nil
+}
+
+// Going users per occurrence of each event by event id, keyed by the unix time of the occurrence
+func EventRsvpGetGoingCounts(db *gorm.DB, eventIDs []uint) (map[uint]map[int64]int, error) {
+	result := map[uint]map[int64]int{}
+	if len(eventIDs) == 0 {
+		return result, nil
+	}
+
+	rows := []struct {
+		EventID        uint      `gorm:"event_id"`
+		OccurrenceDate time.Time `gorm:"occurrence_date"`
+		Going          int       `gorm:"going"`
+	}{}
+	err := db.Raw(`
+SELECT event_id, occurrence_date, COUNT(*) AS going
+FROM event_rsvps
+WHERE event_id IN ? AND status = 'going' AND is_waitlisted = FALSE
+GROUP BY event_id, occurrence_date
+	`, eventIDs).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if result[row.EventID] == nil {
+			result[row.EventID] = map[int64]int{}
+		}
+		result[row.EventID][row.OccurrenceDate.Unix()] = row.Going
+	}
+	return result, nil
+}
+
+// Keeps responses attached to the occurrences of an event after its date or recurrence rule changed.
+// Responses to a single event move along with its date,
+// responses to occurrences that no longer exist are removed.
+func EventRsvpMoveToOccurrences(db *gorm.DB, event *Event) error {
+	if event.RRule == "" {
+		// a recurring event that became a single event keeps the first response of each user
+		err := db.Exec(`
+DELETE er FROM event_rsvps AS er
+JOIN event_rsvps AS other ON other.event_id = er.event_id AND other.user_id = er.user_id AND other.occurrence_date < er.occurrence_date
+WHERE er.event_id = ?
+		`, event.ID).Error
+		if err != nil {
+			return err
+		}
+		return db.Exec(`UPDATE event_rsvps SET occurrence_date = ? WHERE event_id = ?`, event.Date, event.ID).Error
+	}
+
+	dates := []time.Time{}
+	err := db.Raw(`SELECT DISTINCT occurrence_date FROM event_rsvps WHERE event_id = ?`, event.ID).Scan(&dates).Error
+	if err != nil {
+		return err
+	}
+	removed := lo.Filter(dates, func(date time.Time, _ int) bool { return !event.IsOccurrence(date) })
+	if len(removed) == 0 {
+		return nil
+	}
+	return db.Exec(`DELETE FROM event_rsvps WHERE event_id = ? AND occurrence_date IN ?`, event.ID, removed).Error
+}
+
+// Responses used to be for the whole series of a recurring event,
+// they are moved to the next occurrence or removed when the event has ended
+func EventRsvpMigrateOccurrenceDates(db *gorm.DB) error {
+	err := db.Exec(`
+UPDATE event_rsvps AS er
+JOIN events AS e ON e.id = er.event_id
+SET er.occurrence_date = e.date
+WHERE er.occurrence_date IS NULL AND e.rrule = ''
+	`).Error
+	if err != nil {
+		return err
+	}
+
+	events := []Event{}
+	err = db.Raw(EventGetSql + `WHERE events.rrule <> '' AND events.id IN (
+	SELECT event_id FROM event_rsvps WHERE occurrence_date IS NULL
+)`).Scan(&events).Error
+	if err != nil {
+		return err
+	}
+	overrides, err := EventOccurrenceGetAllByEventIDs(db, lo.Map(events, func(e Event, _ int) uint { return e.ID }))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range events {
+		next := events[i].NextOccurrence(now, now.Add(EventRecurrenceHorizon), overrides[events[i].ID])
+		if next == nil || next.OccurrenceDate == nil {
+			err = db.Exec(`DELETE FROM event_rsvps WHERE event_id = ? AND occurrence_date IS NULL`, events[i].ID).Error
+		} else {
+			err = db.Exec(`UPDATE event_rsvps SET occurrence_date = ? WHERE event_id = ? AND occurrence_date IS NULL`, *next.OccurrenceDate, events[i].ID).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// responses of events that no longer exist
+	return db.Exec(`DELETE FROM event_rsvps WHERE occurrence_date IS NULL`).Error
+}
+
+func EventRsvpSetRemindedForDate(db *gorm.DB, rsvpIDs []uint, date time.Time) error {
+	if len(rsvpIDs) == 0 {
+		return nil
+	}
+	return db.Exec(`UPDATE event_rsvps SET reminded_for_date = ? WHERE id IN ?`, date, rsvpIDs).Error
+}
+
+func EventRsvpDeleteAll(db *gorm.DB, eventID uint) error {
+	return db.Exec(`DELETE FROM event_rsvps WHERE event_id = ?`, eventID).Error
+}
@@ -0,0 +1,104 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/pkg/rrule"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestEventSetRRule(t *testing.T) {
+	dateEnd := time.Date(2024, 5, 4, 16, 0, 0, 0, time.UTC)
+	e := &models.Event{
+		Date:    time.Date(2024, 5, 4, 14, 0, 0, 0, time.UTC),
+		DateEnd: &dateEnd,
+	}
+
+	assert.Error(t, e.SetRRule("FREQ=HOURLY"))
+
+	assert.NoError(t, e.SetRRule("RRULE:FREQ=MONTHLY;BYDAY=1SA;COUNT=3"))
+	assert.Equal(t, "FREQ=MONTHLY;COUNT=3;BYDAY=1SA", e.RRule)
+	if assert.NotNil(t, e.RRuleUntil) {
+		assert.Equal(t, time.Date(2024, 7, 6, 16, 0, 0, 0, time.UTC), *e.RRuleUntil)
+	}
+	assert.True(t, e.IsOccurrence(time.Date(2024, 6, 1, 14, 0, 0, 0, time.UTC)))
+	assert.False(t, e.IsOccurrence(time.Date(2024, 6, 8, 14, 0, 0, 0, time.UTC)))
+
+	assert.NoError(t, e.SetRRule("FREQ=MONTHLY;BYDAY=1SA"))
+	assert.Nil(t, e.RRuleUntil)
+
+	assert.NoError(t, e.SetRRule(""))
+	assert.Empty(t, e.RRule)
+}
+
+func TestEventOccurrences(t *testing.T) {
+	e := &models.Event{
+		ID:   1,
+		Date: time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC),
+	}
+	assert.NoError(t, e.SetRRule("FREQ=WEEKLY;COUNT=4"))
+
+	moved := time.Date(2024, 5, 16, 10, 0, 0, 0, time.UTC)
+	overrides := []sharedtypes.EventOccurrence{
+		{EventID: 1, OriginalDate: time.Date(2024, 5, 8, 14, 0, 0, 0, time.UTC), IsCancelled: true},
+		{EventID: 1, OriginalDate: time.Date(2024, 5, 15, 14, 0, 0, 0, time.UTC), Date: &moved},
+	}
+
+	list := e.Occurrences(e.Date, e.Date.AddDate(1, 0, 0), 100, overrides)
+	if assert.Len(t, list, 3) {
+		assert.Equal(t, time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC), list[0].Date)
+		assert.Equal(t, moved, list[1].Date)
+		assert.Equal(t, time.Date(2024, 5, 15, 14, 0, 0, 0, time.UTC), *list[1].OccurrenceDate)
+		assert.Equal(t, time.Date(2024, 5, 22, 14, 0, 0, 0, time.UTC), list[2].Date)
+	}
+}
+
+func TestEventCountOccurrencesBefore(t *testing.T) {
+	e := &models.Event{
+		ID:   1,
+		Date: time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC),
+	}
+	assert.NoError(t, e.SetRRule("FREQ=WEEKLY"))
+
+	before := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+	movedAfter := time.Date(2024, 5, 21, 10, 0, 0, 0, time.UTC)
+	movedBefore := time.Date(2024, 5, 19, 10, 0, 0, 0, time.UTC)
+	overrides := []sharedtypes.EventOccurrence{
+		{EventID: 1, OriginalDate: time.Date(2024, 5, 8, 14, 0, 0, 0, time.UTC), IsCancelled: true},
+		{EventID: 1, OriginalDate: time.Date(2024, 5, 15, 14, 0, 0, 0, time.UTC), Date: &movedAfter},
+		{EventID: 1, OriginalDate: time.Date(2024, 5, 22, 14, 0, 0, 0, time.UTC), Date: &movedBefore},
+	}
+
+	// 1, 8, 15 of may minus the cancelled and moved ones, plus the one moved forward
+	n, err := e.CountOccurrencesBefore(before, overrides)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Len(t, e.Occurrences(e.Date, before, 100, overrides), n)
+}
+
+func TestEventOccurrencesTimezone(t *testing.T) {
+	amsterdam, _ := time.LoadLocation("Europe/Amsterdam")
+	e := &models.Event{
+		ID:       1,
+		Date:     time.Date(2024, 3, 23, 14, 0, 0, 0, amsterdam).UTC(),
+		Timezone: "Europe/Amsterdam",
+	}
+	assert.NoError(t, e.SetRRule("FREQ=WEEKLY;COUNT=2"))
+
+	// daylight saving time starts in between, the local time stays the same
+	list := e.Occurrences(e.Date, e.Date.AddDate(1, 0, 0), 100, nil)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, time.Date(2024, 3, 23, 13, 0, 0, 0, time.UTC), list[0].Date)
+		assert.Equal(t, time.Date(2024, 3, 30, 13, 0, 0, 0, time.UTC), list[1].Date)
+		assert.Equal(t, time.Date(2024, 3, 30, 13, 0, 0, 0, time.UTC), *list[1].OccurrenceDate)
+	}
+	assert.True(t, e.IsOccurrence(time.Date(2024, 3, 30, 13, 0, 0, 0, time.UTC)))
+	if assert.NotNil(t, e.RRuleUntil) {
+		assert.Equal(t, time.UTC, e.RRuleUntil.Location())
+	}
+
+	assert.ErrorIs(t, e.SetRRule("FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30"), rrule.ErrTooManyPeriods)
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"github.com/GGP1/atoll"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+func userCalendarTokenGenerate() (string, error) {
+	tokenB, err := atoll.NewPassword(32, []atoll.Level{atoll.Lower, atoll.Digit})
+	if err != nil {
+		return "", err
+	}
+	return string(tokenB), nil
+}
+
+// Returns the token of the user, a new token is created if none exists
+func UserCalendarTokenFindOrCreate(db *gorm.DB, userID uint) (*sharedtypes.UserCalendarToken, error) {
+	t := &sharedtypes.UserCalendarToken{}
+	err := db.Raw(`SELECT * FROM user_calendar_tokens WHERE user_id = ? LIMIT 1`, userID).Scan(t).Error
+	if err != nil {
+		return nil, err
+	}
+	if t.ID != 0 {
+		return t, nil
+	}
+
+	return UserCalendarTokenReset(db, userID)
+}
+
+// Revokes the current token of the user and creates a new one
+func UserCalendarTokenReset(db *gorm.DB, userID uint) (*sharedtypes.UserCalendarToken, error) {
+	token, err := userCalendarTokenGenerate()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &sharedtypes.UserCalendarToken{UserID: userID, Token: token}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := UserCalendarTokenDelete(tx, userID); err != nil {
+			return err
+		}
+		return tx.Create(t).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func UserCalendarTokenDelete(db *gorm.DB, userID uint) error {
+	return db.Exec(`DELETE FROM user_calendar_tokens WHERE user_id = ?`, userID).Error
+}
+
+// Returns 0 if the token does not exist
+func UserCalendarTokenFindUserID(db *gorm.DB, token string) (uint, error) {
+	var userID uint
+	err := db.Raw(`SELECT user_id FROM user_calendar_tokens WHERE token = ? LIMIT 1`, token).Scan(&userID).Error
+	return userID, err
+}
@@ -0,0 +1,232 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+// Time a confirmation link of a location alert stays valid, the same as for the newsletter
+const LocationAlertConfirmExpiry = NewsletterConfirmExpiry
+
+// Largest radius in kilometers a visitor can choose, the same as the largest radius of a loop
+const LocationAlertMaxRadius = 100
+
+var ErrLocationAlertConfirmInvalid = errors.New("Confirmation link is invalid or has expired")
+
+// A visitor that wants to be emailed when a loop opens near them, one alert per email address.
+// The alert is active once Verified is true by using the confirmation link.
+// Changes to a verified alert are kept in Pending until they are confirmed as well.
+type LocationAlert struct {
+	ID            uint
+	Email         string `gorm:"uniqueIndex;type:varchar(255)"`
+	Name          string
+	I18n          string
+	Latitude      float64 `gorm:"index:idx_location_alerts_location,priority:1"`
+	Longitude     float64 `gorm:"index:idx_location_alerts_location,priority:2"`
+	Radius        float32
+	Sizes         []string `gorm:"serializer:json"`
+	Genders       []string `gorm:"serializer:json"`
+	Verified      bool
+	Pending       *LocationAlertPending `gorm:"serializer:json;type:text"`
+	ConfirmToken  *string               `gorm:"uniqueIndex;type:varchar(64)"`
+	ConfirmSentAt *time.Time
+	ConfirmedAt   *time.Time
+	CreatedAt     time.Time
+}
+
+// Requested settings of a verified alert that replace the current ones once confirmed
+type LocationAlertPending struct {
+	Name      string   `json:"name"`
+	I18n      string   `json:"i18n"`
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Radius    float32  `json:"radius"`
+	Sizes     []string `json:"sizes"`
+	Genders   []string `json:"genders"`
+}
+
+// Remembers which loops an alert was sent for, so that a loop that is closed and opened again is only sent once
+type LocationAlertNotification struct {
+	ID              uint
+	LocationAlertID uint `gorm:"uniqueIndex:uidx_location_alert_notifications,priority:1"`
+	ChainID         uint `gorm:"uniqueIndex:uidx_location_alert_notifications,priority:2"`
+	CreatedAt       time.Time
+}
+
+// A verified alert in range of a loop, with the distance between them in kilometers
+type LocationAlertMatch struct {
+	LocationAlert
+	Distance float64 `gorm:"distance"`
+}
+
+// Prefers loops with at least one of the sizes and genders of the alert, no preference matches every loop
+func (a *LocationAlert) MatchesChain(chain *Chain) bool {
+	if len(a.Sizes) > 0 && len(lo.Intersect(a.Sizes, chain.Sizes)) == 0 {
+		return false
+	}
+	if len(a.Genders) > 0 && len(lo.Intersect(a.Genders, chain.Genders)) == 0 {
+		return false
+	}
+	return true
+}
+
+func LocationAlertGetByEmail(db *gorm.DB, email string) (*LocationAlert, error) {
+	a := &LocationAlert{}
+	err := db.Raw(`SELECT * FROM location_alerts WHERE email = ? LIMIT 1`, email).Scan(a).Error
+	if err != nil {
+		return nil, err
+	}
+	if a.ID == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return a, nil
+}
+
+// Creates the alert of the email address, it stays inactive until confirmed.
+// For an email address with a verified alert the settings are only stored as pending,
+// so that the current alert keeps working until the new settings are confirmed.
+// Returns the token for the confirmation link.
+func LocationAlertCreatePending(db *gorm.DB, alert *LocationAlert) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	now := time.Now()
+
+	existing, err := LocationAlertGetByEmail(db, alert.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if existing != nil && existing.Verified {
+		existing.Pending = &LocationAlertPending{
+			Name:      alert.Name,
+			I18n:      alert.I18n,
+			Latitude:  alert.Latitude,
+			Longitude: alert.Longitude,
+			Radius:    alert.Radius,
+			Sizes:     alert.Sizes,
+			Genders:   alert.Genders,
+		}
+		existing.ConfirmToken = &token
+		existing.ConfirmSentAt = &now
+		*alert = *existing
+		return token, db.Save(alert).Error
+	}
+
+	if existing != nil {
+		alert.ID = existing.ID
+		alert.CreatedAt = existing.CreatedAt
+	}
+	alert.Verified = false
+	alert.Pending = nil
+	alert.ConfirmToken = &token
+	alert.ConfirmSentAt = &now
+	alert.ConfirmedAt = nil
+	return token, db.Save(alert).Error
+}
+
+// Activates the alert belonging to the token, or applies its pending settings
+func LocationAlertConfirm(db *gorm.DB, token string) (*LocationAlert, error) {
+	a := &LocationAlert{}
+	err := db.Raw(`SELECT * FROM location_alerts WHERE confirm_token = ? AND confirm_sent_at > ? LIMIT 1`,
+		token, time.Now().Add(-LocationAlertConfirmExpiry)).Scan(a).Error
+	if err != nil {
+		return nil, err
+	}
+	if a.ID == 0 {
+		return nil, ErrLocationAlertConfirmInvalid
+	}
+
+	now := time.Now()
+	if p := a.Pending; p != nil {
+		a.Name = p.Name
+		a.I18n = p.I18n
+		a.Latitude = p.Latitude
+		a.Longitude = p.Longitude
+		a.Radius = p.Radius
+		a.Sizes = p.Sizes
+		a.Genders = p.Genders
+		a.Pending = nil
+	}
+	a.Verified = true
+	a.ConfirmedAt = &now
+	a.ConfirmToken = nil
+	err = db.Save(a).Error
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func LocationAlertDeleteByEmail(db *gorm.DB, email string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`
+DELETE FROM location_alert_notifications WHERE location_alert_id IN (
+	SELECT id FROM location_alerts WHERE email = ?
+)
+		`, email).Error
+		if err != nil {
+			return err
+		}
+		return tx.Exec(`DELETE FROM location_alerts WHERE email = ?`, email).Error
+	})
+}
+
+// Removes alerts of which the confirmation link was never used,
+// verified alerts only lose their unconfirmed pending settings
+func LocationAlertDeleteExpiredPending(db *gorm.DB) (int64, error) {
+	before := time.Now().Add(-LocationAlertConfirmExpiry)
+	err := db.Exec(`
+UPDATE location_alerts SET pending = NULL, confirm_token = NULL
+WHERE verified = TRUE AND confirm_token IS NOT NULL AND confirm_sent_at < ?
+	`, before).Error
+	if err != nil {
+		return 0, err
+	}
+	res := db.Exec(`DELETE FROM location_alerts WHERE verified = FALSE AND confirm_token IS NOT NULL AND confirm_sent_at < ?`, before)
+	return res.RowsAffected, res.Error
+}
+
+// Verified alerts of which the loop lies within their radius and that have not been sent for this loop yet.
+// The largest possible radius narrows the search down to the location index first.
+func LocationAlertGetMatchesForChain(db *gorm.DB, chain *Chain) ([]LocationAlertMatch, error) {
+	degrees := LocationAlertMaxRadius / 111.195
+	matches := []LocationAlertMatch{}
+	err := db.Raw(`
+SELECT la.*, (ST_Distance(POINT(la.latitude, la.longitude), POINT(?, ?)) * 111.195) AS distance
+FROM location_alerts AS la
+WHERE la.verified = TRUE
+	AND la.latitude BETWEEN ? AND ?
+	AND la.longitude BETWEEN ? AND ?
+	AND (ST_Distance(POINT(la.latitude, la.longitude), POINT(?, ?)) * 111.195) <= la.radius
+	AND la.id NOT IN (
+		SELECT lan.location_alert_id FROM location_alert_notifications AS lan WHERE lan.chain_id = ?
+	)
+ORDER BY distance ASC, la.id ASC
+	`,
+		chain.Latitude, chain.Longitude,
+		chain.Latitude-degrees, chain.Latitude+degrees,
+		chain.Longitude-degrees, chain.Longitude+degrees,
+		chain.Latitude, chain.Longitude,
+		chain.ID,
+	).Scan(&matches).Error
+	if err != nil {
+		return nil, err
+	}
+	return lo.Filter(matches, func(m LocationAlertMatch, _ int) bool {
+		return m.MatchesChain(chain)
+	}), nil
+}
+
+func LocationAlertSetNotified(db *gorm.DB, locationAlertID, chainID uint) error {
+	return db.Exec(`
+INSERT IGNORE INTO location_alert_notifications (location_alert_id, chain_id, created_at)
+VALUES (?, ?, NOW())
+	`, locationAlertID, chainID).Error
+}
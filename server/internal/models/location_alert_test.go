@@ -0,0 +1,17 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocationAlertMatchesChain(t *testing.T) {
+	chain := &Chain{Sizes: []string{"1", "2"}, Genders: []string{"1"}}
+
+	assert.True(t, (&LocationAlert{}).MatchesChain(chain), "no preference")
+	assert.True(t, (&LocationAlert{Sizes: []string{"2", "5"}}).MatchesChain(chain))
+	assert.False(t, (&LocationAlert{Sizes: []string{"5"}}).MatchesChain(chain))
+	assert.True(t, (&LocationAlert{Sizes: []string{"1"}, Genders: []string{"1", "2"}}).MatchesChain(chain))
+	assert.False(t, (&LocationAlert{Sizes: []string{"1"}, Genders: []string{"2"}}).MatchesChain(chain))
+}
@@ -0,0 +1,290 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+	"gorm.io/gorm"
+)
+
+const (
+	MAIL_RETRY_NEVER    = 0
+	MAIL_RETRY_NEXT_DAY = 1
+	MAIL_RETRY_TWO_DAYS = 2
+)
+
+const (
+	MailStatusQueued  = "queued"
+	MailStatusSent    = "sent"
+	MailStatusFailed  = "failed"
+	MailStatusBounced = "bounced"
+)
+
+var (
+	ErrMailLastRetry = fmt.Errorf("Last failed attempt")
+	ErrMailRedacted  = fmt.Errorf("The body of this email has been removed")
+)
+
+// Sent, failed and bounced mails older than this are removed from the outbox
+const MailRetention = 90 * 24 * time.Hour
+
+// Time after creation before a retry is attempted, by retry number
+var mailRetryDelays = []time.Duration{15 * time.Hour, 48 * time.Hour}
+
+// Every outgoing email is stored here first and sent by the outbox worker
+type Mail struct {
+	ID                uint        `json:"id"`
+	SenderName        string      `json:"sender_name"`
+	SenderAddress     string      `json:"sender_address"`
+	ToName            string      `json:"to_name"`
+	ToAddress         string      `json:"to_address" gorm:"index"`
+	Subject           string      `json:"subject"`
+	Body              string      `json:"body,omitempty"`
+	Err               null.String `json:"err"`
+	Status            string      `json:"status" gorm:"type:varchar(20);not null;default:'queued';index"`
+	Attempts          int         `json:"attempts" gorm:"not null;default:0"`
+	MaxRetryAttempts  int         `json:"max_retry_attempts"`
+	NextAttemptAt     *time.Time  `json:"next_attempt_at" gorm:"index"`
+	LockedUntil       *time.Time  `json:"-"`
+	SentAt            *time.Time  `json:"sent_at"`
+	ProviderMessageID null.String `json:"provider_message_id" gorm:"type:varchar(255);index"`
+	// Threading headers so that email clients group a conversation, such as contact ticket replies
+	MessageID  null.String `json:"message_id" gorm:"type:varchar(255)"`
+	InReplyTo  null.String `json:"in_reply_to" gorm:"type:varchar(255)"`
+	References null.String `json:"references" gorm:"type:text"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	// Contains a login token, the body is never shown to admins and is removed once the mail is sent.
+	// The body of any mail is removed once it has failed its last attempt or bounced.
+	Sensitive bool `json:"sensitive" gorm:"not null;default:false"`
+
+	// Login and register verifications are sent even if the address is marked undeliverable,
+	// as a successful login clears the mark.
+	AllowUndeliverable bool `json:"-" gorm:"-"`
+	// Adds an unsubscribe link for this notification type to the email layout
+	NotificationType string `json:"-" gorm:"-"`
+	// Overrides the unsubscribe link of the notification type,
+	// stored to send the List-Unsubscribe headers
+	UnsubscribeURL string `json:"-" gorm:"type:text"`
+}
+
+func (m Mail) TableName() string {
+	return "mail_outbox"
+}
+
+type MailAttempt struct {
+	ID        uint        `json:"-"`
+	MailID    uint        `json:"-" gorm:"index;not null"`
+	Transport string      `json:"transport"`
+	Err       null.String `json:"err"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func (m *Mail) Enqueue(db *gorm.DB) error {
+	m.Status = MailStatusQueued
+	return db.Create(m).Error
+}
+
+// Claims queued mails that are due so that no other worker sends them at the same time
+func MailClaimDue(db *gorm.DB, limit int) ([]*Mail, error) {
+	ids := []uint{}
+	err := db.Raw(`
+SELECT id FROM mail_outbox
+WHERE status = ?
+	AND (next_attempt_at IS NULL OR next_attempt_at <= NOW())
+	AND (locked_until IS NULL OR locked_until < NOW())
+ORDER BY id ASC
+LIMIT ?
+	`, MailStatusQueued, limit).Scan(&ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	mails := []*Mail{}
+	for _, id := range ids {
+		res := db.Exec(`
+UPDATE mail_outbox SET locked_until = NOW() + INTERVAL 5 MINUTE
+WHERE id = ? AND status = ? AND (locked_until IS NULL OR locked_until < NOW())
+		`, id, MailStatusQueued)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+
+		m := &Mail{}
+		err = db.Raw(`SELECT * FROM mail_outbox WHERE id = ?`, id).Scan(m).Error
+		if err != nil {
+			return nil, err
+		}
+		mails = append(mails, m)
+	}
+	return mails, nil
+}
+
+func (m *Mail) MarkSent(db *gorm.DB, transport, providerMessageID string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&MailAttempt{MailID: m.ID, Transport: transport}).Error
+		if err != nil {
+			return err
+		}
+
+		m.Attempts += 1
+		m.Status = MailStatusSent
+		m.ProviderMessageID = null.NewString(providerMessageID, providerMessageID != "")
+		if m.Sensitive {
+			m.Body = ""
+		}
+		return tx.Exec(`
+UPDATE mail_outbox
+SET status = ?, attempts = ?, err = NULL, sent_at = NOW(), locked_until = NULL, next_attempt_at = NULL, provider_message_id = ?,
+	body = IF(sensitive, '', body)
+WHERE id = ?
+		`, m.Status, m.Attempts, m.ProviderMessageID, m.ID).Error
+	})
+}
+
+// Schedules the next retry, returns ErrMailLastRetry if no retries are left
+func (m *Mail) MarkFailed(db *gorm.DB, transport string, sendErr error) error {
+	m.Attempts += 1
+	m.Err = null.StringFrom(sendErr.Error())
+	isLastRetry := m.Attempts > m.MaxRetryAttempts
+
+	var nextAttemptAt *time.Time
+	if isLastRetry {
+		m.Status = MailStatusFailed
+		m.Body = ""
+	} else {
+		t := m.CreatedAt.Add(mailRetryDelays[min(m.Attempts, len(mailRetryDelays))-1])
+		if t.Before(time.Now()) {
+			t = time.Now().Add(15 * time.Minute)
+		}
+		nextAttemptAt = &t
+	}
+	m.NextAttemptAt = nextAttemptAt
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Create(&MailAttempt{MailID: m.ID, Transport: transport, Err: m.Err}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Exec(`
+UPDATE mail_outbox
+SET status = ?, attempts = ?, err = ?, next_attempt_at = ?, locked_until = NULL,
+	body = IF(?, '', body)
+WHERE id = ?
+		`, m.Status, m.Attempts, m.Err, m.NextAttemptAt, isLastRetry, m.ID).Error
+	})
+	if err != nil {
+		return err
+	}
+	if isLastRetry {
+		return ErrMailLastRetry
+	}
+	return nil
+}
+
+// Queues the mail again for a single attempt,
+// returns ErrMailRedacted if the body of the mail has been removed
+func MailResend(db *gorm.DB, id uint) error {
+	m, err := MailGet(db, id)
+	if err != nil {
+		return err
+	}
+	if m.Body == "" {
+		return ErrMailRedacted
+	}
+
+	res := db.Exec(`
+UPDATE mail_outbox
+SET status = ?, next_attempt_at = NULL, locked_until = NULL, max_retry_attempts = attempts
+WHERE id = ?
+	`, MailStatusQueued, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Returns false if no sent mail has this provider message id
+func MailMarkBounced(db *gorm.DB, providerMessageID, reason string) (bool, error) {
+	res := db.Exec(`
+UPDATE mail_outbox
+SET status = ?, err = ?, body = IF(attempts >= max_retry_attempts, '', body)
+WHERE provider_message_id = ?
+	`, MailStatusBounced, null.NewString(reason, reason != ""), providerMessageID)
+	return res.RowsAffected > 0, res.Error
+}
+
+func MailGet(db *gorm.DB, id uint) (*Mail, error) {
+	m := &Mail{}
+	err := db.Raw(`SELECT * FROM mail_outbox WHERE id = ? LIMIT 1`, id).Scan(m).Error
+	if err != nil {
+		return nil, err
+	}
+	if m.ID == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return m, nil
+}
+
+// Lists mails newest first without their body, status and toAddress are optional filters.
+// Set beforeID to 0 to start at the newest mail.
+func MailGetAll(db *gorm.DB, status, toAddress string, beforeID uint, limit int) ([]Mail, error) {
+	sql := `
+SELECT id, sender_name, sender_address, to_name, to_address, subject, err, status, attempts,
+	max_retry_attempts, next_attempt_at, sent_at, provider_message_id, message_id, in_reply_to, created_at, updated_at, sensitive
+FROM mail_outbox
+WHERE TRUE`
+	args := []any{}
+	if status != "" {
+		sql += ` AND status = ?`
+		args = append(args, status)
+	}
+	if toAddress != "" {
+		sql += ` AND to_address = ?`
+		args = append(args, toAddress)
+	}
+	if beforeID != 0 {
+		sql += ` AND id < ?`
+		args = append(args, beforeID)
+	}
+	sql += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	mails := []Mail{}
+	err := db.Raw(sql, args...).Scan(&mails).Error
+	return mails, err
+}
+
+func MailGetAttempts(db *gorm.DB, mailID uint) ([]MailAttempt, error) {
+	attempts := []MailAttempt{}
+	err := db.Raw(`SELECT * FROM mail_attempts WHERE mail_id = ? ORDER BY id ASC`, mailID).Scan(&attempts).Error
+	return attempts, err
+}
+
+// Removes sent, failed and bounced mails and their attempts that were sent, or created if never sent, before the given time
+func MailDeleteBefore(db *gorm.DB, before time.Time) (int64, error) {
+	statuses := []string{MailStatusSent, MailStatusFailed, MailStatusBounced}
+	var affected int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`
+DELETE FROM mail_attempts WHERE mail_id IN (
+	SELECT id FROM mail_outbox WHERE status IN ? AND COALESCE(sent_at, created_at) < ?
+)
+		`, statuses, before).Error
+		if err != nil {
+			return err
+		}
+		res := tx.Exec(`DELETE FROM mail_outbox WHERE status IN ? AND COALESCE(sent_at, created_at) < ?`, statuses, before)
+		affected = res.RowsAffected
+		return res.Error
+	})
+	return affected, err
+}
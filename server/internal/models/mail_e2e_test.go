@@ -0,0 +1,200 @@
+//go:build !ci
+
+package models_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+)
+
+func TestMailClaimDue(t *testing.T) {
+	expected := mocks.MockMail(t, db, mocks.MockMailOptions{})
+
+	list, err := models.MailClaimDue(db, 1000)
+	assert.NoError(t, err)
+	_, found := lo.Find(list, func(m *models.Mail) bool { return m.ID == expected.ID })
+	assert.True(t, found)
+
+	// claimed mails are locked for other workers
+	list, err = models.MailClaimDue(db, 1000)
+	assert.NoError(t, err)
+	_, found = lo.Find(list, func(m *models.Mail) bool { return m.ID == expected.ID })
+	assert.False(t, found)
+}
+
+func TestMailClaimDueHidden(t *testing.T) {
+	tests := []mocks.MockMailOptions{
+		{Status: models.MailStatusSent},
+		{Status: models.MailStatusFailed, IsErr: true},
+		{NextAttemptAt: lo.ToPtr(time.Now().Add(time.Hour)), IsErr: true, Attempts: 1, MaxRetryAttempts: models.MAIL_RETRY_TWO_DAYS},
+	}
+
+	for _, o := range tests {
+		expected := mocks.MockMail(t, db, o)
+		t.Run(fmt.Sprintf("with status %s attempts %d", o.Status, o.Attempts), func(t *testing.T) {
+			list, err := models.MailClaimDue(db, 1000)
+			assert.NoError(t, err)
+			_, found := lo.Find(list, func(m *models.Mail) bool { return m.ID == expected.ID })
+			assert.False(t, found)
+		})
+	}
+}
+
+func TestMailMarkFailedSchedulesRetry(t *testing.T) {
+	expected := mocks.MockMail(t, db, mocks.MockMailOptions{
+		MaxRetryAttempts: models.MAIL_RETRY_TWO_DAYS,
+	})
+
+	err := expected.MarkFailed(db, "test", fmt.Errorf("NewError: %v", faker.Pet().Dog()))
+	assert.NoError(t, err)
+
+	found, err := models.MailGet(db, expected.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.MailStatusQueued, found.Status)
+	assert.Equal(t, 1, found.Attempts)
+	assert.True(t, found.Err.Valid)
+	if assert.NotNil(t, found.NextAttemptAt) {
+		assert.True(t, found.NextAttemptAt.After(time.Now()))
+	}
+
+	attempts, err := models.MailGetAttempts(db, expected.ID)
+	assert.NoError(t, err)
+	assert.Len(t, attempts, 1)
+}
+
+func TestMailMarkFailedLastRetry(t *testing.T) {
+	tests := []mocks.MockMailOptions{
+		{MaxRetryAttempts: models.MAIL_RETRY_NEVER},
+		{MaxRetryAttempts: models.MAIL_RETRY_NEXT_DAY, Attempts: 1, IsErr: true},
+		{MaxRetryAttempts: models.MAIL_RETRY_TWO_DAYS, Attempts: 2, IsErr: true},
+	}
+
+	for _, o := range tests {
+		expected := mocks.MockMail(t, db, o)
+		t.Run(fmt.Sprintf("with attempts %d max %d", o.Attempts, o.MaxRetryAttempts), func(t *testing.T) {
+			err := expected.MarkFailed(db, "test", fmt.Errorf("NewError: %v", faker.Pet().Dog()))
+			assert.ErrorIs(t, err, models.ErrMailLastRetry)
+
+			found, err := models.MailGet(db, expected.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, models.MailStatusFailed, found.Status)
+			assert.Nil(t, found.NextAttemptAt)
+		})
+	}
+}
+
+func TestMailResend(t *testing.T) {
+	expected := mocks.MockMail(t, db, mocks.MockMailOptions{
+		Status:   models.MailStatusFailed,
+		IsErr:    true,
+		Attempts: 1,
+	})
+
+	err := models.MailResend(db, expected.ID)
+	assert.NoError(t, err)
+
+	found, err := models.MailGet(db, expected.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.MailStatusQueued, found.Status)
+	assert.Equal(t, found.Attempts, found.MaxRetryAttempts)
+}
+
+func TestMailBodyRemoved(t *testing.T) {
+	t.Run("Sent", func(t *testing.T) {
+		expected := mocks.MockMail(t, db, mocks.MockMailOptions{IsSensitive: true})
+		err := expected.MarkSent(db, "test", "")
+		assert.NoError(t, err)
+
+		found, err := models.MailGet(db, expected.ID)
+		assert.NoError(t, err)
+		assert.Empty(t, found.Body)
+		assert.ErrorIs(t, models.MailResend(db, expected.ID), models.ErrMailRedacted)
+	})
+
+	t.Run("Retry keeps the body", func(t *testing.T) {
+		expected := mocks.MockMail(t, db, mocks.MockMailOptions{IsSensitive: true, MaxRetryAttempts: models.MAIL_RETRY_TWO_DAYS})
+		err := expected.MarkFailed(db, "test", fmt.Errorf("NewError"))
+		assert.NoError(t, err)
+
+		found, err := models.MailGet(db, expected.ID)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, found.Body)
+	})
+
+	t.Run("Last retry", func(t *testing.T) {
+		expected := mocks.MockMail(t, db, mocks.MockMailOptions{IsSensitive: true})
+		err := expected.MarkFailed(db, "test", fmt.Errorf("NewError"))
+		assert.ErrorIs(t, err, models.ErrMailLastRetry)
+
+		found, err := models.MailGet(db, expected.ID)
+		assert.NoError(t, err)
+		assert.Empty(t, found.Body)
+	})
+
+	t.Run("Last retry of other mails", func(t *testing.T) {
+		expected := mocks.MockMail(t, db, mocks.MockMailOptions{})
+		err := expected.MarkFailed(db, "test", fmt.Errorf("NewError"))
+		assert.ErrorIs(t, err, models.ErrMailLastRetry)
+
+		found, err := models.MailGet(db, expected.ID)
+		assert.NoError(t, err)
+		assert.Empty(t, found.Body)
+		assert.ErrorIs(t, models.MailResend(db, expected.ID), models.ErrMailRedacted)
+	})
+
+	t.Run("Bounced", func(t *testing.T) {
+		expected := mocks.MockMail(t, db, mocks.MockMailOptions{})
+		providerMessageID := "<" + faker.UUID().V4() + "@example.com>"
+		err := expected.MarkSent(db, "test", providerMessageID)
+		assert.NoError(t, err)
+
+		found, err := models.MailMarkBounced(db, providerMessageID, "hard_bounce")
+		assert.NoError(t, err)
+		assert.True(t, found)
+
+		m, err := models.MailGet(db, expected.ID)
+		assert.NoError(t, err)
+		assert.Empty(t, m.Body)
+	})
+
+	t.Run("Other mails keep the body", func(t *testing.T) {
+		expected := mocks.MockMail(t, db, mocks.MockMailOptions{})
+		err := expected.MarkSent(db, "test", "")
+		assert.NoError(t, err)
+
+		found, err := models.MailGet(db, expected.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, expected.Body, found.Body)
+	})
+}
+
+func TestMailDeleteBefore(t *testing.T) {
+	old := mocks.MockMail(t, db, mocks.MockMailOptions{Status: models.MailStatusSent})
+	recent := mocks.MockMail(t, db, mocks.MockMailOptions{Status: models.MailStatusSent})
+	failed := mocks.MockMail(t, db, mocks.MockMailOptions{Status: models.MailStatusFailed, IsErr: true})
+	bounced := mocks.MockMail(t, db, mocks.MockMailOptions{Status: models.MailStatusBounced, IsErr: true})
+	queued := mocks.MockMail(t, db, mocks.MockMailOptions{})
+	tooOld := time.Now().Add(-models.MailRetention - time.Hour)
+	db.Exec(`UPDATE mail_outbox SET sent_at = ? WHERE id IN ?`, tooOld, []uint{old.ID, bounced.ID})
+	db.Exec(`UPDATE mail_outbox SET sent_at = NOW() WHERE id = ?`, recent.ID)
+	// failed and queued mails were never sent, their creation date is used
+	db.Exec(`UPDATE mail_outbox SET created_at = ? WHERE id IN ?`, tooOld, []uint{failed.ID, queued.ID})
+
+	_, err := models.MailDeleteBefore(db, time.Now().Add(-models.MailRetention))
+	assert.NoError(t, err)
+
+	for _, id := range []uint{old.ID, failed.ID, bounced.ID} {
+		_, err = models.MailGet(db, id)
+		assert.Error(t, err)
+	}
+	_, err = models.MailGet(db, recent.ID)
+	assert.NoError(t, err)
+	_, err = models.MailGet(db, queued.ID)
+	assert.NoError(t, err, "queued mails are still to be sent")
+}
@@ -1,29 +1,134 @@
 package models
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// Time a confirmation link of the newsletter double opt-in stays valid
+const NewsletterConfirmExpiry = 7 * 24 * time.Hour
+
+var ErrNewsletterConfirmInvalid = errors.New("Confirmation link is invalid or has expired")
+
+// A subscription is active once Verified is true,
+// either by the confirmation link or by the owner verifying their email address by logging in.
 type Newsletter struct {
-	ID        uint
-	Email     string `gorm:"uniqueIndex"`
-	Name      string
-	Verified  bool
-	CreatedAt time.Time
+	ID            uint
+	Email         string `gorm:"uniqueIndex"`
+	Name          string
+	Verified      bool
+	ConfirmToken  *string `gorm:"uniqueIndex;type:varchar(64)"`
+	ConfirmSentAt *time.Time
+	ConfirmedAt   *time.Time
+	BrevoSyncedAt *time.Time
+	CreatedAt     time.Time
 }
 
 // This requires the following values to be populated: Email, Name, Verified
 func (n *Newsletter) CreateOrUpdate(db *gorm.DB) error {
+	if n.Verified && n.ConfirmedAt == nil {
+		now := time.Now()
+		n.ConfirmedAt = &now
+	}
 	if err := db.Create(n).Error; err != nil {
 		err = db.Exec(`
 UPDATE newsletters
-SET name = ?, verified = ?
+SET name = ?, verified = ?, confirmed_at = IF(?, IFNULL(confirmed_at, NOW()), confirmed_at)
 WHERE email = ?
-		`, n.Name, n.Verified, n.Email).Error
+		`, n.Name, n.Verified, n.Verified, n.Email).Error
 		return err
 	}
 
 	return nil
 }
+
+func NewsletterGetByEmail(db *gorm.DB, email string) (*Newsletter, error) {
+	n := &Newsletter{}
+	err := db.Raw(`SELECT * FROM newsletters WHERE email = ? LIMIT 1`, email).Scan(n).Error
+	if err != nil {
+		return nil, err
+	}
+	if n.ID == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return n, nil
+}
+
+// Creates or renews a pending subscription and returns the token for the confirmation link
+func NewsletterCreatePending(db *gorm.DB, name, email string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	now := time.Now()
+
+	n, err := NewsletterGetByEmail(db, email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if n == nil {
+		n = &Newsletter{Email: email}
+	}
+	n.Name = name
+	n.ConfirmToken = &token
+	n.ConfirmSentAt = &now
+	return token, db.Save(n).Error
+}
+
+// Activates the subscription belonging to the token
+func NewsletterConfirm(db *gorm.DB, token string) (*Newsletter, error) {
+	n := &Newsletter{}
+	err := db.Raw(`SELECT * FROM newsletters WHERE confirm_token = ? AND confirm_sent_at > ? LIMIT 1`,
+		token, time.Now().Add(-NewsletterConfirmExpiry)).Scan(n).Error
+	if err != nil {
+		return nil, err
+	}
+	if n.ID == 0 {
+		return nil, ErrNewsletterConfirmInvalid
+	}
+
+	now := time.Now()
+	n.Verified = true
+	n.ConfirmedAt = &now
+	n.ConfirmToken = nil
+	err = db.Exec(`UPDATE newsletters SET verified = TRUE, confirmed_at = ?, confirm_token = NULL WHERE id = ?`, now, n.ID).Error
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func NewsletterDeleteByEmail(db *gorm.DB, email string) error {
+	return db.Exec(`DELETE FROM newsletters WHERE email = ?`, email).Error
+}
+
+// Removes subscriptions of which the confirmation link was never used
+func NewsletterDeleteExpiredPending(db *gorm.DB) (int64, error) {
+	res := db.Exec(`DELETE FROM newsletters WHERE verified = FALSE AND confirm_token IS NOT NULL AND confirm_sent_at < ?`,
+		time.Now().Add(-NewsletterConfirmExpiry))
+	return res.RowsAffected, res.Error
+}
+
+// Active subscriptions that have not been compared with Brevo since the given time, oldest first
+func NewsletterGetAllUnsynced(db *gorm.DB, syncedBefore time.Time, limit int) ([]Newsletter, error) {
+	list := []Newsletter{}
+	err := db.Raw(`
+SELECT * FROM newsletters
+WHERE verified = TRUE AND (brevo_synced_at IS NULL OR brevo_synced_at < ?)
+ORDER BY brevo_synced_at IS NOT NULL, brevo_synced_at ASC, id ASC
+LIMIT ?
+	`, syncedBefore, limit).Scan(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func NewsletterSetBrevoSynced(db *gorm.DB, id uint) error {
+	return db.Exec(`UPDATE newsletters SET brevo_synced_at = NOW() WHERE id = ?`, id).Error
+}
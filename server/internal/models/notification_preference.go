@@ -0,0 +1,103 @@
+package models
+
+import (
+	"slices"
+
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+func NotificationTypeIsOptIn(notificationType string) bool {
+	return slices.Contains(sharedtypes.NotificationTypesOptIn, notificationType)
+}
+
+func NotificationTypeHasChannel(notificationType, channel string) bool {
+	channels, ok := sharedtypes.NotificationTypeChannels[notificationType]
+	return ok && slices.Contains(channels, channel)
+}
+
+// Returns every notification type and channel combination, including the defaults that are not stored
+func NotificationPreferenceGetAll(db *gorm.DB, userID uint) ([]sharedtypes.NotificationPreferenceItem, error) {
+	stored := []sharedtypes.NotificationPreference{}
+	err := db.Raw(`SELECT * FROM notification_preferences WHERE user_id = ?`, userID).Scan(&stored).Error
+	if err != nil {
+		return nil, err
+	}
+
+	types := lo.Keys(sharedtypes.NotificationTypeChannels)
+	slices.Sort(types)
+	items := []sharedtypes.NotificationPreferenceItem{}
+	for _, t := range types {
+		isOptIn := NotificationTypeIsOptIn(t)
+		for _, channel := range sharedtypes.NotificationTypeChannels[t] {
+			p, found := lo.Find(stored, func(p sharedtypes.NotificationPreference) bool {
+				return p.Type == t && p.Channel == channel
+			})
+			items = append(items, sharedtypes.NotificationPreferenceItem{
+				Type:    t,
+				Channel: channel,
+				Enabled: lo.Ternary(found, p.Enabled, !isOptIn),
+			})
+		}
+	}
+	return items, nil
+}
+
+func NotificationPreferenceSet(db *gorm.DB, userID uint, notificationType, channel string, enabled bool) error {
+	return db.Exec(`
+INSERT INTO notification_preferences (user_id, type, channel, enabled, updated_at)
+VALUES (?, ?, ?, ?, NOW())
+ON DUPLICATE KEY UPDATE enabled = VALUES(enabled), updated_at = NOW()
+	`, userID, notificationType, channel, enabled).Error
+}
+
+// Returns the user uids that have not turned off this notification
+func NotificationPreferenceFilterUserUIDs(db *gorm.DB, notificationType, channel string, userUIDs []string) ([]string, error) {
+	if len(userUIDs) == 0 {
+		return userUIDs, nil
+	}
+	if NotificationTypeIsOptIn(notificationType) {
+		enabledUIDs := []string{}
+		err := db.Raw(`
+SELECT u.uid FROM notification_preferences AS np
+JOIN users AS u ON u.id = np.user_id
+WHERE np.type = ? AND np.channel = ? AND np.enabled = TRUE AND u.uid IN ?
+	`, notificationType, channel, userUIDs).Pluck("uid", &enabledUIDs).Error
+		return enabledUIDs, err
+	}
+
+	disabledUIDs := []string{}
+	err := db.Raw(`
+SELECT u.uid FROM notification_preferences AS np
+JOIN users AS u ON u.id = np.user_id
+WHERE np.type = ? AND np.channel = ? AND np.enabled = FALSE AND u.uid IN ?
+	`, notificationType, channel, userUIDs).Pluck("uid", &disabledUIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return lo.Without(userUIDs, disabledUIDs...), nil
+}
+
+// Addresses that do not belong to a user are enabled, except for opt-in types
+func NotificationPreferenceIsEnabledByEmail(db *gorm.DB, notificationType, channel, email string) (bool, error) {
+	isOptIn := NotificationTypeIsOptIn(notificationType)
+	count := 0
+	err := db.Raw(`
+SELECT COUNT(*) FROM notification_preferences AS np
+JOIN users AS u ON u.id = np.user_id
+WHERE np.type = ? AND np.channel = ? AND np.enabled = ? AND u.email = ?
+	`, notificationType, channel, isOptIn, email).Scan(&count).Error
+	if err != nil {
+		return false, err
+	}
+	if isOptIn {
+		return count > 0, nil
+	}
+	return count == 0, nil
+}
+
+func NotificationPreferenceDeleteAll(db *gorm.DB, userID uint) error {
+	return db.Exec(`DELETE FROM notification_preferences WHERE user_id = ?`, userID).Error
+}
@@ -161,6 +161,51 @@ func (u *User) AcceptLegal(db *gorm.DB) error {
 	return nil
 }
 
+// Only flags the first report, later reports keep the original date and reason
+func UserSetEmailUndeliverable(db *gorm.DB, email, reason string) (found bool, err error) {
+	res := db.Exec(`
+UPDATE users
+SET email_undeliverable_at = NOW(), email_undeliverable_reason = ?
+WHERE email = ? AND email_undeliverable_at IS NULL
+	`, reason, email)
+	return res.RowsAffected > 0, res.Error
+}
+
+func UserClearEmailUndeliverable(db *gorm.DB, userID uint) error {
+	return db.Exec(`
+UPDATE users
+SET email_undeliverable_at = NULL, email_undeliverable_reason = ''
+WHERE id = ? AND email_undeliverable_at IS NOT NULL
+	`, userID).Error
+}
+
+func UserIsEmailUndeliverable(db *gorm.DB, email string) (bool, error) {
+	count := 0
+	err := db.Raw(`
+SELECT COUNT(*) FROM users
+WHERE email = ? AND email_undeliverable_at IS NOT NULL
+	`, email).Scan(&count).Error
+	return count > 0, err
+}
+
+// Returns the i18n of each user by uid
+func UserGetI18nByUIDs(db *gorm.DB, userUIDs []string) (map[string]string, error) {
+	rows := []struct {
+		UID  string
+		I18n string
+	}{}
+	err := db.Raw(`SELECT uid, i18n FROM users WHERE uid IN ?`, userUIDs).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(rows))
+	for _, row := range rows {
+		result[row.UID] = row.I18n
+	}
+	return result, nil
+}
+
 type UserContactData struct {
 	Name       string      `gorm:"name"`
 	Email      zero.String `gorm:"email"`
@@ -248,6 +293,34 @@ WHERE user_chains.chain_id = ? AND users.is_email_verified = TRUE
 	return results, nil
 }
 
+// Returns the verified members of a loop in route order, starting after the given position in the route.
+// Members with the same route order are sorted by id, an afterUserID of 0 starts at the beginning.
+func UserGetPageByChain(db *gorm.DB, chainID uint, afterRouteOrder int, afterUserID uint, limit int) ([]User, error) {
+	results := []User{}
+
+	sql := `
+SELECT users.*
+FROM users
+JOIN user_chains ON user_chains.user_id = users.id 
+WHERE user_chains.chain_id = ? AND users.is_email_verified = TRUE
+`
+	args := []any{chainID}
+	if afterUserID != 0 {
+		sql += `	AND (user_chains.route_order > ? OR (user_chains.route_order = ? AND users.id > ?))
+`
+		args = append(args, afterRouteOrder, afterRouteOrder, afterUserID)
+	}
+	sql += `ORDER BY user_chains.route_order ASC, users.id ASC
+LIMIT ?`
+	args = append(args, limit)
+
+	err := db.Raw(sql, args...).Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
 func UserGetAllApprovedUserUIDsByChain(db *gorm.DB, chainID uint) ([]string, error) {
 	results := []struct {
 		UID string `gorm:"uid"`
@@ -290,122 +363,216 @@ func UserCheckEmail(db *gorm.DB, userEmail string) (userID uint, found bool, err
 	return row.ID, true, nil
 }
 
-func UserOmitData(db *gorm.DB, chain *Chain, users []User, authUserID uint) ([]User, error) {
-	routePrivacy := chain.RoutePrivacy
+// Route distance used for members that only share with their neighbours in a loop that shows everything
+const UserFieldPrivacyDefaultDistance = 2
 
-	// Show all users information
-	if routePrivacy == -1 {
-		// slog.Info("Show all users information")
-		return users, nil
+const (
+	userFieldEmail   = "email"
+	userFieldPhone   = "phone_number"
+	userFieldAddress = "address"
+)
+
+type userPrivacyMember struct {
+	UserID         uint   `gorm:"user_id"`
+	UID            string `gorm:"uid"`
+	IsApproved     bool   `gorm:"is_approved"`
+	IsChainAdmin   bool   `gorm:"is_chain_admin"`
+	IsPaused       bool   `gorm:"is_paused"`
+	HasBulkyItem   bool   `gorm:"has_bulky_item"`
+	PrivacyEmail   string `gorm:"privacy_email"`
+	PrivacyPhone   string `gorm:"privacy_phone"`
+	PrivacyAddress string `gorm:"privacy_address"`
+}
+
+// Decides which contact fields of the loop members a participant may see.
+// Hosts and root admins see everything, callers should not use this for them.
+type UserPrivacy struct {
+	authUserID   uint
+	routePrivacy int
+	members      map[uint]userPrivacyMember
+	closeBy      []uint
+}
+
+func UserPrivacyGet(db *gorm.DB, chain *Chain, authUserID uint) (*UserPrivacy, error) {
+	members := []userPrivacyMember{}
+	err := db.Raw(`
+SELECT
+	u.id AS user_id,
+	u.uid,
+	uc.is_approved,
+	uc.is_chain_admin,
+	`+UserChainIsPausedSql+` AS is_paused,
+	EXISTS (SELECT 1 FROM bulky_items AS bi WHERE bi.user_chain_id = uc.id) AS has_bulky_item,
+	u.privacy_email,
+	u.privacy_phone,
+	u.privacy_address
+FROM user_chains AS uc
+JOIN users AS u ON u.id = uc.user_id
+WHERE uc.chain_id = ?
+ORDER BY uc.route_order ASC
+	`, chain.ID).Scan(&members).Error
+	if err != nil {
+		return nil, err
 	}
 
-	// Hide all users information
-	if routePrivacy == 0 {
-		// slog.Info("Hide all users information")
-		for i, user := range users {
-			if user.ID != authUserID {
-				_, isChainAdmin := user.IsPartOfChain(chain.UID)
-				hideUserInformation(isChainAdmin, &users[i])
-			}
-		}
-		return users, nil
+	p := &UserPrivacy{
+		authUserID:   authUserID,
+		routePrivacy: chain.RoutePrivacy,
+		members:      make(map[uint]userPrivacyMember, len(members)),
+	}
+	for _, m := range members {
+		p.members[m.UserID] = m
 	}
 
-	if len(users) == 0 {
-		return users, nil
+	// paused members are skipped when counting neighbours
+	route := lo.FilterMap(members, func(m userPrivacyMember, _ int) (uint, bool) {
+		return m.UserID, m.IsApproved && !m.IsPaused
+	})
+	distance := chain.RoutePrivacy
+	if distance <= 0 {
+		distance = UserFieldPrivacyDefaultDistance
+	}
+	if len(route) > 0 {
+		r := ring_ext.NewWithValues(route)
+		p.closeBy = ring_ext.GetSurroundingValues(r, authUserID, distance)
 	}
 
-	// slog.Info("Hide out of bounds information")
-	userIDsWithBulkyItems := []uint{}
-	db.Raw(`
-			SELECT u.id
-			FROM users AS u
-			JOIN user_chains AS uc on uc.user_id = u.id
-			JOIN bulky_items AS bi ON uc.id = bi.user_chain_id
-			WHERE uc.chain_id = ? AND bi.id IS NOT NULL
-		`, chain.ID).Pluck("id", &userIDsWithBulkyItems)
-	userIDsChainAdmin := []uint{}
-	userIDsPaused := []uint{}
+	slog.Debug("User privacy", "chainID", chain.ID, "routePrivacy", chain.RoutePrivacy, "closeBy", p.closeBy)
+	return p, nil
+}
 
-	routeUIDs, err := chain.GetRouteOrderByUserUID(db)
-	if err != nil {
-		return nil, err
+// The loop decides the minimum privacy, a member can only choose to share less
+func (p *UserPrivacy) level(userID uint, field string) string {
+	m, ok := p.members[userID]
+
+	chainLevel := sharedtypes.UserFieldPrivacyNeighbours
+	switch {
+	case p.routePrivacy == -1:
+		chainLevel = sharedtypes.UserFieldPrivacyLoop
+	case ok && m.HasBulkyItem:
+		// members need to be able to pick up bulky items
+		chainLevel = sharedtypes.UserFieldPrivacyLoop
+	case ok && m.IsChainAdmin && field != userFieldAddress:
+		// hosts can always be contacted
+		chainLevel = sharedtypes.UserFieldPrivacyLoop
+	case p.routePrivacy == 0:
+		chainLevel = sharedtypes.UserFieldPrivacyHosts
+	}
+	if !ok {
+		return chainLevel
 	}
 
-	lo.ForEach(users, func(u User, i int) {
-		_, isChainAdmin := u.IsPartOfChain(chain.UID)
-		if isChainAdmin {
-			userIDsChainAdmin = append(userIDsChainAdmin, u.ID)
-		}
+	memberLevel := ""
+	switch field {
+	case userFieldEmail:
+		memberLevel = m.PrivacyEmail
+	case userFieldPhone:
+		memberLevel = m.PrivacyPhone
+	case userFieldAddress:
+		memberLevel = m.PrivacyAddress
+	}
+	if memberLevel != "" && userFieldPrivacyRank(memberLevel) < userFieldPrivacyRank(chainLevel) {
+		return memberLevel
+	}
+	return chainLevel
+}
 
-		isCurrentlyPaused := false
-		if u.PausedUntil != nil {
-			isCurrentlyPaused = u.PausedUntil.After(time.Now())
-		}
-		if !isCurrentlyPaused {
-			uc, ok := lo.Find(u.Chains, func(uc sharedtypes.UserChain) bool { return uc.UserID == u.ID && uc.ChainID == chain.ID })
-			if ok && uc.IsPaused {
-				isCurrentlyPaused = true
-			}
-		}
-		if isCurrentlyPaused {
-			userIDsPaused = append(userIDsPaused, u.ID)
-		}
-	})
+func userFieldPrivacyRank(level string) int {
+	switch level {
+	case sharedtypes.UserFieldPrivacyHosts:
+		return 0
+	case sharedtypes.UserFieldPrivacyNeighbours:
+		return 1
+	default:
+		return 2
+	}
+}
 
-	route := []uint{}
-	if len(routeUIDs) != len(users) {
-		slog.Info("missmatch length between route uid list and chain users list", "routeUID", routeUIDs, "users", lo.Map(users, func(u User, i int) string { return u.UID }))
+func (p *UserPrivacy) CanSee(userID uint, field string) bool {
+	if userID == p.authUserID {
+		return true
 	}
-	for _, uid := range routeUIDs {
-		user, ok := lo.Find(users, func(u User) bool { return u.UID == uid })
-		if !ok {
-			slog.Warn("missmatch between route uid list and chain users list", "routeUID", uid, "users", lo.Map(users, func(u User, i int) string { return u.UID }))
-			continue
-		}
-		route = append(route, user.ID)
+	switch p.level(userID, field) {
+	case sharedtypes.UserFieldPrivacyLoop:
+		return true
+	case sharedtypes.UserFieldPrivacyNeighbours:
+		return lo.Contains(p.closeBy, userID)
+	default:
+		return false
 	}
+}
+
+// Which contact fields of a member every participant of the loop may see, used for printed route sheets
+func (p *UserPrivacy) IsSharedWithLoop(userID uint) (email, phone, address bool) {
+	return p.level(userID, userFieldEmail) == sharedtypes.UserFieldPrivacyLoop,
+		p.level(userID, userFieldPhone) == sharedtypes.UserFieldPrivacyLoop,
+		p.level(userID, userFieldAddress) == sharedtypes.UserFieldPrivacyLoop
+}
 
-	// Iterates over the noderoute and returns early for each user that matches:
-	// 1. the current user
-	// 2. users with a bulky item
-	// 3. users close by with a max distance defined by the route privacy
-	//    paused users are skipped
+// The location of a member is as private as their address
+func (p *UserPrivacy) CanSeeLocationByUID(userUID string) bool {
+	for _, m := range p.members {
+		if m.UID == userUID {
+			return p.CanSee(m.UserID, userFieldAddress)
+		}
+	}
+	return false
+}
 
-	routeWithoutPausedUsers := lo.Filter(route, func(item uint, i int) bool {
-		return !lo.Contains(userIDsPaused, item)
-	})
-	userIDsCloseBy := []uint{}
-	if len(routeWithoutPausedUsers) > 0 {
-		r := ring_ext.NewWithValues(routeWithoutPausedUsers)
-		userIDsCloseBy = ring_ext.GetSurroundingValues(r, authUserID, chain.RoutePrivacy)
-	}
-
-	slog.Info("User IDs",
-		"bulkyItems", userIDsWithBulkyItems,
-		"closeBy", userIDsCloseBy,
-		"chainAdmin", userIDsChainAdmin,
-		"pausedUsers", userIDsPaused,
-		"routeIDs", route,
+func (p *UserPrivacy) Omit(user *User) {
+	hideUserInformation(user,
+		!p.CanSee(user.ID, userFieldEmail),
+		!p.CanSee(user.ID, userFieldPhone),
+		!p.CanSee(user.ID, userFieldAddress),
 	)
+}
 
+// Hides the contact fields of other members for a participant of the loop
+func UserOmitData(db *gorm.DB, chain *Chain, users []User, authUserID uint) ([]User, error) {
+	if len(users) == 0 {
+		return users, nil
+	}
+
+	p, err := UserPrivacyGet(db, chain, authUserID)
+	if err != nil {
+		return nil, err
+	}
 	for i := range users {
-		uID := users[i].ID
-		if authUserID == uID {
-			continue
+		p.Omit(&users[i])
+	}
+
+	return users, nil
+}
+
+// Hides the contact fields of event attendees for an organiser that is not a host of the loop of the event.
+// Attendees of an event outside of a loop only share their name with the organiser.
+func UserOmitEventAttendeeData(db *gorm.DB, event *Event, attendees []sharedtypes.EventAttendee, authUserID uint) error {
+	var p *UserPrivacy
+	if event.ChainUID != nil {
+		chain, err := ChainGetByUID(db, *event.ChainUID)
+		if err != nil && !errors.Is(err, ErrChainNotFound) {
+			return err
 		}
-		if lo.Contains(userIDsWithBulkyItems, uID) {
-			continue
+		if chain != nil {
+			p, err = UserPrivacyGet(db, chain, authUserID)
+			if err != nil {
+				return err
+			}
 		}
-		if lo.Contains(userIDsCloseBy, uID) {
+	}
+	for i := range attendees {
+		a := &attendees[i]
+		if a.UserID == authUserID {
 			continue
 		}
-		isChainAdmin := lo.Contains(userIDsChainAdmin, uID)
-
-		hideUserInformation(isChainAdmin, &users[i])
+		if p == nil || !p.CanSee(a.UserID, userFieldEmail) {
+			a.Email = "***"
+		}
+		if p == nil || !p.CanSee(a.UserID, userFieldPhone) {
+			a.PhoneNumber = "***"
+		}
 	}
-
-	return users, nil
+	return nil
 }
 
 func UserChatEmailToChatUserName(chatEmail string) (*string, error) {
@@ -416,10 +583,14 @@ func UserChatEmailToChatUserName(chatEmail string) (*string, error) {
 	return &b, nil
 }
 
-func hideUserInformation(isChainAdmin bool, user *User) {
-	if !isChainAdmin {
+func hideUserInformation(user *User, email, phone, address bool) {
+	if email {
 		user.Email = lo.ToPtr("***")
+	}
+	if phone {
 		user.PhoneNumber = "***"
 	}
-	user.Address = "***"
+	if address {
+		user.Address = "***"
+	}
 }
@@ -2,15 +2,25 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gopkg.in/guregu/null.v3"
 	"gorm.io/gorm"
 )
 
 var ErrRouteInvalid = errors.New("Invalid route")
 
+// SQL condition that is true while a member is on holiday, everywhere or only in this loop.
+// Requires users joined as u and user_chains as uc.
+const UserChainIsPausedSql = `(
+	(COALESCE(u.paused_until > NOW(), FALSE) AND COALESCE(u.paused_from <= NOW(), TRUE))
+	OR (uc.is_paused AND COALESCE(uc.paused_from <= NOW(), TRUE) AND COALESCE(uc.paused_until > NOW(), TRUE))
+)`
+
 func UserChainSetNote(db *gorm.DB, userID, chainID uint, note string) error {
 	return db.Exec(`UPDATE user_chains SET note = ? WHERE user_id = ? AND chain_id = ?`, sql.NullString{Valid: note != "", String: note}, userID, chainID).Error
 }
@@ -55,17 +65,19 @@ SELECT id, user_chain_id FROM bags WHERE user_chain_id IN (
 		return fmt.Errorf("Error to selecting bags: %v", err)
 	}
 
-	// Pass on bag to other host if possible, otherwise stay put
+	// Pass on bag to other host if possible, preferably one that is not on holiday, otherwise stay put
 	errs := []error{}
 	for _, bag := range bags {
 		err := db.Exec(`
 UPDATE bags SET user_chain_id = (
 	SELECT uc.id FROM user_chains AS uc
+	JOIN users AS u ON u.id = uc.user_id
 	WHERE uc.is_chain_admin IS TRUE
 		AND uc.is_approved IS TRUE
 		AND uc.chain_id = ?
 	ORDER BY
 		uc.user_id != ? DESC,
+		`+UserChainIsPausedSql+` ASC,
 		uc.user_id
 	LIMIT 1
 ) WHERE id = ?`, chainID, u.ID, bag.ID).Error
@@ -132,10 +144,7 @@ DELETE FROM bulky_items WHERE user_chain_id IN (
 	return nil
 }
 
-func UserChainGetIndirectByChain(db *gorm.DB, chainID uint) ([]sharedtypes.UserChain, error) {
-	results := []sharedtypes.UserChain{}
-
-	err := db.Raw(`
+const userChainIndirectSql = `
 	SELECT
 		user_chains.id             AS id,
 		user_chains.chain_id       AS chain_id,
@@ -146,10 +155,19 @@ func UserChainGetIndirectByChain(db *gorm.DB, chainID uint) ([]sharedtypes.UserC
 		user_chains.is_chain_warden AS is_chain_warden,
 		user_chains.created_at     AS created_at,
 		user_chains.is_paused      AS is_paused,
-		user_chains.is_approved    AS is_approved
+		user_chains.paused_from    AS paused_from,
+		user_chains.paused_until   AS paused_until,
+		user_chains.is_approved    AS is_approved,
+		user_chains.route_order    AS route_order
 	FROM user_chains
 	LEFT JOIN chains ON user_chains.chain_id = chains.id
 	LEFT JOIN users ON user_chains.user_id = users.id
+`
+
+func UserChainGetIndirectByChain(db *gorm.DB, chainID uint) ([]sharedtypes.UserChain, error) {
+	results := []sharedtypes.UserChain{}
+
+	err := db.Raw(userChainIndirectSql+`
 	WHERE users.id IN (
 		SELECT user_chains.user_id
 		FROM user_chains
@@ -164,6 +182,23 @@ func UserChainGetIndirectByChain(db *gorm.DB, chainID uint) ([]sharedtypes.UserC
 	return results, nil
 }
 
+// All loops of the given users, for when only a page of the members of a loop is needed
+func UserChainGetIndirectByUserIDs(db *gorm.DB, userIDs []uint) ([]sharedtypes.UserChain, error) {
+	results := []sharedtypes.UserChain{}
+	if len(userIDs) == 0 {
+		return results, nil
+	}
+
+	err := db.Raw(userChainIndirectSql+`
+	WHERE user_chains.user_id IN ?
+	`, userIDs).Scan(&results).Error
+
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
 func UserChainCheckIfRelationExist(db *gorm.DB, ChainID uint, UserID uint, checkIfIsApproved bool) (userChainID uint, found bool, err error) {
 	var row struct {
 		ID uint `gorm:"id"`
@@ -185,3 +220,131 @@ func UserChainCheckIfRelationExist(db *gorm.DB, ChainID uint, UserID uint, check
 
 	return row.ID, true, nil
 }
+
+type userChainRouteMember struct {
+	UserID   uint   `gorm:"user_id"`
+	UserUID  string `gorm:"user_uid"`
+	IsPaused bool   `gorm:"is_paused"`
+}
+
+// The first approved member after the given user in the route that is not on holiday,
+// returns gorm.ErrRecordNotFound if everyone else is paused.
+func UserChainGetNextHolder(db *gorm.DB, chainID, fromUserID uint) (string, error) {
+	members := []userChainRouteMember{}
+	err := db.Raw(`
+SELECT u.id AS user_id, u.uid AS user_uid, `+UserChainIsPausedSql+` AS is_paused
+FROM user_chains AS uc
+JOIN users AS u ON u.id = uc.user_id
+WHERE uc.chain_id = ? AND uc.is_approved = TRUE AND u.is_email_verified = TRUE
+ORDER BY uc.route_order ASC
+	`, chainID).Scan(&members).Error
+	if err != nil {
+		return "", err
+	}
+
+	// without a position in the route, start at the beginning
+	start := slices.IndexFunc(members, func(m userChainRouteMember) bool { return m.UserID == fromUserID })
+	for i := 1; i <= len(members); i++ {
+		m := members[(start+i)%len(members)]
+		if m.UserID != fromUserID && !m.IsPaused {
+			return m.UserUID, nil
+		}
+	}
+	return "", gorm.ErrRecordNotFound
+}
+
+// Current and upcoming pauses of approved members, pauses without a start date have already started
+func UserChainGetAbsences(db *gorm.DB, chainID uint) ([]sharedtypes.ChainAbsence, error) {
+	absences := []sharedtypes.ChainAbsence{}
+	err := db.Raw(`
+SELECT * FROM (
+	SELECT u.uid AS user_uid, u.name, u.paused_from, u.paused_until, FALSE AS is_chain_only
+	FROM user_chains AS uc
+	JOIN users AS u ON u.id = uc.user_id
+	WHERE uc.chain_id = ? AND uc.is_approved = TRUE AND u.paused_until > NOW()
+	UNION ALL
+	SELECT u.uid AS user_uid, u.name, uc.paused_from, uc.paused_until, TRUE AS is_chain_only
+	FROM user_chains AS uc
+	JOIN users AS u ON u.id = uc.user_id
+	WHERE uc.chain_id = ? AND uc.is_approved = TRUE AND uc.is_paused = TRUE
+		AND COALESCE(uc.paused_until > NOW(), TRUE)
+) AS absences
+ORDER BY paused_from ASC, paused_until ASC
+	`, chainID, chainID).Scan(&absences).Error
+	return absences, err
+}
+
+type UserChainBatchMember struct {
+	UserChainID  uint        `gorm:"user_chain_id"`
+	UserID       uint        `gorm:"user_id"`
+	UserUID      string      `gorm:"user_uid"`
+	Name         string      `gorm:"name"`
+	Email        null.String `gorm:"email"`
+	I18n         string      `gorm:"i18n"`
+	IsChainAdmin bool        `gorm:"is_chain_admin"`
+	IsApproved   bool        `gorm:"is_approved"`
+}
+
+// Members of the loop out of the given user uids, uids that are not part of the loop are left out
+func UserChainGetBatchMembers(db *gorm.DB, chainID uint, userUIDs []string) ([]UserChainBatchMember, error) {
+	members := []UserChainBatchMember{}
+	err := db.Raw(`
+SELECT uc.id AS user_chain_id, u.id AS user_id, u.uid AS user_uid, u.name, u.email, u.i18n, uc.is_chain_admin, uc.is_approved
+FROM user_chains AS uc
+JOIN users AS u ON u.id = uc.user_id
+WHERE uc.chain_id = ? AND u.uid IN ?
+	`, chainID, userUIDs).Scan(&members).Error
+	return members, err
+}
+
+type UserChainExportMember struct {
+	UserID       uint        `gorm:"user_id"`
+	UserUID      string      `gorm:"user_uid"`
+	Name         string      `gorm:"name"`
+	Email        null.String `gorm:"email"`
+	PhoneNumber  string      `gorm:"phone_number"`
+	Address      string      `gorm:"address"`
+	SizesJSON    string      `gorm:"column:sizes"`
+	Sizes        []string    `gorm:"-"`
+	IsChainAdmin bool        `gorm:"is_chain_admin"`
+	IsPaused     bool        `gorm:"is_paused"`
+	Note         null.String `gorm:"note"`
+	// Comma separated numbers of the bags the member is currently holding
+	Bags null.String `gorm:"bags"`
+}
+
+// Approved members of the loop in route order with the bags they are holding
+func UserChainGetExport(db *gorm.DB, chainID uint) ([]UserChainExportMember, error) {
+	members := []UserChainExportMember{}
+	err := db.Raw(`
+SELECT
+	u.id AS user_id,
+	u.uid AS user_uid,
+	u.name,
+	u.email,
+	u.phone_number,
+	u.address,
+	u.sizes,
+	uc.is_chain_admin,
+	`+UserChainIsPausedSql+` AS is_paused,
+	uc.note,
+	(
+		SELECT GROUP_CONCAT(b.number ORDER BY b.number SEPARATOR ', ')
+		FROM bags AS b WHERE b.user_chain_id = uc.id
+	) AS bags
+FROM user_chains AS uc
+JOIN users AS u ON u.id = uc.user_id
+WHERE uc.chain_id = ? AND uc.is_approved = TRUE
+ORDER BY uc.route_order ASC
+	`, chainID).Scan(&members).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range members {
+		if members[i].SizesJSON != "" {
+			json.Unmarshal([]byte(members[i].SizesJSON), &members[i].Sizes)
+		}
+	}
+	return members, nil
+}
@@ -66,3 +66,28 @@ func TestUserDeleteUserChainDependenciesAllChains(t *testing.T) {
 		assert.Empty(t, newBag.ID, "Bag should be deleted")
 	})
 }
+
+func TestUserChainGetNextHolderSkipsPaused(t *testing.T) {
+	chain, user1, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		RouteOrderIndex: 1,
+		IsChainAdmin:    true,
+	})
+	user2, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{
+		RouteOrderIndex:  2,
+		IsPausedLoopOnly: true,
+	})
+	user3, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{
+		RouteOrderIndex: 3,
+	})
+
+	nextUID, err := models.UserChainGetNextHolder(db, chain.ID, user1.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, user3.UID, nextUID, "paused member should be skipped")
+
+	absences, err := models.UserChainGetAbsences(db, chain.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, absences, 1) {
+		assert.Equal(t, user2.UID, absences[0].UserUID)
+		assert.True(t, absences[0].IsChainOnly)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/the-clothing-loop/website/server/internal/models"
 	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
 )
 
 func TestUserNotificationChainUIDs(t *testing.T) {
@@ -133,6 +134,27 @@ func TestUserOmitData(t *testing.T) {
 		}
 	})
 
+	t.Run("Ensure the privacy chosen per field by a neighbour is applied", func(t *testing.T) {
+		chain1, user1, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+			RoutePrivacy:    lo.ToPtr(2),
+			RouteOrderIndex: 1,
+		})
+		user2, _ := mocks.MockUser(t, db, chain1.ID, mocks.MockChainAndUserOptions{
+			RouteOrderIndex: 2,
+		})
+		db.Exec(`UPDATE users SET privacy_phone = ? WHERE id = ?`, sharedtypes.UserFieldPrivacyHosts, user2.ID)
+
+		res, err := models.UserOmitData(db, chain1, []models.User{*user1, *user2}, user1.ID)
+		assert.NoError(t, err)
+		for _, user := range res {
+			if user.ID == user2.ID {
+				assert.Equal(t, "***", user.PhoneNumber)
+				assert.Equal(t, user2.Address, user.Address)
+				assert.Equal(t, *user2.Email, *user.Email)
+			}
+		}
+	})
+
 	t.Run("Ensure nothing is omitted if authUser is paused else where", func(t *testing.T) {
 		chain1, user2, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
 			IsOpenToNewMembers: true,
@@ -9,7 +9,7 @@ type UserOnesignal sharedtypes.UserOnesignal
 
 func UserOnesignalGetAllPlayerIDs(db *gorm.DB, userIDs []uint) ([]string, error) {
 	onesignalIDs := []string{}
-	err := db.Raw(`SELECT onesignal_id FROM user_onesignal WHERE user_id IN ?`, userIDs).Scan(&onesignalIDs).Error
+	err := db.Raw(`SELECT onesignal_id FROM user_onesignals WHERE user_id IN ?`, userIDs).Scan(&onesignalIDs).Error
 	if err != nil {
 		return nil, err
 	}
@@ -19,7 +19,7 @@ func UserOnesignalGetAllPlayerIDs(db *gorm.DB, userIDs []uint) ([]string, error)
 
 func UserOnesignalPut(db *gorm.DB, userID uint, onesignalID, playerID string) error {
 	userOnesignal := &UserOnesignal{}
-	db.Raw(`SELECT * FROM user_onesignal WHERE onesignal_id = ? LIMIT 1`, onesignalID).Scan(userOnesignal)
+	db.Raw(`SELECT * FROM user_onesignals WHERE onesignal_id = ? LIMIT 1`, onesignalID).Scan(userOnesignal)
 
 	userOnesignal.UserID = userID
 	userOnesignal.OnesignalID = onesignalID
@@ -29,5 +29,14 @@ func UserOnesignalPut(db *gorm.DB, userID uint, onesignalID, playerID string) er
 }
 
 func UserOnesignalDelete(db *gorm.DB, playerID string) error {
-	return db.Exec(`DELETE FROM user_onesignal WHERE player_id = ?`, playerID).Error
+	return db.Exec(`DELETE FROM user_onesignals WHERE player_id = ?`, playerID).Error
+}
+
+// Removes the connections of users that OneSignal reported as invalid external user ids
+func UserOnesignalDeleteByUserUIDs(db *gorm.DB, userUIDs []string) error {
+	return db.Exec(`
+DELETE FROM user_onesignals
+WHERE user_id IN (
+	SELECT id FROM users WHERE uid IN ?
+)`, userUIDs).Error
 }
@@ -5,15 +5,54 @@ import (
 
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
 )
 
 func TestHideUserInformation(t *testing.T) {
 	u := User{}
 	assert.Empty(t, u.Address)
-	hideUserInformation(false, &u)
+	hideUserInformation(&u, true, true, true)
 	assert.Equal(t, u.Address, "***")
 	assert.Equal(t, lo.FromPtr(u.Email), "***")
 	assert.Equal(t, u.PhoneNumber, "***")
+
+	u = User{Email: lo.ToPtr("jane@example.com"), PhoneNumber: "0612345678", Address: "Dam 1"}
+	hideUserInformation(&u, false, true, false)
+	assert.Equal(t, "jane@example.com", lo.FromPtr(u.Email))
+	assert.Equal(t, "***", u.PhoneNumber)
+	assert.Equal(t, "Dam 1", u.Address)
+}
+
+func TestUserPrivacyLevel(t *testing.T) {
+	p := &UserPrivacy{
+		authUserID:   1,
+		routePrivacy: 2,
+		members: map[uint]userPrivacyMember{
+			1: {UserID: 1},
+			2: {UserID: 2, PrivacyPhone: sharedtypes.UserFieldPrivacyHosts},
+			3: {UserID: 3, IsChainAdmin: true},
+			4: {UserID: 4, HasBulkyItem: true, PrivacyAddress: sharedtypes.UserFieldPrivacyNeighbours},
+			5: {UserID: 5, PrivacyEmail: sharedtypes.UserFieldPrivacyLoop},
+		},
+		closeBy: []uint{2},
+	}
+
+	assert.True(t, p.CanSee(1, userFieldPhone), "always see yourself")
+	assert.True(t, p.CanSee(2, userFieldEmail), "neighbour within route privacy")
+	assert.False(t, p.CanSee(2, userFieldPhone), "member chose hosts only")
+	assert.True(t, p.CanSee(3, userFieldPhone), "hosts can be contacted")
+	assert.False(t, p.CanSee(3, userFieldAddress), "host address follows the loop")
+	assert.True(t, p.CanSee(4, userFieldPhone), "bulky item owners are visible")
+	assert.False(t, p.CanSee(4, userFieldAddress), "member restricted the address to neighbours")
+	assert.False(t, p.CanSee(5, userFieldEmail), "a member can not share more than the loop allows")
+
+	p.routePrivacy = -1
+	assert.True(t, p.CanSee(5, userFieldAddress))
+	assert.False(t, p.CanSee(2, userFieldPhone), "member preference applies when the loop shows everything")
+
+	p.routePrivacy = 0
+	assert.False(t, p.CanSee(2, userFieldEmail))
+	assert.True(t, p.CanSee(3, userFieldEmail))
 }
 
 func TestChatEmailToChatUserName(t *testing.T) {
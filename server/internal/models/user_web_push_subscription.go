@@ -0,0 +1,40 @@
+package models
+
+import (
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+func UserWebPushSubscriptionGetAllByUserUIDs(db *gorm.DB, userUIDs []string) ([]sharedtypes.UserWebPushSubscription, error) {
+	list := []sharedtypes.UserWebPushSubscription{}
+	err := db.Raw(`
+SELECT s.* FROM user_web_push_subscriptions AS s
+JOIN users AS u ON u.id = s.user_id
+WHERE u.uid IN ?
+	`, userUIDs).Scan(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// A browser endpoint belongs to one user at a time, the last user to subscribe takes it over
+func UserWebPushSubscriptionPut(db *gorm.DB, userID uint, endpoint, p256dh, auth string) error {
+	s := &sharedtypes.UserWebPushSubscription{}
+	db.Raw(`SELECT * FROM user_web_push_subscriptions WHERE endpoint = ? LIMIT 1`, endpoint).Scan(s)
+
+	s.UserID = userID
+	s.Endpoint = endpoint
+	s.P256dh = p256dh
+	s.Auth = auth
+
+	return db.Save(s).Error
+}
+
+func UserWebPushSubscriptionDelete(db *gorm.DB, endpoint string) error {
+	return db.Exec(`DELETE FROM user_web_push_subscriptions WHERE endpoint = ?`, endpoint).Error
+}
+
+func UserWebPushSubscriptionDeleteByUser(db *gorm.DB, userID uint, endpoint string) error {
+	return db.Exec(`DELETE FROM user_web_push_subscriptions WHERE user_id = ? AND endpoint = ?`, userID, endpoint).Error
+}
@@ -8,7 +8,10 @@ import (
 	cron "github.com/go-co-op/gocron"
 	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/views"
 	"github.com/the-clothing-loop/website/server/pkg/throttle"
+	"gorm.io/gorm"
 )
 
 var Scheduler *cron.Scheduler
@@ -18,14 +21,15 @@ func Routes() *gin.Engine {
 	// initialization
 	db := app.DatabaseInit()
 	app.MailInit()
+	app.MailOnLastRetry = func(db *gorm.DB, m *models.Mail) {
+		views.EmailRootAdminFailedLastRetry(db, m.ToAddress, m.Subject)
+	}
 
 	if app.Config.ENV == app.EnvEnumProduction || (app.Config.SENDINBLUE_API_KEY != "" && app.Config.ENV == app.EnvEnumDevelopment) {
 		app.BrevoInit()
 	}
 
-	if app.Config.ONESIGNAL_APP_ID != "" && app.Config.ONESIGNAL_REST_API_KEY != "" {
-		app.OneSignalInit()
-	}
+	app.PushInit()
 
 	// set gin mode
 	if app.Config.ENV == app.EnvEnumProduction || app.Config.ENV == app.EnvEnumAcceptance {
@@ -37,6 +41,8 @@ func Routes() *gin.Engine {
 	}
 
 	if app.Config.ENV != app.EnvEnumTesting {
+		go app.MailOutboxWorkerRun(db)
+
 		Scheduler = cron.NewScheduler(time.UTC)
 
 		// At 08:03 on day-of-month 1.
@@ -51,6 +57,10 @@ func Routes() *gin.Engine {
 		// https://crontab.guru/#8_8_*_*_*
 		Scheduler.Cron("8 8 * * *").Do(controllers.CronDaily, db)
 
+		// At 08:13 on Monday.
+		// https://crontab.guru/#13_8_*_*_1
+		Scheduler.Cron("13 8 * * 1").Do(controllers.CronWeekly, db)
+
 		Scheduler.StartAsync()
 
 		// testing
@@ -92,6 +102,11 @@ func Routes() *gin.Engine {
 		},
 	})
 
+	// spam protection of the public forms, each form counts separately
+	thrContactIP, thrContactEmail := throttleForm("contact")
+	thrNewsletterIP, thrNewsletterEmail := throttleForm("newsletter")
+	thrLocationAlertIP, thrLocationAlertEmail := throttleForm("location_alert")
+
 	// router groups
 	v2 := r.Group("/v2")
 
@@ -126,6 +141,16 @@ func Routes() *gin.Engine {
 	v2.DELETE("/user/purge", controllers.UserPurge)
 	v2.POST("/user/transfer-chain", controllers.UserTransferChain)
 	v2.GET("/user/check-email", controllers.UserCheckIfEmailExists)
+	v2.GET("/user/calendar-token", controllers.UserCalendarTokenGet)
+	v2.POST("/user/calendar-token", controllers.UserCalendarTokenReset)
+	v2.DELETE("/user/calendar-token", controllers.UserCalendarTokenDelete)
+	v2.GET("/user/notification-preferences", controllers.NotificationPreferencesGet)
+	v2.PATCH("/user/notification-preferences", controllers.NotificationPreferencesUpdate)
+	v2.PUT("/user/web-push-subscription", controllers.UserWebPushSubscriptionPut)
+	v2.DELETE("/user/web-push-subscription", controllers.UserWebPushSubscriptionDelete)
+	v2.GET("/web-push/public-key", controllers.WebPushPublicKeyGet)
+	v2.GET("/notification/unsubscribe", controllers.NotificationUnsubscribe)
+	v2.POST("/notification/unsubscribe", controllers.NotificationUnsubscribe)
 
 	// chain
 	v2.GET("/chain", controllers.ChainGet)
@@ -136,12 +161,28 @@ func Routes() *gin.Engine {
 	v2.POST("/chain/add-user", controllers.ChainAddUser)
 	v2.POST("/chain/remove-user", controllers.ChainRemoveUser)
 	v2.PATCH("/chain/approve-user", controllers.ChainApproveUser)
+	v2.POST("/chain/batch-users", controllers.ChainBatchUsers)
+	v2.POST("/chain/import-users", controllers.ChainImportUsers)
+	v2.GET("/chain/export-users", controllers.ChainExportUsers)
+	v2.POST("/chain/merge", controllers.ChainMerge)
+	v2.POST("/chain/split", controllers.ChainSplit)
+	v2.GET("/chain/restructures", controllers.ChainRestructureGetAll)
+	v2.POST("/chain/invite", controllers.ChainInviteCreate)
+	v2.GET("/chain/invites", controllers.ChainInviteGetAll)
+	v2.DELETE("/chain/invite", controllers.ChainInviteRevoke)
+	v2.GET("/chain/invite/qr", controllers.ChainInviteQRCode)
+	v2.POST("/chain/invite/join", controllers.ChainInviteJoin)
 	v2.DELETE("/chain/unapproved-user", controllers.ChainDeleteUnapproved)
 	v2.POST("/chain/poke", controllers.Poke)
 	v2.GET("/chain/near", controllers.ChainGetNear)
+	v2.GET("/chain/search", controllers.ChainSearch)
+	v2.GET("/chain/map", controllers.ChainGetMap)
 	v2.PATCH("/chain/user/note", controllers.ChainChangeUserNote)
 	v2.GET("/chain/user/note", controllers.ChainGetUserNote)
 	v2.PATCH("/chain/user/warden", controllers.ChainChangeUserWarden)
+	v2.POST("/chain/announce", controllers.ChainAnnounce)
+	v2.GET("/chain/announcements", controllers.ChainAnnouncementGetAll)
+	v2.GET("/chain/absences", controllers.ChainGetAbsences)
 
 	// chat type
 	v2.GET("/chat/type", controllers.ChatGetType)
@@ -156,6 +197,12 @@ func Routes() *gin.Engine {
 	v2.POST("/chat/channel/message/create", controllers.ChatChannelMessageCreate)
 	v2.POST("/chat/channel/message/pin-toggle", controllers.ChatChannelMessagePinToggle)
 	v2.DELETE("/chat/channel/message/delete", controllers.ChatChannelMessageDelete)
+	v2.POST("/chat/channel/message/report", controllers.ChatChannelMessageReport)
+
+	// chat moderation
+	v2.GET("/chat/moderation/reports", controllers.ChatModerationReportList)
+	v2.GET("/chat/moderation/actions", controllers.ChatModerationActionList)
+	v2.POST("/chat/moderation/action", controllers.ChatModerationActionCreate)
 
 	// bag
 	v2.GET("/bag/all", controllers.BagGetAll)
@@ -178,10 +225,21 @@ func Routes() *gin.Engine {
 	v2.POST("/route/order", controllers.RouteOrderSet)
 	v2.GET("/route/optimize", controllers.RouteOptimize)
 	v2.GET("/route/coordinates", controllers.GetRouteCoordinates)
+	v2.GET("/route/next-holder", controllers.RouteNextHolderGet)
 
 	// contact
-	v2.POST("/contact/newsletter", controllers.ContactNewsletter)
-	v2.POST("/contact/email", controllers.ContactMail)
+	v2.POST("/contact/newsletter", thrNewsletterIP, thrNewsletterEmail, controllers.ContactNewsletter)
+	v2.GET("/contact/newsletter/confirm", controllers.ContactNewsletterConfirm)
+	v2.GET("/contact/newsletter/unsubscribe", controllers.ContactNewsletterUnsubscribe)
+	v2.POST("/contact/newsletter/unsubscribe", controllers.ContactNewsletterUnsubscribe)
+	v2.POST("/location-alert", thrLocationAlertIP, thrLocationAlertEmail, controllers.LocationAlertSubscribe)
+	v2.GET("/location-alert/confirm", controllers.LocationAlertConfirm)
+	v2.GET("/location-alert/unsubscribe", controllers.LocationAlertUnsubscribe)
+	v2.POST("/location-alert/unsubscribe", controllers.LocationAlertUnsubscribe)
+	v2.POST("/contact/email", thrContactIP, thrContactEmail, controllers.ContactMail)
+
+	// brevo
+	v2.POST("/brevo/webhook", controllers.BrevoWebhook)
 
 	// event
 	v2.GET("/event/:uid/ical", controllers.EventICal)
@@ -191,6 +249,46 @@ func Routes() *gin.Engine {
 	v2.POST("/event", controllers.EventCreate)
 	v2.PATCH("/event", controllers.EventUpdate)
 	v2.DELETE("/event/:uid", controllers.EventDelete)
+	v2.PATCH("/event/occurrence", controllers.EventOccurrenceUpdate)
+	v2.DELETE("/event/occurrence", controllers.EventOccurrenceCancel)
+	v2.GET("/event/rsvp", controllers.EventRsvpGet)
+	v2.PUT("/event/rsvp", controllers.EventRsvpPut)
+	v2.DELETE("/event/rsvp", controllers.EventRsvpDelete)
+	v2.GET("/event/attendees", controllers.EventAttendeesGet)
+
+	// ical feeds
+	v2.GET("/ical/chain/:uid", controllers.ICalFeedChain)
+	v2.GET("/ical/area", controllers.ICalFeedArea)
+	v2.GET("/ical/user/:token", controllers.ICalFeedUser)
+
+	// mail outbox
+	v2.GET("/admin/mails", controllers.MailOutboxGetAll)
+	v2.GET("/admin/mail", controllers.MailOutboxGet)
+	v2.POST("/admin/mail/resend", controllers.MailOutboxResend)
+	v2.GET("/admin/email-preview", controllers.EmailPreviewGet)
+	v2.POST("/admin/email-preview/send", controllers.EmailPreviewSend)
+	v2.GET("/admin/contact-tickets", controllers.ContactTicketGetAll)
+	v2.GET("/admin/contact-ticket", controllers.ContactTicketGet)
+	v2.PATCH("/admin/contact-ticket", controllers.ContactTicketUpdate)
+	v2.POST("/admin/contact-ticket/reply", controllers.ContactTicketReply)
 
 	return r
 }
+
+// Limits a public form per ip address and per sender address
+func throttleForm(form string) (ip, email gin.HandlerFunc) {
+	ip = throttle.Policy(&throttle.Quota{
+		Limit:  10,
+		Within: time.Hour,
+	}, &throttle.Options{
+		KeyPrefix: form + "_ip",
+	})
+	email = throttle.Policy(&throttle.Quota{
+		Limit:  3,
+		Within: time.Hour,
+	}, &throttle.Options{
+		KeyPrefix:              form + "_email",
+		IdentificationFunction: controllers.ContactMailIdentifyEmail,
+	})
+	return ip, email
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/the-clothing-loop/website/server/internal/models"
 	"github.com/the-clothing-loop/website/server/internal/views"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
 	"gorm.io/gorm"
 )
 
@@ -34,16 +35,18 @@ func EmailLoopAdminsOnUserJoin(db *gorm.DB, user *models.User, chainIDs ...uint)
 		if !result.Email.Valid {
 			continue
 		}
-		views.EmailSomeoneIsInterestedInJoiningYourLoop(db, result.I18n,
-			result.Email.String,
-			result.Name,
-			result.ChainName,
-			user.Name,
-			*user.Email,
-			user.PhoneNumber,
-			user.Address,
-			user.Sizes,
-		)
+		NotifyEmail(db, sharedtypes.NotificationTypeHostUpdates, result.Email.String, func() error {
+			return views.EmailSomeoneIsInterestedInJoiningYourLoop(db, result.I18n,
+				result.Email.String,
+				result.Name,
+				result.ChainName,
+				user.Name,
+				*user.Email,
+				user.PhoneNumber,
+				user.Address,
+				user.Sizes,
+			)
+		})
 	}
 
 	return nil
@@ -67,13 +70,44 @@ func EmailLoopAdminsOnUserLeft(db *gorm.DB, removedUserName, removedUserEmail, e
 		if !email.Valid || excludedEmail == email.String {
 			continue
 		}
-		views.EmailSomeoneLeftLoop(db, admin.I18n,
-			admin.Name,
-			admin.Email.String,
-			admin.ChainName,
-			removedUserName,
-			removedUserEmail,
-		)
+		NotifyEmail(db, sharedtypes.NotificationTypeHostUpdates, email.String, func() error {
+			return views.EmailSomeoneLeftLoop(db, admin.I18n,
+				admin.Name,
+				admin.Email.String,
+				admin.ChainName,
+				removedUserName,
+				removedUserEmail,
+			)
+		})
+	}
+
+	return nil
+}
+
+// Sends each host a single email listing all participants removed at once
+func EmailLoopAdminsOnUsersLeft(db *gorm.DB, removed []views.EmailParticipant, excludedEmail string, chainID uint) error {
+	if len(removed) == 0 {
+		return nil
+	}
+
+	admins, err := models.UserGetAdminsByChain(db, chainID)
+	if err != nil {
+		return err
+	}
+
+	for _, admin := range admins {
+		email := admin.Email
+		if !email.Valid || excludedEmail == email.String {
+			continue
+		}
+		NotifyEmail(db, sharedtypes.NotificationTypeHostUpdates, email.String, func() error {
+			return views.EmailMembersLeftLoop(db, admin.I18n,
+				admin.Name,
+				admin.Email.String,
+				admin.ChainName,
+				removed,
+			)
+		})
 	}
 
 	return nil
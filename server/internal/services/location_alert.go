@@ -0,0 +1,38 @@
+package services
+
+import (
+	"log/slog"
+
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	"gorm.io/gorm"
+)
+
+// Emails everyone with a confirmed location alert near the loop, once per loop.
+// Returns the number of people that were emailed.
+func LocationAlertNotify(db *gorm.DB, chain *models.Chain) (int, error) {
+	if !chain.Published || !chain.OpenToNewMembers {
+		return 0, nil
+	}
+
+	matches, err := models.LocationAlertGetMatchesForChain(db, chain)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, match := range matches {
+		err := views.EmailLoopOpenedNearYou(db, match.I18n, match.Name, match.Email, chain.Name, chain.UID, match.Distance)
+		if err != nil {
+			slog.Error("Unable to send location alert", "chainID", chain.ID, "locationAlertID", match.ID, "err", err)
+			continue
+		}
+		err = models.LocationAlertSetNotified(db, match.ID, chain.ID)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
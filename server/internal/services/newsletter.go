@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"gorm.io/gorm"
+)
+
+// Makes sure an active subscription is in the Brevo newsletter list,
+// if the contact unsubscribed in Brevo the local subscription is removed instead.
+// Subscriptions confirmed since they were last synced are added with NewsletterSubscribeBrevo.
+// Returns true if the subscription was removed.
+func NewsletterSyncBrevo(ctx context.Context, db *gorm.DB, n *models.Newsletter) (bool, error) {
+	if app.Brevo == nil {
+		return false, nil
+	}
+	if n.ConfirmedAt != nil && (n.BrevoSyncedAt == nil || n.ConfirmedAt.After(*n.BrevoSyncedAt)) {
+		return false, NewsletterSubscribeBrevo(ctx, db, n)
+	}
+
+	status, err := app.Brevo.GetContactStatus(ctx, n.Email)
+	if err != nil {
+		return false, err
+	}
+	if status.Unsubscribed {
+		return true, models.NewsletterDeleteByEmail(db, n.Email)
+	}
+	if !status.Exists || !status.InList {
+		err = app.Brevo.CreateContact(ctx, n.Email)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return false, models.NewsletterSetBrevoSynced(db, n.ID)
+}
+
+// Adds a confirmed subscription to the Brevo newsletter list. Confirming is explicit consent,
+// so an earlier unsubscribe or block in Brevo is lifted instead of removing the subscription.
+func NewsletterSubscribeBrevo(ctx context.Context, db *gorm.DB, n *models.Newsletter) error {
+	if app.Brevo == nil {
+		return nil
+	}
+
+	status, err := app.Brevo.GetContactStatus(ctx, n.Email)
+	if err != nil {
+		return err
+	}
+	switch {
+	case status.Unsubscribed:
+		err = app.Brevo.ResubscribeContact(ctx, n.Email)
+	case !status.Exists || !status.InList:
+		err = app.Brevo.CreateContact(ctx, n.Email)
+	}
+	if err != nil {
+		return err
+	}
+
+	return models.NewsletterSetBrevoSynced(db, n.ID)
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"log/slog"
+
+	"github.com/samber/lo"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gorm.io/gorm"
+)
+
+// Sends a push notification to the users that have not turned off this notification type
+func NotifyPush(db *gorm.DB, notificationType string, userUIDs []string, notification, content string) error {
+	userUIDs, err := models.NotificationPreferenceFilterUserUIDs(db, notificationType, sharedtypes.NotificationChannelPush, userUIDs)
+	if err != nil {
+		slog.Error("Unable to filter notification preferences", "err", err, "type", notificationType)
+		return err
+	}
+
+	return NotifyPushRequired(db, userUIDs, notification, content)
+}
+
+// Sends a push notification that can not be turned off, in the language of each user
+func NotifyPushRequired(db *gorm.DB, userUIDs []string, notification, content string) error {
+	if len(userUIDs) == 0 {
+		return nil
+	}
+	userI18ns, err := models.UserGetI18nByUIDs(db, userUIDs)
+	if err != nil {
+		slog.Error("Unable to find the language of users", "err", err)
+		return err
+	}
+
+	groups := lo.GroupBy(userUIDs, func(uid string) string {
+		return views.NotificationLang(userI18ns[uid])
+	})
+	titleEn, bodyEn := views.NotificationText("en", notification, content)
+	for lng, uids := range groups {
+		title, body := views.NotificationText(lng, notification, content)
+		err := app.PushSend(db, uids, app.PushMessage{
+			Notification: notification,
+			Title:        title,
+			Body:         body,
+			Lang:         lng,
+			TitleEn:      titleEn,
+			BodyEn:       bodyEn,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Calls send only if the owner of the email address has not turned off this notification type
+func NotifyEmail(db *gorm.DB, notificationType, email string, send func() error) error {
+	ok, err := models.NotificationPreferenceIsEnabledByEmail(db, notificationType, sharedtypes.NotificationChannelEmail, email)
+	if err != nil {
+		slog.Error("Unable to check notification preferences", "err", err, "type", notificationType)
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	return send()
+}
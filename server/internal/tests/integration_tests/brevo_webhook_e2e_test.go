@@ -0,0 +1,99 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestBrevoWebhook(t *testing.T) {
+	secret := "brevo-test-secret"
+	app.Config.BREVO_WEBHOOK = secret
+	t.Cleanup(func() { app.Config.BREVO_WEBHOOK = "" })
+
+	_, user, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	addNewsletter := func() {
+		t.Helper()
+		n := &models.Newsletter{Email: *user.Email, Name: user.Name, Verified: true}
+		assert.NoError(t, n.CreateOrUpdate(db))
+	}
+	countNewsletter := func() (count int) {
+		db.Raw(`SELECT COUNT(*) FROM newsletters WHERE email = ?`, *user.Email).Scan(&count)
+		return count
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM newsletters WHERE email = ?`, *user.Email)
+	})
+	post := func(secret string, body gin.H) int {
+		t.Helper()
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/brevo/webhook", &body, secret)
+		controllers.BrevoWebhook(c)
+		return resultFunc().Response.StatusCode
+	}
+
+	t.Run("invalid secret", func(t *testing.T) {
+		status := post("wrong", gin.H{"event": "hard_bounce", "email": *user.Email})
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("newsletter unsubscribe", func(t *testing.T) {
+		addNewsletter()
+		status := post(secret, gin.H{"event": "unsubscribe", "email": *user.Email})
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 0, countNewsletter())
+
+		isUndeliverable, err := models.UserIsEmailUndeliverable(db, *user.Email)
+		assert.NoError(t, err)
+		assert.False(t, isUndeliverable)
+	})
+
+	t.Run("hard bounce", func(t *testing.T) {
+		addNewsletter()
+		mail := mocks.MockMail(t, db, mocks.MockMailOptions{Status: models.MailStatusSent})
+		messageID := fmt.Sprintf("<%d@smtp-relay.example.com>", mail.ID)
+		db.Exec(`UPDATE mail_outbox SET provider_message_id = ? WHERE id = ?`, messageID, mail.ID)
+
+		status := post(secret, gin.H{
+			"event":      "hard_bounce",
+			"email":      *user.Email,
+			"message-id": messageID,
+			"reason":     "mailbox does not exist",
+		})
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 0, countNewsletter())
+
+		found, err := models.UserGetByUID(db, user.UID, false)
+		assert.NoError(t, err)
+		if assert.NotNil(t, found.EmailUndeliverableAt) {
+			assert.Equal(t, sharedtypes.EmailUndeliverableReasonBounce, found.EmailUndeliverableReason)
+		}
+
+		foundMail, err := models.MailGet(db, mail.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.MailStatusBounced, foundMail.Status)
+
+		// no longer queued
+		m := app.MailCreate()
+		m.ToAddress = *user.Email
+		m.Subject = "Undeliverable " + user.UID
+		assert.NoError(t, app.MailSend(db, m))
+		count := 0
+		db.Raw(`SELECT COUNT(*) FROM mail_outbox WHERE subject = ?`, m.Subject).Scan(&count)
+		assert.Equal(t, 0, count)
+
+		assert.NoError(t, models.UserClearEmailUndeliverable(db, user.ID))
+		isUndeliverable, err := models.UserIsEmailUndeliverable(db, *user.Email)
+		assert.NoError(t, err)
+		assert.False(t, isUndeliverable)
+	})
+}
@@ -0,0 +1,126 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestChainAnnounce(t *testing.T) {
+	chain, _, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	member, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	memberUnsubscribed, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	memberBounced, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	memberPending, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{IsNotApproved: true})
+	_, memberToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+
+	err := models.NotificationPreferenceSet(db, memberUnsubscribed.ID, sharedtypes.NotificationTypeAnnouncement, sharedtypes.NotificationChannelEmail, false)
+	app.AssertNotErrorNow(t, err)
+	_, err = models.UserSetEmailUndeliverable(db, *memberBounced.Email, sharedtypes.EmailUndeliverableReasonBounce)
+	app.AssertNotErrorNow(t, err)
+	db.Exec(`UPDATE user_chains SET is_chain_warden = TRUE WHERE user_id = ? AND chain_id = ?`, member.ID, chain.ID)
+
+	announce := func(token string, body gin.H) (int, string) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/chain/announce", &body, token)
+		controllers.ChainAnnounce(c)
+		result := resultFunc()
+		return result.Response.StatusCode, result.Body
+	}
+
+	t.Run("members can not announce", func(t *testing.T) {
+		status, body := announce(memberToken, gin.H{"chain_uid": chain.UID, "subject": "Hello", "body": "World"})
+		assert.Equal(t, http.StatusUnauthorized, status, body)
+	})
+
+	t.Run("all approved members", func(t *testing.T) {
+		app.PushTestRecorder.Reset()
+		status, body := announce(hostToken, gin.H{"chain_uid": chain.UID, "subject": "Swap this sunday", "body": "Bring your bags\nSee you there"})
+		assert.Equal(t, http.StatusOK, status, body)
+
+		res := sharedtypes.ChainAnnounceResponse{}
+		json.Unmarshal([]byte(body), &res)
+		assert.Equal(t, 4, res.Announcement.RecipientCount)
+		assert.Equal(t, 2, res.Announcement.EmailedCount)
+		assert.Equal(t, []string{memberBounced.UID}, lo.Map(res.Undeliverable, func(r sharedtypes.ChainAnnounceRecipient, _ int) string { return r.UserUID }))
+		assert.Equal(t, []string{memberUnsubscribed.UID}, lo.Map(res.Unsubscribed, func(r sharedtypes.ChainAnnounceRecipient, _ int) string { return r.UserUID }))
+
+		messages := app.PushTestRecorder.FindByUserUID(member.UID)
+		if assert.Len(t, messages, 1) {
+			assert.Equal(t, views.NotificationEnumAnnouncement, messages[0].Notification)
+		}
+		assert.Empty(t, app.PushTestRecorder.FindByUserUID(memberPending.UID))
+	})
+
+	t.Run("wardens only", func(t *testing.T) {
+		status, body := announce(hostToken, gin.H{"chain_uid": chain.UID, "subject": "Wardens", "body": "Meeting", "wardens_only": true})
+		assert.Equal(t, http.StatusOK, status, body)
+
+		res := sharedtypes.ChainAnnounceResponse{}
+		json.Unmarshal([]byte(body), &res)
+		assert.Equal(t, 1, res.Announcement.RecipientCount)
+	})
+
+	t.Run("history", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/chain/announcements?chain_uid="+chain.UID, nil, hostToken)
+		controllers.ChainAnnouncementGetAll(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		list := []sharedtypes.ChainAnnouncement{}
+		json.Unmarshal([]byte(result.Body), &list)
+		if assert.Len(t, list, 2) {
+			assert.Equal(t, "Wardens", list[0].Subject)
+			assert.True(t, list[0].WardensOnly)
+		}
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		status, body := announce(hostToken, gin.H{"chain_uid": chain.UID, "subject": "Third", "body": "Third"})
+		assert.Equal(t, http.StatusOK, status, body)
+
+		status, body = announce(hostToken, gin.H{"chain_uid": chain.UID, "subject": "Fourth", "body": "Fourth"})
+		assert.Equal(t, http.StatusTooManyRequests, status, body)
+	})
+}
+
+func TestChainAnnouncementCreateConcurrent(t *testing.T) {
+	chain, host, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+
+	results := make(chan error, models.ChainAnnouncementLimit+2)
+	for i := 0; i < models.ChainAnnouncementLimit+2; i++ {
+		go func() {
+			results <- models.ChainAnnouncementCreate(db, &sharedtypes.ChainAnnouncement{
+				UID:          uuid.NewV4().String(),
+				ChainID:      chain.ID,
+				AuthorUserID: host.ID,
+				Subject:      "Fake subject",
+				Body:         "Fake body",
+				FilterSizes:  []string{},
+			})
+		}()
+	}
+
+	created := 0
+	for i := 0; i < models.ChainAnnouncementLimit+2; i++ {
+		err := <-results
+		if err == nil {
+			created++
+		} else {
+			assert.ErrorIs(t, err, models.ErrChainAnnouncementLimit)
+		}
+	}
+	assert.Equal(t, models.ChainAnnouncementLimit, created)
+}
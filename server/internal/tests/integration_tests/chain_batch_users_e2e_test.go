@@ -0,0 +1,123 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestChainBatchUsers(t *testing.T) {
+	chain, host, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	pending1, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{IsNotApproved: true})
+	pending2, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{IsNotApproved: true})
+	member, memberToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	_, otherChainUser, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+
+	batch := func(token string, body gin.H) (int, []sharedtypes.ChainBatchUsersResult) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/chain/batch-users", &body, token)
+		controllers.ChainBatchUsers(c)
+		result := resultFunc()
+		results := []sharedtypes.ChainBatchUsersResult{}
+		json.Unmarshal([]byte(result.Body), &results)
+		return result.Response.StatusCode, results
+	}
+
+	t.Run("Participants are not allowed", func(t *testing.T) {
+		status, _ := batch(memberToken, gin.H{
+			"chain_uid": chain.UID,
+			"user_uids": []string{pending1.UID},
+			"action":    sharedtypes.ChainBatchUsersActionApprove,
+		})
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("Approve with a result per user", func(t *testing.T) {
+		status, results := batch(hostToken, gin.H{
+			"chain_uid": chain.UID,
+			"user_uids": []string{pending1.UID, pending2.UID, member.UID, otherChainUser.UID},
+			"action":    sharedtypes.ChainBatchUsersActionApprove,
+		})
+		assert.Equal(t, http.StatusOK, status)
+		if assert.Len(t, results, 4) {
+			assert.True(t, results[0].OK)
+			assert.True(t, results[1].OK)
+			assert.False(t, results[2].OK, "member is already approved")
+			assert.False(t, results[3].OK, "user is not part of the loop")
+		}
+
+		amountApproved := 0
+		db.Raw(`SELECT COUNT(*) FROM user_chains WHERE chain_id = ? AND user_id IN ? AND is_approved = TRUE`, chain.ID, []uint{pending1.ID, pending2.ID}).Scan(&amountApproved)
+		assert.Equal(t, 2, amountApproved)
+	})
+
+	t.Run("Last host is kept", func(t *testing.T) {
+		status, results := batch(hostToken, gin.H{
+			"chain_uid": chain.UID,
+			"user_uids": []string{host.UID, pending2.UID},
+			"action":    sharedtypes.ChainBatchUsersActionRemove,
+		})
+		assert.Equal(t, http.StatusOK, status)
+		if assert.Len(t, results, 2) {
+			assert.False(t, results[0].OK)
+			assert.True(t, results[1].OK)
+		}
+
+		amount := -1
+		db.Raw(`SELECT COUNT(*) FROM user_chains WHERE chain_id = ? AND user_id IN ?`, chain.ID, []uint{host.ID, pending2.ID}).Scan(&amount)
+		assert.Equal(t, 1, amount)
+	})
+
+	t.Run("Move requires a destination", func(t *testing.T) {
+		status, _ := batch(hostToken, gin.H{
+			"chain_uid": chain.UID,
+			"user_uids": []string{pending1.UID},
+			"action":    sharedtypes.ChainBatchUsersActionMove,
+		})
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}
+
+func TestChainBatchUsersRemoveEmails(t *testing.T) {
+	chain, host, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	otherHost, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	member1, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	member2, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	pending, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{IsNotApproved: true})
+
+	emails := []string{*host.Email, *otherHost.Email, *member1.Email, *member2.Email, *pending.Email}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM mail_outbox WHERE to_address IN ?`, emails)
+	})
+	countMails := func(email string) (count int) {
+		db.Raw(`SELECT COUNT(*) FROM mail_outbox WHERE to_address = ?`, email).Scan(&count)
+		return count
+	}
+
+	c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/chain/batch-users", &gin.H{
+		"chain_uid": chain.UID,
+		"user_uids": []string{member1.UID, member2.UID, pending.UID},
+		"action":    sharedtypes.ChainBatchUsersActionRemove,
+		"reason":    "too_far_away",
+	}, hostToken)
+	controllers.ChainBatchUsers(c)
+	result := resultFunc()
+	assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+	assert.Equal(t, 0, countMails(*host.Email), "the acting host already knows")
+	assert.Equal(t, 1, countMails(*otherHost.Email), "one summary for all removed participants")
+	assert.Equal(t, 1, countMails(*pending.Email), "pending members are denied")
+
+	body := ""
+	db.Raw(`SELECT body FROM mail_outbox WHERE to_address = ? ORDER BY id DESC LIMIT 1`, *otherHost.Email).Scan(&body)
+	assert.Contains(t, body, *member1.Email)
+	assert.Contains(t, body, *member2.Email)
+	assert.NotContains(t, body, *pending.Email)
+}
@@ -7,8 +7,11 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
 	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
 )
 
 func TestChainDeleteWithTwoHosts(t *testing.T) {
@@ -57,6 +60,13 @@ func TestChainDelete(t *testing.T) {
 		IsChainAdmin: false,
 	})
 
+	invite := &sharedtypes.ChainInvite{ChainID: chain.ID, CreatedByUserID: host.ID}
+	err := models.ChainInviteCreate(db, invite)
+	app.AssertNotErrorNow(t, err)
+	db.Create(&sharedtypes.ChainInviteUse{ChainInviteID: invite.ID, UserID: participant.ID})
+	err = models.ChainFacetsSync(db, chain.ID, []string{"1"}, []string{"1"})
+	app.AssertNotErrorNow(t, err)
+
 	// create gin.Context mock
 	url := "/v2/chain?chain_uid=" + chain.UID
 	c, resultFunc := mocks.MockGinContext(db, http.MethodPost, url, nil, token)
@@ -77,4 +87,16 @@ func TestChainDelete(t *testing.T) {
 	count = -1
 	db.Raw(`SELECT COUNT(id) FROM chains WHERE id = ?`, chain.ID).Scan(&count)
 	assert.Equal(t, 0, count)
+
+	count = -1
+	db.Raw(`SELECT COUNT(id) FROM chain_invites WHERE chain_id = ?`, chain.ID).Scan(&count)
+	assert.Equal(t, 0, count)
+
+	count = -1
+	db.Raw(`SELECT COUNT(id) FROM chain_invite_uses WHERE chain_invite_id = ?`, invite.ID).Scan(&count)
+	assert.Equal(t, 0, count)
+
+	count = -1
+	db.Raw(`SELECT COUNT(id) FROM chain_facets WHERE chain_id = ?`, chain.ID).Scan(&count)
+	assert.Equal(t, 0, count)
 }
@@ -0,0 +1,68 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/pkg/xlsx"
+)
+
+func TestChainExportUsers(t *testing.T) {
+	chain, host, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true, RouteOrderIndex: 1})
+	member, memberToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{RouteOrderIndex: 2})
+	mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{IsNotApproved: true})
+	bag := mocks.MockBag(t, db, chain.ID, member.ID, mocks.MockBagOptions{})
+
+	export := func(token, format string) (int, string, []byte) {
+		url := fmt.Sprintf("/v2/chain/export-users?chain_uid=%s&format=%s", chain.UID, format)
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, token)
+		controllers.ChainExportUsers(c)
+		result := resultFunc()
+		return result.Response.StatusCode, result.Response.Header.Get("Content-Type"), []byte(result.Body)
+	}
+
+	t.Run("Participants are not allowed", func(t *testing.T) {
+		status, _, _ := export(memberToken, "csv")
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("csv in route order", func(t *testing.T) {
+		status, _, body := export(hostToken, "csv")
+		assert.Equal(t, http.StatusOK, status)
+		rows, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))).ReadAll()
+		assert.NoError(t, err)
+		if assert.Len(t, rows, 3, "pending members are left out") {
+			assert.Equal(t, host.Name, rows[1][1])
+			assert.Equal(t, member.Name, rows[2][1])
+			assert.Equal(t, bag.Number, rows[2][8])
+		}
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		status, _, body := export(hostToken, "xlsx")
+		assert.Equal(t, http.StatusOK, status)
+		rows, err := xlsx.Read(body)
+		assert.NoError(t, err)
+		assert.Len(t, rows, 3)
+	})
+
+	t.Run("vcard and pdf", func(t *testing.T) {
+		status, contentType, body := export(hostToken, "vcard")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, contentType, "text/vcard")
+		assert.Equal(t, 2, bytes.Count(body, []byte("BEGIN:VCARD")))
+
+		status, contentType, body = export(hostToken, "pdf")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "application/pdf", contentType)
+		assert.True(t, bytes.HasPrefix(body, []byte("%PDF-")))
+	})
+}
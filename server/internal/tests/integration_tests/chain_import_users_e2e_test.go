@@ -0,0 +1,73 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestChainImportUsers(t *testing.T) {
+	chain, _, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	member, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	_, otherUser, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	newEmail := fmt.Sprintf("%s@example.com", faker.UUID().V4())
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM user_chains WHERE user_id IN (SELECT id FROM users WHERE email = ?)`, newEmail)
+		db.Exec(`DELETE FROM user_tokens WHERE user_id IN (SELECT id FROM users WHERE email = ?)`, newEmail)
+		db.Exec(`DELETE FROM users WHERE email = ?`, newEmail)
+		db.Exec(`DELETE FROM chain_invites WHERE chain_id = ?`, chain.ID)
+	})
+
+	file := fmt.Sprintf("name,email,sizes\nNew member,%s,1\nExisting,%s,\nMember,%s,\nBroken,nope,\n", newEmail, *otherUser.Email, *member.Email)
+	importUsers := func(dryRun bool) (int, sharedtypes.ChainImportUsersResponse) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/chain/import-users", &gin.H{
+			"chain_uid": chain.UID,
+			"format":    "csv",
+			"file":      []byte(file),
+			"dry_run":   dryRun,
+		}, hostToken)
+		controllers.ChainImportUsers(c)
+		result := resultFunc()
+		res := sharedtypes.ChainImportUsersResponse{}
+		json.Unmarshal([]byte(result.Body), &res)
+		return result.Response.StatusCode, res
+	}
+
+	status, res := importUsers(true)
+	assert.Equal(t, http.StatusOK, status)
+	if assert.Len(t, res.Rows, 4) {
+		assert.Equal(t, sharedtypes.ChainImportUserStatusNew, res.Rows[0].Status)
+		assert.Equal(t, sharedtypes.ChainImportUserStatusNew, res.Rows[1].Status, "existing accounts must not be revealed")
+		assert.Equal(t, sharedtypes.ChainImportUserStatusMember, res.Rows[2].Status)
+		assert.Equal(t, sharedtypes.ChainImportUserStatusInvalid, res.Rows[3].Status)
+	}
+	amount := -1
+	db.Raw(`SELECT COUNT(*) FROM users WHERE email = ?`, newEmail).Scan(&amount)
+	assert.Equal(t, 0, amount, "dry run must not create users")
+
+	status, res = importUsers(false)
+	assert.Equal(t, http.StatusOK, status)
+	assert.False(t, res.DryRun)
+
+	db.Raw(`
+SELECT COUNT(*) FROM user_chains AS uc
+JOIN users AS u ON u.id = uc.user_id
+WHERE uc.chain_id = ? AND uc.is_approved = TRUE AND u.email = ?
+	`, chain.ID, newEmail).Scan(&amount)
+	assert.Equal(t, 1, amount)
+
+	db.Raw(`SELECT COUNT(*) FROM user_chains WHERE chain_id = ? AND user_id = ?`, chain.ID, otherUser.ID).Scan(&amount)
+	assert.Equal(t, 0, amount, "existing accounts must accept the invite before joining")
+
+	db.Raw(`SELECT COUNT(*) FROM chain_invites WHERE chain_id = ? AND max_uses = 1 AND auto_approve = TRUE`, chain.ID).Scan(&amount)
+	assert.Equal(t, 1, amount, "existing accounts receive a single use invite")
+}
@@ -0,0 +1,150 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestChainInvite(t *testing.T) {
+	chain, host, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM mail_outbox WHERE to_address = ?`, *host.Email)
+	})
+	countHostMails := func() (count int) {
+		db.Raw(`SELECT COUNT(*) FROM mail_outbox WHERE to_address = ?`, *host.Email).Scan(&count)
+		return count
+	}
+	_, memberToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	newUser1, newUser1Token := mocks.MockUser(t, db, 0, mocks.MockChainAndUserOptions{})
+	newUser2, newUser2Token := mocks.MockUser(t, db, 0, mocks.MockChainAndUserOptions{})
+	_, newUser3Token := mocks.MockUser(t, db, 0, mocks.MockChainAndUserOptions{})
+
+	create := func(token string, body gin.H) (int, *sharedtypes.ChainInvite) {
+		body["chain_uid"] = chain.UID
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/chain/invite", &body, token)
+		controllers.ChainInviteCreate(c)
+		result := resultFunc()
+		invite := &sharedtypes.ChainInvite{}
+		json.Unmarshal([]byte(result.Body), invite)
+		return result.Response.StatusCode, invite
+	}
+	join := func(token, inviteToken string) (int, *sharedtypes.ChainInviteJoinResponse) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/chain/invite/join", &gin.H{"token": inviteToken}, token)
+		controllers.ChainInviteJoin(c)
+		result := resultFunc()
+		res := &sharedtypes.ChainInviteJoinResponse{}
+		json.Unmarshal([]byte(result.Body), res)
+		return result.Response.StatusCode, res
+	}
+	isApproved := func(userID uint) (isMember, isApproved bool) {
+		userChains := []sharedtypes.UserChain{}
+		db.Raw(`SELECT * FROM user_chains WHERE user_id = ? AND chain_id = ?`, userID, chain.ID).Scan(&userChains)
+		if len(userChains) == 0 {
+			return false, false
+		}
+		return true, userChains[0].IsApproved
+	}
+
+	t.Run("Participants can not create invites", func(t *testing.T) {
+		status, _ := create(memberToken, gin.H{})
+		assert.Equal(t, http.StatusUnauthorized, status)
+	})
+
+	t.Run("Expiry must be in the future", func(t *testing.T) {
+		status, _ := create(hostToken, gin.H{"expires_at": time.Now().Add(-time.Hour)})
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("Auto approved invite with a single use", func(t *testing.T) {
+		status, invite := create(hostToken, gin.H{"max_uses": 1, "auto_approve": true})
+		assert.Equal(t, http.StatusOK, status)
+		assert.Len(t, invite.Token, 32)
+		assert.True(t, strings.HasSuffix(invite.Url, invite.Token))
+
+		hostMails := countHostMails()
+		status, res := join(newUser1Token, invite.Token)
+		assert.Equal(t, http.StatusOK, status)
+		assert.True(t, res.IsApproved)
+		isMember, approved := isApproved(newUser1.ID)
+		assert.True(t, isMember)
+		assert.True(t, approved)
+		assert.Equal(t, hostMails+1, countHostMails(), "the host is told who joined")
+
+		// joining again as a member does not use up the invite
+		status, _ = join(newUser1Token, invite.Token)
+		assert.Equal(t, http.StatusOK, status)
+
+		status, _ = join(newUser2Token, invite.Token)
+		assert.Equal(t, http.StatusGone, status)
+		isMember, _ = isApproved(newUser2.ID)
+		assert.False(t, isMember)
+	})
+
+	t.Run("Invite without auto approval waits for a host", func(t *testing.T) {
+		status, invite := create(hostToken, gin.H{"expires_at": time.Now().Add(24 * time.Hour)})
+		assert.Equal(t, http.StatusOK, status)
+
+		hostMails := countHostMails()
+		status, res := join(newUser2Token, invite.Token)
+		assert.Equal(t, http.StatusOK, status)
+		assert.False(t, res.IsApproved)
+		assert.Equal(t, hostMails+1, countHostMails())
+		isMember, approved := isApproved(newUser2.ID)
+		assert.True(t, isMember)
+		assert.False(t, approved)
+	})
+
+	t.Run("Revoked invites are listed with their usage", func(t *testing.T) {
+		status, invite := create(hostToken, gin.H{})
+		assert.Equal(t, http.StatusOK, status)
+
+		url := fmt.Sprintf("/v2/chain/invite?chain_uid=%s&token=%s", chain.UID, invite.Token)
+		c, resultFunc := mocks.MockGinContext(db, http.MethodDelete, url, nil, hostToken)
+		controllers.ChainInviteRevoke(c)
+		assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+		status, _ = join(newUser3Token, invite.Token)
+		assert.Equal(t, http.StatusGone, status)
+
+		c, resultFunc = mocks.MockGinContext(db, http.MethodGet, "/v2/chain/invites?chain_uid="+chain.UID, nil, hostToken)
+		controllers.ChainInviteGetAll(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode)
+		invites := []sharedtypes.ChainInvite{}
+		json.Unmarshal([]byte(result.Body), &invites)
+		if assert.Len(t, invites, 3) {
+			assert.NotNil(t, invites[0].RevokedAt)
+			assert.Equal(t, 1, invites[2].UseCount)
+			if assert.Len(t, invites[2].Uses, 1) {
+				assert.Equal(t, newUser1.UID, invites[2].Uses[0].UserUID)
+				assert.True(t, invites[2].Uses[0].IsApproved)
+			}
+		}
+	})
+
+	t.Run("QR code of invite", func(t *testing.T) {
+		_, invite := create(hostToken, gin.H{})
+
+		url := fmt.Sprintf("/v2/chain/invite/qr?chain_uid=%s&token=%s&scale=4", chain.UID, invite.Token)
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, hostToken)
+		controllers.ChainInviteQRCode(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode)
+		assert.Equal(t, "image/png", result.Response.Header.Get("Content-Type"))
+		_, err := png.Decode(strings.NewReader(result.Body))
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,54 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestChainGetMap(t *testing.T) {
+	// a remote spot in the pacific so no other loops are inside the bounding box
+	mockChain := func(latitude float64, allowMap bool, o mocks.MockChainAndUserOptions) string {
+		chain, _, _ := mocks.MockChainAndUser(t, db, o)
+		db.Exec(`UPDATE chains SET latitude = ?, longitude = -140, allow_map = ? WHERE id = ?`, latitude, allowMap, chain.ID)
+		return chain.UID
+	}
+	shownUID := mockChain(-30.001, true, mocks.MockChainAndUserOptions{})
+	mockChain(-30.002, true, mocks.MockChainAndUserOptions{})
+	mockChain(-30.003, false, mocks.MockChainAndUserOptions{})
+	mockChain(-30.004, true, mocks.MockChainAndUserOptions{IsNotPublished: true})
+	app.Cache.Delete("chain_map")
+
+	getMap := func(zoom string) (int, *sharedtypes.ChainMapResponse) {
+		url := "/v2/chain/map?min_latitude=-31&max_latitude=-29&min_longitude=-141&max_longitude=-139&zoom=" + zoom
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, "")
+		controllers.ChainGetMap(c)
+		result := resultFunc()
+		res := &sharedtypes.ChainMapResponse{}
+		json.Unmarshal([]byte(result.Body), res)
+		return result.Response.StatusCode, res
+	}
+
+	status, res := getMap("5")
+	assert.Equal(t, http.StatusOK, status)
+	if assert.Len(t, res.Clusters, 1) {
+		assert.Equal(t, 2, res.Clusters[0].Count)
+	}
+
+	status, res = getMap("16")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, res.Clusters, 2)
+	assert.Contains(t, lo.Map(res.Clusters, func(c sharedtypes.ChainMapCluster, _ int) string { return c.ChainUID }), shownUID)
+
+	status, _ = getMap("30")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
@@ -0,0 +1,100 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestChainSearch(t *testing.T) {
+	word := fmt.Sprintf("searchable%d", faker.IntBetween(100000, 999999))
+
+	mockChain := func(name string, latitude float64, sizes, genders []string, o mocks.MockChainAndUserOptions) *models.Chain {
+		chain, _, _ := mocks.MockChainAndUser(t, db, o)
+		db.Exec(`UPDATE chains SET name = ?, latitude = ?, longitude = 5 WHERE id = ?`, name, latitude, chain.ID)
+		models.ChainFacetsSync(db, chain.ID, sizes, genders)
+		return chain
+	}
+	chainNoord := mockChain("Fake "+word+" Noord", 52.0, []string{"1", "2"}, []string{"1"}, mocks.MockChainAndUserOptions{IsOpenToNewMembers: true})
+	chainZuid := mockChain("Fake "+word+" Zuid", 52.1, []string{"1"}, []string{"1", "2"}, mocks.MockChainAndUserOptions{})
+	mockChain("Fake "+word+" Hidden", 52.0, []string{"1"}, []string{"1"}, mocks.MockChainAndUserOptions{IsNotPublished: true})
+	mocks.MockUser(t, db, chainNoord.ID, mocks.MockChainAndUserOptions{})
+
+	search := func(params string) (int, *sharedtypes.ChainSearchResponse) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/chain/search?q="+word+params, nil, "")
+		controllers.ChainSearch(c)
+		result := resultFunc()
+		res := &sharedtypes.ChainSearchResponse{}
+		json.Unmarshal([]byte(result.Body), res)
+		return result.Response.StatusCode, res
+	}
+	uids := func(res *sharedtypes.ChainSearchResponse) []string {
+		list := []string{}
+		for _, item := range res.Items {
+			list = append(list, item.UID)
+		}
+		return list
+	}
+
+	t.Run("Text with facet counts", func(t *testing.T) {
+		status, res := search("")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, 2, res.Total)
+		assert.ElementsMatch(t, []string{chainNoord.UID, chainZuid.UID}, uids(res))
+		assert.Equal(t, map[string]int{"1": 2, "2": 1}, res.Facets.Sizes)
+		assert.Equal(t, map[string]int{"1": 2, "2": 1}, res.Facets.Genders)
+	})
+
+	t.Run("Every selected size", func(t *testing.T) {
+		status, res := search("&filter_sizes=1&filter_sizes=2")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, []string{chainNoord.UID}, uids(res))
+	})
+
+	t.Run("Invalid size", func(t *testing.T) {
+		status, _ := search("&filter_sizes=Z")
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+
+	t.Run("Sorted by distance", func(t *testing.T) {
+		status, res := search("&latitude=52.1&longitude=5&radius=50")
+		assert.Equal(t, http.StatusOK, status)
+		if assert.Len(t, res.Items, 2) {
+			assert.Equal(t, chainZuid.UID, res.Items[0].UID)
+			assert.Less(t, *res.Items[0].Distance, *res.Items[1].Distance)
+		}
+
+		_, res = search("&latitude=52.1&longitude=5&radius=5")
+		assert.Equal(t, []string{chainZuid.UID}, uids(res))
+	})
+
+	t.Run("Open to new members and member count", func(t *testing.T) {
+		_, res := search("&open_to_new_members=true")
+		assert.Equal(t, []string{chainNoord.UID}, uids(res))
+
+		_, res = search("&min_members=2")
+		if assert.Equal(t, []string{chainNoord.UID}, uids(res)) {
+			assert.Equal(t, 2, *res.Items[0].TotalMembers)
+		}
+	})
+
+	t.Run("Pages", func(t *testing.T) {
+		_, res := search("&latitude=52.1&longitude=5&limit=1")
+		assert.Equal(t, []string{chainZuid.UID}, uids(res))
+		if !assert.NotEmpty(t, res.NextCursor) {
+			return
+		}
+		_, res = search("&latitude=52.1&longitude=5&limit=1&cursor=" + res.NextCursor)
+		assert.Equal(t, []string{chainNoord.UID}, uids(res))
+		assert.Empty(t, res.NextCursor)
+	})
+}
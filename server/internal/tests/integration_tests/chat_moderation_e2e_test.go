@@ -0,0 +1,83 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestChatModerationReportAndMute(t *testing.T) {
+	chain, _, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		IsChainAdmin: true,
+	})
+	sender, senderToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	_, reporterToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+
+	channel := &sharedtypes.ChatChannel{Name: "Fake channel", Color: "#000000", ChainID: chain.ID, CreatedAt: time.Now().UnixMilli()}
+	db.Create(channel)
+	message := &sharedtypes.ChatMessage{Message: "Fake message", SendByUID: sender.UID, ChatChannelID: channel.ID, CreatedAt: time.Now().UnixMilli()}
+	db.Create(message)
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM chat_moderation_actions WHERE chain_id = ?`, chain.ID)
+		db.Exec(`DELETE FROM chat_message_reports WHERE chain_id = ?`, chain.ID)
+		db.Exec(`DELETE FROM chat_messages WHERE chat_channel_id = ?`, channel.ID)
+		db.Exec(`DELETE FROM chat_channels WHERE id = ?`, channel.ID)
+	})
+
+	// report message
+	c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/chat/channel/message/report", &gin.H{
+		"chain_uid":       chain.UID,
+		"chat_channel_id": channel.ID,
+		"chat_message_id": message.ID,
+		"reason":          "spam",
+	}, reporterToken)
+	controllers.ChatChannelMessageReport(c)
+	result := resultFunc()
+	assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+	reports, err := models.ChatMessageReportGetAll(db, chain.ID, sharedtypes.ChatReportStatusOpen)
+	assert.NoError(t, err)
+	if !assert.Len(t, reports, 1) {
+		return
+	}
+	assert.Equal(t, sender.UID, reports[0].SendByUID)
+
+	// mute sender
+	c, resultFunc = mocks.MockGinContext(db, http.MethodPost, "/v2/chat/moderation/action", &gin.H{
+		"chain_uid":  chain.UID,
+		"report_id":  reports[0].ID,
+		"action":     "mute",
+		"mute_hours": 2,
+	}, hostToken)
+	controllers.ChatModerationActionCreate(c)
+	result = resultFunc()
+	assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+	isMuted, err := models.UserChainIsChatMuted(db, sender.ID, chain.ID)
+	assert.NoError(t, err)
+	assert.True(t, isMuted)
+
+	actions, err := models.ChatModerationActionGetAll(db, chain.ID)
+	assert.NoError(t, err)
+	assert.Len(t, actions, 1)
+
+	// muted sender can not post
+	c, resultFunc = mocks.MockGinContext(db, http.MethodPost, "/v2/chat/channel/message/create", &gin.H{
+		"chain_uid":       chain.UID,
+		"chat_channel_id": channel.ID,
+		"message":         fmt.Sprintf("Fake message %d", time.Now().Unix()),
+	}, senderToken)
+	controllers.ChatChannelMessageCreate(c)
+	result = resultFunc()
+	assert.Equal(t, http.StatusForbidden, result.Response.StatusCode, result.Body)
+}
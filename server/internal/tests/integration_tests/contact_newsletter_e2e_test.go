@@ -0,0 +1,111 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+)
+
+func TestContactNewsletterDoubleOptIn(t *testing.T) {
+	email := "newsletter-" + faker.UUID().V4() + "@example.com"
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM newsletters WHERE email = ?`, email)
+		db.Exec(`DELETE FROM mail_outbox WHERE to_address = ?`, email)
+	})
+
+	subscribe := func() int {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/contact/newsletter", &gin.H{
+			"name":      "Jane",
+			"email":     email,
+			"subscribe": true,
+		}, "")
+		controllers.ContactNewsletter(c)
+		return resultFunc().Response.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, subscribe())
+
+	n, err := models.NewsletterGetByEmail(db, email)
+	app.AssertNotErrorNow(t, err)
+	assert.False(t, n.Verified, "subscription must be pending until confirmed")
+	if !assert.NotNil(t, n.ConfirmToken) {
+		return
+	}
+	token := *n.ConfirmToken
+
+	t.Run("wrong token", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/contact/newsletter/confirm?token="+strings.Repeat("0", 64), nil, "")
+		controllers.ContactNewsletterConfirm(c)
+		assert.Equal(t, http.StatusNotFound, resultFunc().Response.StatusCode)
+	})
+
+	t.Run("confirm", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/contact/newsletter/confirm?token="+token, nil, "")
+		controllers.ContactNewsletterConfirm(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		n, err := models.NewsletterGetByEmail(db, email)
+		app.AssertNotErrorNow(t, err)
+		assert.True(t, n.Verified)
+		assert.NotNil(t, n.ConfirmedAt)
+		assert.Nil(t, n.ConfirmToken)
+
+		// the token can only be used once
+		c, resultFunc = mocks.MockGinContext(db, http.MethodGet, "/v2/contact/newsletter/confirm?token="+token, nil, "")
+		controllers.ContactNewsletterConfirm(c)
+		assert.Equal(t, http.StatusNotFound, resultFunc().Response.StatusCode)
+	})
+
+	t.Run("already subscribed", func(t *testing.T) {
+		assert.Equal(t, http.StatusAlreadyReported, subscribe())
+	})
+
+	t.Run("unsubscribe without signed link", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/contact/newsletter", &gin.H{
+			"name":      "Jane",
+			"email":     email,
+			"subscribe": false,
+		}, "")
+		controllers.ContactNewsletter(c)
+		assert.Equal(t, http.StatusBadRequest, resultFunc().Response.StatusCode)
+
+		n, err := models.NewsletterGetByEmail(db, email)
+		app.AssertNotErrorNow(t, err)
+		assert.True(t, n.Verified)
+	})
+
+	t.Run("unsubscribe with signed link", func(t *testing.T) {
+		u, _ := url.Parse(app.NewsletterUnsubscribeURL(email))
+
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/contact/newsletter/unsubscribe?e="+u.Query().Get("e")+"&s=invalid", nil, "")
+		controllers.ContactNewsletterUnsubscribe(c)
+		assert.Equal(t, http.StatusUnauthorized, resultFunc().Response.StatusCode)
+
+		// opening the link only asks to confirm
+		c, resultFunc = mocks.MockGinContext(db, http.MethodGet, "/v2/contact/newsletter/unsubscribe?"+u.RawQuery, nil, "")
+		controllers.ContactNewsletterUnsubscribe(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+		_, err := models.NewsletterGetByEmail(db, email)
+		assert.NoError(t, err)
+
+		c, resultFunc = mocks.MockGinContext(db, http.MethodPost, "/v2/contact/newsletter/unsubscribe?"+u.RawQuery, nil, "")
+		controllers.ContactNewsletterUnsubscribe(c)
+		result = resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		_, err = models.NewsletterGetByEmail(db, email)
+		assert.Error(t, err)
+	})
+}
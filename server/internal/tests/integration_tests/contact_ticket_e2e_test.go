@@ -0,0 +1,125 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestContactTicket(t *testing.T) {
+	_, _, tokenHost := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		IsChainAdmin: true,
+	})
+	userRoot, tokenRoot := mocks.MockUser(t, db, 0, mocks.MockChainAndUserOptions{
+		IsRootAdmin: true,
+	})
+	email := "contact-" + faker.UUID().V4() + "@example.com"
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM contact_ticket_replies WHERE contact_ticket_id IN (SELECT id FROM contact_tickets WHERE email = ?)`, email)
+		db.Exec(`DELETE FROM contact_tickets WHERE email = ?`, email)
+		db.Exec(`DELETE FROM mail_outbox WHERE to_address = ?`, email)
+	})
+
+	c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/contact/email", &gin.H{
+		"name":    "Jane",
+		"email":   email,
+		"message": "How do I start a Loop?",
+	}, "")
+	controllers.ContactMail(c)
+	result := resultFunc()
+	assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+	ticketID := uint(0)
+	db.Raw(`SELECT id FROM contact_tickets WHERE email = ? LIMIT 1`, email).Scan(&ticketID)
+	if !assert.NotZero(t, ticketID, "ticket should be stored") {
+		return
+	}
+	ticket, err := models.ContactTicketGet(db, ticketID)
+	app.AssertNotErrorNow(t, err)
+	assert.Equal(t, sharedtypes.ContactTicketStatusOpen, ticket.Status)
+	assert.NotEmpty(t, ticket.ThreadMessageID)
+
+	t.Run("host is not allowed", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/admin/contact-tickets", nil, tokenHost)
+		controllers.ContactTicketGetAll(c)
+		assert.Equal(t, http.StatusUnauthorized, resultFunc().Response.StatusCode)
+	})
+
+	t.Run("list open tickets", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/admin/contact-tickets?status=open&limit=500", nil, tokenRoot)
+		controllers.ContactTicketGetAll(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		res := sharedtypes.PaginatedResponse[sharedtypes.ContactTicket]{}
+		json.Unmarshal([]byte(result.Body), &res)
+		found := false
+		for _, t := range res.Items {
+			if t.ID == ticketID {
+				found = true
+			}
+		}
+		assert.True(t, found)
+	})
+
+	t.Run("reply is threaded", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/admin/contact-ticket/reply", &gin.H{
+			"id":      ticketID,
+			"message": "You can start a Loop from the website.",
+		}, tokenRoot)
+		controllers.ContactTicketReply(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		reply := sharedtypes.ContactTicketReply{}
+		json.Unmarshal([]byte(result.Body), &reply)
+		m, err := models.MailGet(db, reply.MailID)
+		app.AssertNotErrorNow(t, err)
+		assert.Equal(t, email, m.ToAddress)
+		assert.Equal(t, ticket.ThreadMessageID, m.InReplyTo.String)
+		assert.Equal(t, ticket.ThreadMessageID, m.References.String)
+		assert.True(t, m.MessageID.Valid)
+
+		ticket, err := models.ContactTicketGet(db, ticketID)
+		app.AssertNotErrorNow(t, err)
+		assert.Equal(t, sharedtypes.ContactTicketStatusAnswered, ticket.Status)
+		if assert.NotNil(t, ticket.AssigneeUserUID) {
+			assert.Equal(t, userRoot.UID, *ticket.AssigneeUserUID)
+		}
+	})
+
+	t.Run("update status and notes", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPatch, "/v2/admin/contact-ticket", &gin.H{
+			"id":                ticketID,
+			"status":            sharedtypes.ContactTicketStatusClosed,
+			"notes":             "Sent the toolkit",
+			"assignee_user_uid": "",
+		}, tokenRoot)
+		controllers.ContactTicketUpdate(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		c, resultFunc = mocks.MockGinContext(db, http.MethodGet, fmt.Sprintf("/v2/admin/contact-ticket?id=%d", ticketID), nil, tokenRoot)
+		controllers.ContactTicketGet(c)
+		result = resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		res := sharedtypes.ContactTicketResponse{}
+		json.Unmarshal([]byte(result.Body), &res)
+		assert.Equal(t, sharedtypes.ContactTicketStatusClosed, res.Ticket.Status)
+		assert.Equal(t, "Sent the toolkit", res.Ticket.Notes)
+		assert.Nil(t, res.Ticket.AssigneeUserUID)
+		assert.Len(t, res.Replies, 1)
+	})
+}
@@ -0,0 +1,77 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestEmailPreview(t *testing.T) {
+	_, _, tokenHost := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		IsChainAdmin: true,
+	})
+	userRoot, tokenRoot := mocks.MockUser(t, db, 0, mocks.MockChainAndUserOptions{
+		IsRootAdmin: true,
+	})
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM mail_outbox WHERE to_address = ?`, *userRoot.Email)
+	})
+
+	t.Run("host is not allowed", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/admin/email-preview", nil, tokenHost)
+		controllers.EmailPreviewGet(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusUnauthorized, result.Response.StatusCode)
+	})
+
+	t.Run("list templates", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/admin/email-preview", nil, tokenRoot)
+		controllers.EmailPreviewGet(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		res := sharedtypes.EmailPreviewListResponse{}
+		json.Unmarshal([]byte(result.Body), &res)
+		assert.Contains(t, res.Templates, "poke")
+		assert.Contains(t, res.Languages, "nl")
+		assert.Contains(t, res.Problems, "nl")
+	})
+
+	t.Run("render html", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/admin/email-preview?template=poke&lang=nl&format=html", nil, tokenRoot)
+		controllers.EmailPreviewGet(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+		assert.Contains(t, result.Response.Header.Get("Content-Type"), "text/html")
+		assert.Contains(t, result.Body, "<html")
+	})
+
+	t.Run("unknown template", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/admin/email-preview?template=does_not_exist", nil, tokenRoot)
+		controllers.EmailPreviewGet(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusBadRequest, result.Response.StatusCode, result.Body)
+	})
+
+	t.Run("send to self", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/admin/email-preview/send", &gin.H{
+			"template": "weekly_digest",
+			"lang":     "en",
+		}, tokenRoot)
+		controllers.EmailPreviewSend(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		subject := ""
+		db.Raw(`SELECT subject FROM mail_outbox WHERE to_address = ? ORDER BY id DESC LIMIT 1`, *userRoot.Email).Scan(&subject)
+		assert.Contains(t, subject, "[Preview]")
+	})
+}
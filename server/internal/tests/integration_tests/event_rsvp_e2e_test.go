@@ -0,0 +1,156 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestEventRsvpWaitlist(t *testing.T) {
+	chain, host, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		IsChainAdmin: true,
+	})
+	_, participantToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	event := mocks.MockEvent(t, db, host.ID, chain.ID)
+	db.Exec(`UPDATE events SET capacity = 1 WHERE id = ?`, event.ID)
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM event_rsvps WHERE event_id = ?`, event.ID)
+	})
+
+	putRsvp := func(token, status string) sharedtypes.EventRsvpResponse {
+		t.Helper()
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPut, "/v2/event/rsvp", &gin.H{
+			"event_uid": event.UID,
+			"status":    status,
+		}, token)
+		controllers.EventRsvpPut(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		res := sharedtypes.EventRsvpResponse{}
+		json.Unmarshal([]byte(result.Body), &res)
+		return res
+	}
+
+	res := putRsvp(hostToken, sharedtypes.EventRsvpStatusGoing)
+	if assert.NotNil(t, res.Rsvp) {
+		assert.False(t, res.Rsvp.IsWaitlisted)
+	}
+	assert.Equal(t, 1, res.GoingCount)
+
+	res = putRsvp(participantToken, sharedtypes.EventRsvpStatusGoing)
+	if assert.NotNil(t, res.Rsvp) {
+		assert.True(t, res.Rsvp.IsWaitlisted)
+	}
+	assert.Equal(t, 1, res.WaitlistCount)
+
+	res = putRsvp(hostToken, sharedtypes.EventRsvpStatusNotGoing)
+	assert.Equal(t, 1, res.GoingCount)
+	assert.Equal(t, 0, res.WaitlistCount)
+
+	// only the organiser can see the attendee list
+	url := fmt.Sprintf("/v2/event/attendees?event_uid=%s", event.UID)
+	c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, participantToken)
+	controllers.EventAttendeesGet(c)
+	assert.Equal(t, http.StatusUnauthorized, resultFunc().Response.StatusCode)
+
+	c, resultFunc = mocks.MockGinContext(db, http.MethodGet, url, nil, hostToken)
+	controllers.EventAttendeesGet(c)
+	result := resultFunc()
+	assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+	attendees := []sharedtypes.EventAttendee{}
+	json.Unmarshal([]byte(result.Body), &attendees)
+	assert.Len(t, attendees, 2)
+}
+
+func TestEventRsvpOccurrences(t *testing.T) {
+	chain, host, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		IsChainAdmin: true,
+	})
+	event := mocks.MockEvent(t, db, host.ID, chain.ID)
+	start := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	db.Exec(`UPDATE events SET date = ?, date_end = NULL, rrule = 'FREQ=WEEKLY;COUNT=3' WHERE id = ?`, start, event.ID)
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM event_rsvps WHERE event_id = ?`, event.ID)
+	})
+
+	putRsvp := func(body gin.H) (int, sharedtypes.EventRsvpResponse) {
+		t.Helper()
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPut, "/v2/event/rsvp", &body, hostToken)
+		controllers.EventRsvpPut(c)
+		result := resultFunc()
+		res := sharedtypes.EventRsvpResponse{}
+		json.Unmarshal([]byte(result.Body), &res)
+		return result.Response.StatusCode, res
+	}
+	getRsvp := func(date time.Time) sharedtypes.EventRsvpResponse {
+		t.Helper()
+		url := fmt.Sprintf("/v2/event/rsvp?event_uid=%s&occurrence_date=%s", event.UID, date.Format(time.RFC3339))
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, hostToken)
+		controllers.EventRsvpGet(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+		res := sharedtypes.EventRsvpResponse{}
+		json.Unmarshal([]byte(result.Body), &res)
+		return res
+	}
+
+	status, _ := putRsvp(gin.H{"event_uid": event.UID, "status": sharedtypes.EventRsvpStatusGoing})
+	assert.Equal(t, http.StatusBadRequest, status, "recurring events require an occurrence")
+
+	status, _ = putRsvp(gin.H{"event_uid": event.UID, "status": sharedtypes.EventRsvpStatusGoing, "occurrence_date": start.Add(time.Hour)})
+	assert.Equal(t, http.StatusNotFound, status, "the date must be an occurrence")
+
+	second := start.AddDate(0, 0, 7)
+	status, res := putRsvp(gin.H{"event_uid": event.UID, "status": sharedtypes.EventRsvpStatusGoing, "occurrence_date": second})
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1, res.GoingCount)
+
+	assert.Nil(t, getRsvp(start).Rsvp, "other occurrences are not affected")
+	assert.Equal(t, 0, getRsvp(start).GoingCount)
+	if res := getRsvp(second); assert.NotNil(t, res.Rsvp) {
+		assert.Equal(t, sharedtypes.EventRsvpStatusGoing, res.Rsvp.Status)
+	}
+}
+
+func TestEventAttendeesPrivacy(t *testing.T) {
+	chain, organiser, organiserToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	_, attendee, attendeeToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	// an event outside of a loop
+	event := mocks.MockEvent(t, db, organiser.ID, chain.ID)
+	db.Exec(`UPDATE events SET chain_id = NULL WHERE id = ?`, event.ID)
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM event_rsvps WHERE event_id = ?`, event.ID)
+	})
+
+	c, resultFunc := mocks.MockGinContext(db, http.MethodPut, "/v2/event/rsvp", &gin.H{
+		"event_uid": event.UID,
+		"status":    sharedtypes.EventRsvpStatusGoing,
+	}, attendeeToken)
+	controllers.EventRsvpPut(c)
+	assert.Equal(t, http.StatusOK, resultFunc().Response.StatusCode)
+
+	url := fmt.Sprintf("/v2/event/attendees?event_uid=%s", event.UID)
+	c, resultFunc = mocks.MockGinContext(db, http.MethodGet, url, nil, organiserToken)
+	controllers.EventAttendeesGet(c)
+	result := resultFunc()
+	assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+	attendees := []sharedtypes.EventAttendee{}
+	json.Unmarshal([]byte(result.Body), &attendees)
+	if assert.Len(t, attendees, 1) {
+		assert.Equal(t, attendee.UID, attendees[0].UserUID)
+		assert.Equal(t, attendee.Name, attendees[0].Name)
+		assert.Equal(t, "***", attendees[0].Email)
+		assert.Equal(t, "***", attendees[0].PhoneNumber)
+	}
+}
@@ -0,0 +1,96 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestICalFeedUser(t *testing.T) {
+	chain, user, token := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		IsChainAdmin: true,
+	})
+	event := mocks.MockEvent(t, db, user.ID, chain.ID)
+
+	getToken := func(method string) sharedtypes.UserCalendarTokenResponse {
+		t.Helper()
+		c, resultFunc := mocks.MockGinContext(db, method, "/v2/user/calendar-token", nil, token)
+		if method == http.MethodGet {
+			controllers.UserCalendarTokenGet(c)
+		} else {
+			controllers.UserCalendarTokenReset(c)
+		}
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		res := sharedtypes.UserCalendarTokenResponse{}
+		json.Unmarshal([]byte(result.Body), &res)
+		return res
+	}
+	getFeed := func(calendarToken string) (int, string) {
+		t.Helper()
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, fmt.Sprintf("/v2/ical/user/%s", calendarToken), nil, "")
+		c.Params = gin.Params{{Key: "token", Value: calendarToken}}
+		controllers.ICalFeedUser(c)
+		result := resultFunc()
+		return result.Response.StatusCode, result.Body
+	}
+
+	first := getToken(http.MethodGet)
+	assert.NotEmpty(t, first.Token)
+	assert.Equal(t, first.Token, getToken(http.MethodGet).Token)
+
+	status, body := getFeed(first.Token)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "UID:"+event.UID)
+	assert.Contains(t, body, "SEQUENCE:0")
+
+	second := getToken(http.MethodPost)
+	assert.NotEqual(t, first.Token, second.Token)
+
+	status, _ = getFeed(first.Token)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = getFeed(second.Token)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestICalFeedChainUnpublished(t *testing.T) {
+	chain, host, hostToken := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		IsChainAdmin:   true,
+		IsNotPublished: true,
+	})
+	event := mocks.MockEvent(t, db, host.ID, chain.ID)
+	_, otherToken := mocks.MockUser(t, db, 0, mocks.MockChainAndUserOptions{})
+
+	getFeed := func(token string) (int, string) {
+		t.Helper()
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, fmt.Sprintf("/v2/ical/chain/%s", chain.UID), nil, token)
+		c.Params = gin.Params{{Key: "uid", Value: chain.UID}}
+		controllers.ICalFeedChain(c)
+		result := resultFunc()
+		return result.Response.StatusCode, result.Body
+	}
+
+	status, _ := getFeed("")
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = getFeed(otherToken)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, body := getFeed(hostToken)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "UID:"+event.UID)
+
+	db.Exec(`UPDATE chains SET published = TRUE WHERE id = ?`, chain.ID)
+	status, body = getFeed("")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "UID:"+event.UID)
+}
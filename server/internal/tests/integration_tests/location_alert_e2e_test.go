@@ -0,0 +1,180 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestLocationAlert(t *testing.T) {
+	// far away from other test data, so only the alerts of this test are in range
+	latitude, longitude := -80.0, float64(faker.IntBetween(-170, 170))
+
+	subscribe := func(email string, sizes []string) *models.LocationAlert {
+		t.Cleanup(func() {
+			models.LocationAlertDeleteByEmail(db, email)
+			db.Exec(`DELETE FROM mail_outbox WHERE to_address = ?`, email)
+		})
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/location-alert", &gin.H{
+			"name":      "Jane",
+			"email":     email,
+			"latitude":  latitude,
+			"longitude": longitude,
+			"radius":    10,
+			"sizes":     sizes,
+			"genders":   []string{},
+		}, "")
+		controllers.LocationAlertSubscribe(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		alert, err := models.LocationAlertGetByEmail(db, email)
+		app.AssertNotErrorNow(t, err)
+		assert.False(t, alert.Verified, "alert must be pending until confirmed")
+		return alert
+	}
+	confirm := func(alert *models.LocationAlert) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/location-alert/confirm?token="+*alert.ConfirmToken, nil, "")
+		controllers.LocationAlertConfirm(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+	}
+	countMails := func(email string) (count int) {
+		db.Raw(`SELECT COUNT(*) FROM mail_outbox WHERE to_address = ?`, email).Scan(&count)
+		return count
+	}
+
+	emailConfirmed := "alert-" + faker.UUID().V4() + "@example.com"
+	emailPending := "alert-" + faker.UUID().V4() + "@example.com"
+	emailOtherSize := "alert-" + faker.UUID().V4() + "@example.com"
+	confirm(subscribe(emailConfirmed, []string{}))
+	subscribe(emailPending, []string{})
+	confirm(subscribe(emailOtherSize, []string{"2"}))
+
+	t.Run("Notified when a loop is created nearby", func(t *testing.T) {
+		_, token := mocks.MockUser(t, db, 0, mocks.MockChainAndUserOptions{})
+
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/chain", &gin.H{
+			"name":                "Fake " + faker.Company().Name(),
+			"address":             faker.Address().Address(),
+			"country_code":        "AQ",
+			"latitude":            latitude + 0.05,
+			"longitude":           longitude,
+			"radius":              3,
+			"open_to_new_members": true,
+			"sizes":               []string{"1"},
+			"genders":             []string{"1"},
+			"allow_toh":           true,
+		}, token)
+		controllers.ChainCreate(c)
+		result := resultFunc()
+		if !assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body) {
+			return
+		}
+		res := &sharedtypes.ChainCreateResponse{}
+		json.Unmarshal([]byte(result.Body), res)
+		t.Cleanup(func() {
+			db.Exec(`DELETE FROM location_alert_notifications WHERE chain_id IN (SELECT id FROM chains WHERE uid = ?)`, res.ChainUID)
+			db.Exec(`DELETE FROM chain_facets WHERE chain_id IN (SELECT id FROM chains WHERE uid = ?)`, res.ChainUID)
+			db.Exec(`DELETE FROM user_chains WHERE chain_id IN (SELECT id FROM chains WHERE uid = ?)`, res.ChainUID)
+			db.Exec(`DELETE FROM chains WHERE uid = ?`, res.ChainUID)
+		})
+
+		assert.Equal(t, 1, res.InterestedNearby)
+		assert.Equal(t, 2, countMails(emailConfirmed), "confirmation and alert")
+		assert.Equal(t, 1, countMails(emailPending), "only the confirmation")
+		assert.Equal(t, 1, countMails(emailOtherSize), "only the confirmation")
+	})
+
+	t.Run("Notified once when a loop opens again", func(t *testing.T) {
+		chain, _, token := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{IsChainAdmin: true})
+		db.Exec(`UPDATE chains SET latitude = ?, longitude = ? WHERE id = ?`, latitude, longitude, chain.ID)
+
+		emailLater := "alert-" + faker.UUID().V4() + "@example.com"
+		confirm(subscribe(emailLater, []string{}))
+
+		open := func() int {
+			c, resultFunc := mocks.MockGinContext(db, http.MethodPatch, "/v2/chain", &gin.H{
+				"uid":                 chain.UID,
+				"open_to_new_members": true,
+			}, token)
+			controllers.ChainUpdate(c)
+			return resultFunc().Response.StatusCode
+		}
+
+		before := countMails(emailConfirmed)
+		assert.Equal(t, http.StatusOK, open())
+		assert.Equal(t, before+1, countMails(emailConfirmed))
+		assert.Equal(t, 2, countMails(emailLater))
+
+		db.Exec(`UPDATE chains SET open_to_new_members = FALSE WHERE id = ?`, chain.ID)
+		assert.Equal(t, http.StatusOK, open())
+		assert.Equal(t, before+1, countMails(emailConfirmed))
+	})
+
+	t.Run("Subscribing again keeps the confirmed alert until confirmed", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPost, "/v2/location-alert", &gin.H{
+			"name":      "Someone else",
+			"email":     emailConfirmed,
+			"latitude":  10,
+			"longitude": 10,
+			"radius":    1,
+		}, "")
+		controllers.LocationAlertSubscribe(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		alert, err := models.LocationAlertGetByEmail(db, emailConfirmed)
+		app.AssertNotErrorNow(t, err)
+		assert.True(t, alert.Verified)
+		assert.Equal(t, latitude, alert.Latitude)
+		assert.Equal(t, "Jane", alert.Name)
+		if !assert.NotNil(t, alert.Pending) {
+			return
+		}
+		assert.Equal(t, 10.0, alert.Pending.Latitude)
+
+		confirm(alert)
+		alert, err = models.LocationAlertGetByEmail(db, emailConfirmed)
+		app.AssertNotErrorNow(t, err)
+		assert.True(t, alert.Verified)
+		assert.Nil(t, alert.Pending)
+		assert.Equal(t, 10.0, alert.Latitude)
+		assert.Equal(t, "Someone else", alert.Name)
+	})
+
+	t.Run("Unsubscribe with signed link", func(t *testing.T) {
+		u, _ := url.Parse(app.LocationAlertUnsubscribeURL(emailConfirmed))
+
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/location-alert/unsubscribe?e="+u.Query().Get("e")+"&s=invalid", nil, "")
+		controllers.LocationAlertUnsubscribe(c)
+		assert.Equal(t, http.StatusUnauthorized, resultFunc().Response.StatusCode)
+
+		// opening the link only asks to confirm
+		c, resultFunc = mocks.MockGinContext(db, http.MethodGet, "/v2/location-alert/unsubscribe?"+u.RawQuery, nil, "")
+		controllers.LocationAlertUnsubscribe(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+		_, err := models.LocationAlertGetByEmail(db, emailConfirmed)
+		assert.NoError(t, err)
+
+		c, resultFunc = mocks.MockGinContext(db, http.MethodPost, "/v2/location-alert/unsubscribe?"+u.RawQuery, nil, "")
+		controllers.LocationAlertUnsubscribe(c)
+		result = resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		_, err = models.LocationAlertGetByEmail(db, emailConfirmed)
+		assert.Error(t, err)
+	})
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/the-clothing-loop/website/server/internal/models"
 	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
 	"github.com/the-clothing-loop/website/server/internal/views"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
 )
 
 func TestMailEnvironment(t *testing.T) {
@@ -131,11 +132,13 @@ func TestEmailApproveReminder(t *testing.T) {
 
 func TestEmailContactConfirmation(t *testing.T) {
 	runOnAllLanguages(t, func(t *testing.T, c *gin.Context, lng string) {
-		err := views.EmailContactConfirmation(c, db,
-			lng+" "+faker.Person().Name(),
-			faker.Person().Contact().Email,
-			faker.Lorem().Paragraph(2),
-		)
+		err := views.EmailContactConfirmation(db, &sharedtypes.ContactTicket{
+			Name:            lng + " " + faker.Person().Name(),
+			Email:           faker.Person().Contact().Email,
+			Message:         faker.Lorem().Paragraph(2),
+			I18n:            lng,
+			ThreadMessageID: app.MailNewMessageID(),
+		})
 		assert.Nil(t, err)
 	})
 }
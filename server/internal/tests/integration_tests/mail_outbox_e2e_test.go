@@ -0,0 +1,84 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestMailOutboxGetAll(t *testing.T) {
+	_, _, tokenHost := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		IsChainAdmin: true,
+	})
+	_, tokenRoot := mocks.MockUser(t, db, 0, mocks.MockChainAndUserOptions{
+		IsRootAdmin: true,
+	})
+	mail := mocks.MockMail(t, db, mocks.MockMailOptions{
+		Status: models.MailStatusFailed,
+		IsErr:  true,
+	})
+
+	reqUrl := fmt.Sprintf("/v2/admin/mails?status=failed&to_address=%s", url.QueryEscape(mail.ToAddress))
+
+	t.Run("host is not allowed", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, reqUrl, nil, tokenHost)
+		controllers.MailOutboxGetAll(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusUnauthorized, result.Response.StatusCode)
+	})
+
+	t.Run("root admin", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, reqUrl, nil, tokenRoot)
+		controllers.MailOutboxGetAll(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		res := sharedtypes.PaginatedResponse[models.Mail]{}
+		json.Unmarshal([]byte(result.Body), &res)
+		if assert.Len(t, res.Items, 1) {
+			assert.Equal(t, mail.ID, res.Items[0].ID)
+			assert.Empty(t, res.Items[0].Body)
+		}
+	})
+}
+
+func TestMailOutboxGet(t *testing.T) {
+	_, tokenRoot := mocks.MockUser(t, db, 0, mocks.MockChainAndUserOptions{
+		IsRootAdmin: true,
+	})
+	mail := mocks.MockMail(t, db, mocks.MockMailOptions{})
+
+	c, resultFunc := mocks.MockGinContext(db, http.MethodGet, fmt.Sprintf("/v2/admin/mail?id=%d", mail.ID), nil, tokenRoot)
+	controllers.MailOutboxGet(c)
+	result := resultFunc()
+	assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+	res := struct {
+		Mail     models.Mail          `json:"mail"`
+		Attempts []models.MailAttempt `json:"attempts"`
+	}{}
+	json.Unmarshal([]byte(result.Body), &res)
+	assert.Equal(t, mail.ID, res.Mail.ID)
+	assert.Equal(t, mail.Body, res.Mail.Body)
+	assert.Empty(t, res.Attempts)
+
+	t.Run("Login token hidden", func(t *testing.T) {
+		mail := mocks.MockMail(t, db, mocks.MockMailOptions{IsSensitive: true})
+
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, fmt.Sprintf("/v2/admin/mail?id=%d", mail.ID), nil, tokenRoot)
+		controllers.MailOutboxGet(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+		assert.NotContains(t, result.Body, mail.Body)
+	})
+}
@@ -0,0 +1,101 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestNotificationPreferences(t *testing.T) {
+	_, user, token := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+
+	findPreference := func(res sharedtypes.NotificationPreferencesResponse, notificationType, channel string) sharedtypes.NotificationPreferenceItem {
+		p, _ := lo.Find(res.Preferences, func(p sharedtypes.NotificationPreferenceItem) bool {
+			return p.Type == notificationType && p.Channel == channel
+		})
+		return p
+	}
+
+	t.Run("defaults are enabled except opt-in", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/user/notification-preferences", nil, token)
+		controllers.NotificationPreferencesGet(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		res := sharedtypes.NotificationPreferencesResponse{}
+		json.Unmarshal([]byte(result.Body), &res)
+		assert.NotEmpty(t, res.Preferences)
+		for _, p := range res.Preferences {
+			assert.Equal(t, !models.NotificationTypeIsOptIn(p.Type), p.Enabled, p.Type)
+		}
+	})
+
+	t.Run("channel not used by type", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPatch, "/v2/user/notification-preferences", &gin.H{
+			"preferences": []gin.H{{"type": sharedtypes.NotificationTypeChatMessage, "channel": "email", "enabled": false}},
+		}, token)
+		controllers.NotificationPreferencesUpdate(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusBadRequest, result.Response.StatusCode)
+	})
+
+	t.Run("turn off chat push", func(t *testing.T) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPatch, "/v2/user/notification-preferences", &gin.H{
+			"preferences": []gin.H{{"type": sharedtypes.NotificationTypeChatMessage, "channel": "push", "enabled": false}},
+		}, token)
+		controllers.NotificationPreferencesUpdate(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		res := sharedtypes.NotificationPreferencesResponse{}
+		json.Unmarshal([]byte(result.Body), &res)
+		assert.False(t, findPreference(res, sharedtypes.NotificationTypeChatMessage, "push").Enabled)
+		assert.True(t, findPreference(res, sharedtypes.NotificationTypeBagAssigned, "push").Enabled)
+
+		uids, err := models.NotificationPreferenceFilterUserUIDs(db, sharedtypes.NotificationTypeChatMessage, "push", []string{user.UID})
+		assert.NoError(t, err)
+		assert.Empty(t, uids)
+		uids, err = models.NotificationPreferenceFilterUserUIDs(db, sharedtypes.NotificationTypeBagAssigned, "push", []string{user.UID})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{user.UID}, uids)
+	})
+
+	t.Run("unsubscribe link", func(t *testing.T) {
+		unsubscribeURL := app.UnsubscribeURL(*user.Email, sharedtypes.NotificationTypePoke)
+
+		// opening the link only asks to confirm
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, unsubscribeURL, nil, "")
+		controllers.NotificationUnsubscribe(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+		assert.Contains(t, result.Body, `<form method="post"`)
+		isEnabled, err := models.NotificationPreferenceIsEnabledByEmail(db, sharedtypes.NotificationTypePoke, "email", *user.Email)
+		assert.NoError(t, err)
+		assert.True(t, isEnabled)
+
+		c, resultFunc = mocks.MockGinContext(db, http.MethodPost, unsubscribeURL, nil, "")
+		controllers.NotificationUnsubscribe(c)
+		result = resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		isEnabled, err = models.NotificationPreferenceIsEnabledByEmail(db, sharedtypes.NotificationTypePoke, "email", *user.Email)
+		assert.NoError(t, err)
+		assert.False(t, isEnabled)
+
+		c, resultFunc = mocks.MockGinContext(db, http.MethodPost, unsubscribeURL+"x", nil, "")
+		controllers.NotificationUnsubscribe(c)
+		result = resultFunc()
+		assert.Equal(t, http.StatusUnauthorized, result.Response.StatusCode)
+	})
+}
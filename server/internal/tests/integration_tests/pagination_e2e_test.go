@@ -0,0 +1,250 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/pkg/cursor"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestBagGetAllPaginated(t *testing.T) {
+	chain, host, token := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		IsChainAdmin: true,
+	})
+	bag1 := mocks.MockBag(t, db, chain.ID, host.ID, mocks.MockBagOptions{})
+	bag2 := mocks.MockBag(t, db, chain.ID, host.ID, mocks.MockBagOptions{})
+
+	getPage := func(cursor string) sharedtypes.PaginatedResponse[models.Bag] {
+		t.Helper()
+		url := fmt.Sprintf("/v2/bag/all?chain_uid=%s&user_uid=%s&limit=1&cursor=%s", chain.UID, host.UID, cursor)
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, token)
+		controllers.BagGetAll(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		page := sharedtypes.PaginatedResponse[models.Bag]{}
+		json.Unmarshal([]byte(result.Body), &page)
+		return page
+	}
+
+	page := getPage("")
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, bag1.Number, page.Items[0].Number)
+	}
+	assert.NotEmpty(t, page.NextCursor)
+
+	page = getPage(page.NextCursor)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, bag2.Number, page.Items[0].Number)
+	}
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestBulkyGetAllPaginated(t *testing.T) {
+	chain, host, token := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		IsChainAdmin: true,
+	})
+	userChainID := uint(0)
+	db.Raw(`SELECT id FROM user_chains WHERE user_id = ? AND chain_id = ?`, host.ID, chain.ID).Scan(&userChainID)
+	item1 := &models.BulkyItem{Title: "Fake couch", UserChainID: userChainID}
+	item2 := &models.BulkyItem{Title: "Fake table", UserChainID: userChainID}
+	db.Create(item1)
+	db.Create(item2)
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM bulky_items WHERE user_chain_id = ?`, userChainID)
+	})
+
+	getPage := func(cursor string) sharedtypes.PaginatedResponse[models.BulkyItem] {
+		t.Helper()
+		url := fmt.Sprintf("/v2/bulky-item/all?chain_uid=%s&user_uid=%s&limit=1&cursor=%s", chain.UID, host.UID, cursor)
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, token)
+		controllers.BulkyGetAll(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		page := sharedtypes.PaginatedResponse[models.BulkyItem]{}
+		json.Unmarshal([]byte(result.Body), &page)
+		return page
+	}
+
+	page := getPage("")
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, item1.ID, page.Items[0].ID)
+	}
+	assert.NotEmpty(t, page.NextCursor)
+
+	page = getPage(page.NextCursor)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, item2.ID, page.Items[0].ID)
+	}
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestUserGetAllOfChainPaginated(t *testing.T) {
+	chain, host, token := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		IsChainAdmin: true,
+	})
+	// pages follow the route, not the order in which members joined
+	member1, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{RouteOrderIndex: 2})
+	member2, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{RouteOrderIndex: 1})
+
+	getPage := func(cursor string) sharedtypes.PaginatedResponse[models.User] {
+		t.Helper()
+		url := fmt.Sprintf("/v2/user/all-chain?chain_uid=%s&limit=2&cursor=%s", chain.UID, cursor)
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, token)
+		controllers.UserGetAllOfChain(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		page := sharedtypes.PaginatedResponse[models.User]{}
+		json.Unmarshal([]byte(result.Body), &page)
+		return page
+	}
+
+	page := getPage("")
+	if assert.Len(t, page.Items, 2) {
+		assert.Equal(t, host.UID, page.Items[0].UID)
+		assert.Equal(t, member2.UID, page.Items[1].UID)
+	}
+	assert.NotEmpty(t, page.NextCursor)
+
+	page = getPage(page.NextCursor)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, member1.UID, page.Items[0].UID)
+	}
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestChatChannelMessageListPaginated(t *testing.T) {
+	chain, host, token := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		IsChainAdmin: true,
+	})
+	now := time.Now()
+	channel := &sharedtypes.ChatChannel{Name: "Fake channel", Color: "#000000", ChainID: chain.ID, CreatedAt: now.UnixMilli()}
+	db.Create(channel)
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM chat_channel_reads WHERE chat_channel_id = ?`, channel.ID)
+		db.Exec(`DELETE FROM chat_messages WHERE chat_channel_id = ?`, channel.ID)
+		db.Exec(`DELETE FROM chat_channels WHERE id = ?`, channel.ID)
+	})
+
+	// messages sent at the same time are ordered by id
+	older := &sharedtypes.ChatMessage{Message: "Fake older", SendByUID: host.UID, ChatChannelID: channel.ID, CreatedAt: now.Add(-time.Hour).UnixMilli()}
+	newer1 := &sharedtypes.ChatMessage{Message: "Fake newer 1", SendByUID: host.UID, ChatChannelID: channel.ID, CreatedAt: now.UnixMilli()}
+	newer2 := &sharedtypes.ChatMessage{Message: "Fake newer 2", SendByUID: host.UID, ChatChannelID: channel.ID, CreatedAt: now.UnixMilli()}
+	for _, m := range []*sharedtypes.ChatMessage{older, newer1, newer2} {
+		err := db.Create(m).Error
+		app.AssertNotErrorNow(t, err)
+	}
+
+	getPage := func(cursor string) (int, sharedtypes.ChatChannelMessageListResponse) {
+		t.Helper()
+		url := fmt.Sprintf("/v2/chat/channel/messages?chain_uid=%s&chat_channel_id=%d&limit=2&cursor=%s", chain.UID, channel.ID, cursor)
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, token)
+		controllers.ChatChannelMessageList(c)
+		result := resultFunc()
+
+		res := sharedtypes.ChatChannelMessageListResponse{}
+		json.Unmarshal([]byte(result.Body), &res)
+		return result.Response.StatusCode, res
+	}
+
+	status, page := getPage("")
+	assert.Equal(t, http.StatusOK, status)
+	if assert.Len(t, page.Messages, 2) {
+		assert.Equal(t, newer2.ID, page.Messages[0].ID)
+		assert.Equal(t, newer1.ID, page.Messages[1].ID)
+	}
+	assert.NotEmpty(t, page.NextCursor)
+
+	status, page = getPage(page.NextCursor)
+	assert.Equal(t, http.StatusOK, status)
+	if assert.Len(t, page.Messages, 1) {
+		assert.Equal(t, older.ID, page.Messages[0].ID)
+	}
+	assert.Empty(t, page.NextCursor)
+
+	t.Run("Cursor value must be a timestamp", func(t *testing.T) {
+		status, _ := getPage(cursor.Encode(cursor.Cursor{ID: newer1.ID, Value: "yesterday"}))
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}
+
+func TestMailOutboxGetAllPaginated(t *testing.T) {
+	_, tokenRoot := mocks.MockUser(t, db, 0, mocks.MockChainAndUserOptions{
+		IsRootAdmin: true,
+	})
+	mail1 := mocks.MockMail(t, db, mocks.MockMailOptions{})
+	mail2 := mocks.MockMail(t, db, mocks.MockMailOptions{})
+	db.Exec(`UPDATE mail_outbox SET to_address = ? WHERE id = ?`, mail1.ToAddress, mail2.ID)
+
+	getPage := func(cursor string) sharedtypes.PaginatedResponse[models.Mail] {
+		t.Helper()
+		reqUrl := fmt.Sprintf("/v2/admin/mails?to_address=%s&limit=1&cursor=%s", url.QueryEscape(mail1.ToAddress), cursor)
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, reqUrl, nil, tokenRoot)
+		controllers.MailOutboxGetAll(c)
+		result := resultFunc()
+		assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+		page := sharedtypes.PaginatedResponse[models.Mail]{}
+		json.Unmarshal([]byte(result.Body), &page)
+		return page
+	}
+
+	// newest first
+	page := getPage("")
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, mail2.ID, page.Items[0].ID)
+	}
+	assert.NotEmpty(t, page.NextCursor)
+
+	page = getPage(page.NextCursor)
+	if assert.Len(t, page.Items, 1) {
+		assert.Equal(t, mail1.ID, page.Items[0].ID)
+	}
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestChainGetAllPaginated(t *testing.T) {
+	mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+
+	getPage := func(cursor string) (int, sharedtypes.PaginatedResponse[sharedtypes.ChainResponse]) {
+		t.Helper()
+		c, resultFunc := mocks.MockGinContext(db, http.MethodGet, "/v2/chain/all?limit=1&cursor="+cursor, nil, "")
+		controllers.ChainGetAll(c)
+		result := resultFunc()
+
+		page := sharedtypes.PaginatedResponse[sharedtypes.ChainResponse]{}
+		json.Unmarshal([]byte(result.Body), &page)
+		return result.Response.StatusCode, page
+	}
+
+	status, page1 := getPage("")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, page1.Items, 1)
+	assert.NotEmpty(t, page1.NextCursor)
+
+	status, page2 := getPage(page1.NextCursor)
+	assert.Equal(t, http.StatusOK, status)
+	if assert.Len(t, page2.Items, 1) && len(page1.Items) == 1 {
+		assert.NotEqual(t, page1.Items[0].UID, page2.Items[0].UID)
+	}
+
+	t.Run("Invalid cursor", func(t *testing.T) {
+		status, _ := getPage("not-a-cursor")
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}
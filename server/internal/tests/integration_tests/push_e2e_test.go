@@ -0,0 +1,97 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/services"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/internal/views"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestPushNotifyRecorded(t *testing.T) {
+	chain, user, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	userMuted, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	app.PushTestRecorder.Reset()
+
+	err := models.NotificationPreferenceSet(db, userMuted.ID, sharedtypes.NotificationTypeBagTooOld, sharedtypes.NotificationChannelPush, false)
+	app.AssertNotErrorNow(t, err)
+
+	err = services.NotifyPush(db, sharedtypes.NotificationTypeBagTooOld, []string{user.UID, userMuted.UID}, views.NotificationEnumBagTooOld, "12")
+	app.AssertNotErrorNow(t, err)
+
+	messages := app.PushTestRecorder.FindByUserUID(user.UID)
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, views.NotificationEnumBagTooOld, messages[0].Notification)
+		assert.NotEmpty(t, messages[0].Title)
+	}
+	assert.Empty(t, app.PushTestRecorder.FindByUserUID(userMuted.UID))
+}
+
+func TestPushPruneOnesignalByUserUIDs(t *testing.T) {
+	_, user, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	_, userOther, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM user_onesignals WHERE user_id IN ?`, []uint{user.ID, userOther.ID})
+	})
+
+	models.UserOnesignalPut(db, user.ID, faker.UUID().V4(), faker.UUID().V4())
+	models.UserOnesignalPut(db, userOther.ID, faker.UUID().V4(), faker.UUID().V4())
+
+	err := models.UserOnesignalDeleteByUserUIDs(db, []string{user.UID})
+	app.AssertNotErrorNow(t, err)
+
+	count := -1
+	db.Raw(`SELECT COUNT(*) FROM user_onesignals WHERE user_id = ?`, user.ID).Scan(&count)
+	assert.Equal(t, 0, count)
+	db.Raw(`SELECT COUNT(*) FROM user_onesignals WHERE user_id = ?`, userOther.ID).Scan(&count)
+	assert.Equal(t, 1, count)
+}
+
+func TestUserWebPushSubscription(t *testing.T) {
+	_, user, token := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	endpoint := "https://fcm.googleapis.com/fcm/send/" + faker.UUID().V4()
+
+	put := func(endpoint string) (int, string) {
+		c, resultFunc := mocks.MockGinContext(db, http.MethodPut, "/v2/user/web-push-subscription", &gin.H{
+			"endpoint": endpoint,
+			"keys": gin.H{
+				"p256dh": "BNcRdreALRFXTkOOUHK1EtK2wtaz5Ry4YfYCA_0QTpQtUbVlUls0VJXg7A8u-Ts1XbjhazAkj7I99e8QcYP7DkM",
+				"auth":   "tBHItJI5svbpez7KI4CCXg",
+			},
+		}, token)
+		controllers.UserWebPushSubscriptionPut(c)
+		result := resultFunc()
+		return result.Response.StatusCode, result.Body
+	}
+
+	status, _ := put("http://127.0.0.1:8080/v2/admin/mail")
+	assert.Equal(t, http.StatusBadRequest, status, "only known push services")
+
+	status, body := put(endpoint)
+	assert.Equal(t, http.StatusOK, status, body)
+
+	list, err := models.UserWebPushSubscriptionGetAllByUserUIDs(db, []string{user.UID})
+	app.AssertNotErrorNow(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, endpoint, list[0].Endpoint)
+	}
+
+	c, resultFunc := mocks.MockGinContext(db, http.MethodDelete, "/v2/user/web-push-subscription", &gin.H{
+		"endpoint": endpoint,
+	}, token)
+	controllers.UserWebPushSubscriptionDelete(c)
+	result := resultFunc()
+	assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+	list, _ = models.UserWebPushSubscriptionGetAllByUserUIDs(db, []string{user.UID})
+	assert.Empty(t, list)
+}
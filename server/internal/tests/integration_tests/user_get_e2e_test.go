@@ -7,10 +7,12 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 	"github.com/the-clothing-loop/website/server/internal/app/auth"
 	"github.com/the-clothing-loop/website/server/internal/controllers"
 	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
 )
 
 func TestUserGetUID(t *testing.T) {
@@ -51,3 +53,30 @@ func TestUserGetUID(t *testing.T) {
 	}
 	assert.Truef(t, found, "chainUser of chain_uid: %s not found", chain.UID)
 }
+
+func TestUserGetByParticipantAppliesPrivacy(t *testing.T) {
+	chain, _, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		IsChainAdmin:    true,
+		RoutePrivacy:    lo.ToPtr(-1),
+		RouteOrderIndex: 0,
+	})
+	participant, token := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{RouteOrderIndex: 1})
+	member, _ := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{RouteOrderIndex: 2})
+	_, outsider, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{})
+	db.Exec(`UPDATE users SET privacy_address = ? WHERE id = ?`, sharedtypes.UserFieldPrivacyHosts, member.ID)
+
+	url := fmt.Sprintf("/v2/user?user_uid=%s&chain_uid=%s", member.UID, chain.UID)
+	c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, token)
+	controllers.UserGet(c)
+	result := resultFunc()
+	bodyJSON := result.BodyJSON()
+	assert.Equal(t, 200, result.Response.StatusCode, result.Body)
+	assert.Equal(t, "***", bodyJSON["address"])
+	assert.Equal(t, member.PhoneNumber, bodyJSON["phone_number"])
+	assert.Len(t, bodyJSON["chains"], 1)
+
+	url = fmt.Sprintf("/v2/user?user_uid=%s&chain_uid=%s", outsider.UID, chain.UID)
+	c, resultFunc = mocks.MockGinContext(db, http.MethodGet, url, nil, token)
+	controllers.UserGet(c)
+	assert.Equal(t, http.StatusUnauthorized, resultFunc().Response.StatusCode, "only fellow members are visible to %s", participant.UID)
+}
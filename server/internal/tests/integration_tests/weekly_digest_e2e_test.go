@@ -0,0 +1,61 @@
+//go:build !ci
+
+package integration_tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/controllers"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/internal/tests/mocks"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+)
+
+func TestWeeklyDigestUnreadChatMessages(t *testing.T) {
+	chain, host, _ := mocks.MockChainAndUser(t, db, mocks.MockChainAndUserOptions{
+		IsChainAdmin: true,
+	})
+	member, memberToken := mocks.MockUser(t, db, chain.ID, mocks.MockChainAndUserOptions{})
+	err := models.NotificationPreferenceSet(db, member.ID, sharedtypes.NotificationTypeWeeklyDigest, sharedtypes.NotificationChannelEmail, true)
+	app.AssertNotErrorNow(t, err)
+
+	now := time.Now()
+	channel := &sharedtypes.ChatChannel{Name: "Fake channel", Color: "#000000", ChainID: chain.ID, CreatedAt: now.UnixMilli()}
+	db.Create(channel)
+	sendMessage := func(sentBy string, ago time.Duration) {
+		db.Create(&sharedtypes.ChatMessage{Message: "Fake message", SendByUID: sentBy, ChatChannelID: channel.ID, CreatedAt: now.Add(-ago).UnixMilli()})
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM chat_channel_reads WHERE chat_channel_id = ?`, channel.ID)
+		db.Exec(`DELETE FROM chat_messages WHERE chat_channel_id = ?`, channel.ID)
+		db.Exec(`DELETE FROM chat_channels WHERE id = ?`, channel.ID)
+		db.Exec(`DELETE FROM mail_outbox WHERE to_address = ?`, *member.Email)
+	})
+
+	// older than a week, or already read by the member
+	sendMessage(host.UID, 8*24*time.Hour)
+	sendMessage(host.UID, 3*time.Hour)
+	sendMessage(host.UID, 2*time.Hour)
+
+	url := fmt.Sprintf("/v2/chat/channel/messages?chain_uid=%s&chat_channel_id=%d&start_from=%d&page=0", chain.UID, channel.ID, now.Add(-time.Hour).UnixMilli())
+	c, resultFunc := mocks.MockGinContext(db, http.MethodGet, url, nil, memberToken)
+	controllers.ChatChannelMessageList(c)
+	result := resultFunc()
+	assert.Equal(t, http.StatusOK, result.Response.StatusCode, result.Body)
+
+	// unread, except for the message sent by the member
+	sendMessage(host.UID, 30*time.Minute)
+	sendMessage(host.UID, 20*time.Minute)
+	sendMessage(member.UID, 10*time.Minute)
+
+	controllers.CronWeekly(db)
+
+	body := ""
+	db.Raw(`SELECT body FROM mail_outbox WHERE to_address = ? ORDER BY id DESC LIMIT 1`, *member.Email).Scan(&body)
+	assert.Contains(t, body, "You have 2 unread chat message(s) in your Loops.")
+}
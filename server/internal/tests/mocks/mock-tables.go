@@ -51,8 +51,11 @@ type MockEventOptions struct {
 type MockMailOptions struct {
 	CreatedAt        time.Time
 	IsErr            bool
+	Status           string
+	Attempts         int
 	MaxRetryAttempts int
-	NextRetryAttempt int
+	NextAttemptAt    *time.Time
+	IsSensitive      bool
 }
 type MockBagOptions struct {
 	BagNameOverride string
@@ -135,6 +138,10 @@ func MockUser(t *testing.T, db *gorm.DB, chainID uint, o MockChainAndUserOptions
 		)`, chainID, user.ID)
 		tx.Exec(`DELETE FROM user_chains WHERE user_id = ? OR chain_id = ?`, user.ID, chainID)
 		tx.Exec(`DELETE FROM user_tokens WHERE user_id = ?`, user.ID)
+		tx.Exec(`DELETE FROM user_calendar_tokens WHERE user_id = ?`, user.ID)
+		tx.Exec(`DELETE FROM notification_preferences WHERE user_id = ?`, user.ID)
+		tx.Exec(`DELETE FROM user_web_push_subscriptions WHERE user_id = ?`, user.ID)
+		tx.Exec(`DELETE FROM chat_channel_reads WHERE user_id = ?`, user.ID)
 		tx.Exec(`DELETE FROM users WHERE id = ?`, user.ID)
 		tx.Commit()
 	})
@@ -177,10 +184,17 @@ func MockChainAndUser(t *testing.T, db *gorm.DB, o MockChainAndUserOptions) (cha
 		slog.Error("Unable to create testChain", "err", err)
 		panic(err)
 	}
+	if err := models.ChainFacetsSync(db, chain.ID, chain.Sizes, chain.Genders); err != nil {
+		slog.Error("Unable to create testChain facets", "err", err)
+		panic(err)
+	}
 
 	// Cleanup runs FiLo
 	// So Cleanup must happen before MockUser
 	t.Cleanup(func() {
+		db.Exec(`DELETE FROM chain_announcements WHERE chain_id = ?`, chain.ID)
+		db.Exec(`DELETE FROM chain_facets WHERE chain_id = ?`, chain.ID)
+		db.Exec(`DELETE FROM location_alert_notifications WHERE chain_id = ?`, chain.ID)
 		db.Exec(`DELETE FROM chains WHERE id = ?`, chain.ID)
 	})
 
@@ -302,8 +316,11 @@ func MockMail(t *testing.T, db *gorm.DB, o MockMailOptions) (mail *models.Mail)
 		ToAddress:        faker.Person().Contact().Email,
 		Subject:          faker.Lorem().Sentence(5),
 		Body:             template.HTMLEscapeString(faker.Lorem().Paragraph(3)),
+		Status:           lo.Ternary(o.Status == "", models.MailStatusQueued, o.Status),
+		Attempts:         o.Attempts,
 		MaxRetryAttempts: o.MaxRetryAttempts,
-		NextRetryAttempt: o.NextRetryAttempt,
+		NextAttemptAt:    o.NextAttemptAt,
+		Sensitive:        o.IsSensitive,
 	}
 	if o.IsErr {
 		mail.Err = null.NewString("FakeError: Invalid "+faker.Pet().Cat(), true)
@@ -318,7 +335,8 @@ func MockMail(t *testing.T, db *gorm.DB, o MockMailOptions) (mail *models.Mail)
 	}
 
 	t.Cleanup(func() {
-		db.Exec(`DELETE FROM mail_retries WHERE id = ?`, mail.ID)
+		db.Exec(`DELETE FROM mail_attempts WHERE mail_id = ?`, mail.ID)
+		db.Exec(`DELETE FROM mail_outbox WHERE id = ?`, mail.ID)
 	})
 
 	return mail
@@ -8,11 +8,16 @@ import (
 	"fmt"
 	"html/template"
 	"log/slog"
+	"math"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/samber/lo"
 	"github.com/the-clothing-loop/website/server/internal/app"
 	"github.com/the-clothing-loop/website/server/internal/models"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
+	"gopkg.in/guregu/null.v3"
 	"gorm.io/gorm"
 
 	"github.com/gin-gonic/gin"
@@ -43,6 +48,8 @@ type EmailLayoutData struct {
 	I18nDonate              string
 	I18nAboutUs             string
 	I18nFAQ                 string
+	I18nUnsubscribe         string
+	UnsubscribeURL          string
 }
 
 //go:embed emails
@@ -118,6 +125,11 @@ func emailGenerateMessage(m *models.Mail, lng, templateName string, data any, su
 		buf := new(bytes.Buffer)
 		layoutT := emailLayoutTemplate
 		baseURL := fmt.Sprintf("%s/%s", app.Config.SITE_BASE_URL_FE, lng)
+		unsubscribeURL := m.UnsubscribeURL
+		if unsubscribeURL == "" && m.NotificationType != "" {
+			unsubscribeURL = app.UnsubscribeURL(m.ToAddress, m.NotificationType)
+			m.UnsubscribeURL = unsubscribeURL
+		}
 		err := layoutT.Execute(buf, EmailLayoutData{
 			RTL:                     lng == "he" || lng == "ar",
 			Subject:                 subject,
@@ -129,6 +141,8 @@ func emailGenerateMessage(m *models.Mail, lng, templateName string, data any, su
 			I18nDonate:              emailsTranslations[lng]["layout_donate"],
 			I18nAboutUs:             emailsTranslations[lng]["layout_about_us"],
 			I18nFAQ:                 emailsTranslations[lng]["layout_faq"],
+			I18nUnsubscribe:         emailsTranslations[lng]["layout_unsubscribe"],
+			UnsubscribeURL:          unsubscribeURL,
 		})
 		if err != nil {
 			return err
@@ -203,6 +217,37 @@ func EmailAnAdminDeniedYourJoinRequest(db *gorm.DB, lng,
 	return app.MailSend(db, m)
 }
 
+// The body is plain text, every line becomes a paragraph
+func EmailAnnouncement(db *gorm.DB, lng,
+	name,
+	email,
+	hostName,
+	chainName,
+	subject,
+	body string,
+) error {
+	lng = getI18n(lng)
+	m := app.MailCreate()
+	m.ToName = name
+	m.ToAddress = email
+	m.NotificationType = sharedtypes.NotificationTypeAnnouncement
+	paragraphs := lo.Filter(strings.Split(body, "\n"), func(p string, _ int) bool {
+		return strings.TrimSpace(p) != ""
+	})
+	err := emailGenerateMessage(m, lng, "announcement", gin.H{
+		"Name":       name,
+		"HostName":   hostName,
+		"ChainName":  chainName,
+		"Subject":    subject,
+		"Paragraphs": paragraphs,
+	}, chainName, subject)
+	if err != nil {
+		return err
+	}
+
+	return app.MailSend(db, m)
+}
+
 type EmailApproveReminderItem struct {
 	Name        string `gorm:"name"`
 	Email       string `gorm:"email"`
@@ -221,6 +266,7 @@ func EmailApproveReminder(db *gorm.DB, lng,
 	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
 	m.ToName = name
 	m.ToAddress = email
+	m.NotificationType = sharedtypes.NotificationTypeHostUpdates
 	err := emailGenerateMessage(m, lng, "approve_reminder", gin.H{
 		"Name":      name,
 		"BaseURL":   app.Config.SITE_BASE_URL_FE,
@@ -233,18 +279,139 @@ func EmailApproveReminder(db *gorm.DB, lng,
 	return app.MailSend(db, m)
 }
 
-func EmailContactConfirmation(c *gin.Context, db *gorm.DB,
+// First step of the newsletter double opt-in
+func EmailConfirmNewsletter(c *gin.Context, db *gorm.DB,
 	name,
 	email,
-	message string,
+	token string,
 ) error {
 	i18n := getI18nGin(c)
 	m := app.MailCreate()
 	m.ToName = name
 	m.ToAddress = email
+	err := emailGenerateMessage(m, i18n, "confirm_newsletter", gin.H{
+		"Name":       name,
+		"ConfirmURL": fmt.Sprintf("%s/v2/contact/newsletter/confirm?token=%s", app.Config.SITE_BASE_URL_API, token),
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.MailSend(db, m)
+}
+
+func EmailConfirmLocationAlert(db *gorm.DB, lng,
+	name,
+	email string,
+	radius float32,
+	token string,
+) error {
+	lng = getI18n(lng)
+	m := app.MailCreate()
+	m.ToName = name
+	m.ToAddress = email
+	err := emailGenerateMessage(m, lng, "confirm_location_alert", gin.H{
+		"Name":       name,
+		"Radius":     strconv.FormatFloat(float64(radius), 'f', -1, 32),
+		"ConfirmURL": fmt.Sprintf("%s/v2/location-alert/confirm?token=%s", app.Config.SITE_BASE_URL_API, token),
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.MailSend(db, m)
+}
+
+// Starts the conversation with the sender, replies to the ticket are threaded under this email
+func EmailContactConfirmation(db *gorm.DB, ticket *sharedtypes.ContactTicket) error {
+	i18n := getI18n(ticket.I18n)
+	m := app.MailCreate()
+	m.ToName = ticket.Name
+	m.ToAddress = ticket.Email
+	m.MessageID = null.NewString(ticket.ThreadMessageID, ticket.ThreadMessageID != "")
 	err := emailGenerateMessage(m, i18n, "contact_confirmation", gin.H{
-		"Name":    name,
-		"Message": message,
+		"Name":    ticket.Name,
+		"Message": ticket.Message,
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.MailSend(db, m)
+}
+
+// Returns the mail so that the reply can refer to it, nil if the address is undeliverable
+func EmailContactTicketReply(db *gorm.DB, ticket *sharedtypes.ContactTicket, authorName, message string) (*models.Mail, error) {
+	i18n := getI18n(ticket.I18n)
+	m := app.MailCreate()
+	m.ToName = ticket.Name
+	m.ToAddress = ticket.Email
+	m.MessageID = null.StringFrom(app.MailNewMessageID())
+	if ticket.ThreadMessageID != "" {
+		m.InReplyTo = null.StringFrom(ticket.ThreadMessageID)
+		m.References = null.StringFrom(ticket.ThreadMessageID)
+	}
+	paragraphs := lo.Filter(strings.Split(message, "\n"), func(p string, _ int) bool {
+		return strings.TrimSpace(p) != ""
+	})
+	err := emailGenerateMessage(m, i18n, "contact_ticket_reply", gin.H{
+		"Name":       ticket.Name,
+		"AuthorName": authorName,
+		"Paragraphs": paragraphs,
+		"Message":    ticket.Message,
+	}, emailsTranslations[i18n]["header_contact_confirmation"])
+	if err != nil {
+		return nil, err
+	}
+
+	err = app.MailSend(db, m)
+	if err != nil {
+		return nil, err
+	}
+	if m.ID == 0 {
+		return nil, nil
+	}
+	return m, nil
+}
+
+// Sent to the members that moved to another loop after a merge or split
+func EmailLoopMoved(db *gorm.DB, lng,
+	name,
+	email,
+	fromChainName,
+	toChainName string,
+	isMerge bool,
+) error {
+	lng = getI18n(lng)
+	m := app.MailCreate()
+	m.ToName = name
+	m.ToAddress = email
+	err := emailGenerateMessage(m, lng, "loop_moved", gin.H{
+		"Name":          name,
+		"FromChainName": fromChainName,
+		"ToChainName":   toChainName,
+		"IsMerge":       isMerge,
+	}, toChainName)
+	if err != nil {
+		return err
+	}
+
+	return app.MailSend(db, m)
+}
+
+// Leave chainName empty if the pause was not limited to one loop
+func EmailPauseEnded(db *gorm.DB, lng,
+	name,
+	email,
+	chainName string,
+) error {
+	lng = getI18n(lng)
+	m := app.MailCreate()
+	m.ToName = name
+	m.ToAddress = email
+	err := emailGenerateMessage(m, lng, "pause_ended", gin.H{
+		"Name":      name,
+		"ChainName": chainName,
 	})
 	if err != nil {
 		return err
@@ -295,6 +462,60 @@ func EmailDoYouWantToBeHost(db *gorm.DB, lng,
 	return app.MailSend(db, m)
 }
 
+func EmailEventChanged(db *gorm.DB, lng,
+	name,
+	email,
+	eventName,
+	eventUID,
+	date,
+	address string,
+) error {
+	lng = getI18n(lng)
+	m := app.MailCreate()
+	m.ToName = name
+	m.ToAddress = email
+	m.NotificationType = sharedtypes.NotificationTypeEventChanged
+	err := emailGenerateMessage(m, lng, "event_changed", gin.H{
+		"Name":      name,
+		"EventName": eventName,
+		"Date":      date,
+		"Address":   address,
+		"EventURL":  fmt.Sprintf("%s/%s/events/%s", app.Config.SITE_BASE_URL_FE, lng, eventUID),
+	}, eventName)
+	if err != nil {
+		return err
+	}
+
+	return app.MailSend(db, m)
+}
+
+func EmailEventReminder(db *gorm.DB, lng,
+	name,
+	email,
+	eventName,
+	eventUID,
+	date,
+	address string,
+) error {
+	lng = getI18n(lng)
+	m := app.MailCreate()
+	m.ToName = name
+	m.ToAddress = email
+	m.NotificationType = sharedtypes.NotificationTypeEventReminder
+	err := emailGenerateMessage(m, lng, "event_reminder", gin.H{
+		"Name":      name,
+		"EventName": eventName,
+		"Date":      date,
+		"Address":   address,
+		"EventURL":  fmt.Sprintf("%s/%s/events/%s", app.Config.SITE_BASE_URL_FE, lng, eventUID),
+	}, eventName)
+	if err != nil {
+		return err
+	}
+
+	return app.MailSend(db, m)
+}
+
 func EmailIsYourLoopStillActive(db *gorm.DB, lng,
 	name,
 	email,
@@ -308,6 +529,7 @@ func EmailIsYourLoopStillActive(db *gorm.DB, lng,
 	m.ToAddress = email
 	err := emailGenerateMessage(m, lng, "is_your_loop_still_active", gin.H{
 		"Name":            name,
+		"BaseURL":         app.Config.SITE_BASE_URL_FE,
 		"ParticipantName": participantName,
 		"ChainName":       chainName,
 	})
@@ -329,6 +551,8 @@ func EmailLoginVerification(c *gin.Context, db *gorm.DB,
 	m := app.MailCreate()
 	m.ToName = name
 	m.ToAddress = email
+	m.AllowUndeliverable = true
+	m.Sensitive = true
 
 	// This is a hack to add the chain param to the url
 	// Changing this in the template would be more work in combination with Crowdin
@@ -387,6 +611,7 @@ func EmailPoke(db *gorm.DB, lng,
 	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
 	m.ToName = name
 	m.ToAddress = email
+	m.NotificationType = sharedtypes.NotificationTypePoke
 	err := emailGenerateMessage(m, lng, "poke", gin.H{
 		"Name":            name,
 		"ChainName":       chainName,
@@ -410,6 +635,8 @@ func EmailRegisterVerification(c *gin.Context, db *gorm.DB,
 	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
 	m.ToName = name
 	m.ToAddress = email
+	m.AllowUndeliverable = true
+	m.Sensitive = true
 
 	emailBase64 := base64.StdEncoding.EncodeToString([]byte(email))
 	token += "&u=" + emailBase64
@@ -428,6 +655,66 @@ func EmailRegisterVerification(c *gin.Context, db *gorm.DB,
 	return app.MailSend(db, m)
 }
 
+// Leave token empty for members that already have an account
+func EmailImportedIntoLoop(db *gorm.DB, lng,
+	name,
+	email,
+	hostName,
+	chainName,
+	chainUID,
+	token string,
+) error {
+	lng = getI18n(lng)
+	m := app.MailCreate()
+	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.ToName = name
+	m.ToAddress = email
+	m.Sensitive = true
+
+	token += "&u=" + base64.StdEncoding.EncodeToString([]byte(email)) + "&c=" + chainUID
+
+	err := emailGenerateMessage(m, lng, "imported_into_loop", gin.H{
+		"Name":      name,
+		"HostName":  hostName,
+		"ChainName": chainName,
+		"BaseURL":   app.Config.SITE_BASE_URL_FE,
+		"Token":     template.URL(token),
+	}, chainName)
+	if err != nil {
+		return err
+	}
+	return app.MailSend(db, m)
+}
+
+// Asks someone with an account to join a loop, the invite link adds them once they accept
+func EmailInvitedToLoop(db *gorm.DB, lng,
+	name,
+	email,
+	hostName,
+	chainName,
+	inviteURL string,
+	days int,
+) error {
+	lng = getI18n(lng)
+	m := app.MailCreate()
+	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.ToName = name
+	m.ToAddress = email
+	m.Sensitive = true
+
+	err := emailGenerateMessage(m, lng, "invited_to_loop", gin.H{
+		"Name":      name,
+		"HostName":  hostName,
+		"ChainName": chainName,
+		"InviteURL": template.URL(inviteURL),
+		"Days":      days,
+	}, chainName)
+	if err != nil {
+		return err
+	}
+	return app.MailSend(db, m)
+}
+
 func EmailSomeoneIsInterestedInJoiningYourLoop(db *gorm.DB, lng,
 	adminEmail,
 	adminName,
@@ -444,6 +731,7 @@ func EmailSomeoneIsInterestedInJoiningYourLoop(db *gorm.DB, lng,
 	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
 	m.ToName = adminName
 	m.ToAddress = adminEmail
+	m.NotificationType = sharedtypes.NotificationTypeHostUpdates
 
 	sizesHtml := ""
 	{
@@ -504,6 +792,7 @@ func EmailSomeoneLeftLoop(db *gorm.DB, lng,
 	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
 	m.ToName = name
 	m.ToAddress = email
+	m.NotificationType = sharedtypes.NotificationTypeHostUpdates
 	err := emailGenerateMessage(m, lng, "someone_left_loop", gin.H{
 		"Name":             name,
 		"ParticipantName":  participantName,
@@ -516,6 +805,34 @@ func EmailSomeoneLeftLoop(db *gorm.DB, lng,
 	return app.MailSend(db, m)
 }
 
+type EmailParticipant struct {
+	Name  string
+	Email string
+}
+
+func EmailMembersLeftLoop(db *gorm.DB, lng,
+	name,
+	email,
+	chainName string,
+	participants []EmailParticipant,
+) error {
+	lng = getI18n(lng)
+	m := app.MailCreate()
+	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
+	m.ToName = name
+	m.ToAddress = email
+	m.NotificationType = sharedtypes.NotificationTypeHostUpdates
+	err := emailGenerateMessage(m, lng, "members_left_loop", gin.H{
+		"Name":         name,
+		"ChainName":    chainName,
+		"Participants": participants,
+	})
+	if err != nil {
+		return err
+	}
+	return app.MailSend(db, m)
+}
+
 func EmailSomeoneWaitingToBeAccepted(db *gorm.DB, lng,
 	name,
 	email,
@@ -527,6 +844,7 @@ func EmailSomeoneWaitingToBeAccepted(db *gorm.DB, lng,
 	m.MaxRetryAttempts = models.MAIL_RETRY_TWO_DAYS
 	m.ToName = name
 	m.ToAddress = email
+	m.NotificationType = sharedtypes.NotificationTypeHostUpdates
 	err := emailGenerateMessage(m, lng, "someone_waiting_to_be_accepted", gin.H{
 		"Name":            name,
 		"ParticipantName": participantName,
@@ -546,6 +864,7 @@ func EmailSubscribeToNewsletter(c *gin.Context, db *gorm.DB,
 	m := app.MailCreate()
 	m.ToName = name
 	m.ToAddress = email
+	m.UnsubscribeURL = app.NewsletterUnsubscribeURL(email)
 	err := emailGenerateMessage(m, i18n, "subscribed_to_newsletter", gin.H{"Name": name})
 	if err != nil {
 		return err
@@ -554,6 +873,92 @@ func EmailSubscribeToNewsletter(c *gin.Context, db *gorm.DB,
 	return app.MailSend(db, m)
 }
 
+// Sent to a confirmed location alert when a loop opens within its radius, distance is in kilometers
+func EmailLoopOpenedNearYou(db *gorm.DB, lng,
+	name,
+	email,
+	chainName,
+	chainUID string,
+	distance float64,
+) error {
+	lng = getI18n(lng)
+	m := app.MailCreate()
+	m.ToName = name
+	m.ToAddress = email
+	m.UnsubscribeURL = app.LocationAlertUnsubscribeURL(email)
+	err := emailGenerateMessage(m, lng, "loop_opened_near_you", gin.H{
+		"Name":      name,
+		"ChainName": chainName,
+		"Distance":  strconv.FormatFloat(math.Max(1, math.Round(distance)), 'f', 0, 64),
+		"ChainURL":  fmt.Sprintf("%s/%s/loops/users/signup/?chain=%s", app.Config.SITE_BASE_URL_FE, lng, chainUID),
+	}, chainName)
+	if err != nil {
+		return err
+	}
+
+	return app.MailSend(db, m)
+}
+
+type EmailWeeklyDigestData struct {
+	PendingApprovals       []EmailWeeklyDigestApproval
+	Bags                   []EmailWeeklyDigestBag
+	BulkyItems             []EmailWeeklyDigestBulkyItem
+	Events                 []EmailWeeklyDigestEvent
+	UnreadChatMessageCount int
+}
+
+type EmailWeeklyDigestApproval struct {
+	ChainName string `gorm:"chain_name"`
+	Count     int    `gorm:"count"`
+}
+
+type EmailWeeklyDigestBag struct {
+	Number    string `gorm:"number"`
+	ChainName string `gorm:"chain_name"`
+	Days      int    `gorm:"days"`
+}
+
+type EmailWeeklyDigestBulkyItem struct {
+	Title     string `gorm:"title"`
+	ChainName string `gorm:"chain_name"`
+}
+
+type EmailWeeklyDigestEvent struct {
+	Name string
+	Date string
+	URL  string
+}
+
+func (d *EmailWeeklyDigestData) IsEmpty() bool {
+	return len(d.PendingApprovals) == 0 && len(d.Bags) == 0 && len(d.BulkyItems) == 0 && len(d.Events) == 0 && d.UnreadChatMessageCount == 0
+}
+
+func EmailWeeklyDigest(db *gorm.DB, lng,
+	name,
+	email string,
+	digest *EmailWeeklyDigestData,
+) error {
+	lng = getI18n(lng)
+	m := app.MailCreate()
+	m.ToName = name
+	m.ToAddress = email
+	m.NotificationType = sharedtypes.NotificationTypeWeeklyDigest
+	err := emailGenerateMessage(m, lng, "weekly_digest", gin.H{
+		"Name":                   name,
+		"BaseURL":                app.Config.SITE_BASE_URL_FE,
+		"PendingApprovals":       digest.PendingApprovals,
+		"Bags":                   digest.Bags,
+		"BulkyItems":             digest.BulkyItems,
+		"Events":                 digest.Events,
+		"UnreadChatMessageCount": digest.UnreadChatMessageCount,
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.MailSend(db, m)
+}
+
 func EmailYouSignedUpForLoop(db *gorm.DB, lng,
 	name,
 	email,
@@ -0,0 +1,327 @@
+package views
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/the-clothing-loop/website/server/internal/app"
+	"github.com/the-clothing-loop/website/server/internal/models"
+	"gorm.io/gorm"
+)
+
+type emailPreviewSample struct {
+	Data        gin.H
+	SubjectArgs []any
+}
+
+// Sample data for every English template, each key used by any locale must be present
+var emailPreviewSamples = map[string]emailPreviewSample{
+	"account_deleted_successfully": {Data: gin.H{"Name": "Jane"}},
+	"an_admin_approved_your_join_request": {Data: gin.H{
+		"Name":      "Jane",
+		"ChainName": "Amsterdam Oost",
+	}},
+	"an_admin_denied_your_join_request": {Data: gin.H{
+		"Name":      "Jane",
+		"ChainName": "Amsterdam Oost",
+		"Reason":    "too_far_away",
+	}},
+	"announcement": {Data: gin.H{
+		"Name":       "Jane",
+		"HostName":   "Sam",
+		"ChainName":  "Amsterdam Oost",
+		"Subject":    "Swap this Sunday",
+		"Paragraphs": []string{"We are meeting at the community center at 14:00.", "Bring your bags!"},
+	}, SubjectArgs: []any{"Amsterdam Oost", "Swap this Sunday"}},
+	"approve_reminder": {Data: gin.H{
+		"Name":    "Jane",
+		"BaseURL": "https://www.clothingloop.org",
+		"Approvals": []*EmailApproveReminderItem{
+			{Name: "Sam", Email: "sam@example.com", ChainName: "Amsterdam Oost"},
+		},
+	}},
+	"confirm_location_alert": {Data: gin.H{
+		"Name":       "Jane",
+		"Radius":     10,
+		"ConfirmURL": "https://www.clothingloop.org/api/v2/location-alert/confirm?token=0000",
+	}},
+	"confirm_newsletter": {Data: gin.H{
+		"Name":       "Jane",
+		"ConfirmURL": "https://www.clothingloop.org/api/v2/contact/newsletter/confirm?token=0000",
+	}},
+	"contact_confirmation": {Data: gin.H{
+		"Name":    "Jane",
+		"Message": "Hello, how do I start a Loop?",
+	}},
+	"contact_received": {Data: gin.H{
+		"Name":    "Jane",
+		"Email":   "jane@example.com",
+		"Message": "Hello, how do I start a Loop?",
+	}, SubjectArgs: []any{"Jane"}},
+	"contact_ticket_reply": {Data: gin.H{
+		"Name":       "Jane",
+		"AuthorName": "Sam",
+		"Paragraphs": []string{"Thank you for reaching out!", "You can start a Loop from the website."},
+		"Message":    "Hello, how do I start a Loop?",
+	}, SubjectArgs: []any{"Thank you for contacting the Clothing Loop"}},
+	"do_you_want_to_be_host": {Data: gin.H{
+		"Name":      "Jane",
+		"ChainName": "Amsterdam Oost",
+	}},
+	"event_changed": {Data: gin.H{
+		"Name":      "Jane",
+		"EventName": "Spring swap",
+		"EventURL":  "https://www.clothingloop.org/en/events/00000000-0000-0000-0000-000000000000",
+		"Date":      "2024-05-04 14:00 UTC",
+		"Address":   "Dam 1, Amsterdam",
+	}, SubjectArgs: []any{"Spring swap"}},
+	"event_reminder": {Data: gin.H{
+		"Name":      "Jane",
+		"EventName": "Spring swap",
+		"EventURL":  "https://www.clothingloop.org/en/events/00000000-0000-0000-0000-000000000000",
+		"Date":      "2024-05-04 14:00 UTC",
+		"Address":   "Dam 1, Amsterdam",
+	}, SubjectArgs: []any{"Spring swap"}},
+	"imported_into_loop": {Data: gin.H{
+		"Name":      "Jane",
+		"HostName":  "Sam",
+		"ChainName": "Amsterdam Oost",
+		"BaseURL":   "https://www.clothingloop.org",
+		"Token":     "123456",
+	}, SubjectArgs: []any{"Amsterdam Oost"}},
+	"invited_to_loop": {Data: gin.H{
+		"Name":      "Jane",
+		"HostName":  "Sam",
+		"ChainName": "Amsterdam Oost",
+		"InviteURL": "https://www.clothingloop.org/loops/join/?invite=abc123",
+		"Days":      14,
+	}, SubjectArgs: []any{"Amsterdam Oost"}},
+	"is_your_loop_still_active": {Data: gin.H{
+		"Name":            "Jane",
+		"BaseURL":         "https://www.clothingloop.org",
+		"ChainName":       "Amsterdam Oost",
+		"ParticipantName": "Sam",
+	}},
+	"login_verification": {Data: gin.H{
+		"Name":    "Jane",
+		"BaseURL": "https://www.clothingloop.org",
+		"Token":   "123456",
+		"IsApp":   false,
+	}, SubjectArgs: []any{"123456"}},
+	"loop_is_deleted": {Data: gin.H{
+		"Name":      "Jane",
+		"ChainName": "Amsterdam Oost",
+		"IsPending": false,
+	}},
+	"loop_moved": {Data: gin.H{
+		"Name":          "Jane",
+		"FromChainName": "Amsterdam Oost",
+		"ToChainName":   "Amsterdam Centrum",
+		"IsMerge":       true,
+	}, SubjectArgs: []any{"Amsterdam Centrum"}},
+	"loop_opened_near_you": {Data: gin.H{
+		"Name":      "Jane",
+		"ChainName": "Amsterdam Oost",
+		"Distance":  3,
+		"ChainURL":  "https://www.clothingloop.org/en/loops/users/signup/?chain=0000",
+	}, SubjectArgs: []any{"Amsterdam Oost"}},
+	"members_left_loop": {Data: gin.H{
+		"Name":      "Jane",
+		"ChainName": "Amsterdam Oost",
+		"Participants": []EmailParticipant{
+			{Name: "Sam", Email: "sam@example.com"},
+			{Name: "Alex", Email: "alex@example.com"},
+		},
+	}},
+	"pause_ended": {Data: gin.H{
+		"Name":      "Jane",
+		"ChainName": "Amsterdam Oost",
+	}},
+	"poke": {Data: gin.H{
+		"Name":            "Jane",
+		"ChainName":       "Amsterdam Oost",
+		"ParticipantName": "Sam",
+	}},
+	"register_verification": {Data: gin.H{
+		"Name":    "Jane",
+		"BaseURL": "https://www.clothingloop.org",
+		"Token":   "123456",
+	}},
+	"someone_is_interested_in_joining_your_loop": {Data: gin.H{
+		"Name":      "Jane",
+		"ChainName": "Amsterdam Oost",
+		"Participant": gin.H{
+			"Name":    "Sam",
+			"Email":   "sam@example.com",
+			"Phone":   "+31 6 12345678",
+			"Address": "Dam 1, Amsterdam",
+			"Sizes":   template.HTML("<ul><li>Women's clothing</li></ul>"),
+		},
+	}},
+	"someone_left_loop": {Data: gin.H{
+		"Name":             "Jane",
+		"ChainName":        "Amsterdam Oost",
+		"ParticipantName":  "Sam",
+		"ParticipantEmail": "sam@example.com",
+	}},
+	"someone_waiting_to_be_accepted": {Data: gin.H{
+		"Name":            "Jane",
+		"ChainName":       "Amsterdam Oost",
+		"ParticipantName": "Sam",
+	}},
+	"subscribed_to_newsletter": {Data: gin.H{"Name": "Jane"}},
+	"weekly_digest": {Data: gin.H{
+		"Name":    "Jane",
+		"BaseURL": "https://www.clothingloop.org",
+		"PendingApprovals": []EmailWeeklyDigestApproval{
+			{ChainName: "Amsterdam Oost", Count: 2},
+		},
+		"Bags": []EmailWeeklyDigestBag{
+			{Number: "12", ChainName: "Amsterdam Oost", Days: 9},
+		},
+		"BulkyItems": []EmailWeeklyDigestBulkyItem{
+			{Title: "Sewing machine", ChainName: "Amsterdam Oost"},
+		},
+		"Events": []EmailWeeklyDigestEvent{
+			{Name: "Spring swap", Date: "2024-05-04 14:00 UTC", URL: "https://www.clothingloop.org/en/events/00000000-0000-0000-0000-000000000000"},
+		},
+		"UnreadChatMessageCount": 5,
+	}},
+	"you_created_a_new_loop": {Data: gin.H{
+		"Name":          "Jane",
+		"ChainName":     "Amsterdam Oost",
+		"ToolkitURL":    "https://www.clothingloop.org/en/toolkit",
+		"LoopSignupURL": "https://www.clothingloop.org/en/loops/00000000-0000-0000-0000-000000000000/users/signup",
+	}},
+	"you_signed_up_for_loop": {Data: gin.H{
+		"Name":      "Jane",
+		"ChainName": "Amsterdam Oost",
+	}, SubjectArgs: []any{"Amsterdam Oost"}},
+	"your_loop_deleted_next_month": {Data: gin.H{
+		"Name":      "Jane",
+		"ChainName": "Amsterdam Oost",
+		"ChainUID":  "00000000-0000-0000-0000-000000000000",
+	}},
+	"your_loop_deleted_next_week": {Data: gin.H{
+		"Name":      "Jane",
+		"ChainName": "Amsterdam Oost",
+		"ChainUID":  "00000000-0000-0000-0000-000000000000",
+	}},
+}
+
+// Every supported email language, English first
+func EmailPreviewLanguages() []string {
+	langs := []string{"en"}
+	for _, l := range lang {
+		if !slices.Contains(langs, l) {
+			langs = append(langs, l)
+		}
+	}
+	return langs
+}
+
+// The names of the English templates, English is the source for all other languages
+func EmailPreviewTemplates() []string {
+	return emailTemplateNames("en")
+}
+
+func emailTemplateNames(lng string) []string {
+	entries, _ := fs.ReadDir(emailsFS, path.Join("emails", lng))
+	names := []string{}
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".gohtml"); ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Renders the template with sample data, exactly as it would be sent
+func EmailPreview(lng, templateName string) (*models.Mail, error) {
+	if !slices.Contains(EmailPreviewLanguages(), lng) {
+		return nil, fmt.Errorf("Language %s is not supported", lng)
+	}
+	sample, ok := emailPreviewSamples[templateName]
+	if !ok {
+		return nil, fmt.Errorf("Template %s does not exist", templateName)
+	}
+
+	m := app.MailCreate()
+	err := emailGenerateMessage(m, lng, templateName, sample.Data, sample.SubjectArgs...)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Sends the preview to the given address, the subject is marked as a preview
+func EmailPreviewSend(db *gorm.DB, lng, templateName, name, email string) error {
+	m, err := EmailPreview(lng, templateName)
+	if err != nil {
+		return err
+	}
+	m.ToName = name
+	m.ToAddress = email
+	m.Subject = "[Preview] " + m.Subject
+
+	return app.MailSend(db, m)
+}
+
+// Lists every problem found per language: templates or translations missing compared to English,
+// templates that do not parse and templates that use data the sender does not provide
+func EmailPreviewProblems() map[string][]string {
+	result := map[string][]string{}
+	enTemplates := EmailPreviewTemplates()
+
+	for _, lng := range EmailPreviewLanguages() {
+		problems := []string{}
+
+		for key, en := range emailsTranslations["en"] {
+			tr, ok := emailsTranslations[lng][key]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("translation %s is missing", key))
+			} else if strings.Count(tr, "%s") != strings.Count(en, "%s") {
+				problems = append(problems, fmt.Sprintf("translation %s has a different number of values than English", key))
+			}
+		}
+
+		strict, err := template.New("").Option("missingkey=error").ParseFS(emailsFS, fmt.Sprintf("emails/%s/*.gohtml", lng))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("unable to parse templates: %s", err))
+		}
+		lngTemplates := emailTemplateNames(lng)
+		for _, name := range enTemplates {
+			if !slices.Contains(lngTemplates, name) {
+				problems = append(problems, fmt.Sprintf("template %s is missing", name))
+				continue
+			}
+			sample, ok := emailPreviewSamples[name]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("template %s has no sample data", name))
+				continue
+			}
+			if strict == nil {
+				continue
+			}
+			err := strict.ExecuteTemplate(io.Discard, name+".gohtml", sample.Data)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("template %s: %s", name, err))
+			}
+			subject := fmt.Sprintf(emailsTranslations[lng]["header_"+name], sample.SubjectArgs...)
+			if strings.Contains(subject, "%!") {
+				problems = append(problems, fmt.Sprintf("subject of %s does not match its values: %s", name, subject))
+			}
+		}
+
+		slices.Sort(problems)
+		result[lng] = problems
+	}
+	return result
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/the-clothing-loop/website/server/internal/models"
 	BTagChecker "github.com/the-clothing-loop/website/server/pkg/btagchecker"
+	"github.com/the-clothing-loop/website/server/sharedtypes"
 )
 
 var validate = validator.New()
@@ -73,6 +74,18 @@ func TestEmailFormattingByLanguage(t *testing.T) {
 			DataExpected: []string{"Name", "ChainName"},
 			Args:         []any{},
 		},
+		{
+			Name: "announcement",
+			Data: map[string]any{
+				"Name":       faker.Person().Name(),
+				"HostName":   faker.Person().Name(),
+				"ChainName":  faker.Company().Name(),
+				"Subject":    faker.Lorem().Sentence(4),
+				"Paragraphs": []any{faker.Lorem().Sentence(8), faker.Lorem().Sentence(8)},
+			},
+			DataExpected: []string{"Name", "HostName", "ChainName", "Subject", "Paragraphs[0]", "Paragraphs[1]"},
+			Args:         []any{faker.Company().Name(), faker.Lorem().Sentence(4)},
+		},
 		{
 			Name: "approve_reminder",
 			Data: map[string]any{
@@ -86,6 +99,23 @@ func TestEmailFormattingByLanguage(t *testing.T) {
 			DataExpected: []string{"Name", "BaseURL", "Approvals[0].Name", "Approvals[0].ChainName"},
 			Args:         []any{},
 		},
+		{
+			Name: "confirm_location_alert",
+			Data: map[string]any{
+				"Name":       faker.Person().Name(),
+				"Radius":     faker.IntBetween(1, 100),
+				"ConfirmURL": faker.Internet().URL(),
+			},
+			DataExpected: []string{"Name", "Radius", "ConfirmURL"},
+		},
+		{
+			Name: "confirm_newsletter",
+			Data: map[string]any{
+				"Name":       faker.Person().Name(),
+				"ConfirmURL": faker.Internet().URL(),
+			},
+			DataExpected: []string{"Name", "ConfirmURL"},
+		},
 		{
 			Name: "contact_confirmation",
 			Data: map[string]any{
@@ -105,6 +135,17 @@ func TestEmailFormattingByLanguage(t *testing.T) {
 			DataExpected: []string{"Name", "Email", "Message"},
 			Args:         []any{faker.Person().Name()},
 		},
+		{
+			Name: "contact_ticket_reply",
+			Data: map[string]any{
+				"Name":       faker.Person().Name(),
+				"AuthorName": faker.Person().Name(),
+				"Paragraphs": []any{faker.Lorem().Sentence(8)},
+				"Message":    strings.Join(faker.Lorem().Words(5), " "),
+			},
+			DataExpected: []string{"Name", "AuthorName", "Paragraphs[0]", "Message"},
+			Args:         []any{faker.Lorem().Sentence(3)},
+		},
 		{
 			Name: "do_you_want_to_be_host",
 			Data: map[string]any{
@@ -115,6 +156,54 @@ func TestEmailFormattingByLanguage(t *testing.T) {
 			DataExpected: []string{"Name", "ChainName"},
 			Args:         []any{},
 		},
+		{
+			Name: "event_changed",
+			Data: map[string]any{
+				"Name":      faker.Person().Name(),
+				"EventName": faker.Company().Name(),
+				"Date":      "2024-05-04 14:00 UTC",
+				"Address":   faker.Address().Address(),
+				"EventURL":  faker.Internet().URL(),
+			},
+			DataExpected: []string{"Name", "EventName", "Date", "Address"},
+			Args:         []any{faker.Company().Name()},
+		},
+		{
+			Name: "event_reminder",
+			Data: map[string]any{
+				"Name":      faker.Person().Name(),
+				"EventName": faker.Company().Name(),
+				"Date":      "2024-05-04 14:00 UTC",
+				"Address":   faker.Address().Address(),
+				"EventURL":  faker.Internet().URL(),
+			},
+			DataExpected: []string{"Name", "EventName", "Date", "Address"},
+			Args:         []any{faker.Company().Name()},
+		},
+		{
+			Name: "imported_into_loop",
+			Data: map[string]any{
+				"Name":      faker.Person().Name(),
+				"HostName":  faker.Person().Name(),
+				"ChainName": faker.Company().Name(),
+				"BaseURL":   faker.Internet().URL(),
+				"Token":     strconv.Itoa(faker.IntBetween(10000000, 99999999)),
+			},
+			DataExpected: []string{"Name", "HostName", "ChainName", "Token"},
+			Args:         []any{faker.Company().Name()},
+		},
+		{
+			Name: "invited_to_loop",
+			Data: map[string]any{
+				"Name":      faker.Person().Name(),
+				"HostName":  faker.Person().Name(),
+				"ChainName": faker.Company().Name(),
+				"InviteURL": faker.Internet().URL(),
+				"Days":      14,
+			},
+			DataExpected: []string{"Name", "HostName", "ChainName", "InviteURL"},
+			Args:         []any{faker.Company().Name()},
+		},
 		{
 			Name: "is_your_loop_still_active",
 			Data: map[string]any{
@@ -155,6 +244,50 @@ func TestEmailFormattingByLanguage(t *testing.T) {
 			DataExpected: []string{"Name", "ChainName"},
 			Args:         []any{},
 		},
+		{
+			Name: "loop_moved",
+			Data: map[string]any{
+				"Name":          faker.Person().Name(),
+				"FromChainName": faker.Company().Name(),
+				"ToChainName":   faker.Company().Name(),
+				"IsMerge":       true,
+			},
+			DataExpected: []string{"Name", "FromChainName", "ToChainName"},
+			Args:         []any{faker.Company().Name()},
+		},
+		{
+			Name: "loop_opened_near_you",
+			Data: map[string]any{
+				"Name":      faker.Person().Name(),
+				"ChainName": faker.Company().Name(),
+				"Distance":  faker.IntBetween(1, 100),
+				"ChainURL":  faker.Internet().URL(),
+			},
+			DataExpected: []string{"Name", "ChainName", "Distance", "ChainURL"},
+			Args:         []any{faker.Company().Name()},
+		},
+		{
+			Name: "members_left_loop",
+			Data: map[string]any{
+				"Name":      faker.Person().Name(),
+				"ChainName": faker.Company().Name(),
+				"Participants": []any{map[string]any{
+					"Name":  faker.Person().Name(),
+					"Email": faker.Internet().Email(),
+				}},
+			},
+			DataExpected: []string{"Name", "ChainName", "Participants[0].Name", "Participants[0].Email"},
+			Args:         []any{},
+		},
+		{
+			Name: "pause_ended",
+			Data: map[string]any{
+				"Name":      faker.Person().Name(),
+				"ChainName": faker.Company().Name(),
+			},
+			DataExpected: []string{"Name", "ChainName"},
+			Args:         []any{},
+		},
 		{
 			Name: "poke",
 			Data: map[string]any{
@@ -248,6 +381,34 @@ func TestEmailFormattingByLanguage(t *testing.T) {
 			DataExpected: []string{"Name", "ChainName", "ChainUID"},
 			Args:         []any{},
 		},
+		{
+			Name: "weekly_digest",
+			Data: map[string]any{
+				"Name":    faker.Person().Name(),
+				"BaseURL": faker.Internet().URL(),
+				"PendingApprovals": []any{map[string]any{
+					"ChainName": faker.Company().Name(),
+					"Count":     2,
+				}},
+				"Bags": []any{map[string]any{
+					"Number":    "12",
+					"ChainName": faker.Company().Name(),
+					"Days":      9,
+				}},
+				"BulkyItems": []any{map[string]any{
+					"Title":     faker.Lorem().Word(),
+					"ChainName": faker.Company().Name(),
+				}},
+				"Events": []any{map[string]any{
+					"Name": faker.Company().Name(),
+					"Date": "2024-05-04 14:00 UTC",
+					"URL":  faker.Internet().URL(),
+				}},
+				"UnreadChatMessageCount": 5,
+			},
+			DataExpected: []string{"Name", "PendingApprovals[0].ChainName", "Bags[0].Number", "Bags[0].Days", "BulkyItems[0].Title", "Events[0].Name", "Events[0].URL", "UnreadChatMessageCount"},
+			Args:         []any{},
+		},
 	}
 
 	for _, lng := range languages {
@@ -280,6 +441,25 @@ func TestEmailFormattingByLanguage(t *testing.T) {
 	}
 }
 
+func TestEmailUnsubscribeLink(t *testing.T) {
+	data := map[string]any{
+		"Name":            faker.Person().Name(),
+		"ChainName":       faker.Company().Name(),
+		"ParticipantName": faker.Person().Name(),
+	}
+
+	m := &models.Mail{ToAddress: faker.Internet().Email()}
+	err := emailGenerateMessage(m, "en", "poke", data)
+	assert.NoError(t, err)
+	assert.NotContains(t, m.Body, "/v2/notification/unsubscribe")
+
+	m.NotificationType = sharedtypes.NotificationTypePoke
+	err = emailGenerateMessage(m, "en", "poke", data)
+	assert.NoError(t, err)
+	assert.Contains(t, m.Body, "/v2/notification/unsubscribe?")
+	assert.Contains(t, m.Body, emailsTranslations["en"]["layout_unsubscribe"])
+}
+
 func TestGetI18n(t *testing.T) {
 	list := []struct {
 		Lng    string
@@ -322,3 +502,35 @@ func TestValidateTag(t *testing.T) {
 		assert.Equalf(t, item.ExpectOk, ok, "[%v] Html:\t'%s'", item.ExpectOk, item.Html)
 	}
 }
+
+func TestEmailTemplatesCompleteForAllLanguages(t *testing.T) {
+	enTemplates := EmailPreviewTemplates()
+	assert.NotEmpty(t, enTemplates)
+	for _, lng := range EmailPreviewLanguages() {
+		lngTemplates := emailTemplateNames(lng)
+		for _, name := range enTemplates {
+			assert.Contains(t, lngTemplates, name, "%s is missing in %s", name, lng)
+		}
+	}
+}
+
+func TestEmailPreviewProblems(t *testing.T) {
+	for _, name := range EmailPreviewTemplates() {
+		assert.Contains(t, emailPreviewSamples, name, "sample data for %s", name)
+	}
+	for lng, problems := range EmailPreviewProblems() {
+		assert.Empty(t, problems, lng)
+	}
+}
+
+func TestEmailPreview(t *testing.T) {
+	m, err := EmailPreview("nl", "poke")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, m.Subject)
+	assert.Contains(t, m.Body, "Amsterdam Oost")
+
+	_, err = EmailPreview("xx", "poke")
+	assert.Error(t, err)
+	_, err = EmailPreview("en", "does_not_exist")
+	assert.Error(t, err)
+}
@@ -1,35 +1,81 @@
 package views
 
 import (
-	"github.com/OneSignal/onesignal-go-api"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/the-clothing-loop/website/server/internal/app"
 )
 
 const (
-	NotificationEnumTitleNewBulkyCreated = "NOTIFICATION_TITLE_NEW_BULKY_CREATED"
-	NotificationEnumTitleBagTooOld       = "NOTIFICATION_TITLE_BAG_TOO_OLD"
-	NotificationEnumTitleBagAssignedYou  = "NOTIFICATION_TITLE_BAG_ASSIGNED_YOU"
-	NotificationEnumTitleChatMessage     = "NOTIFICATION_TITLE_CHAT_MESSAGE"
+	NotificationEnumNewBulkyCreated = "new_bulky_created"
+	NotificationEnumBagTooOld       = "bag_too_old"
+	NotificationEnumBagAssignedYou  = "bag_assigned_you"
+	NotificationEnumChatMessage     = "chat_message"
+	NotificationEnumChatWarning     = "chat_warning"
+	NotificationEnumEventReminder   = "event_reminder"
+	NotificationEnumEventChanged    = "event_changed"
+	NotificationEnumEventWaitlist   = "event_waitlist"
+	NotificationEnumAnnouncement    = "announcement"
 )
 
-// TODO: Remove this and use json files instead
-var Notifications map[string]*onesignal.StringMap = map[string]*onesignal.StringMap{
-	NotificationEnumTitleNewBulkyCreated: {
-		En: onesignal.PtrString("A new bulky item has been created"),
-		Nl: onesignal.PtrString("Er is een nieuw groot voorwerp aangemaakt"),
-	},
-
-	NotificationEnumTitleBagTooOld: {
-		En: onesignal.PtrString("The bag you are holding has been in your possession for too long"),
-		Nl: onesignal.PtrString("De tas die u vasthoudt, is te lang in uw bezit geweest"),
-	},
-
-	NotificationEnumTitleBagAssignedYou: {
-		En: onesignal.PtrString("A bag has been assigned to you"),
-		Nl: onesignal.PtrString("Er is u een tas toegewezen"),
-	},
-
-	NotificationEnumTitleChatMessage: {
-		En: onesignal.PtrString("You have a message in chat"),
-		Nl: onesignal.PtrString("Je hebt een bericht in de chat"),
-	},
+type notificationTranslation struct {
+	Title string `json:"title"`
+	// Formatted with the content of the notification, defaults to only the content
+	Body string `json:"body"`
+}
+
+//go:embed notifications
+var notificationsFS embed.FS
+
+var notificationsTranslations = map[string]map[string]notificationTranslation{}
+
+func init() {
+	for _, l := range app.NotificationLangs {
+		b, err := notificationsFS.ReadFile(fmt.Sprintf("notifications/%s.json", l))
+		if err != nil {
+			slog.Error("Notification translations not found", "err", err)
+			os.Exit(1)
+			return
+		}
+		var data map[string]notificationTranslation
+		err = json.Unmarshal(b, &data)
+		if err != nil {
+			slog.Error("Notification translation invalid json", "err", err, "lang", l)
+			os.Exit(1)
+			return
+		}
+		notificationsTranslations[l] = data
+	}
+}
+
+// Falls back to English if the language or the notification is not translated
+func NotificationText(lng, notification, content string) (title, body string) {
+	t, ok := notificationsTranslations[NotificationLang(lng)][notification]
+	if !ok || t.Title == "" {
+		t = notificationsTranslations["en"][notification]
+	}
+
+	title = t.Title
+	if content != "" {
+		if t.Body == "" {
+			body = content
+		} else {
+			body = fmt.Sprintf(t.Body, content)
+		}
+	}
+	return title, body
+}
+
+// Accepts locales with a region like "pt-BR", unknown locales return "en"
+func NotificationLang(lng string) string {
+	lng, _, _ = strings.Cut(strings.ToLower(lng), "-")
+	if _, ok := notificationsTranslations[lng]; ok {
+		return lng
+	}
+	return "en"
 }
@@ -0,0 +1,56 @@
+package views
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/the-clothing-loop/website/server/internal/app"
+)
+
+func TestNotificationTranslationsComplete(t *testing.T) {
+	for _, lng := range app.NotificationLangs {
+		t.Run(lng, func(t *testing.T) {
+			for key, en := range notificationsTranslations["en"] {
+				tr, ok := notificationsTranslations[lng][key]
+				if assert.True(t, ok, key) {
+					assert.NotEmpty(t, tr.Title, key)
+					assert.Equal(t, strings.Count(en.Body, "%s"), strings.Count(tr.Body, "%s"), key)
+				}
+			}
+		})
+	}
+}
+
+// A new locale file is only loaded and sent to OneSignal once it is added to app.NotificationLangs
+func TestNotificationLangsMatchFiles(t *testing.T) {
+	entries, err := notificationsFS.ReadDir("notifications")
+	assert.NoError(t, err)
+	for _, entry := range entries {
+		lng, ok := strings.CutSuffix(entry.Name(), ".json")
+		if assert.True(t, ok, entry.Name()) {
+			assert.Contains(t, app.NotificationLangs, lng)
+		}
+	}
+	assert.Len(t, app.NotificationLangs, len(entries))
+}
+
+func TestNotificationText(t *testing.T) {
+	title, body := NotificationText("nl", NotificationEnumBagAssignedYou, "12")
+	assert.Equal(t, "Er is u een tas toegewezen", title)
+	assert.Equal(t, "Tas 12", body)
+
+	title, body = NotificationText("xx", NotificationEnumChatMessage, "Hello")
+	assert.Equal(t, "You have a message in chat", title)
+	assert.Equal(t, "Hello", body)
+
+	_, body = NotificationText("en", NotificationEnumChatWarning, "")
+	assert.Empty(t, body)
+}
+
+func TestNotificationLang(t *testing.T) {
+	assert.Equal(t, "pt", NotificationLang("pt-BR"))
+	assert.Equal(t, "zh", NotificationLang("zh"))
+	assert.Equal(t, "en", NotificationLang(""))
+	assert.Equal(t, "en", NotificationLang("xx"))
+}
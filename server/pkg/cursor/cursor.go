@@ -0,0 +1,40 @@
+// Opaque cursors for keyset pagination.
+//
+// A cursor points to the last item of a page, using the id of that item
+// and optionally the value of the column that is sorted on.
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalid = errors.New("Invalid cursor")
+
+type Cursor struct {
+	ID    uint   `json:"i"`
+	Value string `json:"v,omitempty"`
+}
+
+func Encode(c Cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// An empty string returns a nil cursor without error
+func Decode(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	c := &Cursor{}
+	err = json.Unmarshal(b, c)
+	if err != nil || c.ID == 0 {
+		return nil, ErrInvalid
+	}
+	return c, nil
+}
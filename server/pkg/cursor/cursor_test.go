@@ -0,0 +1,42 @@
+package cursor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	list := []Cursor{
+		{ID: 1},
+		{ID: 42, Value: "1715172000000"},
+		{ID: 9000, Value: "2024-05-08T12:00:00Z"},
+	}
+
+	for _, expected := range list {
+		s := Encode(expected)
+		assert.NotContains(t, s, "=")
+		result, err := Decode(s)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, *result)
+	}
+}
+
+func TestDecodeEmpty(t *testing.T) {
+	result, err := Decode("")
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	list := []string{
+		"not base64!",
+		Encode(Cursor{}),
+		"e30",
+	}
+
+	for _, s := range list {
+		_, err := Decode(s)
+		assert.ErrorIs(t, err, ErrInvalid, s)
+	}
+}
@@ -0,0 +1,93 @@
+package pdf
+
+import (
+	"slices"
+	"unicode"
+)
+
+const (
+	directionNeutral = iota
+	directionLTR
+	directionRTL
+)
+
+var mirroredRunes = map[rune]rune{'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{', '<': '>', '>': '<'}
+
+func isRTL(r rune) bool {
+	return (r >= 0x0590 && r <= 0x08ff) || (r >= 0xfb1d && r <= 0xfdff) || (r >= 0xfe70 && r <= 0xfeff)
+}
+
+// Puts text in the order its glyphs are drawn, left to right. Right-to-left words such as Hebrew
+// are reversed, numbers and left-to-right words within them keep their order. This is a small part
+// of the Unicode bidirectional algorithm that covers names and addresses, Arabic letters are not
+// joined.
+func visual(s string) []rune {
+	runes := []rune(s)
+	if !slices.ContainsFunc(runes, isRTL) {
+		return runes
+	}
+
+	// the first letter decides the direction of the whole line
+	dirs := make([]int, len(runes))
+	base := directionNeutral
+	for i, r := range runes {
+		switch {
+		case isRTL(r):
+			dirs[i] = directionRTL
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			dirs[i] = directionLTR
+		}
+		if base == directionNeutral && dirs[i] != directionNeutral && !unicode.IsDigit(r) {
+			base = dirs[i]
+		}
+	}
+
+	// spaces and punctuation between two words of the same direction belong to them
+	resolved := slices.Clone(dirs)
+	for i := range dirs {
+		if dirs[i] != directionNeutral {
+			continue
+		}
+		before, after := base, base
+		for j := i - 1; j >= 0; j-- {
+			if dirs[j] != directionNeutral {
+				before = dirs[j]
+				break
+			}
+		}
+		for j := i + 1; j < len(dirs); j++ {
+			if dirs[j] != directionNeutral {
+				after = dirs[j]
+				break
+			}
+		}
+		if before == after {
+			resolved[i] = before
+		} else {
+			resolved[i] = base
+		}
+	}
+
+	runs := [][]rune{}
+	for start := 0; start < len(runes); {
+		end := start + 1
+		for end < len(runes) && resolved[end] == resolved[start] {
+			end++
+		}
+		run := slices.Clone(runes[start:end])
+		if resolved[start] == directionRTL {
+			slices.Reverse(run)
+			for i, r := range run {
+				if m, ok := mirroredRunes[r]; ok {
+					run[i] = m
+				}
+			}
+		}
+		runs = append(runs, run)
+		start = end
+	}
+	if base == directionRTL {
+		slices.Reverse(runs)
+	}
+	return slices.Concat(runs...)
+}
@@ -0,0 +1,354 @@
+package pdf
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// DejaVu Sans covers Latin, Greek, Cyrillic, Hebrew and Arabic, see fonts/LICENSE
+//
+//go:embed fonts/DejaVuSans.ttf
+var dejaVuSans []byte
+
+var errInvalidFont = errors.New("invalid TrueType font")
+
+// Tables a PDF viewer may need to draw the glyphs of an embedded TrueType font
+var fontSubsetTables = []string{"cmap", "cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "prep"}
+
+// A TrueType font, only what is needed to measure text and embed the used glyphs is read
+type font struct {
+	tables     map[string][]byte
+	unitsPerEm int
+	ascent     int
+	descent    int
+	bbox       [4]int
+	numGlyphs  int
+	longLoca   bool
+	advances   []int
+	cmap       map[rune]uint16
+}
+
+var defaultFont = sync.OnceValue(func() *font {
+	f, err := parseFont(dejaVuSans)
+	if err != nil {
+		panic(err)
+	}
+	return f
+})
+
+func parseFont(data []byte) (*font, error) {
+	if len(data) < 12 {
+		return nil, errInvalidFont
+	}
+	f := &font{tables: map[string][]byte{}}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		record := 12 + i*16
+		if record+16 > len(data) {
+			return nil, errInvalidFont
+		}
+		offset := int(binary.BigEndian.Uint32(data[record+8:]))
+		length := int(binary.BigEndian.Uint32(data[record+12:]))
+		if offset+length > len(data) {
+			return nil, errInvalidFont
+		}
+		f.tables[string(data[record:record+4])] = data[offset : offset+length]
+	}
+
+	head, hhea, maxp := f.tables["head"], f.tables["hhea"], f.tables["maxp"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 || f.tables["glyf"] == nil || f.tables["cmap"] == nil {
+		return nil, errInvalidFont
+	}
+	f.unitsPerEm = int(binary.BigEndian.Uint16(head[18:]))
+	for i := range f.bbox {
+		f.bbox[i] = int(int16(binary.BigEndian.Uint16(head[36+i*2:])))
+	}
+	f.longLoca = binary.BigEndian.Uint16(head[50:]) == 1
+	f.ascent = int(int16(binary.BigEndian.Uint16(hhea[4:])))
+	f.descent = int(int16(binary.BigEndian.Uint16(hhea[6:])))
+	f.numGlyphs = int(binary.BigEndian.Uint16(maxp[4:]))
+	if f.unitsPerEm == 0 || len(f.tables["loca"]) < (f.numGlyphs+1)*f.locaSize() {
+		return nil, errInvalidFont
+	}
+
+	// glyphs after the last horizontal metric share its advance width
+	numHMetrics := int(binary.BigEndian.Uint16(hhea[34:]))
+	hmtx := f.tables["hmtx"]
+	if numHMetrics == 0 || len(hmtx) < numHMetrics*4 {
+		return nil, errInvalidFont
+	}
+	f.advances = make([]int, f.numGlyphs)
+	for gid := range f.advances {
+		f.advances[gid] = int(binary.BigEndian.Uint16(hmtx[min(gid, numHMetrics-1)*4:]))
+	}
+
+	var err error
+	f.cmap, err = parseCmap(f.tables["cmap"])
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reads the Unicode character to glyph mapping, preferring the full range over the basic plane
+func parseCmap(b []byte) (map[rune]uint16, error) {
+	if len(b) < 4 {
+		return nil, errInvalidFont
+	}
+	var basic, full []byte
+	for i := 0; i < int(binary.BigEndian.Uint16(b[2:])); i++ {
+		record := 4 + i*8
+		if record+8 > len(b) {
+			return nil, errInvalidFont
+		}
+		platform := binary.BigEndian.Uint16(b[record:])
+		encoding := binary.BigEndian.Uint16(b[record+2:])
+		offset := int(binary.BigEndian.Uint32(b[record+4:]))
+		if platform != 3 || offset+4 > len(b) {
+			continue
+		}
+		sub := b[offset:]
+		switch format := binary.BigEndian.Uint16(sub); {
+		case encoding == 1 && format == 4:
+			basic = sub
+		case encoding == 10 && format == 12:
+			full = sub
+		}
+	}
+
+	m := map[rune]uint16{}
+	switch {
+	case len(full) >= 16:
+		numGroups := int(binary.BigEndian.Uint32(full[12:]))
+		if len(full) < 16+numGroups*12 {
+			return nil, errInvalidFont
+		}
+		for i := 0; i < numGroups; i++ {
+			group := full[16+i*12:]
+			start := binary.BigEndian.Uint32(group)
+			end := binary.BigEndian.Uint32(group[4:])
+			gid := binary.BigEndian.Uint32(group[8:])
+			for c := start; c <= end && c <= 0x10ffff; c++ {
+				m[rune(c)] = uint16(gid + c - start)
+			}
+		}
+	case len(basic) >= 14:
+		segX2 := int(binary.BigEndian.Uint16(basic[6:]))
+		if len(basic) < 16+segX2*4 {
+			return nil, errInvalidFont
+		}
+		for i := 0; i < segX2; i += 2 {
+			end := int(binary.BigEndian.Uint16(basic[14+i:]))
+			start := int(binary.BigEndian.Uint16(basic[16+segX2+i:]))
+			delta := binary.BigEndian.Uint16(basic[16+segX2*2+i:])
+			rangeOffset := int(binary.BigEndian.Uint16(basic[16+segX2*3+i:]))
+			for c := start; c <= end && c < 0xffff; c++ {
+				gid := uint16(c) + delta
+				if rangeOffset != 0 {
+					// the range offset is relative to its own position in the table
+					index := 16 + segX2*3 + i + rangeOffset + (c-start)*2
+					if index+2 > len(basic) {
+						return nil, errInvalidFont
+					}
+					gid = binary.BigEndian.Uint16(basic[index:])
+					if gid != 0 {
+						gid += delta
+					}
+				}
+				if gid != 0 {
+					m[rune(c)] = gid
+				}
+			}
+		}
+	default:
+		return nil, errInvalidFont
+	}
+	return m, nil
+}
+
+// Glyph 0 is the missing character box
+func (f *font) glyph(r rune) uint16 {
+	return f.cmap[r]
+}
+
+// Advance width in thousandths of the font size, the unit PDF uses for glyph widths
+func (f *font) width(gid uint16) int {
+	if int(gid) >= len(f.advances) {
+		return 0
+	}
+	return f.advances[gid] * 1000 / f.unitsPerEm
+}
+
+func (f *font) measure(s string, size float64) float64 {
+	w := 0
+	for _, r := range s {
+		w += f.width(f.glyph(r))
+	}
+	return float64(w) * size / 1000
+}
+
+func (f *font) scale(v int) int {
+	return v * 1000 / f.unitsPerEm
+}
+
+func (f *font) locaSize() int {
+	if f.longLoca {
+		return 4
+	}
+	return 2
+}
+
+func (f *font) glyphData(gid uint16) []byte {
+	loca, glyf := f.tables["loca"], f.tables["glyf"]
+	var start, end int
+	if f.longLoca {
+		start = int(binary.BigEndian.Uint32(loca[int(gid)*4:]))
+		end = int(binary.BigEndian.Uint32(loca[int(gid)*4+4:]))
+	} else {
+		start = int(binary.BigEndian.Uint16(loca[int(gid)*2:])) * 2
+		end = int(binary.BigEndian.Uint16(loca[int(gid)*2+2:])) * 2
+	}
+	if start >= end || end > len(glyf) {
+		return nil
+	}
+	return glyf[start:end]
+}
+
+// Marks a glyph as used, including the glyphs a composite glyph is drawn from
+func (f *font) useGlyph(used map[uint16]bool, gid uint16) {
+	if int(gid) >= f.numGlyphs || used[gid] {
+		return
+	}
+	used[gid] = true
+
+	b := f.glyphData(gid)
+	if len(b) < 10 || int16(binary.BigEndian.Uint16(b)) >= 0 {
+		return
+	}
+	for i := 10; i+4 <= len(b); {
+		flags := binary.BigEndian.Uint16(b[i:])
+		f.useGlyph(used, binary.BigEndian.Uint16(b[i+2:]))
+
+		i += 4
+		if flags&0x0001 != 0 {
+			i += 4
+		} else {
+			i += 2
+		}
+		switch {
+		case flags&0x0008 != 0:
+			i += 2
+		case flags&0x0040 != 0:
+			i += 4
+		case flags&0x0080 != 0:
+			i += 8
+		}
+		if flags&0x0020 == 0 {
+			break
+		}
+	}
+}
+
+// Builds a font file with only the outlines of the given glyphs, the other glyphs are left empty
+// so glyph ids do not change
+func (f *font) subset(glyphs []uint16) []byte {
+	used := map[uint16]bool{}
+	f.useGlyph(used, 0)
+	for _, gid := range glyphs {
+		f.useGlyph(used, gid)
+	}
+
+	glyf := &bytes.Buffer{}
+	loca := make([]byte, (f.numGlyphs+1)*4)
+	for gid := 0; gid < f.numGlyphs; gid++ {
+		binary.BigEndian.PutUint32(loca[gid*4:], uint32(glyf.Len()))
+		if used[uint16(gid)] {
+			glyf.Write(f.glyphData(uint16(gid)))
+			for glyf.Len()%4 != 0 {
+				glyf.WriteByte(0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(loca[f.numGlyphs*4:], uint32(glyf.Len()))
+
+	// the checksum adjustment is filled in once the whole file is written
+	head := bytes.Clone(f.tables["head"])
+	binary.BigEndian.PutUint32(head[8:], 0)
+	binary.BigEndian.PutUint16(head[50:], 1)
+
+	tables := map[string][]byte{"glyf": glyf.Bytes(), "loca": loca, "head": head}
+	for _, tag := range fontSubsetTables {
+		if _, ok := tables[tag]; !ok && f.tables[tag] != nil {
+			tables[tag] = f.tables[tag]
+		}
+	}
+	return writeFont(tables)
+}
+
+func writeFont(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= len(tags) {
+		entrySelector++
+	}
+	searchRange := (1 << entrySelector) * 16
+
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, []uint32{0x00010000})
+	binary.Write(buf, binary.BigEndian, []uint16{uint16(len(tags)), uint16(searchRange), uint16(entrySelector), uint16(len(tags)*16 - searchRange)})
+
+	offset := 12 + len(tags)*16
+	headOffset := 0
+	for _, tag := range tags {
+		if tag == "head" {
+			headOffset = offset
+		}
+		buf.WriteString(tag)
+		binary.Write(buf, binary.BigEndian, []uint32{fontChecksum(tables[tag]), uint32(offset), uint32(len(tables[tag]))})
+		offset += (len(tables[tag]) + 3) &^ 3
+	}
+	for _, tag := range tags {
+		buf.Write(tables[tag])
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+
+	b := buf.Bytes()
+	binary.BigEndian.PutUint32(b[headOffset+8:], 0xb1b0afba-fontChecksum(b))
+	return b
+}
+
+func fontChecksum(b []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(b); i += 4 {
+		word := [4]byte{}
+		copy(word[:], b[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// Subset fonts are named with a tag of six capital letters that identifies the glyphs they contain
+func fontSubsetTag(glyphs []uint16) string {
+	hash := uint32(2166136261)
+	for _, gid := range glyphs {
+		hash = (hash ^ uint32(gid)) * 16777619
+	}
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + byte(hash%26)
+		hash /= 26
+	}
+	return fmt.Sprintf("%s+DejaVuSans", tag)
+}
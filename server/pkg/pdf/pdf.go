@@ -0,0 +1,176 @@
+// Minimal PDF documents with text and lines on A4 pages.
+//
+// Text is drawn with an embedded subset of DejaVu Sans so names and addresses in
+// any script the font covers are printed, bold text is drawn with an outline.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+type Document struct {
+	pages []*bytes.Buffer
+	// characters drawn per glyph, to embed only those glyphs and let viewers copy the text
+	glyphs map[uint16]rune
+}
+
+func New() *Document {
+	return &Document{glyphs: map[uint16]rune{}}
+}
+
+// Starts a new page, all following drawing happens on this page
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+func (d *Document) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// Draws text with its baseline at y, measured from the top of the page
+func (d *Document) Text(x, y, size float64, bold bool, s string) {
+	mode := "0 Tr"
+	if bold {
+		mode = fmt.Sprintf("2 Tr %.2f w", size*0.04)
+	}
+	fmt.Fprintf(d.page(), "BT /F1 %.2f Tf %s %.2f %.2f Td <%s> Tj ET\n", size, mode, x, PageHeight-y, d.encode(s))
+}
+
+// Draws a thin line, coordinates are measured from the top of the page
+func (d *Document) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, PageHeight-y1, x2, PageHeight-y2)
+}
+
+// Shortens text with an ellipsis so it fits the width when drawn at the font size
+func Truncate(s string, size, width float64) string {
+	f := defaultFont()
+	if f.measure(s, size) <= width {
+		return s
+	}
+	ellipsis := f.measure("…", size)
+	w := 0.0
+	for i, r := range []rune(s) {
+		w += f.measure(string(r), size)
+		if w+ellipsis > width {
+			if i == 0 {
+				return s
+			}
+			return string([]rune(s)[:i]) + "…"
+		}
+	}
+	return s
+}
+
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	buf := &bytes.Buffer{}
+	offsets := []int{}
+	object := func(content string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", len(offsets), content)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// objects 1 to 7 are fixed, followed by a page and content object per page
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 8+i*2)
+	}
+	f := defaultFont()
+	glyphs := slices.Sorted(maps.Keys(d.glyphs))
+	name := fontSubsetTag(glyphs)
+	widths := make([]string, len(glyphs))
+	for i, gid := range glyphs {
+		widths[i] = fmt.Sprintf("%d [%d]", gid, f.width(gid))
+	}
+	fontFile := f.subset(glyphs)
+	compressed := &bytes.Buffer{}
+	zw := zlib.NewWriter(compressed)
+	zw.Write(fontFile)
+	zw.Close()
+
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [4 0 R] /ToUnicode 7 0 R >>", name))
+	object(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor 5 0 R /CIDToGIDMap /Identity /W [%s] >>", name, strings.Join(widths, " ")))
+	object(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 6 0 R >>",
+		name, f.scale(f.bbox[0]), f.scale(f.bbox[1]), f.scale(f.bbox[2]), f.scale(f.bbox[3]), f.scale(f.ascent), f.scale(f.descent), f.scale(f.ascent)))
+	object(fmt.Sprintf("<< /Length %d /Length1 %d /Filter /FlateDecode >>\nstream\n%s\nendstream", compressed.Len(), len(fontFile), compressed.String()))
+	toUnicode := d.toUnicode(glyphs)
+	object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(toUnicode), toUnicode))
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", PageWidth, PageHeight, 9+i*2))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	}
+
+	xrefOffset := buf.Len()
+	fmt.Fprintf(buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOffset)
+
+	return buf.WriteTo(w)
+}
+
+// Encodes text as hexadecimal glyph ids in the order the glyphs are drawn
+func (d *Document) encode(s string) string {
+	f := defaultFont()
+	sb := strings.Builder{}
+	for _, r := range visual(s) {
+		if r < 0x20 {
+			r = ' '
+		}
+		gid := f.glyph(r)
+		if gid != 0 {
+			d.glyphs[gid] = r
+		}
+		fmt.Fprintf(&sb, "%04X", gid)
+	}
+	return sb.String()
+}
+
+// Maps glyph ids back to characters so text can be searched and copied
+func (d *Document) toUnicode(glyphs []uint16) string {
+	sb := strings.Builder{}
+	sb.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	sb.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	sb.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	sb.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for chunk := range slices.Chunk(glyphs, 100) {
+		fmt.Fprintf(&sb, "%d beginbfchar\n", len(chunk))
+		for _, gid := range chunk {
+			fmt.Fprintf(&sb, "<%04X> <", gid)
+			for _, c := range utf16.Encode([]rune{d.glyphs[gid]}) {
+				fmt.Fprintf(&sb, "%04X", c)
+			}
+			sb.WriteString(">\n")
+		}
+		sb.WriteString("endbfchar\n")
+	}
+	sb.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend")
+	return sb.String()
+}
@@ -0,0 +1,107 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteTo(t *testing.T) {
+	d := New()
+	d.Text(40, 40, 16, true, "Route (Amsterdam)")
+	d.Line(40, 50, 555, 50)
+	d.AddPage()
+	d.Text(40, 40, 10, false, "Zoë")
+	d.Text(40, 60, 10, false, "שלום")
+
+	buf := &bytes.Buffer{}
+	_, err := d.WriteTo(buf)
+	assert.NoError(t, err)
+	b := buf.Bytes()
+
+	assert.True(t, bytes.HasPrefix(b, []byte("%PDF-1.4")))
+	assert.Contains(t, string(b), "/Count 2")
+	assert.Contains(t, string(b), fmt.Sprintf("2 Tr 0.64 w 40.00 801.89 Td <%s> Tj", glyphHex("Route (Amsterdam)")))
+	assert.Contains(t, string(b), fmt.Sprintf("0 Tr 40.00 801.89 Td <%s> Tj", glyphHex("Zoë")))
+	assert.Contains(t, string(b), fmt.Sprintf("<%s> Tj", glyphHex("םולש")))
+	assert.Contains(t, string(b), "/Encoding /Identity-H")
+	assert.Contains(t, string(b), "/FontFile2 6 0 R")
+
+	// the text can be copied as the original characters
+	f := defaultFont()
+	assert.Contains(t, string(b), fmt.Sprintf("<%04X> <05E9>", f.glyph('ש')))
+	assert.Contains(t, string(b), fmt.Sprintf("<%04X> <00EB>", f.glyph('ë')))
+
+	// every offset in the cross reference table must point to its object
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(b)
+	if !assert.NotNil(t, startxref) {
+		return
+	}
+	xrefOffset, _ := strconv.Atoi(string(startxref[1]))
+	assert.True(t, bytes.HasPrefix(b[xrefOffset:], []byte("xref\n")))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(b[xrefOffset:], -1)
+	assert.Len(t, entries, 11)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		assert.True(t, bytes.HasPrefix(b[offset:], []byte(fmt.Sprintf("%d 0 obj", i+1))), "object %d", i+1)
+	}
+}
+
+func glyphHex(s string) string {
+	f := defaultFont()
+	sb := strings.Builder{}
+	for _, r := range s {
+		fmt.Fprintf(&sb, "%04X", f.glyph(r))
+	}
+	return sb.String()
+}
+
+func TestEncode(t *testing.T) {
+	d := New()
+	assert.Equal(t, glyphHex("a b"), d.encode("a\nb"))
+	assert.Equal(t, "0000", d.encode("中"), "characters missing from the font are drawn as a box")
+	assert.Len(t, d.glyphs, 3)
+}
+
+func TestVisual(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Zoë (Amsterdam)", "Zoë (Amsterdam)"},
+		{"שלום", "םולש"},
+		{"רחוב הרצל 12", "12 לצרה בוחר"},
+		{"שלום John Smith", "John Smith םולש"},
+		{"Anna כהן", "Anna ןהכ"},
+		{"דן (חיפה)", "(הפיח) ןד"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, string(visual(test.in)), test.in)
+	}
+}
+
+func TestSubset(t *testing.T) {
+	f := defaultFont()
+	glyphs := []uint16{f.glyph('a'), f.glyph('ש')}
+	b := f.subset(glyphs)
+	assert.Less(t, len(b), len(dejaVuSans)/4)
+
+	sub, err := parseFont(b)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, f.numGlyphs, sub.numGlyphs)
+	assert.Equal(t, f.glyphData(glyphs[0]), sub.glyphData(glyphs[0]))
+	assert.Equal(t, f.glyphData(glyphs[1]), sub.glyphData(glyphs[1]))
+	assert.Nil(t, sub.glyphData(f.glyph('b')))
+	assert.Equal(t, uint32(0xb1b0afba), fontChecksum(b))
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", Truncate("short", 10, 100))
+	assert.Equal(t, "ab…", Truncate("abcdefghijkl", 10, 25))
+	assert.Equal(t, "WWWW…", Truncate("WWWWWWWWWWWW", 10, 50))
+	assert.Equal(t, "iiiiiiiiiiii", Truncate("iiiiiiiiiiii", 10, 50))
+}
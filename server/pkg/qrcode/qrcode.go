@@ -0,0 +1,502 @@
+// Minimal QR code encoder for links printed on posters.
+//
+// Text is always encoded in byte mode with error correction level M,
+// versions 1 to 10 are supported which fits up to 213 bytes.
+package qrcode
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+var ErrTooLong = errors.New("Text is too long to fit in a QR code")
+
+const (
+	minVersion = 1
+	maxVersion = 10
+	// modules of white space around the code required by the specification
+	quietZone = 4
+)
+
+// error correction codewords per block and number of blocks for level M, indexed by version
+var (
+	eccCodewordsPerBlock = [maxVersion + 1]int{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26}
+	numEccBlocks         = [maxVersion + 1]int{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5}
+	alignmentPositions   = [maxVersion + 1][]int{
+		nil, {}, {6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+		{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+	}
+)
+
+type Code struct {
+	Version int
+	// width and height in modules, without the quiet zone
+	Size int
+
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+
+	version := 0
+	for v := minVersion; v <= maxVersion; v++ {
+		if 4+charCountBits(v)+len(data)*8 <= numDataCodewords(v)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	bb := &bitBuffer{}
+	bb.append(0b0100, 4)
+	bb.append(len(data), charCountBits(version))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+	capacity := numDataCodewords(version) * 8
+	bb.append(0, min(4, capacity-bb.len()))
+	bb.append(0, (8-bb.len()%8)%8)
+	for pad := 0xEC; bb.len() < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	q := newCode(version)
+	q.drawFunctionPatterns()
+	q.drawCodewords(addEccAndInterleave(version, bb.bytes()))
+
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormatBits(mask)
+		penalty := q.penalty()
+		if bestPenalty == -1 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		// applying the same mask again reverts it
+		q.applyMask(mask)
+	}
+	q.applyMask(bestMask)
+	q.drawFormatBits(bestMask)
+
+	return q, nil
+}
+
+func newCode(version int) *Code {
+	size := version*4 + 17
+	q := &Code{Version: version, Size: size}
+	q.modules = make([][]bool, size)
+	q.isFunction = make([][]bool, size)
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.isFunction[i] = make([]bool, size)
+	}
+	return q
+}
+
+// Returns true for a dark module, x and y start at the top left corner
+func (q *Code) Black(x, y int) bool {
+	return x >= 0 && x < q.Size && y >= 0 && y < q.Size && q.modules[y][x]
+}
+
+// Renders the code with its quiet zone, every module is scale pixels wide
+func (q *Code) Image(scale int) image.Image {
+	scale = max(scale, 1)
+	width := (q.Size + quietZone*2) * scale
+	img := image.NewGray(image.Rect(0, 0, width, width))
+	for y := 0; y < width; y++ {
+		for x := 0; x < width; x++ {
+			c := color.White
+			if q.Black(x/scale-quietZone, y/scale-quietZone) {
+				c = color.Black
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func (q *Code) PNG(w io.Writer, scale int) error {
+	return png.Encode(w, q.Image(scale))
+}
+
+func (q *Code) setFunction(x, y int, black bool) {
+	q.modules[y][x] = black
+	q.isFunction[y][x] = true
+}
+
+func (q *Code) drawFunctionPatterns() {
+	for i := 0; i < q.Size; i++ {
+		q.setFunction(6, i, i%2 == 0)
+		q.setFunction(i, 6, i%2 == 0)
+	}
+
+	q.drawFinderPattern(3, 3)
+	q.drawFinderPattern(q.Size-4, 3)
+	q.drawFinderPattern(3, q.Size-4)
+
+	positions := alignmentPositions[q.Version]
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// these overlap with the finder patterns
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			q.drawAlignmentPattern(x, y)
+		}
+	}
+
+	// reserves the format area, the actual bits are drawn after masking
+	q.drawFormatBits(0)
+	q.drawVersion()
+}
+
+// Draws the 7x7 finder pattern with its white separator around the center x, y
+func (q *Code) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= q.Size || yy < 0 || yy >= q.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			q.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (q *Code) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			q.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+func (q *Code) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+
+	// first copy around the top left finder pattern
+	for i := 0; i <= 5; i++ {
+		q.setFunction(8, i, getBit(bits, i))
+	}
+	q.setFunction(8, 7, getBit(bits, 6))
+	q.setFunction(8, 8, getBit(bits, 7))
+	q.setFunction(7, 8, getBit(bits, 8))
+	for i := 9; i < 15; i++ {
+		q.setFunction(14-i, 8, getBit(bits, i))
+	}
+
+	// second copy split between the top right and bottom left finder patterns
+	for i := 0; i < 8; i++ {
+		q.setFunction(q.Size-1-i, 8, getBit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		q.setFunction(8, q.Size-15+i, getBit(bits, i))
+	}
+	q.setFunction(8, q.Size-8, true)
+}
+
+// Versions 7 and up carry their version number next to the top right and bottom left finder patterns
+func (q *Code) drawVersion() {
+	if q.Version < 7 {
+		return
+	}
+	bits := versionBits(q.Version)
+	for i := 0; i < 18; i++ {
+		black := getBit(bits, i)
+		a, b := q.Size-11+i%3, i/3
+		q.setFunction(a, b, black)
+		q.setFunction(b, a, black)
+	}
+}
+
+// Places the codewords in the zigzag pattern of two module wide columns, starting at the bottom right
+func (q *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		// skips the vertical timing pattern
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < q.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if upward {
+					y = q.Size - 1 - vert
+				}
+				if !q.isFunction[y][x] && i < len(data)*8 {
+					q.modules[y][x] = getBit(int(data[i>>3]), 7-(i&7))
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (q *Code) applyMask(mask int) {
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if !q.isFunction[y][x] && maskInverts(mask, x, y) {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+func maskInverts(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// Scores how hard the code is to scan, the mask with the lowest score is used
+func (q *Code) penalty() int {
+	result := 0
+
+	// runs of five or more modules of the same color in rows and columns
+	// and the 1:1:3:1:1 finder like pattern with four white modules on either side
+	finderLike := [][]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+	for _, horizontal := range []bool{true, false} {
+		at := func(i, j int) bool {
+			if horizontal {
+				return q.modules[i][j]
+			}
+			return q.modules[j][i]
+		}
+		for i := 0; i < q.Size; i++ {
+			run := 0
+			for j := 0; j < q.Size; j++ {
+				if j > 0 && at(i, j) == at(i, j-1) {
+					run++
+				} else {
+					run = 1
+				}
+				if run == 5 {
+					result += 3
+				} else if run > 5 {
+					result++
+				}
+			}
+			for j := 0; j+11 <= q.Size; j++ {
+				for _, pattern := range finderLike {
+					matches := true
+					for k, black := range pattern {
+						if at(i, j+k) != black {
+							matches = false
+							break
+						}
+					}
+					if matches {
+						result += 40
+					}
+				}
+			}
+		}
+	}
+
+	// 2x2 blocks of the same color
+	for y := 0; y < q.Size-1; y++ {
+		for x := 0; x < q.Size-1; x++ {
+			c := q.modules[y][x]
+			if c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+				result += 3
+			}
+		}
+	}
+
+	// balance of dark and light modules, 10 points for every 5% away from half
+	dark := 0
+	for _, row := range q.modules {
+		for _, black := range row {
+			if black {
+				dark++
+			}
+		}
+	}
+	result += abs(dark*100/(q.Size*q.Size)-50) / 5 * 10
+
+	return result
+}
+
+// 15 bits of error correction level M and mask, with their BCH error correction
+func formatBits(mask int) int {
+	// level M is encoded as 00
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// 18 bits of the version number with their BCH error correction
+func versionBits(version int) int {
+	rem := version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	return version<<12 | rem
+}
+
+func charCountBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// Number of modules available for data and error correction after the function patterns
+func numRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+func numDataCodewords(version int) int {
+	return numRawDataModules(version)/8 - eccCodewordsPerBlock[version]*numEccBlocks[version]
+}
+
+// Splits the data in blocks, adds error correction codewords to each block and interleaves them
+func addEccAndInterleave(version int, data []byte) []byte {
+	numBlocks := numEccBlocks[version]
+	blockEccLen := eccCodewordsPerBlock[version]
+	rawCodewords := numRawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := reedSolomonDivisor(blockEccLen)
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := range blocks {
+		datLen := shortBlockLen - blockEccLen
+		if i >= numShortBlocks {
+			datLen++
+		}
+		dat := append([]byte{}, data[k:k+datLen]...)
+		k += datLen
+		ecc := reedSolomonRemainder(dat, divisor)
+		if i < numShortBlocks {
+			// keeps all blocks the same length while interleaving, skipped below
+			dat = append(dat, 0)
+		}
+		blocks[i] = append(dat, ecc...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortBlockLen-blockEccLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+func reedSolomonDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+func reedSolomonRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coef := range divisor {
+			result[i] ^= gfMultiply(coef, factor)
+		}
+	}
+	return result
+}
+
+// Multiplies in GF(2^8) with the QR code polynomial x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+type bitBuffer struct {
+	bits []bool
+}
+
+func (bb *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		bb.bits = append(bb.bits, getBit(value, i))
+	}
+}
+
+func (bb *bitBuffer) len() int {
+	return len(bb.bits)
+}
+
+func (bb *bitBuffer) bytes() []byte {
+	result := make([]byte, len(bb.bits)/8)
+	for i, bit := range bb.bits {
+		if bit {
+			result[i>>3] |= 1 << (7 - i&7)
+		}
+	}
+	return result
+}
+
+func getBit(x, i int) bool {
+	return (x>>i)&1 != 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,213 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReedSolomonRemainder(t *testing.T) {
+	// version 1-M example of "01234567" from the specification
+	data := []byte{0x10, 0x20, 0x0C, 0x56, 0x61, 0x80, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11, 0xEC, 0x11}
+	expected := []byte{0xA5, 0x24, 0xD4, 0xC1, 0xED, 0x36, 0xC7, 0x87, 0x2C, 0x55}
+
+	assert.Equal(t, expected, reedSolomonRemainder(data, reedSolomonDivisor(10)))
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	expected := []int{
+		0b101010000010010,
+		0b101000100100101,
+		0b101111001111100,
+		0b101101101001011,
+		0b100010111111001,
+		0b100000011001110,
+		0b100111110010111,
+		0b100101010100000,
+	}
+	for mask, bits := range expected {
+		assert.Equal(t, bits, formatBits(mask), "mask %d", mask)
+	}
+
+	assert.Equal(t, 0b000111110010010100, versionBits(7))
+	assert.Equal(t, 0b001010010011010011, versionBits(10))
+}
+
+func TestNumDataCodewords(t *testing.T) {
+	expected := []int{0, 16, 28, 44, 64, 86, 108, 124, 154, 182, 216}
+	for version := minVersion; version <= maxVersion; version++ {
+		assert.Equal(t, expected[version], numDataCodewords(version), "version %d", version)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		text    string
+		version int
+	}{
+		{"https://www.clothingloop.org", 3},
+		{"https://www.clothingloop.org/invite/" + strings.Repeat("a", 32), 5},
+		{strings.Repeat("x", 120), 7},
+		{strings.Repeat("y", 213), 10},
+	}
+	for _, test := range tests {
+		q, err := Encode(test.text)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, test.version, q.Version)
+		assert.Equal(t, test.version*4+17, q.Size)
+
+		// finder patterns in three corners
+		for _, corner := range [][2]int{{0, 0}, {q.Size - 7, 0}, {0, q.Size - 7}} {
+			assert.True(t, q.Black(corner[0], corner[1]))
+			assert.False(t, q.Black(corner[0]+1, corner[1]+1))
+			assert.True(t, q.Black(corner[0]+3, corner[1]+3))
+		}
+		assert.True(t, q.Black(8, q.Size-8), "dark module")
+
+		assert.Equal(t, test.text, decode(t, q))
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	_, err := Encode(strings.Repeat("z", 214))
+	assert.ErrorIs(t, err, ErrTooLong)
+}
+
+func TestPNG(t *testing.T) {
+	q, err := Encode("https://www.clothingloop.org")
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, q.PNG(buf, 5))
+	img, err := png.Decode(buf)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	width := (q.Size + quietZone*2) * 5
+	assert.Equal(t, width, img.Bounds().Dx())
+	assert.Equal(t, width, img.Bounds().Dy())
+	r, _, _, _ := img.At(0, 0).RGBA()
+	assert.Equal(t, uint32(0xffff), r, "quiet zone is white")
+	r, _, _, _ = img.At(quietZone*5, quietZone*5).RGBA()
+	assert.Equal(t, uint32(0), r, "finder pattern is black")
+}
+
+// Reads the text back from the modules the way a scanner would,
+// only the bits are inspected so it checks placement, masking and interleaving
+func decode(t *testing.T, q *Code) string {
+	t.Helper()
+
+	// both copies of the format bits must agree and point to level M
+	first, second := 0, 0
+	for i := 0; i <= 5; i++ {
+		first |= bit(q.Black(8, i)) << i
+	}
+	first |= bit(q.Black(8, 7))<<6 | bit(q.Black(8, 8))<<7 | bit(q.Black(7, 8))<<8
+	for i := 9; i < 15; i++ {
+		first |= bit(q.Black(14-i, 8)) << i
+	}
+	for i := 0; i < 8; i++ {
+		second |= bit(q.Black(q.Size-1-i, 8)) << i
+	}
+	for i := 8; i < 15; i++ {
+		second |= bit(q.Black(8, q.Size-15+i)) << i
+	}
+	assert.Equal(t, first, second)
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == first {
+			mask = m
+		}
+	}
+	if !assert.NotEqual(t, -1, mask, "format bits") {
+		return ""
+	}
+
+	// a fresh code of the same version tells which modules hold data
+	layout := newCode(q.Version)
+	layout.drawFunctionPatterns()
+	bits := []bool{}
+	for right := q.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = q.Size - 1 - vert
+				}
+				if !layout.isFunction[y][x] {
+					bits = append(bits, q.Black(x, y) != maskInverts(mask, x, y))
+				}
+			}
+		}
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i := range codewords {
+		for j := 0; j < 8; j++ {
+			if bits[i*8+j] {
+				codewords[i] |= 1 << (7 - j)
+			}
+		}
+	}
+
+	// undo the interleaving and verify the error correction of every block
+	numBlocks := numEccBlocks[q.Version]
+	eccLen := eccCodewordsPerBlock[q.Version]
+	numShortBlocks := numBlocks - len(codewords)%numBlocks
+	shortDataLen := len(codewords)/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i <= shortDataLen; i++ {
+		for j := range blocks {
+			if i == shortDataLen && j < numShortBlocks {
+				continue
+			}
+			blocks[j] = append(blocks[j], codewords[k])
+			k++
+		}
+	}
+	data := []byte{}
+	for i := 0; i < eccLen; i++ {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], codewords[k])
+			k++
+		}
+	}
+	divisor := reedSolomonDivisor(eccLen)
+	for _, block := range blocks {
+		dataLen := len(block) - eccLen
+		assert.Equal(t, block[dataLen:], reedSolomonRemainder(block[:dataLen], divisor))
+		data = append(data, block[:dataLen]...)
+	}
+
+	// byte mode header followed by the length and the text
+	assert.Equal(t, byte(0b0100), data[0]>>4)
+	read := func(offset, length int) int {
+		v := 0
+		for i := offset; i < offset+length; i++ {
+			v = v<<1 | int(data[i/8]>>(7-i%8)&1)
+		}
+		return v
+	}
+	length := read(4, charCountBits(q.Version))
+	text := make([]byte, length)
+	for i := range text {
+		text[i] = byte(read(4+charCountBits(q.Version)+i*8, 8))
+	}
+	return string(text)
+}
+
+func bit(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
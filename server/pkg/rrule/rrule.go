@@ -0,0 +1,479 @@
+// A subset of the RFC 5545 recurrence rule.
+//
+// Supported parts are FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT,
+// UNTIL, BYDAY (with an optional ordinal for monthly and yearly rules),
+// BYMONTHDAY and BYMONTH. Weeks always start on monday. Yearly rules with BYDAY
+// and without BYMONTH cover the whole year, with ordinals counted within the year,
+// other yearly rules without BYMONTH stay in the month of the start date.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalid        = errors.New("Invalid recurrence rule")
+	ErrTooManyPeriods = errors.New("Recurrence rule does not finish within the maximum number of periods")
+)
+
+type Frequency string
+
+const (
+	FrequencyDaily   Frequency = "DAILY"
+	FrequencyWeekly  Frequency = "WEEKLY"
+	FrequencyMonthly Frequency = "MONTHLY"
+	FrequencyYearly  Frequency = "YEARLY"
+)
+
+// Protects against rules that never produce a match, ErrTooManyPeriods is returned once reached
+const maxPeriods = 5000
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// N is the ordinal within the month or year, 0 means every weekday of the period
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+// Parses a rule with or without the "RRULE:" prefix
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, ErrInvalid
+	}
+	r := &Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalid, part)
+		}
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+			switch r.Freq {
+			case FrequencyDaily, FrequencyWeekly, FrequencyMonthly, FrequencyYearly:
+			default:
+				err = ErrInvalid
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = ErrInvalid
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && r.Count < 1 {
+				err = ErrInvalid
+			}
+		case "UNTIL":
+			var until time.Time
+			until, err = parseUntil(value)
+			r.Until = &until
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				var wn WeekdayNum
+				wn, err = parseWeekdayNum(v)
+				if err != nil {
+					break
+				}
+				r.ByDay = append(r.ByDay, wn)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(value, ",") {
+				var d int
+				d, err = strconv.Atoi(v)
+				if err != nil || d == 0 || d < -31 || d > 31 {
+					err = ErrInvalid
+					break
+				}
+				r.ByMonthDay = append(r.ByMonthDay, d)
+			}
+		case "BYMONTH":
+			for _, v := range strings.Split(value, ",") {
+				var m int
+				m, err = strconv.Atoi(v)
+				if err != nil || m < 1 || m > 12 {
+					err = ErrInvalid
+					break
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+			}
+		case "WKST":
+			if strings.ToUpper(value) != "MO" {
+				err = ErrInvalid
+			}
+		default:
+			err = ErrInvalid
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalid, part)
+		}
+	}
+	if r.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalid)
+	}
+	if r.Count != 0 && r.Until != nil {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL can not be combined", ErrInvalid)
+	}
+	for _, wn := range r.ByDay {
+		if wn.N != 0 && (r.Freq == FrequencyDaily || r.Freq == FrequencyWeekly) {
+			return nil, fmt.Errorf("%w: BYDAY ordinals require a monthly or yearly rule", ErrInvalid)
+		}
+		// ordinals within a month, only yearly rules without BYMONTH count weeks of the whole year
+		if (wn.N < -5 || wn.N > 5) && (r.Freq != FrequencyYearly || len(r.ByMonth) > 0) {
+			return nil, fmt.Errorf("%w: BYDAY ordinal is out of range", ErrInvalid)
+		}
+	}
+	return r, nil
+}
+
+func parseUntil(s string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrInvalid
+}
+
+func parseWeekdayNum(s string) (WeekdayNum, error) {
+	s = strings.ToUpper(s)
+	if len(s) < 2 {
+		return WeekdayNum{}, ErrInvalid
+	}
+	wd, ok := weekdays[s[len(s)-2:]]
+	if !ok {
+		return WeekdayNum{}, ErrInvalid
+	}
+	n := 0
+	if prefix := s[:len(s)-2]; prefix != "" {
+		var err error
+		n, err = strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -53 || n > 53 {
+			return WeekdayNum{}, ErrInvalid
+		}
+	}
+	return WeekdayNum{Weekday: wd, N: n}, nil
+}
+
+// Returns the rule in its canonical form without the "RRULE:" prefix
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		days := []string{}
+		for _, wn := range r.ByDay {
+			day := ""
+			for k, v := range weekdays {
+				if v == wn.Weekday {
+					day = k
+				}
+			}
+			if wn.N != 0 {
+				day = strconv.Itoa(wn.N) + day
+			}
+			days = append(days, day)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := []string{}
+		for _, d := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(d))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonth) > 0 {
+		months := []string{}
+		for _, m := range r.ByMonth {
+			months = append(months, strconv.Itoa(int(m)))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Returns the occurrences starting from dtstart that fall within [after, before),
+// with at most max results. COUNT is always counted from dtstart.
+// Returns ErrTooManyPeriods with the occurrences found so far when the window is not reached in time.
+func (r *Rule) Between(dtstart, after, before time.Time, max int) ([]time.Time, error) {
+	result := []time.Time{}
+	if max <= 0 {
+		return result, nil
+	}
+	start := 0
+	if r.Count == 0 {
+		// without COUNT the periods before the window do not have to be visited
+		start = r.periodBefore(dtstart, after)
+	}
+	err := r.iterate(dtstart, start, func(t time.Time) bool {
+		if !t.Before(before) {
+			return false
+		}
+		if !t.Before(after) {
+			result = append(result, t)
+		}
+		return len(result) < max
+	})
+	return result, err
+}
+
+// Counts the occurrences starting from dtstart that fall within [after, before) without keeping them.
+// Returns ErrTooManyPeriods with the amount counted so far when the window is not reached in time.
+func (r *Rule) CountBetween(dtstart, after, before time.Time) (int, error) {
+	n := 0
+	start := 0
+	if r.Count == 0 {
+		start = r.periodBefore(dtstart, after)
+	}
+	err := r.iterate(dtstart, start, func(t time.Time) bool {
+		if !t.Before(before) {
+			return false
+		}
+		if !t.Before(after) {
+			n++
+		}
+		return true
+	})
+	return n, err
+}
+
+// Returns the last occurrence of a rule that ends, the second value is false for infinite rules
+func (r *Rule) Last(dtstart time.Time) (time.Time, bool, error) {
+	if r.Count == 0 && r.Until == nil {
+		return time.Time{}, false, nil
+	}
+	last := dtstart
+	err := r.iterate(dtstart, 0, func(t time.Time) bool {
+		last = t
+		return true
+	})
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return last, true, nil
+}
+
+func (r *Rule) iterate(dtstart time.Time, start int, yield func(time.Time) bool) error {
+	count := 0
+	for period := start; period < start+maxPeriods; period++ {
+		for _, t := range r.candidates(dtstart, period) {
+			if t.Before(dtstart) {
+				continue
+			}
+			if r.Until != nil && t.After(*r.Until) {
+				return nil
+			}
+			count++
+			if !yield(t) {
+				return nil
+			}
+			if r.Count != 0 && count >= r.Count {
+				return nil
+			}
+		}
+	}
+	return ErrTooManyPeriods
+}
+
+// Returns a period that starts before the given time, at most one period early
+func (r *Rule) periodBefore(dtstart, t time.Time) int {
+	if !t.After(dtstart) {
+		return 0
+	}
+	n := 0
+	switch r.Freq {
+	case FrequencyDaily:
+		n = int(t.Sub(dtstart).Hours()/24) / r.Interval
+	case FrequencyWeekly:
+		n = int(t.Sub(dtstart).Hours()/24/7) / r.Interval
+	case FrequencyMonthly:
+		n = ((t.Year()-dtstart.Year())*12 + int(t.Month()) - int(dtstart.Month())) / r.Interval
+	case FrequencyYearly:
+		n = (t.Year() - dtstart.Year()) / r.Interval
+	}
+	return max(n-1, 0)
+}
+
+// Returns the sorted candidates of the nth period after dtstart
+func (r *Rule) candidates(dtstart time.Time, n int) []time.Time {
+	y, m, d := dtstart.Date()
+	hh, mm, ss := dtstart.Clock()
+	loc := dtstart.Location()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hh, mm, ss, 0, loc)
+	}
+
+	list := []time.Time{}
+	switch r.Freq {
+	case FrequencyDaily:
+		t := at(y, m, d+n*r.Interval)
+		if r.matchesMonth(t) && r.matchesMonthDay(t) && r.matchesWeekday(t) {
+			list = append(list, t)
+		}
+	case FrequencyWeekly:
+		// monday of the week of dtstart
+		offset := (int(dtstart.Weekday()) + 6) % 7
+		monday := at(y, m, d-offset+n*7*r.Interval)
+		for i := 0; i < 7; i++ {
+			t := monday.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 {
+				if t.Weekday() != dtstart.Weekday() {
+					continue
+				}
+			} else if !r.matchesWeekday(t) {
+				continue
+			}
+			if r.matchesMonth(t) {
+				list = append(list, t)
+			}
+		}
+	case FrequencyMonthly:
+		first := at(y, m+time.Month(n*r.Interval), 1)
+		if r.matchesMonth(first) {
+			list = r.expandMonth(first, d)
+		}
+	case FrequencyYearly:
+		year := y + n*r.Interval
+		if len(r.ByMonth) == 0 && len(r.ByDay) > 0 {
+			list = r.expandYear(at(year, time.January, 1))
+			break
+		}
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{m}
+		}
+		for _, month := range months {
+			list = append(list, r.expandMonth(at(year, month, 1), d)...)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Before(list[j]) })
+	return list
+}
+
+// Expands the days of a month, first must be the first day of that month
+func (r *Rule) expandMonth(first time.Time, defaultDay int) []time.Time {
+	lastDay := first.AddDate(0, 1, -1).Day()
+	list := []time.Time{}
+	for day := 1; day <= lastDay; day++ {
+		t := first.AddDate(0, 0, day-1)
+		switch {
+		case len(r.ByDay) == 0 && len(r.ByMonthDay) == 0:
+			if day != defaultDay {
+				continue
+			}
+		case len(r.ByDay) == 0:
+			if !r.matchesMonthDay(t) {
+				continue
+			}
+		default:
+			if !r.matchesMonthDay(t) || !r.matchesWeekdayInPeriod(t, day, lastDay) {
+				continue
+			}
+		}
+		list = append(list, t)
+	}
+	return list
+}
+
+// Expands the days of a year matching BYDAY, first must be the first day of that year
+func (r *Rule) expandYear(first time.Time) []time.Time {
+	lastDay := first.AddDate(1, 0, -1).YearDay()
+	list := []time.Time{}
+	for day := 1; day <= lastDay; day++ {
+		t := first.AddDate(0, 0, day-1)
+		if r.matchesMonthDay(t) && r.matchesWeekdayInPeriod(t, t.YearDay(), lastDay) {
+			list = append(list, t)
+		}
+	}
+	return list
+}
+
+func (r *Rule) matchesMonth(t time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if t.Month() == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(t time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	lastDay := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	for _, d := range r.ByMonthDay {
+		if d == t.Day() || (d < 0 && lastDay+d+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesWeekday(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wn := range r.ByDay {
+		if wn.Weekday == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// Day is the position of t within its month or year and lastDay the length of that period
+func (r *Rule) matchesWeekdayInPeriod(t time.Time, day, lastDay int) bool {
+	for _, wn := range r.ByDay {
+		if wn.Weekday != t.Weekday() {
+			continue
+		}
+		switch {
+		case wn.N == 0:
+			return true
+		case wn.N > 0 && (day-1)/7+1 == wn.N:
+			return true
+		case wn.N < 0 && (lastDay-day)/7+1 == -wn.N:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 14, 30, 0, 0, time.UTC)
+}
+
+func TestParseInvalid(t *testing.T) {
+	list := []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=WEEKLY;INTERVAL=0",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=YEARLY;BYMONTH=3;BYDAY=20MO",
+		"FREQ=YEARLY;BYDAY=54MO",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;COUNT=3;UNTIL=20240101",
+		"FREQ=DAILY;FOO=BAR",
+	}
+
+	for _, s := range list {
+		_, err := Parse(s)
+		assert.ErrorIs(t, err, ErrInvalid, s)
+	}
+}
+
+func TestParseString(t *testing.T) {
+	list := []string{
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH",
+		"FREQ=MONTHLY;COUNT=6;BYDAY=-1SU",
+		"FREQ=YEARLY;UNTIL=20301231T000000Z;BYMONTHDAY=15;BYMONTH=3,9",
+	}
+
+	for _, s := range list {
+		r, err := Parse("RRULE:" + s)
+		assert.NoError(t, err)
+		assert.Equal(t, s, r.String())
+	}
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		rule     string
+		dtstart  time.Time
+		expected []time.Time
+	}{
+		{
+			rule:     "FREQ=DAILY;INTERVAL=3;COUNT=3",
+			dtstart:  date(2024, 1, 30),
+			expected: []time.Time{date(2024, 1, 30), date(2024, 2, 2), date(2024, 2, 5)},
+		},
+		{
+			rule:     "FREQ=WEEKLY;BYDAY=TU,SA;COUNT=4",
+			dtstart:  date(2024, 5, 8), // wednesday
+			expected: []time.Time{date(2024, 5, 11), date(2024, 5, 14), date(2024, 5, 18), date(2024, 5, 21)},
+		},
+		{
+			rule:     "FREQ=WEEKLY;INTERVAL=2;UNTIL=20240530T000000Z",
+			dtstart:  date(2024, 5, 1),
+			expected: []time.Time{date(2024, 5, 1), date(2024, 5, 15), date(2024, 5, 29)},
+		},
+		{
+			rule:     "FREQ=MONTHLY;BYDAY=1SA;COUNT=3",
+			dtstart:  date(2024, 5, 1),
+			expected: []time.Time{date(2024, 5, 4), date(2024, 6, 1), date(2024, 7, 6)},
+		},
+		{
+			rule:     "FREQ=MONTHLY;BYDAY=-1SU;COUNT=2",
+			dtstart:  date(2024, 5, 1),
+			expected: []time.Time{date(2024, 5, 26), date(2024, 6, 30)},
+		},
+		{
+			rule:     "FREQ=MONTHLY;COUNT=3",
+			dtstart:  date(2024, 1, 31),
+			expected: []time.Time{date(2024, 1, 31), date(2024, 3, 31), date(2024, 5, 31)},
+		},
+		{
+			rule:     "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=2",
+			dtstart:  date(2024, 1, 10),
+			expected: []time.Time{date(2024, 1, 31), date(2024, 2, 29)},
+		},
+		{
+			rule:     "FREQ=YEARLY;BYDAY=20MO;COUNT=2",
+			dtstart:  date(2024, 1, 1),
+			expected: []time.Time{date(2024, 5, 13), date(2025, 5, 19)},
+		},
+		{
+			rule:     "FREQ=YEARLY;BYDAY=-1FR;COUNT=2",
+			dtstart:  date(2024, 6, 1),
+			expected: []time.Time{date(2024, 12, 27), date(2025, 12, 26)},
+		},
+		{
+			rule:     "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH;COUNT=2",
+			dtstart:  date(2024, 1, 1),
+			expected: []time.Time{date(2024, 11, 28), date(2025, 11, 27)},
+		},
+		{
+			rule:     "FREQ=YEARLY;BYMONTH=3,9;BYMONTHDAY=15;COUNT=3",
+			dtstart:  date(2024, 1, 1),
+			expected: []time.Time{date(2024, 3, 15), date(2024, 9, 15), date(2025, 3, 15)},
+		},
+	}
+
+	for _, test := range tests {
+		r, err := Parse(test.rule)
+		assert.NoError(t, err, test.rule)
+		result, err := r.Between(test.dtstart, test.dtstart, date(2030, 1, 1), 100)
+		assert.NoError(t, err, test.rule)
+		assert.Equal(t, test.expected, result, test.rule)
+	}
+}
+
+func TestBetweenWindow(t *testing.T) {
+	r, _ := Parse("FREQ=WEEKLY;COUNT=10")
+	dtstart := date(2024, 1, 1)
+
+	result, _ := r.Between(dtstart, date(2024, 1, 20), date(2024, 2, 6), 100)
+	assert.Equal(t, []time.Time{date(2024, 1, 22), date(2024, 1, 29), date(2024, 2, 5)}, result)
+
+	result, _ = r.Between(dtstart, dtstart, date(2030, 1, 1), 2)
+	assert.Len(t, result, 2)
+
+	// infinite rules skip the periods before the window
+	r, _ = Parse("FREQ=DAILY")
+	result, err := r.Between(dtstart, date(2050, 3, 1), date(2050, 3, 3), 100)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{date(2050, 3, 1), date(2050, 3, 2)}, result)
+}
+
+func TestCountBetween(t *testing.T) {
+	r, _ := Parse("FREQ=WEEKLY;COUNT=10")
+	dtstart := date(2024, 1, 1)
+
+	n, err := r.CountBetween(dtstart, date(2024, 1, 20), date(2024, 2, 6))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	n, err = r.CountBetween(dtstart, dtstart, date(2030, 1, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+
+	// the amount counted so far is returned with the error
+	r, _ = Parse("FREQ=DAILY")
+	n, err = r.CountBetween(dtstart, dtstart, date(2050, 1, 1))
+	assert.ErrorIs(t, err, ErrTooManyPeriods)
+	assert.Equal(t, maxPeriods, n)
+}
+
+func TestTooManyPeriods(t *testing.T) {
+	// february never has a 30th
+	r, _ := Parse("FREQ=MONTHLY;BYMONTH=2;BYMONTHDAY=30")
+	result, err := r.Between(date(2024, 1, 1), date(2024, 1, 1), date(3000, 1, 1), 1)
+	assert.ErrorIs(t, err, ErrTooManyPeriods)
+	assert.Empty(t, result)
+
+	r, _ = Parse("FREQ=DAILY;COUNT=6000")
+	_, ok, err := r.Last(date(2024, 1, 1))
+	assert.ErrorIs(t, err, ErrTooManyPeriods)
+	assert.False(t, ok)
+}
+
+func TestLast(t *testing.T) {
+	r, _ := Parse("FREQ=WEEKLY;COUNT=3")
+	last, ok, err := r.Last(date(2024, 1, 1))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, date(2024, 1, 15), last)
+
+	r, _ = Parse("FREQ=WEEKLY")
+	_, ok, err = r.Last(date(2024, 1, 1))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,216 @@
+// Minimal reading and writing of Office Open XML spreadsheets.
+//
+// Only the cell values of the first worksheet are read or written, styles, formulas
+// and dates are left as they are stored in the file.
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Size of a worksheet in Excel, larger row numbers or cell references are invalid
+const (
+	MaxRows    = 1048576
+	MaxColumns = 16384
+)
+
+var (
+	ErrNoWorksheet = errors.New("Spreadsheet contains no worksheet")
+	ErrTooLarge    = errors.New("Spreadsheet contains too many rows or columns")
+)
+
+type xmlWorkbook struct {
+	Sheets []struct {
+		RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xmlRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xmlRichText struct {
+	T string `xml:"t"`
+	R []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (rt xmlRichText) String() string {
+	if len(rt.R) == 0 {
+		return rt.T
+	}
+	sb := strings.Builder{}
+	for _, r := range rt.R {
+		sb.WriteString(r.T)
+	}
+	return sb.String()
+}
+
+type xmlSharedStrings struct {
+	SI []xmlRichText `xml:"si"`
+}
+
+type xmlWorksheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref       string      `xml:"r,attr"`
+			Type      string      `xml:"t,attr"`
+			Value     string      `xml:"v"`
+			InlineStr xmlRichText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// Returns the rows of the first worksheet, empty cells in between are kept as empty strings
+func Read(b []byte) ([][]string, error) {
+	return ReadLimit(b, MaxRows, MaxColumns)
+}
+
+// Same as Read, but returns ErrTooLarge for a row or column outside of the limits.
+// The row numbers and cell references of the file are checked before empty cells are added,
+// so a file can not make the reader allocate more than the limits.
+func ReadLimit(b []byte, maxRows, maxColumns int) ([][]string, error) {
+	maxRows = min(maxRows, MaxRows)
+	maxColumns = min(maxColumns, MaxColumns)
+
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedStrings := xmlSharedStrings{}
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		err = decodeZipFile(f, &sharedStrings)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f, ok := files[sheetPath]
+	if !ok {
+		return nil, ErrNoWorksheet
+	}
+	sheet := xmlWorksheet{}
+	err = decodeZipFile(f, &sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, xmlRow := range sheet.Rows {
+		if xmlRow.R > maxRows || len(rows) >= maxRows {
+			return nil, ErrTooLarge
+		}
+		// keep row numbers in line with the spreadsheet when empty rows are left out
+		for len(rows) < xmlRow.R-1 {
+			rows = append(rows, []string{})
+		}
+		row := []string{}
+		for i, cell := range xmlRow.Cells {
+			col := columnIndex(cell.Ref)
+			if col < 0 {
+				col = i
+			}
+			if col >= maxColumns {
+				return nil, ErrTooLarge
+			}
+			for len(row) <= col {
+				row = append(row, "")
+			}
+
+			value := cell.Value
+			switch cell.Type {
+			case "s":
+				index, err := strconv.Atoi(value)
+				if err != nil || index < 0 || index >= len(sharedStrings.SI) {
+					return nil, errors.New("Invalid shared string reference")
+				}
+				value = sharedStrings.SI[index].String()
+			case "inlineStr":
+				value = cell.InlineStr.String()
+			}
+			row[col] = value
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	const fallback = "xl/worksheets/sheet1.xml"
+
+	wbFile, ok := files["xl/workbook.xml"]
+	relsFile, ok2 := files["xl/_rels/workbook.xml.rels"]
+	if !ok || !ok2 {
+		return fallback, nil
+	}
+	wb := xmlWorkbook{}
+	if err := decodeZipFile(wbFile, &wb); err != nil {
+		return "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return "", ErrNoWorksheet
+	}
+	rels := xmlRelationships{}
+	if err := decodeZipFile(relsFile, &rels); err != nil {
+		return "", err
+	}
+	for _, rel := range rels.Relationships {
+		if rel.ID != wb.Sheets[0].RID {
+			continue
+		}
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return fallback, nil
+}
+
+func decodeZipFile(f *zip.File, v any) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return xml.NewDecoder(io.LimitReader(r, 50<<20)).Decode(v)
+}
+
+// Converts a cell reference like "C12" to a zero based column index,
+// a reference past the last column of Excel returns MaxColumns
+func columnIndex(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		if col > MaxColumns {
+			return MaxColumns
+		}
+	}
+	return col - 1
+}
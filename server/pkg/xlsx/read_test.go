@@ -0,0 +1,108 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockXlsx(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		w.Write([]byte(content))
+	}
+	assert.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	b := mockXlsx(t, map[string]string{
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Members" sheetId="1" r:id="rId3"/></sheets>
+</workbook>`,
+		"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/members.xml"/>
+</Relationships>`,
+		"xl/sharedStrings.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="3" uniqueCount="3">
+<si><t>name</t></si>
+<si><t>email</t></si>
+<si><r><t>Jane </t></r><r><t>Doe</t></r></si>
+</sst>`,
+		"xl/worksheets/members.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>
+<row r="3"><c r="A3" t="s"><v>2</v></c><c r="C3" t="inlineStr"><is><t>jane@example.com</t></is></c><c r="D3"><v>12</v></c></row>
+</sheetData></worksheet>`,
+	})
+
+	rows, err := Read(b)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"name", "email"},
+		{},
+		{"Jane Doe", "", "jane@example.com", "12"},
+	}, rows)
+}
+
+func TestReadHostileReferences(t *testing.T) {
+	sheet := func(rows string) []byte {
+		return mockXlsx(t, map[string]string{
+			"xl/worksheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + rows + `</sheetData></worksheet>`,
+		})
+	}
+
+	tests := []struct {
+		name string
+		rows string
+	}{
+		{"Row number", `<row r="1048576000"><c r="A1048576000"><v>1</v></c></row>`},
+		{"Column", `<row r="1"><c r="XFDZZZ1"><v>1</v></c></row>`},
+		{"Overflowing column", `<row r="1"><c r="ZZZZZZZZZZZZZZZZZZZZ1"><v>1</v></c></row>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Read(sheet(test.rows))
+			assert.ErrorIs(t, err, ErrTooLarge)
+		})
+	}
+
+	t.Run("Import limit", func(t *testing.T) {
+		b := sheet(`<row r="1"><c r="A1"><v>1</v></c></row><row r="20"><c r="A20"><v>1</v></c></row>`)
+		_, err := ReadLimit(b, 10, 10)
+		assert.ErrorIs(t, err, ErrTooLarge)
+
+		b = sheet(`<row r="1"><c r="K1"><v>1</v></c></row>`)
+		_, err = ReadLimit(b, 10, 10)
+		assert.ErrorIs(t, err, ErrTooLarge)
+
+		b = sheet(`<row r="1"><c r="J1"><v>1</v></c></row><row r="10"><c r="A10"><v>1</v></c></row>`)
+		rows, err := ReadLimit(b, 10, 10)
+		assert.NoError(t, err)
+		assert.Len(t, rows, 10)
+		assert.Len(t, rows[0], 10)
+	})
+}
+
+func TestReadInvalid(t *testing.T) {
+	_, err := Read([]byte("name,email\n"))
+	assert.Error(t, err)
+}
+
+func TestColumnIndex(t *testing.T) {
+	assert.Equal(t, 0, columnIndex("A1"))
+	assert.Equal(t, 25, columnIndex("Z9"))
+	assert.Equal(t, 26, columnIndex("AA3"))
+	assert.Equal(t, -1, columnIndex(""))
+	assert.Equal(t, MaxColumns-1, columnIndex("XFD1"))
+	assert.Equal(t, MaxColumns, columnIndex("XFDZZZ1"))
+}
@@ -0,0 +1,42 @@
+package xlsx
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteRead(t *testing.T) {
+	expected := [][]string{
+		{"name", "email", "note"},
+		{"Jane <Doe>", "", "a & b\nc"},
+		{"", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "", "AB"},
+	}
+
+	buf := &bytes.Buffer{}
+	err := Write(buf, "Members: Amsterdam/Oost", expected)
+	assert.NoError(t, err)
+
+	rows, err := Read(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"name", "email", "note"},
+		{"Jane <Doe>", "", "a & b\nc"},
+		expected[2],
+	}, rows)
+}
+
+func TestColumnName(t *testing.T) {
+	assert.Equal(t, "A", columnName(0))
+	assert.Equal(t, "Z", columnName(25))
+	assert.Equal(t, "AA", columnName(26))
+	assert.Equal(t, "AZ", columnName(51))
+	assert.Equal(t, "BA", columnName(52))
+}
+
+func TestSanitizeSheetName(t *testing.T) {
+	assert.Equal(t, "Members Amsterdam Oost", sanitizeSheetName("Members: Amsterdam/ Oost"))
+	assert.Equal(t, "Sheet1", sanitizeSheetName("[]"))
+	assert.Len(t, []rune(sanitizeSheetName("a very long loop name that goes on and on")), 31)
+}
@@ -0,0 +1,43 @@
+package sharedtypes
+
+import "time"
+
+// A message from a host to the members of their loop
+type ChainAnnouncement struct {
+	ID             uint      `json:"-"`
+	UID            string    `json:"uid" gorm:"uniqueIndex"`
+	ChainID        uint      `json:"-" gorm:"index"`
+	ChainUID       string    `json:"chain_uid" gorm:"-:migration;<-:false"`
+	AuthorUserID   uint      `json:"-"`
+	AuthorUserUID  string    `json:"author_user_uid" gorm:"-:migration;<-:false"`
+	AuthorName     string    `json:"author_name" gorm:"-:migration;<-:false"`
+	Subject        string    `json:"subject"`
+	Body           string    `json:"body" gorm:"type:text"`
+	FilterSizes    []string  `json:"filter_sizes" gorm:"serializer:json"`
+	WardensOnly    bool      `json:"wardens_only"`
+	RecipientCount int       `json:"recipient_count"`
+	EmailedCount   int       `json:"emailed_count"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type ChainAnnounceRequest struct {
+	ChainUID string `json:"chain_uid" binding:"required,uuid"`
+	Subject  string `json:"subject" binding:"required,max=150"`
+	Body     string `json:"body" binding:"required,max=5000"`
+	// Only members with at least one of these sizes, all members if empty
+	FilterSizes []string `json:"filter_sizes"`
+	WardensOnly bool     `json:"wardens_only"`
+}
+
+type ChainAnnounceRecipient struct {
+	UserUID string `json:"user_uid" gorm:"user_uid"`
+	Name    string `json:"name" gorm:"name"`
+}
+
+type ChainAnnounceResponse struct {
+	Announcement ChainAnnouncement `json:"announcement"`
+	// Members without a working email address, bounced or marked as spam
+	Undeliverable []ChainAnnounceRecipient `json:"undeliverable"`
+	// Members that turned off announcement emails
+	Unsubscribed []ChainAnnounceRecipient `json:"unsubscribed"`
+}
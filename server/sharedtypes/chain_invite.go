@@ -0,0 +1,51 @@
+package sharedtypes
+
+import "time"
+
+// Link created by a host that lets anyone holding the token join the loop,
+// even when the loop is not open to new members
+type ChainInvite struct {
+	ID               uint       `json:"-"`
+	Token            string     `json:"token" gorm:"uniqueIndex;type:varchar(64);not null"`
+	ChainID          uint       `json:"-" gorm:"index"`
+	ChainUID         string     `json:"chain_uid" gorm:"-:migration;<-:false"`
+	CreatedByUserID  uint       `json:"-"`
+	CreatedByUserUID string     `json:"created_by_user_uid" gorm:"-:migration;<-:false"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+	// 0 is unlimited
+	MaxUses  int `json:"max_uses"`
+	UseCount int `json:"use_count"`
+	// New members join approved instead of waiting for a host
+	AutoApprove bool       `json:"auto_approve"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+
+	Url  string           `json:"url" gorm:"-"`
+	Uses []ChainInviteUse `json:"uses" gorm:"-"`
+}
+
+type ChainInviteUse struct {
+	ID            uint      `json:"-"`
+	ChainInviteID uint      `json:"-" gorm:"index"`
+	UserID        uint      `json:"-"`
+	UserUID       string    `json:"user_uid" gorm:"-:migration;<-:false"`
+	UserName      string    `json:"user_name" gorm:"-:migration;<-:false"`
+	IsApproved    bool      `json:"is_approved"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type ChainInviteCreateRequest struct {
+	ChainUID    string     `json:"chain_uid" binding:"required,uuid"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	MaxUses     int        `json:"max_uses" binding:"min=0,max=10000"`
+	AutoApprove bool       `json:"auto_approve"`
+}
+
+type ChainInviteJoinRequest struct {
+	Token string `json:"token" binding:"required,max=64"`
+}
+
+type ChainInviteJoinResponse struct {
+	ChainUID   string `json:"chain_uid"`
+	IsApproved bool   `json:"is_approved"`
+}
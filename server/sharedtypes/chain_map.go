@@ -0,0 +1,33 @@
+package sharedtypes
+
+type ChainMapQuery struct {
+	MinLatitude *float64 `form:"min_latitude" binding:"required,latitude"`
+	MaxLatitude *float64 `form:"max_latitude" binding:"required,latitude"`
+	// Larger than MaxLongitude when the bounding box crosses the antimeridian
+	MinLongitude *float64 `form:"min_longitude" binding:"required,longitude"`
+	MaxLongitude *float64 `form:"max_longitude" binding:"required,longitude"`
+	Zoom         int      `form:"zoom" binding:"min=0,max=22"`
+}
+
+// A group of loops close to each other at the requested zoom level
+type ChainMapCluster struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Count     int     `json:"count"`
+	// Bounds of the loops in the cluster, to zoom in on when clicked
+	MinLatitude  float64 `json:"min_latitude"`
+	MaxLatitude  float64 `json:"max_latitude"`
+	MinLongitude float64 `json:"min_longitude"`
+	MaxLongitude float64 `json:"max_longitude"`
+
+	// Only set for a cluster of a single loop
+	ChainUID string   `json:"chain_uid,omitempty"`
+	Name     string   `json:"name,omitempty"`
+	Radius   float32  `json:"radius,omitempty"`
+	Genders  []string `json:"genders,omitempty"`
+}
+
+type ChainMapResponse struct {
+	Zoom     int               `json:"zoom"`
+	Clusters []ChainMapCluster `json:"clusters"`
+}
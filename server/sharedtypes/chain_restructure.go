@@ -0,0 +1,42 @@
+package sharedtypes
+
+import "time"
+
+const (
+	ChainRestructureActionMerge = "merge"
+	ChainRestructureActionSplit = "split"
+)
+
+// Audit trail of loops that were merged into another loop or split off into a new one
+type ChainRestructure struct {
+	ID           uint      `json:"id"`
+	Action       string    `json:"action"`
+	FromChainID  uint      `json:"-" gorm:"index"`
+	FromChainUID string    `json:"from_chain_uid" gorm:"-:migration;<-:false"`
+	FromName     string    `json:"from_name"`
+	ToChainID    uint      `json:"-" gorm:"index"`
+	ToChainUID   string    `json:"to_chain_uid" gorm:"-:migration;<-:false"`
+	ToName       string    `json:"to_name"`
+	ByUserID     uint      `json:"-"`
+	ByUserUID    string    `json:"by_user_uid" gorm:"-:migration;<-:false"`
+	MovedCount   int       `json:"moved_count"`
+	MergedCount  int       `json:"merged_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ChainMergeRequest struct {
+	FromChainUID string `json:"from_chain_uid" binding:"required,uuid"`
+	ToChainUID   string `json:"to_chain_uid" binding:"required,uuid,nefield=FromChainUID"`
+}
+
+type ChainSplitRequest struct {
+	ChainUID string   `json:"chain_uid" binding:"required,uuid"`
+	Name     string   `json:"name" binding:"required,min=3,max=150"`
+	UserUIDs []string `json:"user_uids" binding:"required,min=1,max=500,dive,uuid"`
+	// Members of UserUIDs that become the hosts of the new loop
+	HostUIDs []string `json:"host_uids" binding:"required,min=1,dive,uuid"`
+}
+
+type ChainSplitResponse struct {
+	ChainUID string `json:"chain_uid"`
+}
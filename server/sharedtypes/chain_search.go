@@ -0,0 +1,50 @@
+package sharedtypes
+
+const (
+	ChainFacetSize   = "size"
+	ChainFacetGender = "gender"
+)
+
+// A size or gender of a loop, kept next to the json columns of chains so that searching can use an index
+type ChainFacet struct {
+	ID      uint   `json:"-"`
+	ChainID uint   `json:"-" gorm:"index;index:idx_chain_facets_facet_value,priority:3"`
+	Facet   string `json:"facet" gorm:"type:varchar(16);not null;index:idx_chain_facets_facet_value,priority:1"`
+	Value   string `json:"value" gorm:"type:varchar(16);not null;index:idx_chain_facets_facet_value,priority:2"`
+}
+
+type ChainSearchQuery struct {
+	// Matched against the name and description, every word must be present
+	Q             string   `form:"q" binding:"omitempty,max=100"`
+	FilterSizes   []string `form:"filter_sizes"`
+	FilterGenders []string `form:"filter_genders"`
+	// Results are sorted by distance when a location is given
+	Latitude         *float64 `form:"latitude" binding:"omitempty,latitude"`
+	Longitude        *float64 `form:"longitude" binding:"required_with=Latitude,omitempty,longitude"`
+	Radius           float64  `form:"radius" binding:"omitempty,gt=0,lte=500"`
+	OpenToNewMembers bool     `form:"open_to_new_members"`
+	MinMembers       int      `form:"min_members" binding:"omitempty,min=0"`
+	MaxMembers       int      `form:"max_members" binding:"omitempty,min=0"`
+	PaginationQuery
+}
+
+type ChainSearchResult struct {
+	ChainResponse
+	// In kilometers, only set when searching from a location
+	Distance  *float64 `json:"distance,omitempty" gorm:"distance"`
+	Relevance float64  `json:"-" gorm:"relevance"`
+}
+
+type ChainSearchFacets struct {
+	Sizes   map[string]int `json:"sizes"`
+	Genders map[string]int `json:"genders"`
+}
+
+type ChainSearchResponse struct {
+	Items      []ChainSearchResult `json:"items"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+	// Number of loops matching the search over all pages
+	Total int `json:"total"`
+	// Number of matching loops per size and gender
+	Facets ChainSearchFacets `json:"facets"`
+}
@@ -1,6 +1,7 @@
 package sharedtypes
 
 type ChainResponse struct {
+	ID               uint     `json:"-" gorm:"chains.id"`
 	UID              string   `json:"uid" gorm:"chains.uid"`
 	Name             string   `json:"name" gorm:"chains.name"`
 	Description      string   `json:"description" gorm:"chains.description"`
@@ -38,6 +39,12 @@ type ChainCreateRequest struct {
 	AllowTOH         bool     `json:"allow_toh" binding:"required"`
 }
 
+type ChainCreateResponse struct {
+	ChainUID string `json:"chain_uid"`
+	// Number of people with a location alert near the new loop that were emailed
+	InterestedNearby int `json:"interested_nearby"`
+}
+
 type ChainUpdateRequest struct {
 	UID              string    `json:"uid" binding:"required"`
 	Name             *string   `json:"name,omitempty"`
@@ -81,3 +88,56 @@ type ChainApproveUserRequest struct {
 	UserUID  string `json:"user_uid" binding:"required,uuid"`
 	ChainUID string `json:"chain_uid" binding:"required,uuid"`
 }
+
+const (
+	ChainBatchUsersActionApprove = "approve"
+	ChainBatchUsersActionRemove  = "remove"
+	ChainBatchUsersActionMove    = "move"
+	ChainBatchUsersActionPromote = "promote"
+)
+
+type ChainBatchUsersRequest struct {
+	ChainUID   string   `json:"chain_uid" binding:"required,uuid"`
+	UserUIDs   []string `json:"user_uids" binding:"required,min=1,max=500,dive,uuid"`
+	Action     string   `json:"action" binding:"required,oneof=approve remove move promote"`
+	ToChainUID string   `json:"to_chain_uid" binding:"required_if=Action move,omitempty,uuid"`
+	Reason     string   `json:"reason" binding:"omitempty,oneof='other' 'too_far_away' 'sizes_genders' 'loop_not_active'"`
+}
+
+type ChainBatchUsersResult struct {
+	UserUID string `json:"user_uid"`
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+}
+
+const (
+	ChainImportUserStatusNew       = "new"
+	ChainImportUserStatusMember    = "member"
+	ChainImportUserStatusDuplicate = "duplicate"
+	ChainImportUserStatusInvalid   = "invalid"
+)
+
+type ChainImportUsersRequest struct {
+	ChainUID string `json:"chain_uid" binding:"required,uuid"`
+	Format   string `json:"format" binding:"required,oneof=csv xlsx"`
+	// Base64 encoded contents of the csv or xlsx file
+	File   []byte `json:"file" binding:"required,max=2097152"`
+	DryRun bool   `json:"dry_run"`
+}
+
+type ChainImportUserRow struct {
+	// Line number in the file, the header being line 1
+	Row         int      `json:"row"`
+	Name        string   `json:"name"`
+	Email       string   `json:"email"`
+	PhoneNumber string   `json:"phone_number"`
+	Address     string   `json:"address"`
+	Sizes       []string `json:"sizes"`
+	Status      string   `json:"status"`
+	Error       string   `json:"error,omitempty"`
+}
+
+type ChainImportUsersResponse struct {
+	DryRun bool                 `json:"dry_run"`
+	Rows   []ChainImportUserRow `json:"rows"`
+}
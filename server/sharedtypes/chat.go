@@ -43,11 +43,15 @@ type ChatChannelDeleteQuery struct {
 type ChatChannelMessageListQuery struct {
 	ChainUID      string `form:"chain_uid" binding:"required,uuid"`
 	ChatChannelID uint   `form:"chat_channel_id" binding:"required"`
-	StartFrom     int64  `form:"start_from"`
-	Page          int64  `form:"page"`
+	// Deprecated: use cursor and limit instead
+	StartFrom int64 `form:"start_from"`
+	// Deprecated: use cursor and limit instead
+	Page int64 `form:"page"`
+	PaginationQuery
 }
 type ChatChannelMessageListResponse struct {
-	Messages []ChatMessage `json:"messages"`
+	Messages   []ChatMessage `json:"messages"`
+	NextCursor string        `json:"next_cursor,omitempty"`
 }
 
 type ChatChannel struct {
@@ -61,6 +65,14 @@ type ChatChannel struct {
 	ChatMessages []ChatMessage `json:"-"`
 }
 
+// Newest message a user has loaded in a chat room, messages after it are unread
+type ChatChannelRead struct {
+	ID            uint  `json:"-"`
+	UserID        uint  `json:"-" gorm:"uniqueIndex:ucr_user_id_chat_channel_id;not null"`
+	ChatChannelID uint  `json:"-" gorm:"uniqueIndex:ucr_user_id_chat_channel_id;not null"`
+	LastReadAt    int64 `json:"-" gorm:"not null"`
+}
+
 type ChatMessageCreateRequest struct {
 	ChainUID      string `json:"chain_uid" binding:"required,uuid"`
 	ChatChannelID uint   `json:"chat_channel_id" binding:"required"`
@@ -81,3 +93,83 @@ type ChatMessage struct {
 	CreatedAt     int64      `json:"created_at"`
 	DeletedAt     *time.Time `json:"-"`
 }
+
+const (
+	ChatReportStatusOpen      = "open"
+	ChatReportStatusResolved  = "resolved"
+	ChatReportStatusDismissed = "dismissed"
+
+	ChatModerationActionDelete  = "delete"
+	ChatModerationActionWarn    = "warn"
+	ChatModerationActionMute    = "mute"
+	ChatModerationActionUnmute  = "unmute"
+	ChatModerationActionDismiss = "dismiss"
+)
+
+type ChatMessageReportRequest struct {
+	ChainUID      string `json:"chain_uid" binding:"required,uuid"`
+	ChatChannelID uint   `json:"chat_channel_id" binding:"required"`
+	ChatMessageID uint   `json:"chat_message_id" binding:"required"`
+	Reason        string `json:"reason" binding:"required,oneof=spam harassment inappropriate other"`
+	Comment       string `json:"comment" binding:"max=500"`
+}
+
+type ChatModerationReportListQuery struct {
+	ChainUID string `form:"chain_uid" binding:"omitempty,uuid"`
+	Status   string `form:"status" binding:"omitempty,oneof=open resolved dismissed"`
+}
+type ChatModerationReportListResponse struct {
+	Reports []ChatMessageReport `json:"reports"`
+}
+
+type ChatModerationActionRequest struct {
+	ChainUID  string `json:"chain_uid" binding:"required,uuid"`
+	ReportID  uint   `json:"report_id" binding:"required_without=UserUID"`
+	UserUID   string `json:"user_uid" binding:"omitempty,uuid"`
+	Action    string `json:"action" binding:"required,oneof=delete warn mute unmute dismiss"`
+	MuteHours int    `json:"mute_hours" binding:"omitempty,min=1,max=720"`
+	Note      string `json:"note" binding:"max=500"`
+}
+
+type ChatModerationActionListQuery struct {
+	ChainUID string `form:"chain_uid" binding:"omitempty,uuid"`
+}
+type ChatModerationActionListResponse struct {
+	Actions []ChatModerationAction `json:"actions"`
+}
+
+// A report is kept even after the reported message is removed,
+// the message text is copied over at the time of reporting.
+type ChatMessageReport struct {
+	ID               uint       `json:"id"`
+	ChainID          uint       `json:"-" gorm:"index"`
+	ChainUID         string     `json:"chain_uid" gorm:"-:migration;<-:false"`
+	ChatChannelID    uint       `json:"chat_channel_id"`
+	ChatMessageID    uint       `json:"chat_message_id"`
+	Message          string     `json:"message"`
+	SendByUID        string     `json:"sent_by"`
+	ReportedByUserID uint       `json:"-"`
+	ReportedByUID    string     `json:"reported_by" gorm:"-:migration;<-:false"`
+	Reason           string     `json:"reason"`
+	Comment          string     `json:"comment"`
+	Status           string     `json:"status" gorm:"index"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ResolvedAt       *time.Time `json:"resolved_at"`
+}
+
+// Audit trail of every moderation action taken by a host or root admin
+type ChatModerationAction struct {
+	ID                  uint       `json:"id"`
+	ChainID             uint       `json:"-" gorm:"index"`
+	ChainUID            string     `json:"chain_uid" gorm:"-:migration;<-:false"`
+	ChatMessageReportID *uint      `json:"report_id"`
+	ChatMessageID       *uint      `json:"chat_message_id"`
+	ModeratorUserID     uint       `json:"-"`
+	ModeratorUID        string     `json:"moderator_uid" gorm:"-:migration;<-:false"`
+	TargetUserID        uint       `json:"-"`
+	TargetUID           string     `json:"target_uid" gorm:"-:migration;<-:false"`
+	Action              string     `json:"action"`
+	Note                string     `json:"note"`
+	MutedUntil          *time.Time `json:"muted_until"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
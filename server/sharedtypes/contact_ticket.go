@@ -0,0 +1,58 @@
+package sharedtypes
+
+import "time"
+
+const (
+	ContactTicketStatusOpen     = "open"
+	ContactTicketStatusAnswered = "answered"
+	ContactTicketStatusClosed   = "closed"
+)
+
+// A message sent through the contact form, handled by the root admins
+type ContactTicket struct {
+	ID              uint    `json:"id"`
+	Name            string  `json:"name"`
+	Email           string  `json:"email" gorm:"index"`
+	Message         string  `json:"message" gorm:"type:text"`
+	I18n            string  `json:"i18n" gorm:"type:varchar(5)"`
+	Status          string  `json:"status" gorm:"type:varchar(20);not null;default:'open';index"`
+	AssigneeUserID  *uint   `json:"-"`
+	AssigneeUserUID *string `json:"assignee_user_uid" gorm:"-:migration;<-:false"`
+	AssigneeName    *string `json:"assignee_name" gorm:"-:migration;<-:false"`
+	Notes           string  `json:"notes" gorm:"type:text"`
+	// Message-ID of the confirmation email, replies refer to it so they are grouped in one conversation
+	ThreadMessageID string    `json:"-" gorm:"type:varchar(255)"`
+	IPAddress       string    `json:"-" gorm:"type:varchar(45)"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type ContactTicketReply struct {
+	ID              uint      `json:"id"`
+	ContactTicketID uint      `json:"contact_ticket_id" gorm:"index"`
+	AuthorUserID    uint      `json:"-"`
+	AuthorUserUID   string    `json:"author_user_uid" gorm:"-:migration;<-:false"`
+	AuthorName      string    `json:"author_name" gorm:"-:migration;<-:false"`
+	Message         string    `json:"message" gorm:"type:text"`
+	MailID          uint      `json:"mail_id"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type ContactTicketResponse struct {
+	Ticket  ContactTicket        `json:"ticket"`
+	Replies []ContactTicketReply `json:"replies"`
+}
+
+// Only the fields that are set are changed
+type ContactTicketUpdateRequest struct {
+	ID     uint    `json:"id" binding:"required"`
+	Status *string `json:"status" binding:"omitempty,oneof=open answered closed"`
+	// An empty string removes the assignee
+	AssigneeUserUID *string `json:"assignee_user_uid"`
+	Notes           *string `json:"notes" binding:"omitempty,max=10000"`
+}
+
+type ContactTicketReplyRequest struct {
+	ID      uint   `json:"id" binding:"required"`
+	Message string `json:"message" binding:"required,max=10000"`
+}
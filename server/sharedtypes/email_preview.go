@@ -0,0 +1,20 @@
+package sharedtypes
+
+type EmailPreviewListResponse struct {
+	Templates []string `json:"templates"`
+	Languages []string `json:"languages"`
+	// Missing templates, missing translations and render errors per language
+	Problems map[string][]string `json:"problems"`
+}
+
+type EmailPreviewResponse struct {
+	Template string `json:"template"`
+	Lang     string `json:"lang"`
+	Subject  string `json:"subject"`
+	Body     string `json:"body"`
+}
+
+type EmailPreviewSendRequest struct {
+	Template string `json:"template" binding:"required"`
+	Lang     string `json:"lang" binding:"required"`
+}
@@ -0,0 +1,55 @@
+package sharedtypes
+
+import "time"
+
+const (
+	EventRsvpStatusGoing    = "going"
+	EventRsvpStatusMaybe    = "maybe"
+	EventRsvpStatusNotGoing = "not_going"
+)
+
+// A response to a single occurrence of an event
+type EventRsvp struct {
+	ID      uint `json:"-"`
+	EventID uint `json:"-" gorm:"uniqueIndex:uci_event_id_occurrence_date_user_id;not null"`
+	// Original start of the occurrence of a recurring event, the start date of a single event
+	OccurrenceDate time.Time `json:"occurrence_date" gorm:"uniqueIndex:uci_event_id_occurrence_date_user_id"`
+	UserID         uint      `json:"-" gorm:"uniqueIndex:uci_event_id_occurrence_date_user_id;not null"`
+	Status         string    `json:"status" gorm:"type:varchar(20);not null"`
+	IsWaitlisted   bool      `json:"is_waitlisted" gorm:"not null;default:false"`
+	// Start of the occurrence the last reminder was sent for
+	RemindedForDate *time.Time `json:"-"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"-"`
+}
+
+// OccurrenceDate is required for recurring events and is the original start of the occurrence
+type EventRsvpPutRequest struct {
+	EventUID       string     `json:"event_uid" binding:"required,uuid"`
+	OccurrenceDate *time.Time `json:"occurrence_date,omitempty"`
+	Status         string     `json:"status" binding:"required,oneof=going maybe not_going"`
+}
+
+type EventRsvpQuery struct {
+	EventUID       string     `form:"event_uid" binding:"required,uuid"`
+	OccurrenceDate *time.Time `form:"occurrence_date"`
+}
+
+type EventRsvpResponse struct {
+	Rsvp          *EventRsvp `json:"rsvp"`
+	GoingCount    int        `json:"going_count"`
+	MaybeCount    int        `json:"maybe_count"`
+	WaitlistCount int        `json:"waitlist_count"`
+	Capacity      int        `json:"capacity"`
+}
+
+type EventAttendee struct {
+	UserID       uint      `json:"-"`
+	UserUID      string    `json:"user_uid"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email"`
+	PhoneNumber  string    `json:"phone_number"`
+	Status       string    `json:"status"`
+	IsWaitlisted bool      `json:"is_waitlisted"`
+	CreatedAt    time.Time `json:"created_at"`
+}
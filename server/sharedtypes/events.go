@@ -33,6 +33,31 @@ type Event struct {
 	ImageUrl       string          `json:"image_url"`
 	ImageDeleteUrl string          `json:"-"`
 	ChainName      *string         `json:"chain_name" gorm:"-:migration;<-:false"`
+	// IANA timezone the event takes place in, for example Europe/Amsterdam
+	Timezone string `json:"timezone" gorm:"type:varchar(64);not null;default:'UTC'"`
+	// RFC 5545 recurrence rule, expanded from the start date in the timezone of the event
+	RRule      string     `json:"rrule" gorm:"type:varchar(255);not null;default:''"`
+	RRuleUntil *time.Time `json:"-"`
+	// Incremented on each change, used by calendar apps to detect updates
+	Sequence int `json:"-" gorm:"not null;default:0"`
+	// Maximum number of attendees that are going, 0 means unlimited
+	Capacity       int  `json:"capacity" gorm:"not null;default:0"`
+	RsvpGoingCount *int `json:"rsvp_going_count,omitempty" gorm:"-:migration;<-:false"`
+	// Set when this is an expanded occurrence of a recurring event,
+	// identifies the occurrence before it was moved.
+	OccurrenceDate *time.Time `json:"occurrence_date,omitempty" gorm:"-"`
+}
+
+// Overrides a single occurrence of a recurring event
+type EventOccurrence struct {
+	ID           uint       `json:"-"`
+	EventID      uint       `json:"-" gorm:"uniqueIndex:uci_event_id_original_date;not null"`
+	OriginalDate time.Time  `json:"original_date" gorm:"uniqueIndex:uci_event_id_original_date;not null"`
+	IsCancelled  bool       `json:"is_cancelled" gorm:"not null;default:false"`
+	Date         *time.Time `json:"date"`
+	DateEnd      *time.Time `json:"date_end"`
+	CreatedAt    time.Time  `json:"-"`
+	UpdatedAt    time.Time  `json:"-"`
 }
 
 type EventCreateRequest struct {
@@ -51,6 +76,9 @@ type EventCreateRequest struct {
 	ChainUID       string         `json:"chain_uid,omitempty" binding:"omitempty"`
 	ImageUrl       string         `json:"image_url" binding:"required,url"`
 	ImageDeleteUrl string         `json:"image_delete_url" binding:"omitempty,url"`
+	RRule          string         `json:"rrule,omitempty" binding:"omitempty,max=255"`
+	Timezone       string         `json:"timezone,omitempty" binding:"omitempty,max=64,timezone"`
+	Capacity       int            `json:"capacity,omitempty" binding:"omitempty,min=0"`
 }
 
 type EventUpdateRequest struct {
@@ -70,4 +98,19 @@ type EventUpdateRequest struct {
 	ImageUrl       *string         `json:"image_url,omitempty"`
 	ImageDeleteUrl *string         `json:"image_delete_url,omitempty"`
 	ChainUID       *string         `json:"chain_uid,omitempty"`
+	RRule          *string         `json:"rrule,omitempty" binding:"omitempty,max=255"`
+	Timezone       *string         `json:"timezone,omitempty" binding:"omitempty,max=64,timezone"`
+	Capacity       *int            `json:"capacity,omitempty" binding:"omitempty,min=0"`
+}
+
+type EventOccurrenceUpdateRequest struct {
+	EventUID     string     `json:"event_uid" binding:"required,uuid"`
+	OriginalDate time.Time  `json:"original_date" binding:"required"`
+	Date         time.Time  `json:"date" binding:"required"`
+	DateEnd      *time.Time `json:"date_end,omitempty"`
+}
+
+type EventOccurrenceCancelQuery struct {
+	EventUID     string    `form:"event_uid" binding:"required,uuid"`
+	OriginalDate time.Time `form:"original_date" binding:"required"`
 }
@@ -0,0 +1,23 @@
+package sharedtypes
+
+import "time"
+
+// Secret token that gives access to the personal calendar feed of a user
+type UserCalendarToken struct {
+	ID        uint      `json:"-"`
+	UserID    uint      `json:"-" gorm:"uniqueIndex;not null"`
+	Token     string    `json:"token" gorm:"uniqueIndex;type:varchar(64);not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserCalendarTokenResponse struct {
+	Token     string    `json:"token"`
+	Url       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ICalFeedAreaQuery struct {
+	Latitude  float32 `form:"latitude" binding:"required,latitude"`
+	Longitude float32 `form:"longitude" binding:"required,longitude"`
+	Radius    float32 `form:"radius" binding:"required,gt=0,lte=500"`
+}
@@ -0,0 +1,11 @@
+package sharedtypes
+
+type LocationAlertSubscribeRequest struct {
+	Name      string   `json:"name" binding:"required"`
+	Email     string   `json:"email" binding:"required,email"`
+	Latitude  float64  `json:"latitude" binding:"latitude"`
+	Longitude float64  `json:"longitude" binding:"longitude"`
+	Radius    float32  `json:"radius" binding:"required,gte=1.0,lte=100.0"`
+	Sizes     []string `json:"sizes"`
+	Genders   []string `json:"genders"`
+}
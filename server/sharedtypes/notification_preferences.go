@@ -0,0 +1,72 @@
+package sharedtypes
+
+import "time"
+
+const (
+	NotificationChannelEmail = "email"
+	NotificationChannelPush  = "push"
+)
+
+// Notifications that members can turn off, required emails like login links are not listed here
+const (
+	NotificationTypeChatMessage   = "chat_message"
+	NotificationTypeBagAssigned   = "bag_assigned"
+	NotificationTypeBagTooOld     = "bag_too_old"
+	NotificationTypeBulkyItem     = "bulky_item"
+	NotificationTypeEventReminder = "event_reminder"
+	NotificationTypeEventChanged  = "event_changed"
+	NotificationTypeEventWaitlist = "event_waitlist"
+	NotificationTypePoke          = "poke"
+	NotificationTypeHostUpdates   = "host_updates"
+	NotificationTypeWeeklyDigest  = "weekly_digest"
+	NotificationTypeAnnouncement  = "announcement"
+)
+
+// The channels each notification type is sent through
+var NotificationTypeChannels = map[string][]string{
+	NotificationTypeChatMessage:   {NotificationChannelPush},
+	NotificationTypeBagAssigned:   {NotificationChannelPush},
+	NotificationTypeBagTooOld:     {NotificationChannelPush},
+	NotificationTypeBulkyItem:     {NotificationChannelPush},
+	NotificationTypeEventReminder: {NotificationChannelEmail, NotificationChannelPush},
+	NotificationTypeEventChanged:  {NotificationChannelEmail, NotificationChannelPush},
+	NotificationTypeEventWaitlist: {NotificationChannelPush},
+	NotificationTypePoke:          {NotificationChannelEmail},
+	NotificationTypeHostUpdates:   {NotificationChannelEmail},
+	NotificationTypeWeeklyDigest:  {NotificationChannelEmail},
+	NotificationTypeAnnouncement:  {NotificationChannelEmail, NotificationChannelPush},
+}
+
+// Notification types that are only sent after a user turns them on
+var NotificationTypesOptIn = []string{NotificationTypeWeeklyDigest}
+
+// Only stored once a user changes a preference,
+// without a row the notification is enabled unless it is an opt-in type
+type NotificationPreference struct {
+	ID        uint      `json:"-"`
+	UserID    uint      `json:"-" gorm:"uniqueIndex:unp_user_id_type_channel;not null"`
+	Type      string    `json:"type" gorm:"uniqueIndex:unp_user_id_type_channel;type:varchar(30);not null"`
+	Channel   string    `json:"channel" gorm:"uniqueIndex:unp_user_id_type_channel;type:varchar(10);not null"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+type NotificationPreferenceItem struct {
+	Type    string `json:"type" binding:"required"`
+	Channel string `json:"channel" binding:"required,oneof=email push"`
+	Enabled bool   `json:"enabled"`
+}
+
+type NotificationPreferencesResponse struct {
+	Preferences []NotificationPreferenceItem `json:"preferences"`
+}
+
+type NotificationPreferencesUpdateRequest struct {
+	Preferences []NotificationPreferenceItem `json:"preferences" binding:"required,min=1,dive"`
+}
+
+type NotificationUnsubscribeQuery struct {
+	Email     string `form:"e" binding:"required"`
+	Type      string `form:"t" binding:"required"`
+	Signature string `form:"s" binding:"required"`
+}
@@ -0,0 +1,11 @@
+package sharedtypes
+
+type PaginationQuery struct {
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=500"`
+	Cursor string `form:"cursor"`
+}
+
+type PaginatedResponse[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
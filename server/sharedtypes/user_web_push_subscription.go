@@ -0,0 +1,30 @@
+package sharedtypes
+
+import "time"
+
+// A browser subscription created with the PushManager api
+type UserWebPushSubscription struct {
+	ID        uint      `json:"-"`
+	UserID    uint      `json:"-" gorm:"index;not null"`
+	Endpoint  string    `json:"endpoint" gorm:"uniqueIndex;type:varchar(512);not null"`
+	P256dh    string    `json:"p256dh" gorm:"type:varchar(100);not null"`
+	Auth      string    `json:"auth" gorm:"type:varchar(50);not null"`
+	CreatedAt time.Time `json:"-"`
+}
+
+// Matches PushSubscription.toJSON() in the browser
+type UserWebPushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint" binding:"required,url,max=512"`
+	Keys     struct {
+		P256dh string `json:"p256dh" binding:"required,max=100"`
+		Auth   string `json:"auth" binding:"required,max=50"`
+	} `json:"keys" binding:"required"`
+}
+
+type UserWebPushSubscriptionDeleteRequest struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+}
+
+type WebPushPublicKeyResponse struct {
+	PublicKey string `json:"public_key"`
+}
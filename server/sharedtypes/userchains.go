@@ -15,7 +15,19 @@ type UserChain struct {
 	LastNotifiedIsUnapprovedAt *time.Time  `json:"-"`
 	RouteOrder                 int         `json:"-"`
 	IsPaused                   bool        `json:"is_paused"`
+	PausedFrom                 *time.Time  `json:"paused_from"`
+	PausedUntil                *time.Time  `json:"paused_until"`
+	ChatMutedUntil             *time.Time  `json:"chat_muted_until,omitempty"`
 	Note                       *string     `json:"-" gorm:"->:false;<-:create"`
 	Bags                       []Bag       `json:"-"`
 	Bulky                      []BulkyItem `json:"-"`
 }
+
+// A period where a member does not receive bags, either everywhere or only in this loop
+type ChainAbsence struct {
+	UserUID     string     `json:"user_uid" gorm:"user_uid"`
+	Name        string     `json:"name" gorm:"name"`
+	PausedFrom  *time.Time `json:"paused_from" gorm:"paused_from"`
+	PausedUntil *time.Time `json:"paused_until" gorm:"paused_until"`
+	IsChainOnly bool       `json:"is_chain_only" gorm:"is_chain_only"`
+}
@@ -12,6 +12,7 @@ type User struct {
 	IsEmailVerified       bool            `json:"is_email_verified"`
 	IsRootAdmin           bool            `json:"is_root_admin"`
 	PausedUntil           *time.Time      `json:"paused_until"`
+	PausedFrom            *time.Time      `json:"paused_from"`
 	Name                  string          `json:"name"`
 	PhoneNumber           string          `json:"phone_number"`
 	Address               string          `json:"address"`
@@ -36,8 +37,31 @@ type User struct {
 	ChatUserID            *string         `json:"chat_id"`
 	ChatPass              *string         `json:"-"`
 	ChatUserName          *string         `json:"chat_user_name"`
+	// Set when Brevo reports a hard bounce, spam complaint or unsubscribe, cleared on the next login
+	EmailUndeliverableAt     *time.Time `json:"email_undeliverable_at,omitempty"`
+	EmailUndeliverableReason string     `json:"email_undeliverable_reason,omitempty" gorm:"type:varchar(20);not null;default:''"`
+	// Who may see each contact field, combined with the route privacy of the loop, see UserFieldPrivacy...
+	PrivacyEmail   string `json:"privacy_email" gorm:"type:varchar(20);not null;default:''"`
+	PrivacyPhone   string `json:"privacy_phone" gorm:"type:varchar(20);not null;default:''"`
+	PrivacyAddress string `json:"privacy_address" gorm:"type:varchar(20);not null;default:''"`
 }
 
+// Who may see a contact field of a member, from most to least private.
+// An empty value follows the route privacy of the loop.
+const (
+	UserFieldPrivacyHosts      = "hosts"
+	UserFieldPrivacyNeighbours = "neighbours"
+	UserFieldPrivacyLoop       = "loop"
+)
+
+var UserFieldPrivacies = []string{"", UserFieldPrivacyHosts, UserFieldPrivacyNeighbours, UserFieldPrivacyLoop}
+
+const (
+	EmailUndeliverableReasonBounce       = "hard_bounce"
+	EmailUndeliverableReasonComplaint    = "spam_complaint"
+	EmailUndeliverableReasonUnsubscribed = "unsubscribed"
+)
+
 type UserCreateRequest struct {
 	Email       string   `json:"email" binding:"required,email"`
 	Name        string   `json:"name" binding:"required,min=3"`
@@ -63,6 +87,15 @@ type UserUpdateRequest struct {
 	Latitude      *float64   `json:"latitude,omitempty"`
 	Longitude     *float64   `json:"longitude,omitempty"`
 	AcceptedLegal *bool      `json:"accepted_legal,omitempty"`
+	// See UserFieldPrivacies
+	PrivacyEmail   *string `json:"privacy_email,omitempty"`
+	PrivacyPhone   *string `json:"privacy_phone,omitempty"`
+	PrivacyAddress *string `json:"privacy_address,omitempty"`
+	// Schedules the pause to start later, starts right away if empty
+	PausedFrom *time.Time `json:"paused_from,omitempty"`
+	// Only used when ChainPaused is true, without an end date the loop is paused until further notice
+	ChainPausedFrom  *time.Time `json:"chain_paused_from,omitempty"`
+	ChainPausedUntil *time.Time `json:"chain_paused_until,omitempty"`
 }
 
 type UserTransferChainRequest struct {